@@ -0,0 +1,122 @@
+// Command astro-cshared builds the compiler as a C shared library, so native
+// tooling can call it in-process:
+//
+//	go build -buildmode=c-shared -o libastro.so ./cmd/astro-cshared
+//
+// Every function takes and returns NUL-terminated UTF-8 JSON, matching the
+// methods of cmd/astro-service. Results are either {"result": ...} or
+// {"error": {code, message, data}}, and must be released with astro_free.
+package main
+
+/*
+#include <stdlib.h>
+#include <string.h>
+*/
+import "C"
+
+import (
+	"context"
+	"unsafe"
+
+	"github.com/snowpackjs/astro/internal/service"
+)
+
+func call(method string, params *C.char) *C.char {
+	var b []byte
+	if params != nil {
+		b = C.GoBytes(unsafe.Pointer(params), C.int(C.strlen(params)))
+	}
+	return C.CString(string(service.Call(context.Background(), method, b)))
+}
+
+// astro_compile compiles {source, options} to {code, map, diagnostics, version}
+//
+//export astro_compile
+func astro_compile(params *C.char) *C.char {
+	return call("compile", params)
+}
+
+// astro_compile_project compiles {files, options, analyze} to
+// {files, manifest, analysis, version}
+//
+//export astro_compile_project
+func astro_compile_project(params *C.char) *C.char {
+	return call("compileProject", params)
+}
+
+// astro_parse parses {source, options} to {ast, references, definitions,
+// diagnostics}
+//
+//export astro_parse
+func astro_parse(params *C.char) *C.char {
+	return call("parse", params)
+}
+
+// astro_open_document parses {source, options} to {document, ast, references,
+// definitions, diagnostics}, and keeps the document until astro_close_document
+//
+//export astro_open_document
+func astro_open_document(params *C.char) *C.char {
+	return call("openDocument", params)
+}
+
+// astro_edit_document applies {document, edits} to an open document, parsing
+// again only what the edits change where it can
+//
+//export astro_edit_document
+func astro_edit_document(params *C.char) *C.char {
+	return call("editDocument", params)
+}
+
+// astro_close_document releases {document}
+//
+//export astro_close_document
+func astro_close_document(params *C.char) *C.char {
+	return call("closeDocument", params)
+}
+
+// astro_outline parses {source, options} to {symbols, diagnostics}
+//
+//export astro_outline
+func astro_outline(params *C.char) *C.char {
+	return call("outline", params)
+}
+
+// astro_semantic_tokens tokenizes {source} to {legend, data}
+//
+//export astro_semantic_tokens
+func astro_semantic_tokens(params *C.char) *C.char {
+	return call("semanticTokens", params)
+}
+
+// astro_completion tokenizes {source, offset} to {kind, prefix, start, tag,
+// attribute, attributes, parents, scope}
+//
+//export astro_completion
+func astro_completion(params *C.char) *C.char {
+	return call("completion", params)
+}
+
+// astro_diff compiles {source, options, summary, before} to {summary,
+// changes, diagnostics}
+//
+//export astro_diff
+func astro_diff(params *C.char) *C.char {
+	return call("diff", params)
+}
+
+// astro_version returns {version}
+//
+//export astro_version
+func astro_version() *C.char {
+	return call("version", nil)
+}
+
+// astro_free releases a string returned by the library
+//
+//export astro_free
+func astro_free(str *C.char) {
+	C.free(unsafe.Pointer(str))
+}
+
+func main() {}
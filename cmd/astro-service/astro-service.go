@@ -0,0 +1,78 @@
+// Command astro-service keeps the compiler running as a long-lived process,
+// answering newline-delimited JSON-RPC 2.0 requests on stdin with responses on
+// stdout. Integrations outside of Node can use it instead of the WASM build.
+//
+// Methods:
+//
+//	compile        {source, options}
+//	               -> {code, map, diagnostics, version}
+//	compileProject {files, options, analyze}
+//	               -> {files, manifest, analysis, version}
+//	parse          {source, options}
+//	               -> {ast, references, definitions, diagnostics}
+//	openDocument   {source, options}
+//	               -> {document, ast, references, definitions, diagnostics}
+//	editDocument   {document, edits}
+//	               -> {document, ast, references, definitions, diagnostics}
+//	closeDocument  {document} -> {}
+//	outline        {source, options} -> {symbols, diagnostics}
+//	semanticTokens {source} -> {legend, data}
+//	completion     {source, offset}
+//	               -> {kind, prefix, start, tag, attribute, attributes,
+//	                   parents, scope}
+//	diff           {source, options, summary, before}
+//	               -> {summary, changes, diagnostics}
+//	version        -> {version}
+//	shutdown       -> {}
+//
+// There is no format method, since the compiler has no formatter to offer.
+//
+// Options use the same names as the JavaScript API. Running requests can be
+// stopped with a "$/cancelRequest" notification carrying their id.
+//
+// A document opened with openDocument is kept until closeDocument, and
+// editDocument applies edits of {start, end, text} to it, parsing again only
+// the elements they're inside of where it can.
+//
+// With -http, it instead serves POST /compile, POST /compileProject,
+// POST /parse, POST /outline, POST /semanticTokens, POST /completion,
+// POST /diff and GET /version on the given address, so a build farm can share
+// one compiler and the results it keeps.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/snowpackjs/astro/internal/service"
+)
+
+func main() {
+	addr := flag.String("http", "", "serve HTTP on this address instead of JSON-RPC on stdio")
+	concurrency := flag.Int("concurrency", 0, "maximum concurrent HTTP compilations, defaults to the number of CPUs")
+	flag.Parse()
+
+	if *addr != "" {
+		server := &http.Server{
+			Addr:    *addr,
+			Handler: service.NewHTTPHandler(*concurrency),
+			// Slow clients can't hold on to connections, but a large
+			// request has time to arrive
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       time.Minute,
+		}
+		if err := server.ListenAndServe(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if err := service.Serve(context.Background(), os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
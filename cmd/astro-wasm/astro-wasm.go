@@ -4,15 +4,21 @@
 package main
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall/js"
+	"time"
 
 	"github.com/norunners/vert"
 	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/compiler"
+	"github.com/snowpackjs/astro/internal/handler"
+	"github.com/snowpackjs/astro/internal/loc"
 	"github.com/snowpackjs/astro/internal/printer"
 	"github.com/snowpackjs/astro/internal/transform"
 	wasm_utils "github.com/snowpackjs/astro/internal_wasm/utils"
@@ -23,6 +29,10 @@ var done chan bool
 
 func main() {
 	js.Global().Set("__astro_transform", Transform())
+	js.Global().Set("__astro_outline", Outline())
+	js.Global().Set("__astro_semanticTokens", SemanticTokens())
+	js.Global().Set("__astro_completion", Completion())
+	js.Global().Set("__astro_dumpAST", DumpAST())
 	// This ensures that the WASM doesn't exit early
 	<-make(chan bool)
 }
@@ -34,57 +44,496 @@ func jsString(j js.Value) string {
 	return j.String()
 }
 
-func makeTransformOptions(options js.Value, hash string) transform.TransformOptions {
-	filename := jsString(options.Get("sourcefile"))
-	if filename == "" {
-		filename = "<stdin>"
+func jsBool(j js.Value) bool {
+	if j.IsUndefined() || j.IsNull() {
+		return false
 	}
+	return j.Bool()
+}
 
-	as := jsString(options.Get("as"))
-	if as == "" {
-		as = "document"
+func jsInt(j js.Value) int {
+	if j.Type() != js.TypeNumber {
+		return 0
 	}
+	return j.Int()
+}
 
-	internalURL := jsString(options.Get("internalURL"))
-	if internalURL == "" {
-		internalURL = "astro/internal"
+func jsStringMap(j js.Value) map[string]string {
+	if j.IsUndefined() || j.IsNull() {
+		return nil
+	}
+	m := make(map[string]string)
+	keys := js.Global().Get("Object").Call("keys", j)
+	for i := 0; i < keys.Length(); i++ {
+		key := keys.Index(i).String()
+		m[key] = jsString(j.Get(key))
 	}
+	return m
+}
 
+func makeTransformOptions(options js.Value, hash string) transform.TransformOptions {
 	sourcemap := jsString(options.Get("sourcemap"))
-	if sourcemap == "<boolean: true>" {
+	switch sourcemap {
+	case "<boolean: true>":
 		sourcemap = "both"
-	}
-
-	site := jsString(options.Get("site"))
-	if site == "" {
-		site = "https://astro.build"
+	case "<boolean: false>":
+		sourcemap = ""
 	}
 
 	preprocessStyle := options.Get("preprocessStyle")
 
-	return transform.TransformOptions{
-		As:              as,
-		Scope:           hash,
-		Filename:        filename,
-		InternalURL:     internalURL,
-		SourceMap:       sourcemap,
-		Site:            site,
-		PreprocessStyle: preprocessStyle,
+	var rewriteAsset func(string) string
+	if fn := options.Get("rewriteAsset"); fn.Type() == js.TypeFunction {
+		rewriteAsset = func(url string) string {
+			next := fn.Invoke(url)
+			if next.Type() != js.TypeString {
+				return url
+			}
+			return next.String()
+		}
+	}
+
+	opts := transform.TransformOptions{
+		As:                    jsString(options.Get("as")),
+		Scope:                 hash,
+		Filename:              jsString(options.Get("sourcefile")),
+		InternalURL:           jsString(options.Get("internalURL")),
+		SourceMap:             sourcemap,
+		Site:                  jsString(options.Get("site")),
+		PreprocessStyle:       preprocessStyle,
+		DedentRaw:             jsBool(options.Get("dedentRaw")),
+		Entities:              jsString(options.Get("entities")),
+		CustomElements:        jsStringMap(options.Get("customElements")),
+		RewriteAsset:          rewriteAsset,
+		ImageHints:            jsBool(options.Get("imageHints")),
+		A11y:                  jsBool(options.Get("a11y")),
+		Strict:                jsBool(options.Get("strict")),
+		PropsSerialization:    jsString(options.Get("propsSerialization")),
+		ExtractMessages:       jsBool(options.Get("extractMessages")),
+		Translate:             jsString(options.Get("translate")),
+		Define:                jsStringMap(options.Get("define")),
+		PreserveJSXComments:   jsBool(options.Get("preserveJSXComments")),
+		ContentType:           jsString(options.Get("contentType")),
+		PreserveAttributeCase: jsBool(options.Get("preserveAttributeCase")),
+		RenderTelemetry:       jsBool(options.Get("renderTelemetry")),
+		IslandMarkers:         jsBool(options.Get("islandMarkers")),
+		StampVersion:          jsBool(options.Get("stampVersion")),
+		IsPage:                jsBool(options.Get("isPage")),
+		Route:                 jsString(options.Get("route")),
+		DataFrontmatter:       jsBool(options.Get("dataFrontmatter")),
+		FullOutput:            jsBool(options.Get("fullOutput")),
+		CompactOutput:         jsBool(options.Get("compactOutput")),
+		ModuleFormat:          jsString(options.Get("moduleFormat")),
+		MetadataFormat:        jsString(options.Get("metadataFormat")),
+		GlobalName:            jsString(options.Get("globalName")),
+		RuntimeGlobal:         jsString(options.Get("runtimeGlobal")),
+		Banner:                jsString(options.Get("banner")),
+		Footer:                jsString(options.Get("footer")),
+		ScopeRootElements:     jsBool(options.Get("scopeRootElements")),
+		ScopedStyleStrategy:   jsString(options.Get("scopedStyleStrategy")),
+		UnusedSelectors:       jsString(options.Get("unusedSelectors")),
+		TrimWhitespace:        jsString(options.Get("trimWhitespace")),
+		CSPNonceVar:           jsString(options.Get("cspNonceVar")),
+		IslandBudget:          jsInt(options.Get("islandBudget")),
+		InlineScriptBudget:    jsInt(options.Get("inlineScriptBudget")),
+		MaxInputSize:          jsInt(options.Get("maxInputSize")),
+		MaxNodes:              jsInt(options.Get("maxNodes")),
+		MaxExpressionDepth:    jsInt(options.Get("maxExpressionDepth")),
+		Timeout:               time.Duration(jsInt(options.Get("timeout"))) * time.Millisecond,
+	}
+	if jsBool(options.Get("trace")) {
+		opts.Trace = transform.NewTrace(nil)
 	}
+	opts.Normalize()
+	return opts
 }
 
 type RawSourceMap struct {
-	File           string   `js:"file"`
-	Mappings       string   `js:"mappings"`
-	Names          []string `js:"names"`
-	Sources        []string `js:"sources"`
-	SourcesContent []string `js:"sourcesContent"`
-	Version        int      `js:"version"`
+	File           string   `js:"file" json:"file"`
+	Mappings       string   `js:"mappings" json:"mappings"`
+	Names          []string `js:"names" json:"names"`
+	Sources        []string `js:"sources" json:"sources"`
+	SourcesContent []string `js:"sourcesContent" json:"sourcesContent"`
+	Version        int      `js:"version" json:"version"`
+}
+
+type Asset struct {
+	URL       string `js:"url" json:"url"`
+	Element   string `js:"element" json:"element"`
+	Attribute string `js:"attribute" json:"attribute"`
+	Start     int    `js:"start" json:"start"`
+}
+
+type StyleImport struct {
+	Specifier string `js:"specifier" json:"specifier"`
+	Start     int    `js:"start" json:"start"`
+}
+
+type DataFrontmatter struct {
+	Lang    string `js:"lang" json:"lang"`
+	Content string `js:"content" json:"content"`
+	Start   int    `js:"start" json:"start"`
+}
+
+type Glob struct {
+	Pattern string `js:"pattern" json:"pattern"`
+	Start   int    `js:"start" json:"start"`
+}
+
+type Island struct {
+	Name      string `js:"name" json:"name"`
+	Directive string `js:"directive" json:"directive"`
+	Start     int    `js:"start" json:"start"`
+}
+
+type Route struct {
+	Pattern        string   `js:"pattern" json:"pattern"`
+	Params         []string `js:"params" json:"params"`
+	ReadParams     []string `js:"readParams" json:"readParams"`
+	GetStaticPaths bool     `js:"getStaticPaths" json:"getStaticPaths"`
+	Prerender      *bool    `js:"prerender" json:"prerender"`
+}
+
+type Message struct {
+	ID      string `js:"id" json:"id"`
+	Element string `js:"element" json:"element"`
+	Start   int    `js:"start" json:"start"`
+}
+
+type UndefinedComponent struct {
+	Name  string `js:"name" json:"name"`
+	Start int    `js:"start" json:"start"`
+}
+
+type Reference struct {
+	Name  string `js:"name" json:"name"`
+	Kind  string `js:"kind" json:"kind"`
+	Start int    `js:"start" json:"start"`
+}
+
+type NodeMetadata struct {
+	Directives []string `js:"directives" json:"directives"`
+	Hydration  string   `js:"hydration" json:"hydration"`
+	Scope      string   `js:"scope" json:"scope"`
+	Slot       string   `js:"slot" json:"slot"`
+}
+
+type OutlineResult struct {
+	Symbols     []Symbol                `js:"symbols" json:"symbols"`
+	Diagnostics []loc.DiagnosticMessage `js:"diagnostics" json:"diagnostics"`
+}
+
+type Symbol struct {
+	Name     string   `js:"name" json:"name"`
+	Kind     string   `js:"kind" json:"kind"`
+	Start    int      `js:"start" json:"start"`
+	End      int      `js:"end" json:"end"`
+	Children []Symbol `js:"children" json:"children"`
+}
+
+type Definition struct {
+	Name        string `js:"name" json:"name"`
+	Start       int    `js:"start" json:"start"`
+	ImportStart int    `js:"importStart" json:"importStart"`
+	ImportEnd   int    `js:"importEnd" json:"importEnd"`
+	Specifier   string `js:"specifier" json:"specifier"`
+	ExportName  string `js:"exportName" json:"exportName"`
+}
+
+type Tag struct {
+	Index    int    `js:"index" json:"index"`
+	Start    int    `js:"start" json:"start"`
+	Hash     string `js:"hash" json:"hash"`
+	Critical bool   `js:"critical" json:"critical"`
+}
+
+type Hashes struct {
+	Frontmatter string `js:"frontmatter" json:"frontmatter"`
+	Template    string `js:"template" json:"template"`
+}
+
+type Preload struct {
+	URL   string `js:"url" json:"url"`
+	Kind  string `js:"kind" json:"kind"`
+	Rel   string `js:"rel" json:"rel"`
+	As    string `js:"as" json:"as"`
+	Start int    `js:"start" json:"start"`
+}
+
+type InlineSource struct {
+	Kind      string `js:"kind" json:"kind"`
+	Start     int    `js:"start" json:"start"`
+	Attribute string `js:"attribute" json:"attribute"`
+	Hash      string `js:"hash" json:"hash"`
 }
 
 type TransformResult struct {
-	Code string `js:"code"`
-	Map  string `js:"map"`
+	Code                string                  `js:"code" json:"code"`
+	Map                 string                  `js:"map" json:"map"`
+	Assets              []Asset                 `js:"assets" json:"assets"`
+	StyleImports        []StyleImport           `js:"styleImports" json:"styleImports"`
+	Globs               []Glob                  `js:"globs" json:"globs"`
+	Islands             []Island                `js:"islands" json:"islands"`
+	Messages            []Message               `js:"messages" json:"messages"`
+	UndefinedComponents []UndefinedComponent    `js:"undefinedComponents" json:"undefinedComponents"`
+	References          []Reference             `js:"references" json:"references"`
+	Definitions         []Definition            `js:"definitions" json:"definitions"`
+	Metadata            map[string]NodeMetadata `js:"metadata" json:"metadata"`
+	Diagnostics         []loc.DiagnosticMessage `js:"diagnostics" json:"diagnostics"`
+	Stats               Stats                   `js:"stats" json:"stats"`
+	Styles              []Tag                   `js:"styles" json:"styles"`
+	Scripts             []Tag                   `js:"scripts" json:"scripts"`
+	Hashes              Hashes                  `js:"hashes" json:"hashes"`
+	InlineSources       []InlineSource          `js:"inlineSources" json:"inlineSources"`
+	Preloads            []Preload               `js:"preloads" json:"preloads"`
+	Route               *Route                  `js:"route" json:"route"`
+	Prerender           *bool                   `js:"prerender" json:"prerender"`
+	DataFrontmatter     *DataFrontmatter        `js:"dataFrontmatter" json:"dataFrontmatter"`
+	Trace               []TraceEvent            `js:"trace" json:"trace"`
+	Version             string                  `js:"version" json:"version"`
+}
+
+type Stats struct {
+	Elements    int       `js:"elements" json:"elements"`
+	Components  int       `js:"components" json:"components"`
+	Expressions int       `js:"expressions" json:"expressions"`
+	Islands     int       `js:"islands" json:"islands"`
+	Styles      int       `js:"styles" json:"styles"`
+	Scripts     int       `js:"scripts" json:"scripts"`
+	OutputSize  int       `js:"outputSize" json:"outputSize"`
+	Durations   Durations `js:"durations" json:"durations"`
+}
+
+// Durations are in milliseconds
+type Durations struct {
+	Parse     float64 `js:"parse" json:"parse"`
+	Transform float64 `js:"transform" json:"transform"`
+	Print     float64 `js:"print" json:"print"`
+}
+
+func makeStats(stats printer.Stats) Stats {
+	milliseconds := func(d time.Duration) float64 {
+		return float64(d) / float64(time.Millisecond)
+	}
+	return Stats{
+		Elements:    stats.Elements,
+		Components:  stats.Components,
+		Expressions: stats.Expressions,
+		Islands:     stats.Islands,
+		Styles:      stats.Styles,
+		Scripts:     stats.Scripts,
+		OutputSize:  stats.OutputSize,
+		Durations: Durations{
+			Parse:     milliseconds(stats.Parse),
+			Transform: milliseconds(stats.Transform),
+			Print:     milliseconds(stats.Print),
+		},
+	}
+}
+
+// TraceEvent is a transform.TraceEvent, with its duration in milliseconds
+type TraceEvent struct {
+	Phase    string  `js:"phase" json:"phase"`
+	Pass     string  `js:"pass" json:"pass"`
+	Decision string  `js:"decision" json:"decision"`
+	Duration float64 `js:"duration" json:"duration"`
+	Nodes    int     `js:"nodes" json:"nodes"`
+}
+
+// makeTrace returns the events of trace, which is nil unless the trace option
+// is set
+func makeTrace(trace *transform.Trace) []TraceEvent {
+	events := make([]TraceEvent, 0)
+	if trace == nil {
+		return events
+	}
+	for _, e := range trace.Events {
+		events = append(events, TraceEvent{
+			Phase:    e.Phase,
+			Pass:     e.Pass,
+			Decision: e.Decision,
+			Duration: float64(e.Duration) / float64(time.Millisecond),
+			Nodes:    e.Nodes,
+		})
+	}
+	return events
+}
+
+func makeTags(tags []printer.Tag) []Tag {
+	result := make([]Tag, 0, len(tags))
+	for _, tag := range tags {
+		result = append(result, Tag{
+			Index:    tag.Index,
+			Start:    tag.Loc.Start,
+			Hash:     tag.Hash,
+			Critical: tag.Critical,
+		})
+	}
+	return result
+}
+
+func makePreloads(preloads []transform.Preload) []Preload {
+	result := make([]Preload, 0, len(preloads))
+	for _, p := range preloads {
+		result = append(result, Preload{
+			URL:   p.URL,
+			Kind:  p.Kind,
+			Rel:   p.Rel,
+			As:    p.As,
+			Start: p.Loc.Start,
+		})
+	}
+	return result
+}
+
+func makeInlineSources(sources []printer.InlineSource) []InlineSource {
+	result := make([]InlineSource, 0, len(sources))
+	for _, source := range sources {
+		result = append(result, InlineSource{
+			Kind:      source.Kind,
+			Start:     source.Loc.Start,
+			Attribute: source.Attribute,
+			Hash:      source.Hash,
+		})
+	}
+	return result
+}
+
+func makeAssets(doc *astro.Node) []Asset {
+	assets := make([]Asset, 0)
+	for _, asset := range transform.CollectAssets(doc) {
+		assets = append(assets, Asset{
+			URL:       asset.URL,
+			Element:   asset.Element,
+			Attribute: asset.Attribute,
+			Start:     asset.Loc.Start,
+		})
+	}
+	return assets
+}
+
+func makeStyleImports(doc *astro.Node) []StyleImport {
+	imports := make([]StyleImport, 0)
+	for _, i := range transform.CollectStyleImports(doc) {
+		imports = append(imports, StyleImport{
+			Specifier: i.Specifier,
+			Start:     i.Loc.Start,
+		})
+	}
+	return imports
+}
+
+func makeDataFrontmatter(data *transform.DataFrontmatter) *DataFrontmatter {
+	if data == nil {
+		return nil
+	}
+	return &DataFrontmatter{
+		Lang:    data.Lang,
+		Content: data.Content,
+		Start:   data.Loc.Start,
+	}
+}
+
+func makeGlobs(doc *astro.Node) []Glob {
+	globs := make([]Glob, 0)
+	for _, g := range transform.CollectGlobs(doc) {
+		globs = append(globs, Glob{
+			Pattern: g.Pattern,
+			Start:   g.Loc.Start,
+		})
+	}
+	return globs
+}
+
+func makeIslands(doc *astro.Node) []Island {
+	islands := make([]Island, 0)
+	for _, island := range transform.Islands(doc) {
+		islands = append(islands, Island{
+			Name:      island.Name,
+			Directive: island.Directive,
+			Start:     island.Loc.Start,
+		})
+	}
+	return islands
+}
+
+func makeRoute(doc *astro.Node, opts transform.TransformOptions) *Route {
+	if !opts.IsPage {
+		return nil
+	}
+	route := transform.RouteInfo(doc, opts)
+	return &Route{
+		Pattern:        route.Pattern,
+		Params:         route.Params,
+		ReadParams:     route.ReadParams,
+		GetStaticPaths: route.GetStaticPaths,
+		Prerender:      route.Prerender,
+	}
+}
+
+func makeMessages(doc *astro.Node) []Message {
+	messages := make([]Message, 0)
+	for _, m := range transform.ExtractMessages(doc) {
+		messages = append(messages, Message{
+			ID:      m.ID,
+			Element: m.Element,
+			Start:   m.Loc.Start,
+		})
+	}
+	return messages
+}
+
+func makeUndefinedComponents(doc *astro.Node) []UndefinedComponent {
+	components := make([]UndefinedComponent, 0)
+	for _, c := range transform.UndefinedComponents(doc) {
+		components = append(components, UndefinedComponent{
+			Name:  c.Name,
+			Start: c.Loc.Start,
+		})
+	}
+	return components
+}
+
+func makeReferences(doc *astro.Node) []Reference {
+	references := make([]Reference, 0)
+	for _, r := range transform.References(doc) {
+		references = append(references, Reference{
+			Name:  r.Name,
+			Kind:  r.Kind,
+			Start: r.Loc.Start,
+		})
+	}
+	return references
+}
+
+func makeDefinitions(doc *astro.Node) []Definition {
+	definitions := make([]Definition, 0)
+	for _, d := range transform.ComponentDefinitions(doc) {
+		definitions = append(definitions, Definition{
+			Name:        d.Name,
+			Start:       d.Loc.Start,
+			ImportStart: d.Import.Loc.Start,
+			ImportEnd:   d.Import.End(),
+			Specifier:   d.Specifier,
+			ExportName:  d.ExportName,
+		})
+	}
+	return definitions
+}
+
+// makeMetadata keys the metadata by position as a string, since object keys
+// are strings in JavaScript
+func makeMetadata(doc *astro.Node, opts transform.TransformOptions) map[string]NodeMetadata {
+	metadata := make(map[string]NodeMetadata)
+	for start, m := range transform.Metadata(doc, opts) {
+		metadata[strconv.Itoa(start)] = NodeMetadata{
+			Directives: m.Directives,
+			Hydration:  m.Hydration,
+			Scope:      m.Scope,
+			Slot:       m.Slot,
+		}
+	}
+	return metadata
 }
 
 // This is spawned as a goroutine to preprocess style nodes using an async function passed from JS
@@ -106,80 +555,220 @@ func preprocessStyle(i int, style *astro.Node, transformOptions transform.Transf
 	style.FirstChild.Data = str
 }
 
+// makeResult converts a result to a JavaScript object, or with the "json"
+// encoding to a single Uint8Array of JSON which the JavaScript side decodes at
+// once, which is much cheaper than converting every field separately
+func makeResult(result TransformResult, encoding string) interface{} {
+	if encoding != "json" {
+		return vert.ValueOf(result)
+	}
+	b, err := json.Marshal(result)
+	if err != nil {
+		return vert.ValueOf(result)
+	}
+	buf := js.Global().Get("Uint8Array").New(len(b))
+	js.CopyBytesToJS(buf, b)
+	return buf
+}
+
+// makeError creates a JavaScript Error for err, with every diagnostic attached
+func makeError(err error, h *handler.Handler) js.Value {
+	jsErr := js.Global().Get("Error").New(err.Error())
+	jsErr.Set("diagnostics", vert.ValueOf(h.Diagnostics()).JSValue())
+	return jsErr
+}
+
+func makeSymbols(symbols []transform.Symbol) []Symbol {
+	result := make([]Symbol, 0, len(symbols))
+	for _, s := range symbols {
+		result = append(result, Symbol{Name: s.Name, Kind: s.Kind, Start: s.Start, End: s.End, Children: makeSymbols(s.Children)})
+	}
+	return result
+}
+
+// Outline returns the document symbols of a component for editor outlines and
+// folding ranges, which only takes parsing it
+func Outline() interface{} {
+	return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		source := jsString(args[0])
+		options := makeTransformOptions(js.Value(args[1]), "")
+		h := handler.NewHandler(source, options.Filename)
+		var doc *astro.Node
+		if options.As == "fragment" {
+			nodes, _ := astro.ParseFragmentWithOptions(strings.NewReader(source), &astro.Node{
+				Type:     astro.ElementNode,
+				Data:     atom.Body.String(),
+				DataAtom: atom.Body,
+			}, astro.ParseOptionWithHandler(h))
+			doc = &astro.Node{Type: astro.DocumentNode}
+			for _, n := range nodes {
+				doc.AppendChild(n)
+			}
+		} else {
+			doc, _ = astro.ParseWithOptions(strings.NewReader(source), astro.ParseOptionWithHandler(h), astro.ParseOptionXML(options.ContentType == "xml"))
+		}
+		result := OutlineResult{Symbols: makeSymbols(transform.Outline(source, doc)), Diagnostics: h.Diagnostics()}
+		doc.Release()
+		return vert.ValueOf(result)
+	})
+}
+
+type SemanticTokensResult struct {
+	Legend SemanticTokensLegend `js:"legend" json:"legend"`
+	Data   []int                `js:"data" json:"data"`
+}
+
+type SemanticTokensLegend struct {
+	TokenTypes     []string `js:"tokenTypes" json:"tokenTypes"`
+	TokenModifiers []string `js:"tokenModifiers" json:"tokenModifiers"`
+}
+
+// SemanticTokens returns the semantic tokens of a component in the encoding
+// of the Language Server Protocol, which only takes tokenizing it
+func SemanticTokens() interface{} {
+	return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		return vert.ValueOf(SemanticTokensResult{
+			Legend: SemanticTokensLegend{TokenTypes: astro.SemanticTokenTypes, TokenModifiers: []string{}},
+			Data:   astro.SemanticTokens(jsString(args[0])),
+		})
+	})
+}
+
+type CompletionResult struct {
+	Kind       string   `js:"kind" json:"kind"`
+	Prefix     string   `js:"prefix" json:"prefix"`
+	Start      int      `js:"start" json:"start"`
+	Tag        string   `js:"tag" json:"tag"`
+	Attribute  string   `js:"attribute" json:"attribute"`
+	Attributes []string `js:"attributes" json:"attributes"`
+	Parents    []string `js:"parents" json:"parents"`
+	Scope      []string `js:"scope" json:"scope"`
+}
+
+// Completion returns what can be typed at an offset of a component, which
+// only takes tokenizing the source before it
+func Completion() interface{} {
+	return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		c := astro.Completion(jsString(args[0]), args[1].Int())
+		return vert.ValueOf(CompletionResult{
+			Kind:       c.Kind,
+			Prefix:     c.Prefix,
+			Start:      c.Start,
+			Tag:        c.Tag,
+			Attribute:  c.Attribute,
+			Attributes: c.Attributes,
+			Parents:    c.Parents,
+			Scope:      c.Scope,
+		})
+	})
+}
+
+type DumpASTResult struct {
+	AST         string                  `js:"ast" json:"ast"`
+	Diagnostics []loc.DiagnosticMessage `js:"diagnostics" json:"diagnostics"`
+}
+
+// DumpAST returns the tree of a component after the parse or the transform
+// phase in a readable form, for debugging templates
+func DumpAST() interface{} {
+	return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		source := jsString(args[0])
+		options := makeTransformOptions(js.Value(args[1]), "")
+		phase := jsString(args[1].Get("phase"))
+		if phase == "" {
+			phase = "parse"
+		}
+		h := handler.NewHandler(source, options.Filename)
+		tree, err := compiler.Dump(context.Background(), source, options, h, phase)
+		// An error which isn't a diagnostic, like an invalid option, is still reported
+		if err != nil && !h.HasErrors() {
+			h.AppendError(err)
+		}
+		return vert.ValueOf(DumpASTResult{AST: tree, Diagnostics: h.Diagnostics()})
+	})
+}
+
 func Transform() interface{} {
 	return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
 		source := jsString(args[0])
 		hash := astro.HashFromSource(source)
 		transformOptions := makeTransformOptions(js.Value(args[1]), hash)
+		resultEncoding := jsString(js.Value(args[1]).Get("resultEncoding"))
 
 		handler := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
 			resolve := args[0]
+			reject := args[1]
 
-			var doc *astro.Node
-
-			if transformOptions.As == "document" {
-				docNode, err := astro.Parse(strings.NewReader(source))
-				doc = docNode
-				if err != nil {
-					fmt.Println(err)
-				}
-			} else if transformOptions.As == "fragment" {
-				nodes, err := astro.ParseFragment(strings.NewReader(source), &astro.Node{
-					Type:     astro.ElementNode,
-					Data:     atom.Body.String(),
-					DataAtom: atom.Body,
-				})
-				if err != nil {
-					fmt.Println(err)
-				}
-				doc = &astro.Node{
-					Type: astro.DocumentNode,
-				}
-				for i := 0; i < len(nodes); i++ {
-					n := nodes[i]
-					doc.AppendChild(n)
-				}
+			if resultEncoding != "" && resultEncoding != "object" && resultEncoding != "json" {
+				reject.Invoke(js.Global().Get("Error").New(fmt.Sprintf(`invalid resultEncoding option "%s", expected one of "object", "json"`, resultEncoding)))
+				return nil
 			}
 
-			// Hoist styles and scripts to the top-level
-			transform.ExtractStyles(doc)
-
-			// Pre-process styles
-			// Important! These goroutines need to be spawned from this file or they don't work
-			var wg sync.WaitGroup
-			if len(doc.Styles) > 0 {
-				if transformOptions.PreprocessStyle.(js.Value).IsUndefined() != true {
-					for i, style := range doc.Styles {
-						wg.Add(1)
-						i := i
-						go preprocessStyle(i, style, transformOptions, wg.Done)
+			var transformResult TransformResult
+			h := handler.NewHandler(source, transformOptions.Filename)
+			result, err := compiler.CompileWithHooks(context.Background(), source, transformOptions, h, func(doc *astro.Node) {
+				// Pre-process styles
+				// Important! These goroutines need to be spawned from this file or they don't work
+				var wg sync.WaitGroup
+				if len(doc.Styles) > 0 {
+					if transformOptions.PreprocessStyle.(js.Value).IsUndefined() != true {
+						for i, style := range doc.Styles {
+							wg.Add(1)
+							i := i
+							go preprocessStyle(i, style, transformOptions, wg.Done)
+						}
 					}
 				}
+				// Wait for all the style goroutines to finish
+				wg.Wait()
+
+				// Collected before the compiler adds attributes which reference components
+				transformResult.UndefinedComponents = makeUndefinedComponents(doc)
+				transformResult.References = makeReferences(doc)
+				transformResult.Definitions = makeDefinitions(doc)
+				// Collected before text is rewritten for translation
+				transformResult.Messages = make([]Message, 0)
+				if transformOptions.ExtractMessages {
+					transformResult.Messages = makeMessages(doc)
+				}
+			}, func(doc *astro.Node) {
+				transformResult.Assets = makeAssets(doc)
+				transformResult.StyleImports = makeStyleImports(doc)
+				transformResult.Globs = makeGlobs(doc)
+				transformResult.Islands = makeIslands(doc)
+				transformResult.Metadata = makeMetadata(doc, transformOptions)
+				transformResult.Route = makeRoute(doc, transformOptions)
+			})
+			if err != nil {
+				reject.Invoke(makeError(err, h))
+				return nil
+			}
+			transformResult.Diagnostics = h.Diagnostics()
+			transformResult.Stats = makeStats(result.Stats)
+			transformResult.Styles = makeTags(result.Styles)
+			transformResult.Scripts = makeTags(result.Scripts)
+			transformResult.InlineSources = makeInlineSources(result.InlineSources)
+			transformResult.Preloads = makePreloads(result.Preloads)
+			transformResult.Version = astro.Version
+			transformResult.Prerender = result.Prerender
+			transformResult.DataFrontmatter = makeDataFrontmatter(result.DataFrontmatter)
+			transformResult.Trace = makeTrace(transformOptions.Trace)
+			transformResult.Hashes = Hashes{
+				Frontmatter: result.Hashes.Frontmatter,
+				Template:    result.Hashes.Template,
 			}
-			// Wait for all the style goroutines to finish
-			wg.Wait()
-
-			// Perform CSS and element scoping as needed
-			transform.Transform(doc, transformOptions)
-
-			result := printer.PrintToJS(source, doc, transformOptions)
 
 			switch transformOptions.SourceMap {
 			case "external":
-				resolve.Invoke(createExternalSourceMap(source, result, transformOptions))
-				return nil
+				transformResult = createExternalSourceMap(source, result, transformResult, transformOptions)
 			case "both":
-				resolve.Invoke(createBothSourceMap(source, result, transformOptions))
-				return nil
+				transformResult = createBothSourceMap(source, result, transformResult, transformOptions)
 			case "inline":
-				resolve.Invoke(createInlineSourceMap(source, result, transformOptions))
-				return nil
+				transformResult = createInlineSourceMap(source, result, transformResult, transformOptions)
+			default:
+				transformResult.Code = string(result.Output)
 			}
-
-			resolve.Invoke(vert.ValueOf(TransformResult{
-				Code: string(result.Output),
-				Map:  "",
-			}))
+			resolve.Invoke(makeResult(transformResult, resultEncoding))
 
 			return nil
 		})
@@ -208,27 +797,23 @@ func createSourceMapString(source string, result printer.PrintResult, transformO
 }`, sourcemap.Sources[0], sourcemap.SourcesContent[0], sourcemap.Mappings)
 }
 
-func createExternalSourceMap(source string, result printer.PrintResult, transformOptions transform.TransformOptions) interface{} {
-	return vert.ValueOf(TransformResult{
-		Code: string(result.Output),
-		Map:  createSourceMapString(source, result, transformOptions),
-	})
+func createExternalSourceMap(source string, result printer.PrintResult, transformResult TransformResult, transformOptions transform.TransformOptions) TransformResult {
+	transformResult.Code = string(result.Output)
+	transformResult.Map = createSourceMapString(source, result, transformOptions)
+	return transformResult
 }
 
-func createInlineSourceMap(source string, result printer.PrintResult, transformOptions transform.TransformOptions) interface{} {
+func createInlineSourceMap(source string, result printer.PrintResult, transformResult TransformResult, transformOptions transform.TransformOptions) TransformResult {
 	sourcemapString := createSourceMapString(source, result, transformOptions)
 	inlineSourcemap := `//# sourceMappingURL=data:application/json;charset=utf-8;base64,` + base64.StdEncoding.EncodeToString([]byte(sourcemapString))
-	return vert.ValueOf(TransformResult{
-		Code: string(result.Output) + "\n" + inlineSourcemap,
-		Map:  "",
-	})
+	transformResult.Code = string(result.Output) + "\n" + inlineSourcemap
+	return transformResult
 }
 
-func createBothSourceMap(source string, result printer.PrintResult, transformOptions transform.TransformOptions) interface{} {
+func createBothSourceMap(source string, result printer.PrintResult, transformResult TransformResult, transformOptions transform.TransformOptions) TransformResult {
 	sourcemapString := createSourceMapString(source, result, transformOptions)
 	inlineSourcemap := `//# sourceMappingURL=data:application/json;charset=utf-8;base64,` + base64.StdEncoding.EncodeToString([]byte(sourcemapString))
-	return vert.ValueOf(TransformResult{
-		Code: string(result.Output) + "\n" + inlineSourcemap,
-		Map:  sourcemapString,
-	})
+	transformResult.Code = string(result.Output) + "\n" + inlineSourcemap
+	transformResult.Map = sourcemapString
+	return transformResult
 }
@@ -1,17 +1,30 @@
 package main
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"flag"
 	"fmt"
-	"strings"
+	"os"
 
-	astro "github.com/snowpackjs/astro/internal"
-	"github.com/snowpackjs/astro/internal/printer"
+	"github.com/snowpackjs/astro/internal/compiler"
+	"github.com/snowpackjs/astro/internal/handler"
+	"github.com/snowpackjs/astro/internal/loc"
+	"github.com/snowpackjs/astro/internal/service"
 	"github.com/snowpackjs/astro/internal/transform"
 )
 
+// Compiles the file given as an argument, or a sample component, and prints
+// the module with an inline source map, or what the flags ask for instead
 func main() {
+	jsonDiagnostics := flag.Bool("json", false, "print the diagnostics as JSON instead of the module, for CI annotators and editors")
+	summary := flag.Bool("summary", false, "print the summary of the module as JSON, which -diff compares with after a compiler upgrade")
+	diff := flag.String("diff", "", "print how the module differs from the summary in the given file, like its imports, islands, styles and scripts")
+	ast := flag.String("ast", "", "print the tree of the document after the \"parse\" or \"transform\" phase instead of the module, for debugging templates")
+	flag.Parse()
+
+	filename := "file.astro"
 	source := `
 ---
 import Component from '../components/Component.vue';
@@ -41,22 +54,83 @@ const something = await Astro.fetchContent('../*.md');
 </html>
 `
 
-	doc, err := astro.Parse(strings.NewReader(source))
+	if flag.NArg() > 0 {
+		filename = flag.Arg(0)
+		b, err := os.ReadFile(filename)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		source = string(b)
+	}
+
+	if *summary || *diff != "" {
+		params := service.DiffParams{Source: source, Options: service.Options{Sourcefile: filename}}
+		if *diff != "" {
+			b, err := os.ReadFile(*diff)
+			if err == nil {
+				err = json.Unmarshal(b, &params.Summary)
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+		result, err := service.Diff(context.Background(), params)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		if *summary {
+			encoder.Encode(result.Summary)
+			return
+		}
+		encoder.Encode(result.Changes)
+		return
+	}
+
+	h := handler.NewHandler(source, filename)
+	if *ast != "" {
+		tree, err := compiler.Dump(context.Background(), source, transform.TransformOptions{
+			Filename: filename,
+		}, h, *ast)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Print(tree)
+		return
+	}
+	result, err := compiler.Compile(context.Background(), source, transform.TransformOptions{
+		Filename: filename,
+	}, h)
+	if *jsonDiagnostics {
+		// An error which isn't a diagnostic, like an invalid option, is still reported
+		if err != nil && !h.HasErrors() {
+			h.AppendError(err)
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(struct {
+			Diagnostics []loc.DiagnosticMessage `json:"diagnostics"`
+		}{h.Diagnostics()})
+		if h.HasErrors() {
+			os.Exit(1)
+		}
+		return
+	}
 	if err != nil {
 		fmt.Println(err)
 		return
 	}
-	hash := astro.HashFromSource(source)
-
-	transform.ExtractStyles(doc)
-	transform.Transform(doc, transform.TransformOptions{
-		Scope: hash,
-	})
-
-	result := printer.PrintToJS(source, doc, transform.TransformOptions{})
 
 	content, _ := json.Marshal(source)
-	sourcemap := `{ "version": 3, "sources": ["file.astro"], "names": [], "mappings": "` + string(result.SourceMapChunk.Buffer) + `", "sourcesContent": [` + string(content) + `] }`
+	name, _ := json.Marshal(filename)
+	sourcemap := `{ "version": 3, "sources": [` + string(name) + `], "names": [], "mappings": "` + string(result.SourceMapChunk.Buffer) + `", "sourcesContent": [` + string(content) + `] }`
 	b64 := base64.StdEncoding.EncodeToString([]byte(sourcemap))
 	output := string(result.Output) + string('\n') + `//# sourceMappingURL=data:application/json;base64,` + b64 + string('\n')
 	fmt.Print(output)
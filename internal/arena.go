@@ -0,0 +1,60 @@
+package astro
+
+import "sync"
+
+// nodeArenaChunk is the number of nodes allocated at once
+const nodeArenaChunk = 64
+
+// Chunks are reused by later parses once a document is released
+var nodeChunkPool = sync.Pool{
+	New: func() interface{} {
+		chunk := make([]Node, 0, nodeArenaChunk)
+		return &chunk
+	},
+}
+
+// nodeArena allocates the nodes of a single parse in chunks, so a page with
+// thousands of elements makes a few large allocations instead of one per node
+type nodeArena struct {
+	chunks []*[]Node
+}
+
+func (a *nodeArena) alloc(n Node) *Node {
+	if len(a.chunks) == 0 || len(*a.chunks[len(a.chunks)-1]) == nodeArenaChunk {
+		a.chunks = append(a.chunks, nodeChunkPool.Get().(*[]Node))
+	}
+	// Chunks never grow past their capacity, so earlier nodes never move
+	chunk := a.chunks[len(a.chunks)-1]
+	*chunk = append(*chunk, n)
+	return &(*chunk)[len(*chunk)-1]
+}
+
+func (a *nodeArena) release() {
+	for _, chunk := range a.chunks {
+		// Pooled chunks must not keep the strings and nodes of this document alive
+		for i := range *chunk {
+			(*chunk)[i] = Node{}
+		}
+		*chunk = (*chunk)[:0]
+		nodeChunkPool.Put(chunk)
+	}
+	a.chunks = nil
+}
+
+// Release lets later parses reuse the memory of a document returned by Parse.
+// Neither n nor any node in it, including nodes kept elsewhere like n.Styles,
+// may be used afterwards. It does nothing for nodes which aren't the root of a
+// parsed document.
+func (n *Node) Release() {
+	if n.arena == nil {
+		return
+	}
+	n.arena.release()
+	n.arena = nil
+	n.FirstChild = nil
+	n.LastChild = nil
+	n.Styles = nil
+	n.Scripts = nil
+	n.HydratedComponents = nil
+	n.ClientOnlyComponents = nil
+}
@@ -0,0 +1,218 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/handler"
+	"github.com/snowpackjs/astro/internal/js_scanner"
+	"github.com/snowpackjs/astro/internal/loc"
+	"github.com/snowpackjs/astro/internal/transform"
+)
+
+// Finding is something Analyze reports about a file of the project
+type Finding struct {
+	Path       string
+	Diagnostic loc.DiagnosticMessage
+}
+
+// componentInfo is what a component declares and renders, and how it uses
+// other components
+type componentInfo struct {
+	// The declared props, only complete if hasProps is set
+	props    map[string]bool
+	hasProps bool
+	// The names of the slots rendered, where any slot may be rendered if
+	// anySlot is set
+	slots   map[string]bool
+	anySlot bool
+	usages  []componentUsage
+}
+
+// componentUsage is an element of a template which renders a component
+type componentUsage struct {
+	name string
+	loc  loc.Loc
+	// The props passed, which can't all be known if there's a spread
+	props   []astro.Attribute
+	anyProp bool
+	// The slots passed, where the loc of the default slot is its first child
+	slots []slotUsage
+}
+
+type slotUsage struct {
+	name string
+	loc  loc.Loc
+}
+
+func collectComponentInfo(doc *astro.Node, source string) componentInfo {
+	info := componentInfo{
+		props: make(map[string]bool),
+		slots: make(map[string]bool),
+		// Slots rendered from the frontmatter or expressions can't be known
+		anySlot: strings.Contains(source, "Astro.slots.render"),
+	}
+	for c := doc.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == astro.FrontmatterNode && c.FirstChild != nil {
+			keys, ok := js_scanner.PropsKeys([]byte(c.FirstChild.Data))
+			info.hasProps = ok
+			for _, key := range keys {
+				info.props[key] = true
+			}
+		}
+	}
+
+	var walk func(n *astro.Node)
+	walk = func(n *astro.Node) {
+		if n.Type == astro.ElementNode {
+			switch {
+			case n.Data == "slot" && !n.Component && !n.CustomElement:
+				name := astro.GetAttribute(n, "name")
+				switch {
+				case name == nil:
+					info.slots["default"] = true
+				case name.Type == astro.QuotedAttribute:
+					info.slots[name.Val] = true
+				default:
+					info.anySlot = true
+				}
+			case n.Component && !n.Fragment && transform.DynamicTag(n) == nil:
+				info.usages = append(info.usages, collectUsage(n))
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return info
+}
+
+func collectUsage(n *astro.Node) componentUsage {
+	usage := componentUsage{name: n.Data}
+	if len(n.Loc) > 0 {
+		usage.loc = n.Loc[0]
+	}
+	for _, attr := range n.Attr {
+		switch {
+		case attr.Type == astro.SpreadAttribute:
+			usage.anyProp = true
+		case attr.Key == "class:list":
+			// Passed as the class prop
+			attr.Key = "class"
+			usage.props = append(usage.props, attr)
+		case attr.Key == "slot" || strings.Contains(attr.Key, ":"):
+			// Slotted into its parent, or a directive rather than a prop
+		default:
+			usage.props = append(usage.props, attr)
+		}
+	}
+
+	hasDefault := false
+	var children func(n *astro.Node)
+	children = func(n *astro.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			switch {
+			case c.Type == astro.CommentNode:
+				continue
+			case c.Type == astro.TextNode && strings.TrimSpace(c.Data) == "":
+				continue
+			case c.Type == astro.ElementNode && c.Expression:
+				// The elements of an expression may each go to a slot of their own
+				children(c)
+				continue
+			case c.Type == astro.ElementNode:
+				if slot := astro.GetAttribute(c, "slot"); slot != nil {
+					if slot.Type == astro.QuotedAttribute {
+						usage.slots = append(usage.slots, slotUsage{slot.Val, slot.KeyLoc})
+					}
+					continue
+				}
+			}
+			if !hasDefault {
+				hasDefault = true
+				s := slotUsage{name: "default"}
+				if len(c.Loc) > 0 {
+					s.loc = c.Loc[0]
+				}
+				usage.slots = append(usage.slots, s)
+			}
+		}
+	}
+	children(n)
+	return usage
+}
+
+// analyze reports what isn't used across the files of the last compile
+func (p *Project) analyze(manifest Manifest) []Finding {
+	findings := make([]Finding, 0)
+	for _, component := range manifest.Components {
+		entry := p.cache[component.Path]
+		h := handler.NewHandler(entry.source, component.Path)
+
+		if len(component.Dependents) == 0 && !isPage(component.Path) {
+			h.AppendWarning(&loc.ErrorWithRange{
+				Code: loc.WARNING_UNUSED_COMPONENT,
+				Text: fmt.Sprintf("Component %s is never imported", component.Path),
+				Hint: "Pages are only detected in a pages directory, anything else has to be imported to be rendered",
+			})
+		}
+
+		imported := make(map[string]string)
+		for _, i := range component.Imports {
+			if i.Name != "" && i.Path != "" {
+				imported[i.Name] = i.Path
+			}
+		}
+		for _, usage := range entry.info.usages {
+			path, ok := imported[usage.name]
+			if !ok {
+				continue
+			}
+			target := p.cache[path].info
+			if target.hasProps && !usage.anyProp {
+				for _, prop := range usage.props {
+					if target.props[prop.Key] {
+						continue
+					}
+					h.AppendWarning(&loc.ErrorWithRange{
+						Code:  loc.WARNING_UNDECLARED_PROP,
+						Text:  fmt.Sprintf("%s is passed the prop %s, which its Props don't declare", usage.name, prop.Key),
+						Hint:  fmt.Sprintf("Declare it in the Props of %s, or stop passing it", path),
+						Range: loc.Range{Loc: prop.KeyLoc, Len: len(prop.Key)},
+					})
+				}
+			}
+			if target.anySlot {
+				continue
+			}
+			for _, slot := range usage.slots {
+				if target.slots[slot.name] {
+					continue
+				}
+				text := fmt.Sprintf("%s doesn't render a slot named %q, so its content is never rendered", usage.name, slot.name)
+				hint := fmt.Sprintf("Add <slot name=%q /> to %s", slot.name, path)
+				if slot.name == "default" {
+					text = fmt.Sprintf("%s doesn't render its default slot, so its children are never rendered", usage.name)
+					hint = fmt.Sprintf("Add <slot /> to %s", path)
+				}
+				h.AppendWarning(&loc.ErrorWithRange{
+					Code:  loc.WARNING_UNRENDERED_SLOT,
+					Text:  text,
+					Hint:  hint,
+					Range: loc.Range{Loc: slot.loc, Len: 1},
+				})
+			}
+		}
+
+		for _, d := range h.Diagnostics() {
+			findings = append(findings, Finding{Path: component.Path, Diagnostic: d})
+		}
+	}
+	return findings
+}
+
+func isPage(path string) bool {
+	return strings.HasPrefix(path, "pages/") || strings.Contains(path, "/pages/")
+}
@@ -0,0 +1,194 @@
+package compiler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/handler"
+	"github.com/snowpackjs/astro/internal/printer"
+	"github.com/snowpackjs/astro/internal/transform"
+	"golang.org/x/net/html/atom"
+)
+
+// Compile parses, transforms and prints source as a JavaScript module.
+// Diagnostics are added to h. ctx is checked between each phase, so a compile
+// which is no longer needed, like in an editor when the user keeps typing, can
+// be abandoned early with ctx.Err(). The limits in opts, like opts.Timeout,
+// also abandon the compile, with an error diagnostic.
+func Compile(ctx context.Context, source string, opts transform.TransformOptions, h *handler.Handler) (printer.PrintResult, error) {
+	return CompileWithHooks(ctx, source, opts, h, nil, nil)
+}
+
+// CompileWithHooks is Compile, which also calls visit with the document as
+// parsed, with its styles extracted, right before it's transformed, and
+// transformed with the document as printed. visit may change the document,
+// like the styles a caller preprocesses. Neither may keep the document, which
+// is released once the compile returns.
+func CompileWithHooks(ctx context.Context, source string, opts transform.TransformOptions, h *handler.Handler, visit func(doc *astro.Node), transformed func(doc *astro.Node)) (printer.PrintResult, error) {
+	source, data, err := setup(ctx, source, &opts, h)
+	if err != nil {
+		return printer.PrintResult{}, err
+	}
+	ctx, cancel := withTimeout(ctx, opts)
+	defer cancel()
+
+	parseStart := time.Now()
+	endParse := opts.Trace.Phase("parse")
+	doc, err := parse(ctx, source, opts, h)
+	if err != nil {
+		return printer.PrintResult{}, transform.ParseError(ctx, err, opts, h)
+	}
+	// The result doesn't reference the document, so its nodes can be reused
+	defer doc.Release()
+	parseTime := time.Since(parseStart)
+	endParse(doc)
+
+	transformStart := time.Now()
+	endTransform := opts.Trace.Phase("transform")
+	transform.ExtractStyles(doc)
+	if visit != nil {
+		visit(doc)
+	}
+	transform.Transform(doc, opts, h)
+	transformTime := time.Since(transformStart)
+	endTransform(doc)
+	if err := transform.CheckContext(ctx, opts, h); err != nil {
+		return printer.PrintResult{}, err
+	}
+	// In strict mode, any error fails the compile
+	if err := h.Error(); opts.Strict && err != nil {
+		return printer.PrintResult{}, err
+	}
+
+	endPrint := opts.Trace.Phase("print")
+	result := printer.PrintToJS(source, doc, opts)
+	opts.Trace.Decide("print", "output", "%d bytes of %s", len(result.Output), opts.ModuleFormat)
+	endPrint(doc)
+	if transformed != nil {
+		transformed(doc)
+	}
+	result.Stats.Parse = parseTime
+	result.Stats.Transform = transformTime
+	result.DataFrontmatter = data
+	return result, nil
+}
+
+// Dump returns the tree of source as it is after phase, "parse" or
+// "transform", in the readable form of astro.Dump
+func Dump(ctx context.Context, source string, opts transform.TransformOptions, h *handler.Handler, phase string) (string, error) {
+	if phase != "parse" && phase != "transform" {
+		return "", fmt.Errorf(`invalid phase "%s", expected one of "parse", "transform"`, phase)
+	}
+	source, _, err := setup(ctx, source, &opts, h)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := withTimeout(ctx, opts)
+	defer cancel()
+	doc, err := parse(ctx, source, opts, h)
+	if err != nil {
+		return "", transform.ParseError(ctx, err, opts, h)
+	}
+	defer doc.Release()
+	if phase == "transform" {
+		transform.ExtractStyles(doc)
+		transform.Transform(doc, opts, h)
+	}
+	var b strings.Builder
+	if err := astro.Dump(&b, doc); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Parse parses source like Compile does, with the same options and limits,
+// without transforming it. The document is the caller's to release.
+func Parse(ctx context.Context, source string, opts transform.TransformOptions, h *handler.Handler) (*astro.Node, error) {
+	source, _, err := setup(ctx, source, &opts, h)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := withTimeout(ctx, opts)
+	defer cancel()
+	doc, err := parse(ctx, source, opts, h)
+	if err != nil {
+		return nil, transform.ParseError(ctx, err, opts, h)
+	}
+	return doc, nil
+}
+
+// Reparse is astro.Reparse of a document parsed by Parse, with the limits of
+// opts checked against the source as edited. opts.As must be "document", and
+// without opts.DataFrontmatter, since the source Parse parses is then not the
+// one the edit is made to.
+func Reparse(ctx context.Context, doc *astro.Node, source string, edit astro.Edit, opts transform.TransformOptions, h *handler.Handler) (*astro.Node, error) {
+	if _, _, err := setup(ctx, edit.Apply(source), &opts, h); err != nil {
+		return nil, err
+	}
+	ctx, cancel := withTimeout(ctx, opts)
+	defer cancel()
+	parseOpts := append(transform.ParseLimits(ctx, opts), astro.ParseOptionWithHandler(h), astro.ParseOptionXML(opts.ContentType == "xml"))
+	next, err := astro.Reparse(doc, source, edit, parseOpts...)
+	if err != nil {
+		return nil, transform.ParseError(ctx, err, opts, h)
+	}
+	return next, nil
+}
+
+// setup normalizes and validates opts and checks source against their limits,
+// like every compile does before parsing. It returns the source to parse,
+// which is without the data frontmatter of a content file.
+func setup(ctx context.Context, source string, opts *transform.TransformOptions, h *handler.Handler) (string, *transform.DataFrontmatter, error) {
+	opts.Normalize()
+	if err := opts.Validate(); err != nil {
+		return "", nil, err
+	}
+	if opts.Scope == "" {
+		opts.Scope = astro.HashFromSource(source)
+	}
+	if err := transform.CheckContext(ctx, *opts, h); err != nil {
+		return "", nil, err
+	}
+	if !transform.CheckInputSize(source, *opts, h) {
+		return "", nil, h.Error()
+	}
+	var data *transform.DataFrontmatter
+	if opts.DataFrontmatter {
+		source, data = transform.ExtractDataFrontmatter(source)
+		if data != nil {
+			opts.Trace.Decide("parse", "data-frontmatter", "%s data extracted, %d bytes", data.Lang, len(data.Content))
+		}
+	}
+	return source, data, nil
+}
+
+// withTimeout returns ctx with the deadline of opts.Timeout, if there is one
+func withTimeout(ctx context.Context, opts transform.TransformOptions) (context.Context, context.CancelFunc) {
+	if opts.Timeout > 0 {
+		return context.WithTimeout(ctx, opts.Timeout)
+	}
+	return ctx, func() {}
+}
+
+func parse(ctx context.Context, source string, opts transform.TransformOptions, h *handler.Handler) (*astro.Node, error) {
+	parseOpts := append(transform.ParseLimits(ctx, opts), astro.ParseOptionWithHandler(h))
+	if opts.As == "document" {
+		return astro.ParseWithOptions(strings.NewReader(source), append(parseOpts, astro.ParseOptionXML(opts.ContentType == "xml"))...)
+	}
+	nodes, err := astro.ParseFragmentWithOptions(strings.NewReader(source), &astro.Node{
+		Type:     astro.ElementNode,
+		Data:     atom.Body.String(),
+		DataAtom: atom.Body,
+	}, parseOpts...)
+	if err != nil {
+		return nil, err
+	}
+	doc := &astro.Node{Type: astro.DocumentNode}
+	for _, n := range nodes {
+		doc.AppendChild(n)
+	}
+	return doc, nil
+}
@@ -0,0 +1,255 @@
+package compiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/handler"
+	"github.com/snowpackjs/astro/internal/loc"
+	"github.com/snowpackjs/astro/internal/transform"
+)
+
+func TestCompile(t *testing.T) {
+	source := "---\nconst name = 'world';\n---\n<h1>Hello {name}</h1>"
+	result, err := Compile(context.Background(), source, transform.TransformOptions{}, handler.NewHandler(source, "<stdin>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "<h1>Hello ${name}</h1>"; !strings.Contains(string(result.Output), want) {
+		t.Errorf("\nFAIL: compile\n  want to contain: %s\n  got: %s", want, result.Output)
+	}
+}
+
+func TestCompileFragment(t *testing.T) {
+	source := "<p>one</p><p>two</p>"
+	result, err := Compile(context.Background(), source, transform.TransformOptions{As: "fragment"}, handler.NewHandler(source, "<stdin>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "$$render`<p>one</p><p>two</p>`"; !strings.Contains(string(result.Output), want) {
+		t.Errorf("\nFAIL: compile fragment\n  want to contain: %s\n  got: %s", want, result.Output)
+	}
+}
+
+func TestCompileDataFrontmatter(t *testing.T) {
+	source := "---\ntitle: Hello\n---\n---\nconst { title } = Astro.props;\n---\n<h1>{title}</h1><div client:lod />"
+	h := handler.NewHandler(source, "<stdin>")
+	result, err := Compile(context.Background(), source, transform.TransformOptions{DataFrontmatter: true}, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data := result.DataFrontmatter; data == nil || data.Lang != "yaml" || data.Content != "title: Hello\n" || data.Loc.Start != 4 {
+		t.Errorf("\nFAIL: data frontmatter\n  want: yaml \"title: Hello\\n\"@4\n  got:  %+v", data)
+	}
+	if want := "const { title } = Astro.props;"; !strings.Contains(string(result.Output), want) || strings.Contains(string(result.Output), "title: Hello") {
+		t.Errorf("\nFAIL: data frontmatter\n  want the script and not the data, got: %s", result.Output)
+	}
+	// Diagnostics point into the source as authored
+	warnings := h.Warnings()
+	if len(warnings) != 1 || warnings[0].Location.Line != 7 {
+		t.Errorf("\nFAIL: data frontmatter\n  want a warning on line 7, got: %+v", warnings)
+	}
+}
+
+func TestCompileCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	source := "<div></div>"
+	_, err := Compile(ctx, source, transform.TransformOptions{}, handler.NewHandler(source, "<stdin>"))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("\nFAIL: canceled\n  want: %v\n  got:  %v", context.Canceled, err)
+	}
+}
+
+func TestCompileInvalidOptions(t *testing.T) {
+	source := "<div></div>"
+	_, err := Compile(context.Background(), source, transform.TransformOptions{As: "page"}, handler.NewHandler(source, "<stdin>"))
+	if err == nil {
+		t.Error("\nFAIL: invalid options\n  want an error")
+	}
+}
+
+func TestCompileStrict(t *testing.T) {
+	source := "<div client:lod></div>"
+	_, err := Compile(context.Background(), source, transform.TransformOptions{Strict: true}, handler.NewHandler(source, "<stdin>"))
+	if err == nil {
+		t.Error("\nFAIL: strict\n  want an error")
+	}
+}
+
+func TestCompileLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		opts   transform.TransformOptions
+		want   string
+	}{
+		{
+			name:   "input size",
+			source: "<div>Hello world</div>",
+			opts:   transform.TransformOptions{MaxInputSize: 10},
+			want:   "<stdin>:1:1: Input is 22 bytes, which is more than the limit of 10 bytes",
+		},
+		{
+			name:   "node count",
+			source: "<ul><li>1</li><li>2</li><li>3</li></ul>",
+			opts:   transform.TransformOptions{As: "fragment", MaxNodes: 5},
+			want:   "<stdin>:1:15: Document has more than the limit of 5 nodes",
+		},
+		{
+			name:   "expression depth",
+			source: "<div>{a && <p>{b && <span>{c}</span>}</p>}</div>",
+			opts:   transform.TransformOptions{MaxExpressionDepth: 2},
+			want:   "<stdin>:1:27: Expressions are nested more than the limit of 2 levels deep",
+		},
+		{
+			name:   "within limits",
+			source: "<div>{a && <p>{b}</p>}</div>",
+			opts:   transform.TransformOptions{MaxInputSize: 100, MaxNodes: 100, MaxExpressionDepth: 2},
+			want:   "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(context.Background(), tt.source, tt.opts, handler.NewHandler(tt.source, "<stdin>"))
+			got := ""
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tt.want {
+				t.Errorf("\nFAIL: %s\n  want: %s\n  got:  %s", tt.name, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCompileTimeout(t *testing.T) {
+	source := "<div></div>"
+	h := handler.NewHandler(source, "<stdin>")
+	_, err := Compile(context.Background(), source, transform.TransformOptions{Timeout: time.Nanosecond}, h)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("\nFAIL: timeout\n  want: %v\n  got:  %v", context.DeadlineExceeded, err)
+	}
+	if errs := h.Errors(); len(errs) != 1 || errs[0].Code != int(loc.ERROR_COMPILE_TIMEOUT) {
+		t.Errorf("\nFAIL: timeout\n  want a timeout diagnostic\n  got:  %+v", errs)
+	}
+}
+
+// Compiling many files in one process, like the WASM service does, must not
+// keep memory from earlier compiles. Run it with -bench=CompileMemory; the
+// heap-B/op it reports should stay near zero as -benchtime grows.
+func BenchmarkCompileMemory(b *testing.B) {
+	compile := func(i int) {
+		source := fmt.Sprintf(`---
+import Counter from '../components/Counter%d.jsx';
+const items = [%d, %d, %d];
+---
+<html>
+	<head><title>Page {%d}</title></head>
+	<body>
+		<ul>{items.map((item) => <li class="item">{item}</li>)}</ul>
+		<Counter client:visible count={%d} />
+		<style>.item { color: red; }</style>
+		<script hoist>console.log(%d)</script>
+	</body>
+</html>`, i, i, i+1, i+2, i, i, i)
+		opts := transform.TransformOptions{Filename: fmt.Sprintf("page%d.astro", i), SourceMap: "both"}
+		if _, err := Compile(context.Background(), source, opts, handler.NewHandler(source, opts.Filename)); err != nil {
+			b.Fatal(err)
+		}
+	}
+	heap := func() uint64 {
+		runtime.GC()
+		runtime.GC()
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		return stats.HeapAlloc
+	}
+
+	for i := 0; i < 50; i++ {
+		compile(i)
+	}
+	before := heap()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		compile(50 + i)
+	}
+	b.StopTimer()
+	after := heap()
+
+	growth := float64(0)
+	if after > before {
+		growth = float64(after - before)
+	}
+	b.ReportMetric(growth/float64(b.N), "heap-B/op")
+	if after > before+512*1024 {
+		b.Errorf("heap grew from %d to %d bytes over %d compiles", before, after, b.N)
+	}
+}
+
+func TestCompileTrace(t *testing.T) {
+	source := "<div class=\"a\">{import.meta.env.DEV && <p>debug</p>}</div>\n<style>.a { color: red; }</style>"
+	var b strings.Builder
+	trace := transform.NewTrace(&b)
+	opts := transform.TransformOptions{Define: map[string]string{"import.meta.env.DEV": "false"}, Trace: trace}
+	if _, err := Compile(context.Background(), source, opts, handler.NewHandler(source, "<stdin>")); err != nil {
+		t.Fatal(err)
+	}
+	phases := make([]string, 0)
+	decisions := make(map[string]string)
+	for _, e := range trace.Events {
+		if e.Pass == "" {
+			if e.Nodes == 0 {
+				t.Errorf("expected the %s phase to count nodes", e.Phase)
+			}
+			phases = append(phases, e.Phase)
+		} else {
+			decisions[e.Pass] = e.Decision
+		}
+	}
+	if got := strings.Join(phases, ","); got != "parse,transform,print" {
+		t.Errorf("\nFAIL: trace\n  want phases: parse,transform,print\n  got:  %s", got)
+	}
+	want := map[string]string{
+		"define": "substituted 1 defines, dead branches removed 4 nodes",
+		"scope":  "elements scoped to " + astro.HashFromSource(source) + " with the class strategy, unused selectors: keep",
+	}
+	for pass, decision := range want {
+		if decisions[pass] != decision {
+			t.Errorf("\nFAIL: trace %s\n  want: %s\n  got:  %s", pass, decision, decisions[pass])
+		}
+	}
+	if lines := strings.Count(b.String(), "\n"); lines != len(trace.Events) {
+		t.Errorf("expected a line for each of the %d events, got:\n%s", len(trace.Events), b.String())
+	}
+}
+
+func TestDump(t *testing.T) {
+	source := "<div>a</div><style>div { color: red; }</style>"
+	parsed, err := Dump(context.Background(), source, transform.TransformOptions{As: "fragment"}, handler.NewHandler(source, "<stdin>"), "parse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(parsed, "Hoisted styles") || !strings.Contains(parsed, "  Element <style> @12,38") {
+		t.Errorf("\nFAIL: dump parse\n  want the style in the tree, got:\n%s", parsed)
+	}
+	transformed, err := Dump(context.Background(), source, transform.TransformOptions{As: "fragment", Scope: "abc"}, handler.NewHandler(source, "<stdin>"), "transform")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"  Element <div> @0,6 class=\"astro-abc\"@0\n", "Hoisted styles\n  Element <style> @12,38"} {
+		if !strings.Contains(transformed, want) {
+			t.Errorf("\nFAIL: dump transform\n  want to contain: %s\n  got:\n%s", want, transformed)
+		}
+	}
+	if _, err := Dump(context.Background(), source, transform.TransformOptions{}, handler.NewHandler(source, "<stdin>"), "print"); err == nil {
+		t.Error("\nFAIL: dump print\n  want an error")
+	}
+}
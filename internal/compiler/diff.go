@@ -0,0 +1,146 @@
+package compiler
+
+import (
+	"context"
+	"fmt"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/handler"
+	"github.com/snowpackjs/astro/internal/js_scanner"
+	"github.com/snowpackjs/astro/internal/transform"
+)
+
+// Summary is what the compiled module of a component does which matters to
+// an app, rather than how its code is written, so the impact of a compiler
+// upgrade or of new options can be audited: a summary recorded before is
+// compared with one of the same component compiled after, see Diff.
+type Summary struct {
+	// The version of the compiler which compiled the component
+	Version string
+	// The specifiers the module imports or re-exports from with ES module
+	// syntax, sorted
+	Imports []string
+	// Every hydrated component with its directive, like "Counter client:load",
+	// in document order
+	Islands []string
+	// Hashes of the CSS of every style and of every hoisted script, as printed
+	Styles  []string
+	Scripts []string
+	// A hash of the whole module, which any change to the output changes
+	Output string
+}
+
+// Change is a difference between two summaries. Kind is "import", "island",
+// "style", "script" or "output", and Change is "added", "removed" or
+// "changed". Value is the import or the island, or the position of the style
+// or the script starting from 1.
+type Change struct {
+	Kind   string
+	Change string
+	Value  string
+}
+
+// Summarize compiles source and returns the summary of its module
+func Summarize(ctx context.Context, source string, opts transform.TransformOptions, h *handler.Handler) (Summary, error) {
+	summary := Summary{Version: astro.Version}
+	result, err := CompileWithHooks(ctx, source, opts, h, nil, func(doc *astro.Node) {
+		for _, island := range transform.Islands(doc) {
+			summary.Islands = append(summary.Islands, fmt.Sprintf("%s client:%s", island.Name, island.Directive))
+		}
+		for _, style := range doc.Styles {
+			summary.Styles = append(summary.Styles, astro.HashFromSource(textContent(style)))
+		}
+		for _, script := range doc.Scripts {
+			content := textContent(script)
+			if src := astro.GetAttribute(script, "src"); src != nil {
+				content = src.Val
+			}
+			summary.Scripts = append(summary.Scripts, astro.HashFromSource(content))
+		}
+	})
+	if err != nil {
+		return Summary{}, err
+	}
+	imports := make([]string, 0)
+	pos, statement := js_scanner.NextModuleStatement(result.Output, 0)
+	for pos != -1 {
+		imports = append(imports, statement.Specifier)
+		pos, statement = js_scanner.NextModuleStatement(result.Output, pos)
+	}
+	summary.Imports = uniqueSorted(imports)
+	if summary.Islands == nil {
+		summary.Islands = make([]string, 0)
+	}
+	if summary.Styles == nil {
+		summary.Styles = make([]string, 0)
+	}
+	if summary.Scripts == nil {
+		summary.Scripts = make([]string, 0)
+	}
+	summary.Output = astro.HashFromSource(string(result.Output))
+	return summary, nil
+}
+
+// Diff returns the changes from before to after: imports and islands which
+// were added or removed, and styles and scripts which were added, removed or
+// changed. If the output changed in any way, the last change is of the
+// "output", so a change which isn't one of the others isn't missed.
+func Diff(before Summary, after Summary) []Change {
+	changes := make([]Change, 0)
+	changes = append(changes, diffLists("import", before.Imports, after.Imports)...)
+	changes = append(changes, diffLists("island", before.Islands, after.Islands)...)
+	changes = append(changes, diffHashes("style", before.Styles, after.Styles)...)
+	changes = append(changes, diffHashes("script", before.Scripts, after.Scripts)...)
+	if before.Output != after.Output {
+		changes = append(changes, Change{Kind: "output", Change: "changed"})
+	}
+	return changes
+}
+
+// diffLists returns the values of before which after has fewer of as removed,
+// and the values of after which before has fewer of as added
+func diffLists(kind string, before []string, after []string) []Change {
+	counts := make(map[string]int)
+	for _, v := range before {
+		counts[v]++
+	}
+	changes := make([]Change, 0)
+	for _, v := range after {
+		if counts[v] > 0 {
+			counts[v]--
+			continue
+		}
+		changes = append(changes, Change{Kind: kind, Change: "added", Value: v})
+	}
+	for _, v := range before {
+		if counts[v] > 0 {
+			counts[v]--
+			changes = append(changes, Change{Kind: kind, Change: "removed", Value: v})
+		}
+	}
+	return changes
+}
+
+// diffHashes compares the hashes of before and after by their position
+func diffHashes(kind string, before []string, after []string) []Change {
+	changes := make([]Change, 0)
+	for i := 0; i < len(before) || i < len(after); i++ {
+		position := fmt.Sprint(i + 1)
+		switch {
+		case i >= len(before):
+			changes = append(changes, Change{Kind: kind, Change: "added", Value: position})
+		case i >= len(after):
+			changes = append(changes, Change{Kind: kind, Change: "removed", Value: position})
+		case before[i] != after[i]:
+			changes = append(changes, Change{Kind: kind, Change: "changed", Value: position})
+		}
+	}
+	return changes
+}
+
+func textContent(n *astro.Node) string {
+	if n.FirstChild == nil {
+		return ""
+	}
+	return n.FirstChild.Data
+}
@@ -0,0 +1,74 @@
+package compiler
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/snowpackjs/astro/internal/handler"
+	"github.com/snowpackjs/astro/internal/transform"
+)
+
+func TestDiff(t *testing.T) {
+	source := "---\nimport Counter from './Counter.jsx';\nimport Chart from './Chart.jsx';\n---\n<Counter client:load />{import.meta.env.DEV && <Chart client:idle />}\n<style>div { color: red; }</style><script>console.log(1);</script>"
+	tests := []struct {
+		name   string
+		before transform.TransformOptions
+		after  transform.TransformOptions
+		want   []Change
+	}{
+		{
+			name:   "same options",
+			before: transform.TransformOptions{Scope: "abc"},
+			after:  transform.TransformOptions{Scope: "abc"},
+			want:   []Change{},
+		},
+		{
+			name:   "dead branch",
+			before: transform.TransformOptions{Scope: "abc"},
+			after:  transform.TransformOptions{Scope: "abc", Define: map[string]string{"import.meta.env.DEV": "false"}},
+			want: []Change{
+				{Kind: "import", Change: "removed", Value: "./Chart.jsx"},
+				{Kind: "island", Change: "removed", Value: "Chart client:idle"},
+				{Kind: "output", Change: "changed"},
+			},
+		},
+		{
+			name:   "scoped style strategy",
+			before: transform.TransformOptions{Scope: "abc"},
+			after:  transform.TransformOptions{Scope: "abc", ScopedStyleStrategy: "where"},
+			want: []Change{
+				{Kind: "style", Change: "changed", Value: "1"},
+				{Kind: "output", Change: "changed"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, err := Summarize(context.Background(), source, tt.before, handler.NewHandler(source, "<stdin>"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			after, err := Summarize(context.Background(), source, tt.after, handler.NewHandler(source, "<stdin>"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := Diff(before, after); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("\nFAIL: %s\n  want: %+v\n  got:  %+v", tt.name, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDiffHashes(t *testing.T) {
+	before := Summary{Styles: []string{"a", "b"}, Scripts: []string{"c"}, Output: "o"}
+	after := Summary{Styles: []string{"a", "d", "e"}, Output: "o"}
+	want := []Change{
+		{Kind: "style", Change: "changed", Value: "2"},
+		{Kind: "style", Change: "added", Value: "3"},
+		{Kind: "script", Change: "removed", Value: "1"},
+	}
+	if got := Diff(before, after); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("\nFAIL: diff hashes\n  want: %+v\n  got:  %+v", want, got)
+	}
+}
@@ -0,0 +1,234 @@
+package compiler
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/handler"
+	"github.com/snowpackjs/astro/internal/js_scanner"
+	"github.com/snowpackjs/astro/internal/loc"
+	"github.com/snowpackjs/astro/internal/printer"
+	"github.com/snowpackjs/astro/internal/transform"
+)
+
+// File is a component of a project. Path is relative to the root of the
+// project, with forward slashes, like `src/components/Card.astro`.
+type File struct {
+	Path   string
+	Source string
+}
+
+// FileResult is a compiled File. A file which failed to compile has Err set,
+// with the diagnostics explaining why.
+type FileResult struct {
+	Path        string
+	Result      printer.PrintResult
+	Diagnostics []loc.DiagnosticMessage
+	Err         error
+}
+
+// Manifest describes the components of a project and how they depend on each
+// other, in the order the files were given
+type Manifest struct {
+	Components []ManifestComponent
+}
+
+type ManifestComponent struct {
+	Path  string
+	Scope string
+	// The components this one imports with a relative or root-relative
+	// specifier, in the order they're imported
+	Imports []ComponentImport
+	// The paths of the components of the project which import this one, sorted
+	Dependents []string
+}
+
+// ComponentImport is an import of an `.astro` file
+type ComponentImport struct {
+	// The name the default export is imported as, if it is
+	Name      string
+	Specifier string
+	// The path of the component of the project which Specifier resolves to,
+	// or "" if the project has no such file
+	Path string
+}
+
+type ProjectResult struct {
+	Files    []FileResult
+	Manifest Manifest
+	// Only set by Analyze
+	Analysis []Finding
+}
+
+// Project compiles a set of components which import each other. Every file
+// is parsed once, for both its output and its imports, and its scope is
+// derived from its source once. Results are cached by path, so a file whose
+// source hasn't changed since the last Compile isn't compiled again.
+type Project struct {
+	opts  transform.TransformOptions
+	mu    sync.Mutex
+	cache map[string]*projectEntry
+}
+
+type projectEntry struct {
+	source  string
+	scope   string
+	result  FileResult
+	imports []ComponentImport
+	info    componentInfo
+}
+
+// NewProject returns a Project which compiles every file with opts. The
+// Filename and Scope of opts are set for each file.
+func NewProject(opts transform.TransformOptions) *Project {
+	return &Project{opts: opts, cache: make(map[string]*projectEntry)}
+}
+
+// Compile compiles files, which replace the files of the previous call. A file
+// which fails to compile doesn't stop the others. The only error returned is
+// for files which can't be compiled together, or ctx.Err() if ctx is done.
+func (p *Project) Compile(ctx context.Context, files []File) (ProjectResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.compileFiles(ctx, files)
+}
+
+// Analyze compiles files like Compile does, and reports what the project
+// doesn't use in the Analysis of the result: components which nothing
+// imports, props which a component is passed but doesn't declare, and slots
+// which a component is passed but doesn't render
+func (p *Project) Analyze(ctx context.Context, files []File) (ProjectResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	result, err := p.compileFiles(ctx, files)
+	if err != nil {
+		return result, err
+	}
+	result.Analysis = p.analyze(result.Manifest)
+	return result, nil
+}
+
+func (p *Project) compileFiles(ctx context.Context, files []File) (ProjectResult, error) {
+	paths := make(map[string]bool, len(files))
+	for _, f := range files {
+		name := cleanPath(f.Path)
+		if paths[name] {
+			return ProjectResult{}, fmt.Errorf("the project has more than one file at %s", name)
+		}
+		paths[name] = true
+	}
+
+	cache := make(map[string]*projectEntry, len(files))
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return ProjectResult{}, err
+		}
+		name := cleanPath(f.Path)
+		entry, ok := p.cache[name]
+		if !ok || entry.source != f.Source {
+			entry = p.compile(ctx, name, f.Source)
+			// A compile which was abandoned isn't a result to keep
+			if err := ctx.Err(); err != nil {
+				return ProjectResult{}, err
+			}
+		}
+		cache[name] = entry
+	}
+	p.cache = cache
+
+	result := ProjectResult{Files: make([]FileResult, 0, len(files))}
+	dependents := make(map[string][]string)
+	for _, f := range files {
+		name := cleanPath(f.Path)
+		entry := cache[name]
+		result.Files = append(result.Files, entry.result)
+		component := ManifestComponent{Path: name, Scope: entry.scope, Imports: make([]ComponentImport, 0, len(entry.imports))}
+		for _, i := range entry.imports {
+			if resolved := resolveImport(name, i.Specifier); paths[resolved] {
+				i.Path = resolved
+				dependents[resolved] = append(dependents[resolved], name)
+			}
+			component.Imports = append(component.Imports, i)
+		}
+		result.Manifest.Components = append(result.Manifest.Components, component)
+	}
+	for i, component := range result.Manifest.Components {
+		result.Manifest.Components[i].Dependents = uniqueSorted(dependents[component.Path])
+	}
+	return result, nil
+}
+
+func (p *Project) compile(ctx context.Context, name string, source string) *projectEntry {
+	entry := &projectEntry{source: source, scope: astro.HashFromSource(source), imports: make([]ComponentImport, 0)}
+	opts := p.opts
+	opts.Filename = name
+	opts.Scope = entry.scope
+	h := handler.NewHandler(source, name)
+	result, err := CompileWithHooks(ctx, source, opts, h, func(doc *astro.Node) {
+		entry.imports = componentImports(doc)
+		entry.info = collectComponentInfo(doc, source)
+	}, nil)
+	entry.result = FileResult{Path: name, Result: result, Diagnostics: h.Diagnostics(), Err: err}
+	return entry
+}
+
+// componentImports returns the local `.astro` imports of the frontmatter of doc
+func componentImports(doc *astro.Node) []ComponentImport {
+	imports := make([]ComponentImport, 0)
+	for c := doc.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != astro.FrontmatterNode || c.FirstChild == nil {
+			continue
+		}
+		source := []byte(c.FirstChild.Data)
+		pos, statement := js_scanner.NextImportStatement(source, 0)
+		for pos != -1 {
+			if isLocalComponent(statement.Specifier) {
+				i := ComponentImport{Specifier: statement.Specifier}
+				for _, imported := range statement.Imports {
+					if imported.ExportName == "default" {
+						i.Name = imported.LocalName
+					}
+				}
+				imports = append(imports, i)
+			}
+			pos, statement = js_scanner.NextImportStatement(source, pos)
+		}
+	}
+	return imports
+}
+
+func isLocalComponent(specifier string) bool {
+	local := strings.HasPrefix(specifier, "./") || strings.HasPrefix(specifier, "../") || strings.HasPrefix(specifier, "/")
+	return local && strings.HasSuffix(specifier, ".astro")
+}
+
+// resolveImport returns the project path specifier refers to from the file at
+// from. Root-relative specifiers are relative to the root of the project.
+func resolveImport(from string, specifier string) string {
+	if strings.HasPrefix(specifier, "/") {
+		return cleanPath(specifier)
+	}
+	return cleanPath(path.Join(path.Dir(from), specifier))
+}
+
+func cleanPath(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(p, "\\", "/")), "/")
+}
+
+func uniqueSorted(values []string) []string {
+	result := make([]string, 0, len(values))
+	seen := make(map[string]bool, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
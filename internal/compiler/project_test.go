@@ -0,0 +1,159 @@
+package compiler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/test_utils"
+	"github.com/snowpackjs/astro/internal/transform"
+)
+
+func TestProjectCompile(t *testing.T) {
+	files := []File{
+		{Path: "src/pages/index.astro", Source: "---\nimport Layout from '../layouts/Layout.astro';\nimport Card from '/src/components/Card.astro';\nimport Missing from './Missing.astro';\nimport React from './Counter.jsx';\n---\n<Layout><Card /></Layout>"},
+		{Path: "src/layouts/Layout.astro", Source: "---\nimport '../components/Card.astro';\n---\n<html><body><slot /></body></html>"},
+		{Path: "./src/components/Card.astro", Source: "<div class=\"card\">Card</div><style>.card{color:red}</style>"},
+	}
+	result, err := NewProject(transform.TransformOptions{}).Compile(context.Background(), files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Manifest{Components: []ManifestComponent{
+		{
+			Path:  "src/pages/index.astro",
+			Scope: astro.HashFromSource(files[0].Source),
+			Imports: []ComponentImport{
+				{Name: "Layout", Specifier: "../layouts/Layout.astro", Path: "src/layouts/Layout.astro"},
+				{Name: "Card", Specifier: "/src/components/Card.astro", Path: "src/components/Card.astro"},
+				{Name: "Missing", Specifier: "./Missing.astro"},
+			},
+			Dependents: []string{},
+		},
+		{
+			Path:       "src/layouts/Layout.astro",
+			Scope:      astro.HashFromSource(files[1].Source),
+			Imports:    []ComponentImport{{Specifier: "../components/Card.astro", Path: "src/components/Card.astro"}},
+			Dependents: []string{"src/pages/index.astro"},
+		},
+		{
+			Path:       "src/components/Card.astro",
+			Scope:      astro.HashFromSource(files[2].Source),
+			Imports:    []ComponentImport{},
+			Dependents: []string{"src/layouts/Layout.astro", "src/pages/index.astro"},
+		},
+	}}
+	if diff := test_utils.ANSIDiff(want, result.Manifest); diff != "" {
+		t.Error(fmt.Sprintf("manifest mismatch (-want +got):\n%s", diff))
+	}
+	card := result.Files[2]
+	if card.Err != nil || card.Path != "src/components/Card.astro" {
+		t.Errorf("\nFAIL: card\n  got: %+v", card)
+	}
+	if scope := "astro-" + want.Components[2].Scope; !strings.Contains(string(card.Result.Output), scope) {
+		t.Errorf("\nFAIL: card\n  want to contain: %s\n  got: %s", scope, card.Result.Output)
+	}
+}
+
+func TestProjectCache(t *testing.T) {
+	project := NewProject(transform.TransformOptions{})
+	files := []File{
+		{Path: "a.astro", Source: "<p>a</p>"},
+		{Path: "b.astro", Source: "<p>b</p>"},
+	}
+	first, err := project.Compile(context.Background(), files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files[1].Source = "<p>changed</p>"
+	second, err := project.Compile(context.Background(), files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Cached results share their output, recompiled ones don't
+	if &first.Files[0].Result.Output[0] != &second.Files[0].Result.Output[0] {
+		t.Error("\nFAIL: cache\n  want an unchanged file to be reused")
+	}
+	if !strings.Contains(string(second.Files[1].Result.Output), "changed") {
+		t.Errorf("\nFAIL: cache\n  want a changed file to be compiled again\n  got: %s", second.Files[1].Result.Output)
+	}
+}
+
+func TestProjectErrors(t *testing.T) {
+	_, err := NewProject(transform.TransformOptions{}).Compile(context.Background(), []File{
+		{Path: "a.astro", Source: "<p>a</p>"},
+		{Path: "./a.astro", Source: "<p>b</p>"},
+	})
+	if want := "the project has more than one file at a.astro"; err == nil || err.Error() != want {
+		t.Errorf("\nFAIL: duplicate\n  want: %s\n  got:  %v", want, err)
+	}
+
+	result, err := NewProject(transform.TransformOptions{MaxInputSize: 10}).Compile(context.Background(), []File{
+		{Path: "small.astro", Source: "<p>a</p>"},
+		{Path: "large.astro", Source: "<p>Hello world</p>"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Files[0].Err != nil || result.Files[1].Err == nil {
+		t.Errorf("\nFAIL: file error\n  want only large.astro to fail\n  got: %v, %v", result.Files[0].Err, result.Files[1].Err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewProject(transform.TransformOptions{}).Compile(ctx, []File{{Path: "a.astro", Source: "<p>a</p>"}}); err != context.Canceled {
+		t.Errorf("\nFAIL: canceled\n  want: %v\n  got:  %v", context.Canceled, err)
+	}
+}
+
+func TestProjectAnalyze(t *testing.T) {
+	files := []File{
+		{Path: "src/pages/index.astro", Source: `---
+import Card from '../components/Card.astro';
+import Open from '../components/Open.astro';
+import Dynamic from '../components/Dynamic.astro';
+---
+<Card title="a" subtitle="b" client:load class:list={["x"]}>
+	<p>Body</p>
+	<span slot="footer">Footer</span>
+	<span slot="header">Header</span>
+</Card>
+<Card title="a" {...rest} />
+<Open anything="a"><p>Body</p></Open>
+<Dynamic><p slot="x">a</p></Dynamic>`},
+		{Path: "src/components/Card.astro", Source: `---
+interface Props {
+	title: string;
+	class?: string;
+}
+---
+<div><slot name="header" /></div>`},
+		{Path: "src/components/Open.astro", Source: "<div><slot /></div>"},
+		{Path: "src/components/Dynamic.astro", Source: "---\nconst html = await Astro.slots.render('x');\n---\n<div set:html={html} />"},
+		{Path: "src/components/Unused.astro", Source: "<div />"},
+	}
+	result, err := NewProject(transform.TransformOptions{}).Analyze(context.Background(), files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, 0)
+	for _, f := range result.Analysis {
+		got = append(got, fmt.Sprintf("%s:%d:%d: %s", f.Path, f.Diagnostic.Location.Line, f.Diagnostic.Location.Column, f.Diagnostic.Text))
+	}
+	want := []string{
+		"src/pages/index.astro:6:17: Card is passed the prop subtitle, which its Props don't declare",
+		"src/pages/index.astro:7:2: Card doesn't render its default slot, so its children are never rendered",
+		`src/pages/index.astro:8:8: Card doesn't render a slot named "footer", so its content is never rendered`,
+		"src/components/Unused.astro:1:1: Component src/components/Unused.astro is never imported",
+	}
+	if diff := test_utils.ANSIDiff(want, got); diff != "" {
+		t.Error(fmt.Sprintf("analysis mismatch (-want +got):\n%s", diff))
+	}
+
+	compiled, err := NewProject(transform.TransformOptions{}).Compile(context.Background(), files)
+	if err != nil || compiled.Analysis != nil {
+		t.Errorf("\nFAIL: compile\n  want no analysis\n  got: %v %v", compiled.Analysis, err)
+	}
+}
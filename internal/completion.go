@@ -0,0 +1,307 @@
+package astro
+
+import (
+	"strings"
+
+	"github.com/snowpackjs/astro/internal/js_scanner"
+)
+
+// CompletionContext is what can be typed at a position of a component, for
+// completion providers which would otherwise need an error-tolerant parser of
+// their own
+type CompletionContext struct {
+	// "frontmatter", "tag-name", "end-tag-name", "attribute-name",
+	// "attribute-value", "expression" or "text"
+	Kind string
+	// What has been typed from Start to the position, which a completion
+	// replaces: a tag or attribute name, an attribute value or the member
+	// expression before the position, like `Astro.props.`
+	Prefix string
+	Start  int
+	// The tag that the position is in, or else the closest open element,
+	// which is the one that an end tag closes
+	Tag string
+	// The attribute whose value the position is in
+	Attribute string
+	// The attributes of the tag which come before the position
+	Attributes []string
+	// The elements open at the position, outermost first
+	Parents []string
+	// The names the frontmatter declares or imports, which the template can
+	// refer to
+	Scope []string
+}
+
+// Completion returns the context of the position offset of source. Only the
+// source before offset decides the context, so the rest of it may be
+// incomplete or not written yet.
+func Completion(source string, offset int) CompletionContext {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(source) {
+		offset = len(source)
+	}
+	c := CompletionContext{Kind: "text", Start: offset, Attributes: make([]string, 0), Parents: make([]string, 0), Scope: frontmatterScope(source)}
+	before := source[:offset]
+
+	z := NewTokenizer(strings.NewReader(before))
+	var last Token
+	var lastType TokenType
+	var raw string
+	fences, depth := 0, 0
+	for {
+		tt := z.Next()
+		if tt == ErrorToken {
+			break
+		}
+		raw = string(z.Raw())
+		t := z.Token()
+		switch tt {
+		case StartTagToken:
+			if tagClosed(raw) {
+				c.Parents = append(c.Parents, t.Data)
+			}
+		case EndTagToken:
+			if !strings.HasSuffix(raw, ">") {
+				break
+			}
+			for i := len(c.Parents) - 1; i >= 0; i-- {
+				if c.Parents[i] == t.Data {
+					c.Parents = c.Parents[:i]
+					break
+				}
+			}
+		case StartExpressionToken:
+			depth++
+		case EndExpressionToken:
+			depth--
+		case FrontmatterFenceToken:
+			fences++
+		}
+		last, lastType = t, tt
+	}
+	if len(c.Parents) > 0 {
+		c.Tag = c.Parents[len(c.Parents)-1]
+	}
+
+	// The token which ends at offset is the one being typed
+	atEnd := lastType != ErrorToken && last.Loc.Start+len(raw) == offset
+	switch {
+	case fences == 1:
+		c.Kind, c.Tag, c.Parents = "frontmatter", "", make([]string, 0)
+		c.Start = memberStart(before)
+		c.Prefix = before[c.Start:]
+	case atEnd && (lastType == StartTagToken || lastType == SelfClosingTagToken) && !tagClosed(raw):
+		completeTag(&c, before, last.Loc.Start)
+	case atEnd && lastType == EndTagToken && !strings.HasSuffix(raw, ">"):
+		c.Kind, c.Start, c.Prefix = "end-tag-name", last.Loc.Start+len("</"), last.Data
+	case atEnd && lastType == TextToken && !isRawTextTag(c.Tag, depth) && partialTag(raw) != -1:
+		start := last.Loc.Start + partialTag(raw) + 1
+		c.Kind = "tag-name"
+		if start < len(before) && before[start] == '/' {
+			c.Kind = "end-tag-name"
+			start++
+		}
+		c.Start, c.Prefix = start, before[start:]
+	case depth > 0:
+		c.Kind = "expression"
+		c.Start = memberStart(before)
+		c.Prefix = before[c.Start:]
+	}
+	return c
+}
+
+// completeTag sets the context of the start tag at before[start:], which is
+// still being typed
+func completeTag(c *CompletionContext, before string, start int) {
+	s := before[start:]
+	i := 1
+	for i < len(s) && !isTagSpace(s[i]) && s[i] != '/' && s[i] != '>' {
+		i++
+	}
+	if i == len(s) {
+		c.Kind, c.Start, c.Prefix = "tag-name", start+1, s[1:]
+		return
+	}
+	c.Tag = s[1:i]
+	expression := func(attribute string) {
+		c.Kind, c.Attribute = "expression", attribute
+		c.Start = memberStart(before)
+		c.Prefix = before[c.Start:]
+	}
+	// An attribute name or a new one may follow, until a value is started
+	c.Kind, c.Start, c.Prefix = "attribute-name", len(before), ""
+	for i < len(s) {
+		switch ch := s[i]; {
+		case isTagSpace(ch) || ch == '/':
+			i++
+		case ch == '{':
+			end := js_scanner.MatchingBrace([]byte(s), i)
+			if end == -1 {
+				expression("")
+				return
+			}
+			i = end + 1
+		default:
+			keyStart := i
+			for i < len(s) && !isTagSpace(s[i]) && s[i] != '=' && s[i] != '/' && s[i] != '>' {
+				i++
+			}
+			key := s[keyStart:i]
+			if i == len(s) {
+				c.Start, c.Prefix = start+keyStart, key
+				return
+			}
+			c.Attributes = append(c.Attributes, key)
+			j := i
+			for j < len(s) && isTagSpace(s[j]) {
+				j++
+			}
+			if j == len(s) || s[j] != '=' {
+				i = j
+				continue
+			}
+			j++
+			for j < len(s) && isTagSpace(s[j]) {
+				j++
+			}
+			if j == len(s) {
+				c.Kind, c.Attribute = "attribute-value", key
+				return
+			}
+			switch q := s[j]; q {
+			case '"', '\'', '`':
+				end := strings.IndexByte(s[j+1:], q)
+				if end == -1 {
+					if q == '`' {
+						expression(key)
+						return
+					}
+					c.Kind, c.Attribute, c.Start, c.Prefix = "attribute-value", key, start+j+1, s[j+1:]
+					return
+				}
+				i = j + 1 + end + 1
+			case '{':
+				end := js_scanner.MatchingBrace([]byte(s), j)
+				if end == -1 {
+					expression(key)
+					return
+				}
+				i = end + 1
+			default:
+				i = j
+				for i < len(s) && !isTagSpace(s[i]) && s[i] != '>' {
+					i++
+				}
+				if i == len(s) {
+					c.Kind, c.Attribute, c.Start, c.Prefix = "attribute-value", key, start+j, s[j:]
+					return
+				}
+			}
+		}
+	}
+}
+
+// tagClosed reports whether the `>` of the tag raw has been typed, outside of
+// quoted values and expressions
+func tagClosed(raw string) bool {
+	for i := 1; i < len(raw); i++ {
+		switch c := raw[i]; c {
+		case '>':
+			return true
+		case '"', '\'', '`':
+			end := strings.IndexByte(raw[i+1:], c)
+			if end == -1 {
+				return false
+			}
+			i += end + 1
+		case '{':
+			end := js_scanner.MatchingBrace([]byte(raw), i)
+			if end == -1 {
+				return false
+			}
+			i = end
+		}
+	}
+	return false
+}
+
+// partialTag returns the position of the `<` of a tag at the end of text whose
+// name hasn't been finished, or -1 if there is none
+func partialTag(text string) int {
+	i := strings.LastIndexByte(text, '<')
+	if i == -1 {
+		return -1
+	}
+	name := strings.TrimPrefix(text[i+1:], "/")
+	for j := 0; j < len(name); j++ {
+		if !isTagNamePart(name[j]) {
+			return -1
+		}
+	}
+	return i
+}
+
+// isRawTextTag reports whether text in tag isn't markup, like the contents of
+// a <style>
+func isRawTextTag(tag string, depth int) bool {
+	return depth == 0 && (tag == "style" || tag == "script" || tag == "textarea" || tag == "title")
+}
+
+// memberStart returns where the identifier or member expression at the end of
+// s starts
+func memberStart(s string) int {
+	i := len(s)
+	for i > 0 && (isIdentifierPart(s[i-1]) || s[i-1] == '.') {
+		i--
+	}
+	// Not the dots of a spread
+	for i < len(s) && s[i] == '.' {
+		i++
+	}
+	return i
+}
+
+// frontmatterScope returns the names declared in the frontmatter of source
+func frontmatterScope(source string) []string {
+	var b strings.Builder
+	z := NewTokenizer(strings.NewReader(source))
+	fences := 0
+	for fences < 2 {
+		tt := z.Next()
+		if tt == ErrorToken {
+			break
+		}
+		switch {
+		case tt == FrontmatterFenceToken:
+			fences++
+		case fences == 1:
+			b.Write(z.Raw())
+		case tt != TextToken || strings.TrimSpace(string(z.Raw())) != "":
+			// The frontmatter must come first
+			fences = 2
+		}
+	}
+	scope := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, name := range js_scanner.Declarations([]byte(b.String())) {
+		if !seen[name] {
+			seen[name] = true
+			scope = append(scope, name)
+		}
+	}
+	return scope
+}
+
+func isTagSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func isTagNamePart(c byte) bool {
+	return isIdentifierPart(c) || c == '-' || c == '.' || c == ':'
+}
+
+func isIdentifierPart(c byte) bool {
+	return c == '_' || c == '$' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c >= 0x80
+}
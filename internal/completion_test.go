@@ -0,0 +1,103 @@
+package astro
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCompletion(t *testing.T) {
+	tests := []struct {
+		name string
+		// The position is at the `|`
+		source string
+		want   CompletionContext
+	}{
+		{
+			name:   "frontmatter",
+			source: "---\nimport Card from './Card.astro';\nconst title = 'Hi';\nAstro.pr|\n---\n<div />",
+			want:   CompletionContext{Kind: "frontmatter", Prefix: "Astro.pr", Start: 57, Scope: []string{"Card", "title"}},
+		},
+		{
+			name:   "tag name",
+			source: "<main>\n  <Ca|",
+			want:   CompletionContext{Kind: "tag-name", Prefix: "Ca", Start: 10, Tag: "main", Parents: []string{"main"}},
+		},
+		{
+			name:   "tag name being typed in a tag",
+			source: "<ma| class=\"a\">",
+			want:   CompletionContext{Kind: "tag-name", Prefix: "ma", Start: 1},
+		},
+		{
+			name:   "end tag name",
+			source: "<main><div></|</main>",
+			want:   CompletionContext{Kind: "end-tag-name", Start: 13, Tag: "div", Parents: []string{"main", "div"}},
+		},
+		{
+			name:   "attribute name",
+			source: "<div class=\"a\" data-|>",
+			want:   CompletionContext{Kind: "attribute-name", Prefix: "data-", Start: 15, Tag: "div", Attributes: []string{"class"}},
+		},
+		{
+			name:   "new attribute",
+			source: "<Card title={title} |",
+			want:   CompletionContext{Kind: "attribute-name", Start: 20, Tag: "Card", Attributes: []string{"title"}},
+		},
+		{
+			name:   "quoted attribute value",
+			source: "<div class=\"a b|\">",
+			want:   CompletionContext{Kind: "attribute-value", Prefix: "a b", Start: 12, Tag: "div", Attribute: "class", Attributes: []string{"class"}},
+		},
+		{
+			name:   "attribute value with a >",
+			source: "<div title=\"a > b\" class=\"|",
+			want:   CompletionContext{Kind: "attribute-value", Start: 26, Tag: "div", Attribute: "class", Attributes: []string{"title", "class"}},
+		},
+		{
+			name:   "expression attribute",
+			source: "---\nconst post = {};\n---\n<a href={post.|}>",
+			want:   CompletionContext{Kind: "expression", Prefix: "post.", Start: 34, Tag: "a", Attribute: "href", Attributes: []string{"href"}, Scope: []string{"post"}},
+		},
+		{
+			name:   "spread attribute",
+			source: "<div {...pro|",
+			want:   CompletionContext{Kind: "expression", Prefix: "pro", Start: 9, Tag: "div"},
+		},
+		{
+			name:   "expression",
+			source: "<ul>{items.map(item => <li>{item.|}</li>)}</ul>",
+			want:   CompletionContext{Kind: "expression", Prefix: "item.", Start: 28, Tag: "li", Parents: []string{"ul", "li"}},
+		},
+		{
+			name:   "text",
+			source: "<p>Hello <b>world</b> |</p>",
+			want:   CompletionContext{Kind: "text", Start: 22, Tag: "p", Parents: []string{"p"}},
+		},
+		{
+			name:   "text of a style",
+			source: "<style>a <|</style>",
+			want:   CompletionContext{Kind: "text", Start: 10, Tag: "style", Parents: []string{"style"}},
+		},
+		{
+			name:   "void element",
+			source: "<div><img src=\"a.png\">|",
+			want:   CompletionContext{Kind: "text", Start: 22, Tag: "div", Parents: []string{"div"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offset := strings.Index(tt.source, "|")
+			source := tt.source[:offset] + tt.source[offset+1:]
+			want := tt.want
+			for _, list := range []*[]string{&want.Attributes, &want.Parents, &want.Scope} {
+				if *list == nil {
+					*list = make([]string, 0)
+				}
+			}
+			got := Completion(source, offset)
+			if fmt.Sprintf("%+v", got) != fmt.Sprintf("%+v", want) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %+v\n  got:  %+v", tt.name, want, got))
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package astro
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var nodeTypeNames = map[NodeType]string{
+	ErrorNode:                 "Error",
+	TextNode:                  "Text",
+	DocumentNode:              "Document",
+	ElementNode:               "Element",
+	CommentNode:               "Comment",
+	DoctypeNode:               "Doctype",
+	RawNode:                   "Raw",
+	FrontmatterNode:           "Frontmatter",
+	ExpressionNode:            "Expression",
+	ProcessingInstructionNode: "ProcessingInstruction",
+	CDATANode:                 "CDATA",
+}
+
+// Dump writes the tree of n to w in a readable form, for debugging how a
+// template was parsed or transformed. Each node is a line, indented by its
+// depth, with its type, its tag or content, and its locations after an `@`,
+// which are the start of its start tag and of its end tag for elements.
+// Attributes follow with the location of their key, and the flags of elements
+// are in parentheses, along with their namespace. The hoisted styles and
+// scripts of a document are dumped after its tree.
+func Dump(w io.Writer, n *Node) error {
+	b := bufio.NewWriter(w)
+	dumpTree(b, n, 0)
+	if n.Type == DocumentNode {
+		for _, hoisted := range []struct {
+			name  string
+			nodes []*Node
+		}{{"styles", n.Styles}, {"scripts", n.Scripts}} {
+			// Styles and scripts stay in the tree until they're hoisted
+			if len(hoisted.nodes) == 0 || hoisted.nodes[0].Parent != nil {
+				continue
+			}
+			fmt.Fprintf(b, "Hoisted %s\n", hoisted.name)
+			for _, c := range hoisted.nodes {
+				dumpTree(b, c, 1)
+			}
+		}
+	}
+	return b.Flush()
+}
+
+func dumpTree(b *bufio.Writer, n *Node, depth int) {
+	b.WriteString(strings.Repeat("  ", depth))
+	name, ok := nodeTypeNames[n.Type]
+	if !ok {
+		name = fmt.Sprintf("Node(%d)", n.Type)
+	}
+	b.WriteString(name)
+	switch n.Type {
+	case ElementNode:
+		fmt.Fprintf(b, " <%s>", n.Data)
+	case DocumentNode, FrontmatterNode:
+	default:
+		fmt.Fprintf(b, " %q", n.Data)
+	}
+	if len(n.Loc) > 0 {
+		starts := make([]string, 0, len(n.Loc))
+		for _, l := range n.Loc {
+			starts = append(starts, fmt.Sprint(l.Start))
+		}
+		fmt.Fprintf(b, " @%s", strings.Join(starts, ","))
+	}
+	implicit := false
+	for _, attr := range n.Attr {
+		if attr.Key == ImplicitNodeMarker {
+			implicit = true
+			continue
+		}
+		fmt.Fprintf(b, " %s@%d", dumpAttribute(attr), attr.KeyLoc.Start)
+	}
+	flags := make([]string, 0)
+	if n.Namespace != "" {
+		flags = append(flags, n.Namespace)
+	}
+	for _, flag := range []struct {
+		name string
+		set  bool
+	}{
+		{"implicit", implicit},
+		{"component", n.Component},
+		{"custom-element", n.CustomElement},
+		{"expression", n.Expression},
+		{"fragment", n.Fragment},
+	} {
+		if flag.set {
+			flags = append(flags, flag.name)
+		}
+	}
+	if len(flags) > 0 {
+		fmt.Fprintf(b, " (%s)", strings.Join(flags, ", "))
+	}
+	b.WriteByte('\n')
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		dumpTree(b, c, depth+1)
+	}
+}
+
+// dumpAttribute returns attr as it would be authored
+func dumpAttribute(attr Attribute) string {
+	key := attr.Key
+	if attr.Namespace != "" {
+		key = attr.Namespace + ":" + key
+	}
+	switch attr.Type {
+	case EmptyAttribute:
+		return key
+	case ExpressionAttribute:
+		return fmt.Sprintf("%s={%s}", key, attr.Val)
+	case SpreadAttribute:
+		return fmt.Sprintf("{...%s}", strings.TrimSpace(attr.Key))
+	case ShorthandAttribute:
+		return fmt.Sprintf("{%s}", strings.TrimSpace(attr.Key))
+	case TemplateLiteralAttribute:
+		return fmt.Sprintf("%s=`%s`", key, attr.Val)
+	}
+	return fmt.Sprintf("%s=%q", key, attr.Val)
+}
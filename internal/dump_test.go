@@ -0,0 +1,69 @@
+package astro
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDump(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "frontmatter",
+			source: "---\nconst a = 1;\n---\n<div>{a}</div>",
+			want: []string{
+				"Document",
+				"  Frontmatter @0,20",
+				"    Text \"\\nconst a = 1;\\n\" @3",
+				"  Element <html> @0 (implicit)",
+				"    Element <head> @0,0 (implicit)",
+				"    Element <body> @0 (implicit)",
+				"      Element <div> @21,29",
+				"        Element <astro:expression> @26,28 (expression)",
+				"          Text \"a\" @27",
+			},
+		},
+		{
+			name:   "attributes",
+			source: "<Card {...props} {a} b={a} c=`x` d=\"e\" f client:load />",
+			want: []string{
+				"Document",
+				"  Frontmatter @0",
+				"  Element <Card> @0 {...props}@10 {a}@18 b={a}@21 c=`x`@27 d=\"e\"@33 f@39 client:load@41 (component)",
+			},
+		},
+		{
+			name:   "namespace",
+			source: "<svg><path d=\"m\" /></svg>",
+			want: []string{
+				"Document",
+				"  Frontmatter @0",
+				"  Element <html> @0 (implicit)",
+				"    Element <head> @0,0 (implicit)",
+				"    Element <body> @0 (implicit)",
+				"      Element <svg> @0 (svg)",
+				"        Element <path> @5 d=\"m\"@11 (svg)",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var b strings.Builder
+			if err := Dump(&b, doc); err != nil {
+				t.Fatal(err)
+			}
+			want := strings.Join(tt.want, "\n") + "\n"
+			if got := b.String(); got != want {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want:\n%s\n  got:\n%s", tt.name, want, got))
+			}
+		})
+	}
+}
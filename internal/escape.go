@@ -253,3 +253,14 @@ func UnescapeString(s string) string {
 	}
 	return s
 }
+
+// UnescapeAttributeString is like UnescapeString, but follows the rules for
+// attribute values. For example, "&amp=" is left as-is inside of an attribute.
+func UnescapeAttributeString(s string) string {
+	for _, c := range s {
+		if c == '&' {
+			return string(unescape([]byte(s), true))
+		}
+	}
+	return s
+}
@@ -0,0 +1,152 @@
+// Package escape escapes text for each of the contexts the printer writes it
+// into, so that the printed code evaluates to exactly the original text.
+package escape
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+var templateLiteralReplacer = strings.NewReplacer(`\`, `\\`, "`", "\\`", "${", `\${`)
+
+// TemplateLiteral escapes str for the inside of a JavaScript template literal
+func TemplateLiteral(str string) string {
+	return templateLiteralReplacer.Replace(str)
+}
+
+var doubleQuotedReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\u2028", `\u2028`, "\u2029", `\u2029`)
+
+// DoubleQuoted escapes str for the inside of a double-quoted JavaScript string
+func DoubleQuoted(str string) string {
+	return doubleQuotedReplacer.Replace(str)
+}
+
+var singleQuotedReplacer = strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`, "\r", `\r`, "\u2028", `\u2028`, "\u2029", `\u2029`)
+
+// SingleQuoted escapes str for the inside of a single-quoted JavaScript string
+func SingleQuoted(str string) string {
+	return singleQuotedReplacer.Replace(str)
+}
+
+// JSONString returns str as a double-quoted string literal, which JSON and
+// JavaScript read the same way, so no character of str can end it early or
+// change what it evaluates to. Invalid UTF-8 is replaced with U+FFFD.
+func JSONString(str string) string {
+	var b strings.Builder
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(str)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Requote escapes body, the inside of a JavaScript string literal as it was
+// authored with either kind of quotes, for the inside of a literal quoted
+// with quote. Escape sequences are kept as they are, so it evaluates to the
+// same string.
+func Requote(body string, quote byte) string {
+	if strings.IndexByte(body, quote) == -1 {
+		return body
+	}
+	var b strings.Builder
+	for i := 0; i < len(body); i++ {
+		switch c := body[i]; {
+		case c == '\\' && i+1 < len(body):
+			b.WriteByte(c)
+			i++
+			b.WriteByte(body[i])
+		case c == quote:
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+var htmlTextReplacer = strings.NewReplacer("&", "&amp;", "<", "&lt;")
+
+// HTMLText encodes the characters of str which would otherwise be read as
+// markup in HTML text
+func HTMLText(str string) string {
+	return htmlTextReplacer.Replace(str)
+}
+
+var htmlAttrReplacer = strings.NewReplacer("&", "&amp;", `"`, "&quot;")
+
+// HTMLAttr encodes the characters of str which would otherwise be read as
+// markup in a double-quoted HTML attribute value
+func HTMLAttr(str string) string {
+	return htmlAttrReplacer.Replace(str)
+}
+
+// DoubleQuotes encodes only the double quotes in str, for attribute values
+// which are already encoded as authored and must be kept that way
+func DoubleQuotes(str string) string {
+	return strings.ReplaceAll(str, `"`, "&quot;")
+}
+
+// HTMLComment keeps str from ending the <!-- --> comment it's rendered into
+// early. Comments can't contain `--`, so every run of dashes is split with
+// spaces, and a comment can't start with `>` or end with a dash either.
+func HTMLComment(str string) string {
+	for strings.Contains(str, "--") {
+		str = strings.ReplaceAll(str, "--", "- -")
+	}
+	if strings.HasPrefix(str, ">") || strings.HasPrefix(str, "->") {
+		str = " " + str
+	}
+	if strings.HasSuffix(str, "-") || strings.HasSuffix(str, "<!") {
+		str += " "
+	}
+	return str
+}
+
+// ScriptContent keeps str from ending the <script> element it's rendered
+// into. A closing tag inside of JavaScript can only appear in a string,
+// regular expression, template or comment, where `<\/` means the same thing.
+func ScriptContent(str string) string {
+	return escapeClosingTag(str, "script")
+}
+
+// StyleContent keeps str from ending the <style> element it's rendered into.
+// A closing tag inside of CSS can only appear in a string or comment, where
+// `<\/` means the same thing.
+func StyleContent(str string) string {
+	return escapeClosingTag(str, "style")
+}
+
+// escapeClosingTag adds a backslash to every `</tag`, ignoring ASCII case like
+// the HTML tokenizer does
+func escapeClosingTag(str string, tag string) string {
+	var b strings.Builder
+	prev := 0
+	for i := 0; i+2+len(tag) <= len(str); i++ {
+		if str[i] != '<' || str[i+1] != '/' || !equalFoldASCII(str[i+2:i+2+len(tag)], tag) {
+			continue
+		}
+		b.WriteString(str[prev : i+1])
+		b.WriteString(`\`)
+		prev = i + 1
+	}
+	if prev == 0 {
+		return str
+	}
+	b.WriteString(str[prev:])
+	return b.String()
+}
+
+// equalFoldASCII reports whether str equals lower, a lowercase ASCII string,
+// ignoring the case of ASCII letters only
+func equalFoldASCII(str string, lower string) bool {
+	for i := 0; i < len(lower); i++ {
+		c := str[i]
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		if c != lower[i] {
+			return false
+		}
+	}
+	return true
+}
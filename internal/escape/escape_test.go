@@ -0,0 +1,187 @@
+package escape
+
+import (
+	"encoding/json"
+	"html"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+// Strings made mostly of characters which need escaping in some context
+var special = []rune{'/', 's', 't', 'S', 'T', '\\', '`', '$', '{', '}', '"', '\'', '<', '>', '&', ';', '#', 'a', '1', ' ', '\n', '\r', '\u2028', '\u2029', 'é', '😀'}
+
+func specialString(values []reflect.Value, r *rand.Rand) {
+	n := r.Intn(24)
+	runes := make([]rune, n)
+	for i := range runes {
+		runes[i] = special[r.Intn(len(special))]
+	}
+	values[0] = reflect.ValueOf(string(runes))
+}
+
+// check tests f with both arbitrary strings and strings of special characters
+func check(t *testing.T, f func(string) bool) {
+	t.Helper()
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+	if err := quick.Check(f, &quick.Config{MaxCount: 1000, Values: specialString}); err != nil {
+		t.Error(err)
+	}
+}
+
+// unescapeJS evaluates the escape sequences this package produces the same
+// way a JavaScript engine would
+func unescapeJS(str string) (string, bool) {
+	var b strings.Builder
+	for i := 0; i < len(str); i++ {
+		if str[i] != '\\' {
+			b.WriteByte(str[i])
+			continue
+		}
+		i++
+		if i == len(str) {
+			return "", false
+		}
+		switch str[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'u':
+			if i+4 >= len(str) {
+				return "", false
+			}
+			code, err := strconv.ParseUint(str[i+1:i+5], 16, 32)
+			if err != nil {
+				return "", false
+			}
+			b.WriteRune(rune(code))
+			i += 4
+		default:
+			b.WriteByte(str[i])
+		}
+	}
+	return b.String(), true
+}
+
+// hasUnescaped returns true if str contains target without a backslash before it
+func hasUnescaped(str string, target string) bool {
+	for i := 0; i < len(str); i++ {
+		if str[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.HasPrefix(str[i:], target) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestTemplateLiteral(t *testing.T) {
+	check(t, func(str string) bool {
+		escaped := TemplateLiteral(str)
+		got, ok := unescapeJS(escaped)
+		return ok && got == str && !hasUnescaped(escaped, "`") && !hasUnescaped(escaped, "${")
+	})
+}
+
+func TestDoubleQuoted(t *testing.T) {
+	check(t, func(str string) bool {
+		escaped := DoubleQuoted(str)
+		got, ok := unescapeJS(escaped)
+		return ok && got == str && !hasUnescaped(escaped, `"`) && !strings.ContainsAny(escaped, "\n\r\u2028\u2029")
+	})
+}
+
+func TestSingleQuoted(t *testing.T) {
+	check(t, func(str string) bool {
+		escaped := SingleQuoted(str)
+		got, ok := unescapeJS(escaped)
+		return ok && got == str && !hasUnescaped(escaped, `'`) && !strings.ContainsAny(escaped, "\n\r\u2028\u2029")
+	})
+}
+
+func TestJSONString(t *testing.T) {
+	check(t, func(str string) bool {
+		escaped := JSONString(str)
+		var got string
+		err := json.Unmarshal([]byte(escaped), &got)
+		return err == nil && got == str && !strings.ContainsAny(escaped, "\n\r\u2028\u2029")
+	})
+	if got, want := JSONString(`</script> & "it's"`), `"</script> & \"it's\""`; got != want {
+		t.Errorf("\nFAIL: JSON string\n  want: %s\n  got:  %s", want, got)
+	}
+}
+
+func TestRequote(t *testing.T) {
+	check(t, func(str string) bool {
+		escaped := Requote(SingleQuoted(str), '"')
+		got, ok := unescapeJS(escaped)
+		return ok && got == str && !hasUnescaped(escaped, `"`)
+	})
+	if got, want := Requote(`it's \'quoted\'`, '\''), `it\'s \'quoted\'`; got != want {
+		t.Errorf("\nFAIL: requote\n  want: %s\n  got:  %s", want, got)
+	}
+}
+
+func TestHTMLText(t *testing.T) {
+	check(t, func(str string) bool {
+		escaped := HTMLText(str)
+		return html.UnescapeString(escaped) == str && !strings.Contains(escaped, "<")
+	})
+}
+
+func TestHTMLAttr(t *testing.T) {
+	check(t, func(str string) bool {
+		escaped := HTMLAttr(str)
+		return html.UnescapeString(escaped) == str && !strings.Contains(escaped, `"`)
+	})
+}
+
+func TestDoubleQuotes(t *testing.T) {
+	check(t, func(str string) bool {
+		escaped := DoubleQuotes(str)
+		return !strings.Contains(escaped, `"`) && strings.ReplaceAll(escaped, "&quot;", `"`) == strings.ReplaceAll(str, "&quot;", `"`)
+	})
+}
+
+func TestHTMLComment(t *testing.T) {
+	check(t, func(str string) bool {
+		escaped := HTMLComment(str)
+		return !strings.Contains(escaped, "--") && !strings.HasPrefix(escaped, ">") && !strings.HasSuffix(escaped, "-") &&
+			strings.ReplaceAll(strings.ReplaceAll(escaped, " ", ""), "-", "") == strings.ReplaceAll(strings.ReplaceAll(str, " ", ""), "-", "")
+	})
+	if got, want := HTMLComment(`a --> b <!-- c ---`), `a - -> b <!- - c - - - `; got != want {
+		t.Errorf("\nFAIL: HTML comment\n  want: %s\n  got:  %s", want, got)
+	}
+}
+
+func TestScriptContent(t *testing.T) {
+	check(t, func(str string) bool {
+		escaped := ScriptContent(str + "</sCRipt>" + str)
+		return !strings.Contains(strings.ToLower(escaped), "</script") && strings.ReplaceAll(escaped, `<\/`, "</") == strings.ReplaceAll(str+"</sCRipt>"+str, `<\/`, "</")
+	})
+	if got, want := ScriptContent(`s = "</script><!-- </SCRIPT"`), `s = "<\/script><!-- <\/SCRIPT"`; got != want {
+		t.Errorf("\nFAIL: script content\n  want: %s\n  got:  %s", want, got)
+	}
+}
+
+func TestStyleContent(t *testing.T) {
+	check(t, func(str string) bool {
+		escaped := StyleContent(str + "</style" + str)
+		return !strings.Contains(strings.ToLower(escaped), "</style") && strings.ReplaceAll(escaped, `<\/`, "</") == strings.ReplaceAll(str+"</style"+str, `<\/`, "</")
+	})
+	if got, want := StyleContent(`a::after { content: "</Style>" }`), `a::after { content: "<\/Style>" }`; got != want {
+		t.Errorf("\nFAIL: style content\n  want: %s\n  got:  %s", want, got)
+	}
+	// Ɥ is shorter in lowercase, which mustn't shift where the tag is found
+	if got, want := StyleContent(`Ɥ</style>`), `Ɥ<\/style>`; got != want {
+		t.Errorf("\nFAIL: style content\n  want: %s\n  got:  %s", want, got)
+	}
+}
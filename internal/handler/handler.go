@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/snowpackjs/astro/internal/loc"
+)
+
+// Handler collects the errors and warnings produced while compiling a single file
+type Handler struct {
+	sourcetext string
+	filename   string
+	errors     []error
+	warnings   []error
+}
+
+func NewHandler(sourcetext string, filename string) *Handler {
+	return &Handler{
+		sourcetext: sourcetext,
+		filename:   filename,
+		errors:     make([]error, 0),
+		warnings:   make([]error, 0),
+	}
+}
+
+func (h *Handler) HasErrors() bool {
+	return len(h.errors) > 0
+}
+
+func (h *Handler) AppendError(err error) {
+	h.errors = append(h.errors, err)
+}
+
+// AppendWarning adds a warning, unless an identical warning was already added.
+// Separate passes may check for the same problem, like missing alt text.
+func (h *Handler) AppendWarning(err error) {
+	for _, warning := range h.warnings {
+		if isSameDiagnostic(warning, err) {
+			return
+		}
+	}
+	h.warnings = append(h.warnings, err)
+}
+
+func isSameDiagnostic(a error, b error) bool {
+	var x, y *loc.ErrorWithRange
+	if !errors.As(a, &x) || !errors.As(b, &y) {
+		return false
+	}
+	return x.Code == y.Code && x.Range == y.Range
+}
+
+// PromoteWarnings turns every warning with one of the given codes into an error
+func (h *Handler) PromoteWarnings(codes ...loc.DiagnosticCode) {
+	warnings := make([]error, 0, len(h.warnings))
+	for _, warning := range h.warnings {
+		var rangedError *loc.ErrorWithRange
+		if errors.As(warning, &rangedError) && hasCode(codes, rangedError.Code) {
+			h.errors = append(h.errors, warning)
+		} else {
+			warnings = append(warnings, warning)
+		}
+	}
+	h.warnings = warnings
+}
+
+func hasCode(codes []loc.DiagnosticCode, code loc.DiagnosticCode) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Error returns an error which summarizes every error, or nil if there are none
+func (h *Handler) Error() error {
+	if !h.HasErrors() {
+		return nil
+	}
+	msgs := h.Errors()
+	text := msgs[0].Text
+	if location := msgs[0].Location; location != nil {
+		text = fmt.Sprintf("%s:%d:%d: %s", location.File, location.Line, location.Column, text)
+	}
+	if len(msgs) > 1 {
+		text = fmt.Sprintf("%s (and %d more errors)", text, len(msgs)-1)
+	}
+	return errors.New(text)
+}
+
+func (h *Handler) Errors() []loc.DiagnosticMessage {
+	return h.toMessages(h.errors, loc.ErrorType)
+}
+
+func (h *Handler) Warnings() []loc.DiagnosticMessage {
+	return h.toMessages(h.warnings, loc.WarningType)
+}
+
+// Diagnostics returns every error, followed by every warning
+func (h *Handler) Diagnostics() []loc.DiagnosticMessage {
+	return append(h.Errors(), h.Warnings()...)
+}
+
+func (h *Handler) toMessages(errs []error, severity loc.DiagnosticSeverity) []loc.DiagnosticMessage {
+	msgs := make([]loc.DiagnosticMessage, 0, len(errs))
+	for _, err := range errs {
+		msg := h.toMessage(err)
+		msg.Severity = int(severity)
+		// Errors without a code of their own get the code of their severity
+		if msg.Code == 0 {
+			code := loc.ERROR
+			if severity == loc.WarningType {
+				code = loc.WARNING
+			}
+			msg.Code, msg.ID, msg.Name = int(code), code.ID(), code.Name()
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+func (h *Handler) toMessage(err error) loc.DiagnosticMessage {
+	var rangedError *loc.ErrorWithRange
+	if !errors.As(err, &rangedError) {
+		return loc.DiagnosticMessage{Text: err.Error()}
+	}
+	msg := rangedError.ToMessage(h.location(rangedError.Range))
+	for _, fix := range rangedError.Fixes {
+		msg.Fixes = append(msg.Fixes, loc.DiagnosticFix{
+			Title:    fix.Title,
+			Location: h.location(fix.Range),
+			Text:     fix.Text,
+		})
+	}
+	return msg
+}
+
+func (h *Handler) location(r loc.Range) *loc.DiagnosticLocation {
+	start := r.Loc.Start
+	if start < 0 || start > len(h.sourcetext) {
+		return nil
+	}
+	lineStart := strings.LastIndexByte(h.sourcetext[:start], '\n') + 1
+	lineEnd := strings.IndexByte(h.sourcetext[start:], '\n')
+	if lineEnd == -1 {
+		lineEnd = len(h.sourcetext)
+	} else {
+		lineEnd += start
+	}
+	end := r.End()
+	if end > len(h.sourcetext) {
+		end = len(h.sourcetext)
+	}
+	return &loc.DiagnosticLocation{
+		File:     h.filename,
+		Line:     strings.Count(h.sourcetext[:start], "\n") + 1,
+		Column:   utf16Length(h.sourcetext[lineStart:start]) + 1,
+		Length:   utf16Length(h.sourcetext[start:end]),
+		LineText: strings.TrimSuffix(h.sourcetext[lineStart:lineEnd], "\r"),
+	}
+}
+
+// Count UTF-16 code units, which is how JavaScript measures strings
+func utf16Length(str string) int {
+	n := 0
+	for len(str) > 0 {
+		r, size := utf8.DecodeRuneInString(str)
+		str = str[size:]
+		if r > 0xFFFF {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/snowpackjs/astro/internal/loc"
+)
+
+func TestDiagnostics(t *testing.T) {
+	source := "---\n---\n<p>😀 <img src=\"a.png\"></p>"
+	h := NewHandler(source, "test.astro")
+	h.AppendWarning(&loc.ErrorWithRange{Code: loc.WARNING, Text: "warning", Range: loc.Range{Loc: loc.Loc{Start: 16}, Len: 4}})
+	h.AppendError(errors.New("error"))
+
+	diagnostics := h.Diagnostics()
+	if len(diagnostics) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %d", len(diagnostics))
+	}
+	if err := diagnostics[0]; err.Severity != int(loc.ErrorType) || err.Text != "error" || err.Location != nil {
+		t.Errorf("unexpected error %+v", err)
+	}
+
+	warning := diagnostics[1]
+	if warning.Severity != int(loc.WarningType) || warning.Code != int(loc.WARNING) {
+		t.Errorf("unexpected warning %+v", warning)
+	}
+	want := loc.DiagnosticLocation{File: "test.astro", Line: 3, Column: 7, Length: 4, LineText: "<p>😀 <img src=\"a.png\"></p>"}
+	if *warning.Location != want {
+		t.Errorf("Location = %+v\nExpected = %+v", *warning.Location, want)
+	}
+}
+
+func TestDuplicateWarnings(t *testing.T) {
+	h := NewHandler("<img>", "test.astro")
+	warning := loc.ErrorWithRange{Code: loc.WARNING_IMAGE_MISSING_ALT, Text: "missing alt", Range: loc.Range{Loc: loc.Loc{Start: 0}, Len: 4}}
+	duplicate := warning
+	other := warning
+	other.Code = loc.WARNING_IMAGE_MISSING_DIMENSIONS
+	h.AppendWarning(&warning)
+	h.AppendWarning(&duplicate)
+	h.AppendWarning(&other)
+
+	if warnings := h.Warnings(); len(warnings) != 2 {
+		t.Errorf("expected 2 warnings, got %d", len(warnings))
+	}
+}
+
+func TestDiagnosticCodes(t *testing.T) {
+	h := NewHandler("<p>", "test.astro")
+	h.AppendWarning(&loc.ErrorWithRange{Code: loc.WARNING_UNCLOSED_ELEMENT, Text: "unclosed", Range: loc.Range{Loc: loc.Loc{Start: 0}, Len: 2}})
+	h.AppendError(errors.New("error"))
+
+	got := make([]string, 0)
+	for _, d := range h.Diagnostics() {
+		got = append(got, d.ID+" "+d.Name)
+	}
+	want := []string{"AST1000 Error", "AST2005 UnclosedElement"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("codes = %v\nExpected = %v", got, want)
+	}
+}
@@ -0,0 +1,50 @@
+package astro
+
+import a "golang.org/x/net/html/atom"
+
+// Strings which appear in most documents but aren't HTML atoms, like
+// directives. This is only read after init, so tokenizers can share it.
+var commonStrings = map[string]string{}
+
+func init() {
+	for _, s := range []string{
+		"client:load", "client:idle", "client:visible", "client:media", "client:only",
+		"define:vars", "server:defer", "set:html", "set:text",
+		"is:raw", "is:inline", "is:global", "is:ignore", "hoist",
+		"transition:name", "transition:animate", "transition:persist",
+		"data-astro-raw", "Fragment", "Markdown",
+		"aria-label", "aria-hidden", "aria-describedby", "aria-labelledby",
+		"data-testid", "viewBox", "xmlns", "fill", "stroke", "d",
+	} {
+		commonStrings[s] = s
+	}
+}
+
+// Strings longer than this are unlikely to repeat, so they aren't interned
+const maxInternLength = 32
+
+// intern returns b as a string, sharing one copy of every tag name and
+// attribute key between the tokens of a document. Names which repeat, like
+// components used in a loop, are only allocated once, and equal names share a
+// pointer which makes comparing them cheap.
+func (z *Tokenizer) intern(b []byte) string {
+	if len(b) > maxInternLength {
+		return string(b)
+	}
+	if atom := a.Lookup(b); atom != 0 {
+		return atom.String()
+	}
+	// Map lookups with string(b) don't allocate
+	if s, ok := commonStrings[string(b)]; ok {
+		return s
+	}
+	if s, ok := z.interned[string(b)]; ok {
+		return s
+	}
+	if z.interned == nil {
+		z.interned = make(map[string]string)
+	}
+	s := string(b)
+	z.interned[s] = s
+	return s
+}
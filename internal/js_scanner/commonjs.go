@@ -0,0 +1,297 @@
+package js_scanner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tdewolff/parse/v2/js"
+)
+
+// NodeModuleURL is the URL of a CommonJS module in Node, to replace
+// `import.meta.url` with
+const NodeModuleURL = "require('url').pathToFileURL(__filename).href"
+
+type edit struct {
+	start, end int
+	text       string
+}
+
+// ToCommonJS rewrites the top-level imports and exports of an ES module to
+// `require()` calls and assignments to `exports`, with the default export as
+// `exports.default` and `__esModule` set, like other ESM to CommonJS
+// compilers do. `import.meta.url` is replaced with moduleURL, an expression
+// for the URL of the module where it runs. Exports are assigned at the end of
+// the module, so exported `let` bindings which change later aren't updated.
+// Each statement keeps its line breaks, so a source map of the module stays
+// accurate to the line. Type-only imports are left for a TypeScript compiler
+// to remove, and destructured exports, like `export const { a } = b`, aren't
+// supported.
+func ToCommonJS(source []byte, moduleURL string) []byte {
+	tokens := significantTokens(source)
+	edits := make([]edit, 0)
+	exports := make([]string, 0)
+	depth := 0
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		switch t.token {
+		case js.OpenBraceToken, js.OpenBracketToken, js.OpenParenToken, js.TemplateStartToken:
+			depth++
+			continue
+		case js.CloseBraceToken, js.CloseBracketToken, js.CloseParenToken, js.TemplateEndToken:
+			depth--
+			continue
+		}
+
+		if t.token == js.ImportToken && isImportMetaURL(tokens[i:]) {
+			url := tokens[i+4]
+			edits = append(edits, edit{t.start, url.start + len(url.value), moduleURL})
+			i += 4
+			continue
+		}
+		if depth != 0 || i+1 >= len(tokens) {
+			continue
+		}
+		next := tokens[i+1]
+
+		switch {
+		case t.token == js.ImportToken && next.token != js.OpenParenToken && next.token != js.DotToken && !isTypeImport(tokens[i+1:]):
+			pos, statement := NextImportStatement(source, t.start)
+			if pos == -1 || statement.Span.Start != t.start {
+				continue
+			}
+			spec := specifierLiteral(tokens[i:], statement.Span.End)
+			edits = append(edits, statementEdit(source, statement, requireImports(statement, spec)))
+			i = skipTo(tokens, i, statement.Span.End)
+
+		case t.token == js.ExportToken && next.token == js.DefaultToken:
+			edits = append(edits, edit{t.start, next.start + len(next.value), "exports.default ="})
+			i++
+
+		case t.token == js.ExportToken && (next.token == js.MulToken || next.token == js.OpenBraceToken):
+			pos, statement := NextModuleStatement(source, t.start)
+			if pos != -1 && statement.IsExport && statement.Span.Start == t.start {
+				spec := specifierLiteral(tokens[i:], statement.Span.End)
+				edits = append(edits, statementEdit(source, statement, requireReExports(statement, spec)))
+				i = skipTo(tokens, i, statement.Span.End)
+				continue
+			}
+			if next.token != js.OpenBraceToken {
+				continue
+			}
+			// `export { a, b as c }` of local bindings
+			end := i + 1
+			for end < len(tokens) && tokens[end].token != js.CloseBraceToken {
+				end++
+			}
+			if end == len(tokens) {
+				continue
+			}
+			exports = append(exports, exportClause(tokens[i+2:end])...)
+			last := tokens[end]
+			if end+1 < len(tokens) && tokens[end+1].token == js.SemicolonToken {
+				last = tokens[end+1]
+			}
+			edits = append(edits, edit{t.start, last.start + len(last.value), ""})
+			i = end
+
+		default:
+			if t.token != js.ExportToken {
+				continue
+			}
+			names := declaredNames(source, tokens[i+1:])
+			if len(names) == 0 {
+				continue
+			}
+			for _, name := range names {
+				exports = append(exports, fmt.Sprintf("exports.%s = %s;", name, name))
+			}
+			edits = append(edits, edit{t.start, next.start, ""})
+		}
+	}
+
+	sort.SliceStable(edits, func(a, b int) bool { return edits[a].start < edits[b].start })
+	out := make([]byte, 0, len(source))
+	written := 0
+	for _, e := range edits {
+		out = append(out, source[written:e.start]...)
+		out = append(out, e.text...)
+		written = e.end
+	}
+	out = append(out, source[written:]...)
+	if len(out) > 0 && out[len(out)-1] != '\n' {
+		out = append(out, '\n')
+	}
+	for _, e := range exports {
+		out = append(out, e+"\n"...)
+	}
+	out = append(out, "Object.defineProperty(exports, '__esModule', { value: true });"...)
+	return out
+}
+
+func isImportMetaURL(tokens []significantToken) bool {
+	return len(tokens) >= 5 && tokens[1].token == js.DotToken && string(tokens[2].value) == "meta" &&
+		tokens[3].token == js.DotToken && string(tokens[4].value) == "url"
+}
+
+// isTypeImport reports whether tokens, which follow `import`, are a type-only
+// import like `import type { A } from 'a'`, as opposed to importing a
+// binding named `type`
+func isTypeImport(tokens []significantToken) bool {
+	if len(tokens) < 2 || string(tokens[0].value) != "type" {
+		return false
+	}
+	return tokens[1].token == js.OpenBraceToken || tokens[1].token == js.MulToken || (js.IsIdentifier(tokens[1].token) && string(tokens[1].value) != "from")
+}
+
+// specifierLiteral returns the module specifier of the statement starting at
+// tokens[0] as it's quoted in source
+func specifierLiteral(tokens []significantToken, end int) string {
+	for _, t := range tokens {
+		if t.start >= end {
+			break
+		}
+		if t.token == js.StringToken {
+			return string(t.value)
+		}
+	}
+	return "''"
+}
+
+func skipTo(tokens []significantToken, i int, end int) int {
+	for i+1 < len(tokens) && tokens[i+1].start < end {
+		i++
+	}
+	return i
+}
+
+// statementEdit replaces statement with text followed by as many line breaks
+// as statement had
+func statementEdit(source []byte, statement ImportStatement, text string) edit {
+	lines := strings.Count(string(source[statement.Span.Start:statement.Span.End]), "\n")
+	return edit{statement.Span.Start, statement.Span.End, text + strings.Repeat("\n", lines)}
+}
+
+func requireImports(statement ImportStatement, spec string) string {
+	module := fmt.Sprintf("require(%s)", spec)
+	if len(statement.Imports) == 0 {
+		return module + ";"
+	}
+	statements := make([]string, 0)
+	bindings := make([]string, 0)
+	for _, imported := range statement.Imports {
+		switch {
+		case imported.ExportName == "*":
+			statements = append(statements, fmt.Sprintf("const %s = %s;", imported.LocalName, module))
+			module = imported.LocalName
+		case imported.ExportName == imported.LocalName:
+			bindings = append(bindings, imported.LocalName)
+		default:
+			bindings = append(bindings, fmt.Sprintf("%s: %s", imported.ExportName, imported.LocalName))
+		}
+	}
+	if len(bindings) > 0 {
+		statements = append(statements, fmt.Sprintf("const { %s } = %s;", strings.Join(bindings, ", "), module))
+	}
+	return strings.Join(statements, " ")
+}
+
+func requireReExports(statement ImportStatement, spec string) string {
+	module := fmt.Sprintf("require(%s)", spec)
+	statements := make([]string, 0)
+	for _, exported := range statement.Imports {
+		switch {
+		case exported.ExportName == "*" && exported.LocalName == "":
+			statements = append(statements, fmt.Sprintf("for (const [key, value] of Object.entries(%s)) if (key !== 'default') exports[key] = value;", module))
+		case exported.ExportName == "*":
+			statements = append(statements, fmt.Sprintf("exports.%s = %s;", exported.LocalName, module))
+		default:
+			statements = append(statements, fmt.Sprintf("exports.%s = %s.%s;", exported.LocalName, module, exported.ExportName))
+		}
+	}
+	return strings.Join(statements, " ")
+}
+
+// exportClause returns the assignments for the names of an export clause like
+// `a, b as c`
+func exportClause(tokens []significantToken) []string {
+	assignments := make([]string, 0)
+	local, exported := "", ""
+	flush := func() {
+		if local != "" {
+			if exported == "" {
+				exported = local
+			}
+			assignments = append(assignments, fmt.Sprintf("exports.%s = %s;", exported, local))
+		}
+		local, exported = "", ""
+	}
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		switch {
+		case t.token == js.CommaToken:
+			flush()
+		case string(t.value) == "as" && local != "" && i+1 < len(tokens):
+			exported = string(tokens[i+1].value)
+			i++
+		case local == "":
+			local = string(t.value)
+		}
+	}
+	flush()
+	return assignments
+}
+
+// declaredNames returns the names declared by the declaration in tokens,
+// which follow `export`. Further declarators, like `b` in
+// `export const a = 1, b = 2`, are only found on the same line.
+func declaredNames(source []byte, tokens []significantToken) []string {
+	i := 0
+	if i < len(tokens) && string(tokens[i].value) == "async" {
+		i++
+	}
+	if i >= len(tokens) {
+		return nil
+	}
+	switch string(tokens[i].value) {
+	case "function", "class":
+		i++
+		if i < len(tokens) && tokens[i].token == js.MulToken {
+			i++
+		}
+		if i < len(tokens) && js.IsIdentifier(tokens[i].token) {
+			return []string{string(tokens[i].value)}
+		}
+		return nil
+	case "const", "let", "var":
+		i++
+	default:
+		return nil
+	}
+	if i >= len(tokens) || !js.IsIdentifier(tokens[i].token) {
+		return nil
+	}
+	names := []string{string(tokens[i].value)}
+	depth := 0
+	for j := i + 1; j < len(tokens); j++ {
+		t := tokens[j]
+		if depth == 0 && strings.ContainsRune(string(source[tokens[j-1].start:t.start]), '\n') {
+			break
+		}
+		switch t.token {
+		case js.OpenBraceToken, js.OpenBracketToken, js.OpenParenToken, js.TemplateStartToken:
+			depth++
+		case js.CloseBraceToken, js.CloseBracketToken, js.CloseParenToken, js.TemplateEndToken:
+			depth--
+		case js.SemicolonToken:
+			if depth == 0 {
+				return names
+			}
+		case js.CommaToken:
+			if depth == 0 && j+1 < len(tokens) && js.IsIdentifier(tokens[j+1].token) {
+				names = append(names, string(tokens[j+1].value))
+			}
+		}
+	}
+	return names
+}
@@ -2,7 +2,9 @@ package js_scanner
 
 import (
 	"io"
+	"strings"
 
+	"github.com/snowpackjs/astro/internal/loc"
 	"github.com/tdewolff/parse/v2"
 	"github.com/tdewolff/parse/v2/js"
 )
@@ -22,7 +24,7 @@ func FindRenderBody(source []byte) int {
 
 	// Let's lex the script until we find what we need!
 	for {
-		token, value := l.Next()
+		token, value := nextToken(l, source, i)
 		openPairs := pairs['{'] > 0 || pairs['('] > 0 || pairs['['] > 0
 
 		if token == js.ErrorToken {
@@ -43,13 +45,21 @@ func FindRenderBody(source []byte) int {
 		if token == js.ImportToken {
 			i += len(value)
 			foundSpecifier := false
+			depth := 0
 			for {
-				next, nextValue := l.Next()
+				next, nextValue := nextToken(l, source, i)
 				i += len(nextValue)
 				if next == js.StringToken {
 					foundSpecifier = true
 				}
-				if foundSpecifier && (next == js.LineTerminatorToken || next == js.SemicolonToken) {
+				// An assertion clause may span several lines
+				if next == js.OpenBraceToken {
+					depth++
+				}
+				if next == js.CloseBraceToken {
+					depth--
+				}
+				if next == js.ErrorToken || (foundSpecifier && depth == 0 && (next == js.LineTerminatorToken || next == js.SemicolonToken)) {
 					break
 				}
 			}
@@ -63,7 +73,7 @@ func FindRenderBody(source []byte) int {
 			foundSemicolonOrLineTerminator := false
 			i += len(value)
 			for {
-				next, nextValue := l.Next()
+				next, nextValue := nextToken(l, source, i)
 				i += len(nextValue)
 				if js.IsIdentifier(next) {
 					foundIdentifier = true
@@ -81,7 +91,8 @@ func FindRenderBody(source []byte) int {
 					}
 				}
 
-				if foundIdentifier && foundSemicolonOrLineTerminator && pairs['{'] == 0 && pairs['('] == 0 && pairs['['] == 0 {
+				// Unbalanced pairs would otherwise never let the export end
+				if next == js.ErrorToken || foundIdentifier && foundSemicolonOrLineTerminator && pairs['{'] == 0 && pairs['('] == 0 && pairs['['] == 0 {
 					break
 				}
 			}
@@ -125,8 +136,9 @@ func FindRenderBody(source []byte) int {
 
 func HasExports(source []byte) bool {
 	l := js.NewLexer(parse.NewInputBytes(source))
+	i := 0
 	for {
-		token, _ := l.Next()
+		token, value := nextToken(l, source, i)
 		if token == js.ErrorToken {
 			// EOF or other error
 			return false
@@ -134,6 +146,7 @@ func HasExports(source []byte) bool {
 		if token == js.ExportToken {
 			return true
 		}
+		i += len(value)
 	}
 }
 
@@ -156,7 +169,7 @@ func HoistExports(source []byte) HoistedScripts {
 
 	// Let's lex the script until we find what we need!
 	for {
-		token, value := l.Next()
+		token, value := nextToken(l, source, i)
 
 		if token == js.ErrorToken {
 			if l.Err() != io.EOF {
@@ -181,7 +194,7 @@ func HoistExports(source []byte) HoistedScripts {
 			start := i - 1
 			i += len(value)
 			for {
-				next, nextValue := l.Next()
+				next, nextValue := nextToken(l, source, i)
 				i += len(nextValue)
 
 				if js.IsIdentifier(next) {
@@ -249,8 +262,9 @@ func HoistExports(source []byte) HoistedScripts {
 
 func hasGetStaticPaths(source []byte) bool {
 	l := js.NewLexer(parse.NewInputBytes(source))
+	i := 0
 	for {
-		token, value := l.Next()
+		token, value := nextToken(l, source, i)
 		if token == js.ErrorToken {
 			// EOF or other error
 			return false
@@ -258,13 +272,15 @@ func hasGetStaticPaths(source []byte) bool {
 		if token == js.IdentifierToken && string(value) == "getStaticPaths" {
 			return true
 		}
+		i += len(value)
 	}
 }
 
 func AccessesPrivateVars(source []byte) bool {
 	l := js.NewLexer(parse.NewInputBytes(source))
+	i := 0
 	for {
-		token, value := l.Next()
+		token, value := nextToken(l, source, i)
 		if token == js.ErrorToken {
 			// EOF or other error
 			return false
@@ -272,6 +288,780 @@ func AccessesPrivateVars(source []byte) bool {
 		if js.IsIdentifier(token) && len(value) > 1 && value[0] == '$' && value[1] == '$' {
 			return true
 		}
+		i += len(value)
+	}
+}
+
+// CustomElementDefinitions returns the tag names of every custom element
+// registered with a literal name, like `customElements.define('my-element', MyElement)`
+func CustomElementDefinitions(source []byte) []string {
+	l := js.NewLexer(parse.NewInputBytes(source))
+	tags := make([]string, 0)
+	// The significant tokens we expect to see, in order
+	expected := []string{"customElements", ".", "define", "("}
+	matched := 0
+	i := 0
+	for {
+		token, value := nextToken(l, source, i)
+		if token == js.ErrorToken {
+			// EOF or other error
+			return tags
+		}
+		i += len(value)
+		if token == js.WhitespaceToken || token == js.LineTerminatorToken || token == js.CommentToken || token == js.CommentLineTerminatorToken {
+			continue
+		}
+		if matched == len(expected) {
+			if token == js.StringToken || token == js.TemplateToken {
+				tags = append(tags, string(value[1:len(value)-1]))
+			}
+			matched = 0
+			continue
+		}
+		if string(value) == expected[matched] {
+			matched++
+		} else if string(value) == expected[0] {
+			matched = 1
+		} else {
+			matched = 0
+		}
+	}
+}
+
+// Declarations returns the names of the bindings declared at the top level of
+// source, including imports and the names bound by destructuring patterns,
+// like `user` in `const { user } = Astro.props`.
+func Declarations(source []byte) []string {
+	names := make([]string, 0)
+	pos, statement := NextImportStatement(source, 0)
+	for pos != -1 {
+		for _, imported := range statement.Imports {
+			names = append(names, imported.LocalName)
+		}
+		pos, statement = NextImportStatement(source, pos)
+	}
+
+	tokens := significantTokens(source)
+	depth := 0
+	for i := 0; i < len(tokens); i++ {
+		switch tokens[i].token {
+		case js.OpenBraceToken, js.OpenParenToken, js.OpenBracketToken, js.TemplateStartToken:
+			depth++
+		case js.CloseBraceToken, js.CloseParenToken, js.CloseBracketToken, js.TemplateEndToken:
+			depth--
+		case js.ConstToken, js.LetToken, js.VarToken:
+			if depth == 0 && i+1 < len(tokens) {
+				var bound []string
+				bound, i = bindingNames(tokens, i+1)
+				names = append(names, bound...)
+				// The pattern is skipped as a whole, so it doesn't change the depth
+				i--
+			}
+		case js.FunctionToken, js.ClassToken:
+			if depth == 0 && i+1 < len(tokens) && js.IsIdentifier(tokens[i+1].token) {
+				names = append(names, string(tokens[i+1].value))
+				i++
+			}
+		}
+	}
+	return names
+}
+
+// bindingNames returns the names bound by the binding identifier or pattern at
+// tokens[i], and the index of the token after it
+func bindingNames(tokens []significantToken, i int) ([]string, int) {
+	switch t := tokens[i]; {
+	case t.token == js.OpenBraceToken:
+		return objectPatternNames(tokens, i)
+	case t.token == js.OpenBracketToken:
+		return arrayPatternNames(tokens, i)
+	case js.IsIdentifier(t.token):
+		return []string{string(t.value)}, i + 1
+	}
+	return nil, i + 1
+}
+
+// objectPatternNames returns the names bound by the object pattern which
+// starts at tokens[i], like `{ a, b: c, d = 1, ...e }`, and the index of the
+// token after it
+func objectPatternNames(tokens []significantToken, i int) ([]string, int) {
+	names := make([]string, 0)
+	for i++; i < len(tokens); {
+		t := tokens[i]
+		switch {
+		case t.token == js.CloseBraceToken:
+			return names, i + 1
+		case t.token == js.CommaToken:
+			i++
+			continue
+		case t.token == js.EllipsisToken && i+1 < len(tokens):
+			var bound []string
+			bound, i = bindingNames(tokens, i+1)
+			names = append(names, bound...)
+		default:
+			// The key, which is also the binding unless it's renamed
+			if t.token == js.OpenBracketToken {
+				i = skipBalanced(tokens, i)
+			} else {
+				i++
+			}
+			if i < len(tokens) && tokens[i].token == js.ColonToken && i+1 < len(tokens) {
+				var bound []string
+				bound, i = bindingNames(tokens, i+1)
+				names = append(names, bound...)
+			} else if js.IsIdentifier(t.token) {
+				names = append(names, string(t.value))
+			}
+		}
+		i = skipInitializer(tokens, i)
+	}
+	return names, i
+}
+
+// arrayPatternNames returns the names bound by the array pattern which starts
+// at tokens[i], like `[a, , b = 1, ...c]`, and the index of the token after it
+func arrayPatternNames(tokens []significantToken, i int) ([]string, int) {
+	names := make([]string, 0)
+	for i++; i < len(tokens); {
+		var bound []string
+		switch tokens[i].token {
+		case js.CloseBracketToken:
+			return names, i + 1
+		case js.CommaToken:
+			i++
+			continue
+		case js.EllipsisToken:
+			if i+1 < len(tokens) {
+				bound, i = bindingNames(tokens, i+1)
+			} else {
+				i++
+			}
+		default:
+			bound, i = bindingNames(tokens, i)
+		}
+		names = append(names, bound...)
+		i = skipInitializer(tokens, i)
+	}
+	return names, i
+}
+
+// skipInitializer returns the index of the token after the default value
+// which may follow a binding at tokens[i], like `= 1` in `{ a = 1 }` or `(a = 1)`
+func skipInitializer(tokens []significantToken, i int) int {
+	if i >= len(tokens) || tokens[i].token != js.EqToken {
+		return i
+	}
+	for i++; i < len(tokens); i++ {
+		switch tokens[i].token {
+		case js.CommaToken, js.CloseBraceToken, js.CloseBracketToken, js.CloseParenToken:
+			return i
+		case js.OpenBraceToken, js.OpenParenToken, js.OpenBracketToken, js.TemplateStartToken:
+			i = skipBalanced(tokens, i) - 1
+		}
+	}
+	return i
+}
+
+// skipBalanced returns the index of the token after the bracket, brace, paren
+// or template which opens at tokens[i] closes
+func skipBalanced(tokens []significantToken, i int) int {
+	depth := 0
+	for ; i < len(tokens); i++ {
+		switch tokens[i].token {
+		case js.OpenBraceToken, js.OpenParenToken, js.OpenBracketToken, js.TemplateStartToken:
+			depth++
+		case js.CloseBraceToken, js.CloseParenToken, js.CloseBracketToken, js.TemplateEndToken:
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return i
+}
+
+// Identifiers returns every identifier referenced in source, in order
+func Identifiers(source []byte) []string {
+	identifiers := make([]string, 0)
+	l := js.NewLexer(parse.NewInputBytes(source))
+	i := 0
+	for {
+		token, value := nextToken(l, source, i)
+		if token == js.ErrorToken {
+			// EOF or other error
+			return identifiers
+		}
+		if js.IsIdentifier(token) {
+			identifiers = append(identifiers, string(value))
+		}
+		i += len(value)
+	}
+}
+
+// IdentifierReference is an identifier which source refers to
+type IdentifierReference struct {
+	Name string
+	// The position of the name in source
+	Start int
+}
+
+// IdentifierReferences returns the identifiers which source refers to, in
+// order. Property names, like `b` in `a.b` or `{ b: 1 }`, aren't references,
+// and neither are the names which source binds itself, like the parameters of
+// an arrow function, wherever they appear in source.
+func IdentifierReferences(source []byte) []IdentifierReference {
+	tokens := significantTokens(source)
+	bound := make(map[string]bool)
+	for _, name := range localBindings(tokens) {
+		bound[name] = true
+	}
+	references := make([]IdentifierReference, 0)
+	for i, t := range tokens {
+		if !js.IsIdentifier(t.token) || bound[string(t.value)] {
+			continue
+		}
+		if i > 0 && (tokens[i-1].token == js.DotToken || tokens[i-1].token == js.OptChainToken) {
+			continue
+		}
+		// An object literal key, rather than the branch of a conditional
+		if i > 0 && i+1 < len(tokens) && tokens[i+1].token == js.ColonToken &&
+			(tokens[i-1].token == js.OpenBraceToken || tokens[i-1].token == js.CommaToken) {
+			continue
+		}
+		references = append(references, IdentifierReference{Name: string(t.value), Start: t.start})
+	}
+	return references
+}
+
+// LocalBindings returns the names which source binds for itself, like `item`
+// in `items.map(item => item.name)`: the parameters and names of its
+// functions, the parameters of its catch clauses and the variables it
+// declares, at any depth.
+func LocalBindings(source []byte) []string {
+	return localBindings(significantTokens(source))
+}
+
+func localBindings(tokens []significantToken) []string {
+	names := make([]string, 0)
+	for i, t := range tokens {
+		var bound []string
+		switch {
+		case js.IsIdentifier(t.token) && i+1 < len(tokens) && tokens[i+1].token == js.ArrowToken:
+			bound = []string{string(t.value)}
+		case t.token == js.OpenParenToken:
+			// The parameters of an arrow function, or of a function or catch
+			// clause which comes before them
+			end := skipBalanced(tokens, i)
+			arrow := end < len(tokens) && tokens[end].token == js.ArrowToken
+			if arrow || (i > 0 && (tokens[i-1].token == js.CatchToken || isFunctionName(tokens, i-1))) {
+				bound = parameterNames(tokens, i)
+			}
+		case t.token == js.FunctionToken && i+1 < len(tokens) && js.IsIdentifier(tokens[i+1].token):
+			bound = []string{string(tokens[i+1].value)}
+		case (t.token == js.ConstToken || t.token == js.LetToken || t.token == js.VarToken) && i+1 < len(tokens):
+			bound, _ = bindingNames(tokens, i+1)
+		}
+		names = append(names, bound...)
+	}
+	return names
+}
+
+// isFunctionName reports whether tokens[i] is the `function` keyword or the
+// name which follows it, so the parameters of a function come next
+func isFunctionName(tokens []significantToken, i int) bool {
+	if tokens[i].token == js.FunctionToken {
+		return true
+	}
+	return i > 0 && js.IsIdentifier(tokens[i].token) && tokens[i-1].token == js.FunctionToken
+}
+
+// parameterNames returns the names bound by the parameter list which starts at
+// tokens[i], like `(a, { b }, c = 1, ...d)`
+func parameterNames(tokens []significantToken, i int) []string {
+	names := make([]string, 0)
+	for i++; i < len(tokens); {
+		var bound []string
+		switch tokens[i].token {
+		case js.CloseParenToken:
+			return names
+		case js.CommaToken:
+			i++
+			continue
+		case js.EllipsisToken:
+			if i+1 < len(tokens) {
+				bound, i = bindingNames(tokens, i+1)
+			} else {
+				i++
+			}
+		default:
+			bound, i = bindingNames(tokens, i)
+		}
+		names = append(names, bound...)
+		i = skipInitializer(tokens, i)
+	}
+	return names
+}
+
+// ObjectLiteralKeys returns the static property names of source if it is an
+// object literal, like `{ a: 1, "b": 2, c }`. Computed properties and spreads
+// can't be known ahead of time, so they're skipped.
+func ObjectLiteralKeys(source []byte) []string {
+	keys := make([]string, 0)
+	l := js.NewLexer(parse.NewInputBytes(source))
+	depth := 0
+	closed := false
+	// The property name being read, and whether a property name may start here
+	candidate := ""
+	expectKey := false
+	i := 0
+	for {
+		token, value := nextToken(l, source, i)
+		i += len(value)
+		switch token {
+		case js.ErrorToken:
+			// EOF or other error
+			if !closed {
+				return nil
+			}
+			return keys
+		case js.WhitespaceToken, js.LineTerminatorToken, js.CommentToken, js.CommentLineTerminatorToken:
+			continue
+		}
+		if closed || (depth == 0 && token != js.OpenBraceToken) {
+			return nil
+		}
+		if depth == 1 && candidate != "" {
+			switch {
+			case token == js.ColonToken || token == js.CommaToken || token == js.CloseBraceToken || token == js.OpenParenToken:
+				keys = append(keys, candidate)
+			case (candidate == "get" || candidate == "set" || candidate == "async") && js.IsIdentifierName(token):
+				// An accessor or async method, the name comes next
+				candidate = string(value)
+				continue
+			}
+			candidate = ""
+		}
+		switch token {
+		case js.OpenBraceToken, js.OpenParenToken, js.OpenBracketToken, js.TemplateStartToken:
+			depth++
+		case js.CloseBraceToken, js.CloseParenToken, js.CloseBracketToken, js.TemplateEndToken:
+			depth--
+			closed = depth == 0
+		}
+		if expectKey && depth == 1 {
+			if js.IsIdentifierName(token) || js.IsNumeric(token) {
+				candidate = string(value)
+			} else if token == js.StringToken {
+				candidate = string(value[1 : len(value)-1])
+			}
+		}
+		expectKey = (depth == 1 && token == js.CommaToken) || (depth == 1 && token == js.OpenBraceToken && !closed)
+	}
+}
+
+// PropsKeys returns the names of the members of the Props of a component, as
+// declared in its frontmatter by `interface Props { ... }` or
+// `type Props = { ... }`. ok is false if source doesn't declare Props, or if
+// not every prop can be known, like when Props extends another type, is an
+// intersection or has an index signature.
+func PropsKeys(source []byte) (keys []string, ok bool) {
+	l := js.NewLexer(parse.NewInputBytes(source))
+	depth := 0
+	// The significant tokens at the top level leading up to the body of Props
+	var prev []string
+	i := 0
+	for {
+		token, value := nextToken(l, source, i)
+		i += len(value)
+		switch token {
+		case js.ErrorToken:
+			return nil, false
+		case js.WhitespaceToken, js.LineTerminatorToken, js.CommentToken, js.CommentLineTerminatorToken:
+			continue
+		case js.OpenBraceToken, js.OpenParenToken, js.OpenBracketToken, js.TemplateStartToken:
+			depth++
+			if depth == 1 && token == js.OpenBraceToken && isPropsDeclaration(prev) {
+				return propsMembers(l, source, i)
+			}
+			continue
+		case js.CloseBraceToken, js.CloseParenToken, js.CloseBracketToken, js.TemplateEndToken:
+			depth--
+			continue
+		}
+		if depth == 0 {
+			prev = append(prev, string(value))
+			if len(prev) > 3 {
+				prev = prev[1:]
+			}
+			if len(prev) >= 2 && prev[len(prev)-2] == "Props" && (prev[len(prev)-1] == "extends" || prev[len(prev)-1] == "<") {
+				if len(prev) >= 3 && (prev[len(prev)-3] == "interface" || prev[len(prev)-3] == "type") {
+					return nil, false
+				}
+			}
+		}
+	}
+}
+
+func isPropsDeclaration(prev []string) bool {
+	n := len(prev)
+	if n >= 2 && prev[n-2] == "interface" && prev[n-1] == "Props" {
+		return true
+	}
+	return n >= 3 && prev[n-3] == "type" && prev[n-2] == "Props" && prev[n-1] == "="
+}
+
+// propsMembers reads the member names of a type literal whose `{` was just
+// read, ending at i in source
+func propsMembers(l *js.Lexer, source []byte, i int) ([]string, bool) {
+	keys := make([]string, 0)
+	depth := 1
+	expectKey := true
+	candidate := ""
+	for {
+		token, value := nextToken(l, source, i)
+		i += len(value)
+		switch token {
+		case js.ErrorToken:
+			return nil, false
+		case js.WhitespaceToken, js.CommentToken:
+			continue
+		case js.LineTerminatorToken, js.CommentLineTerminatorToken:
+			if depth == 1 && candidate == "" {
+				expectKey = true
+			}
+			continue
+		}
+		if depth == 1 && candidate != "" {
+			switch {
+			case token == js.QuestionToken || token == js.ColonToken || token == js.OpenParenToken || token == js.LtToken:
+				keys = append(keys, candidate)
+			case candidate == "readonly" && (js.IsIdentifierName(token) || token == js.StringToken):
+				// A modifier, the name comes next
+				candidate = propertyName(token, value)
+				continue
+			}
+			candidate = ""
+		}
+		switch token {
+		case js.OpenBraceToken, js.OpenParenToken, js.OpenBracketToken, js.TemplateStartToken:
+			if depth == 1 && expectKey && token == js.OpenBracketToken {
+				// An index signature allows any prop
+				return nil, false
+			}
+			depth++
+		case js.CloseBraceToken, js.CloseParenToken, js.CloseBracketToken, js.TemplateEndToken:
+			depth--
+			if depth == 0 {
+				// An intersection or union adds props which aren't listed here
+				for {
+					token, value := nextToken(l, source, i)
+					i += len(value)
+					switch token {
+					case js.WhitespaceToken, js.LineTerminatorToken, js.CommentToken, js.CommentLineTerminatorToken:
+						continue
+					case js.BitAndToken, js.BitOrToken:
+						return nil, false
+					}
+					return keys, true
+				}
+			}
+		}
+		if depth == 1 && expectKey && (js.IsIdentifierName(token) || token == js.StringToken || js.IsNumeric(token)) {
+			candidate = propertyName(token, value)
+		}
+		expectKey = depth == 1 && (token == js.SemicolonToken || token == js.CommaToken || token == js.OpenBraceToken)
+	}
+}
+
+func propertyName(token js.TokenType, value []byte) string {
+	if token == js.StringToken {
+		return string(value[1 : len(value)-1])
+	}
+	return string(value)
+}
+
+type significantToken struct {
+	token js.TokenType
+	value []byte
+	start int
+}
+
+// significantTokens returns every token of source except whitespace and comments
+func significantTokens(source []byte) []significantToken {
+	l := js.NewLexer(parse.NewInputBytes(source))
+	tokens := make([]significantToken, 0)
+	i := 0
+	for {
+		token, value := nextToken(l, source, i)
+		switch token {
+		case js.ErrorToken:
+			return tokens
+		case js.WhitespaceToken, js.LineTerminatorToken, js.CommentToken, js.CommentLineTerminatorToken:
+		default:
+			tokens = append(tokens, significantToken{token: token, value: value, start: i})
+		}
+		i += len(value)
+	}
+}
+
+// ParamReference is a route param read from `Astro.params`
+type ParamReference struct {
+	Name string
+	// The position of the name in source
+	Start int
+}
+
+// AstroParams returns every param read from `Astro.params` in source, in
+// order, whether by name, like `Astro.params.slug` or `Astro.params['slug']`,
+// or by destructuring, like `const { slug } = Astro.params`. Computed keys and
+// rest elements can't be known and are left out.
+func AstroParams(source []byte) []ParamReference {
+	tokens := significantTokens(source)
+	params := make([]ParamReference, 0)
+	for i := 0; i+2 < len(tokens); i++ {
+		if string(tokens[i].value) != "Astro" || tokens[i+1].token != js.DotToken || string(tokens[i+2].value) != "params" {
+			continue
+		}
+		// `foo.Astro.params` is something else entirely
+		if i > 0 && (tokens[i-1].token == js.DotToken || tokens[i-1].token == js.OptChainToken) {
+			continue
+		}
+		rest := tokens[i+3:]
+		switch {
+		case len(rest) >= 2 && (rest[0].token == js.DotToken || rest[0].token == js.OptChainToken) && js.IsIdentifierName(rest[1].token):
+			params = append(params, ParamReference{Name: string(rest[1].value), Start: rest[1].start})
+		case len(rest) >= 3 && rest[0].token == js.OpenBracketToken && rest[1].token == js.StringToken && rest[2].token == js.CloseBracketToken:
+			params = append(params, ParamReference{Name: propertyName(rest[1].token, rest[1].value), Start: rest[1].start + 1})
+		case i >= 2 && tokens[i-1].token == js.EqToken && tokens[i-2].token == js.CloseBraceToken:
+			params = append(params, destructuredKeys(tokens[:i-1])...)
+		}
+	}
+	return params
+}
+
+// destructuredKeys returns the keys of the object pattern which tokens end with
+func destructuredKeys(tokens []significantToken) []ParamReference {
+	depth := 0
+	open := -1
+	for i := len(tokens) - 1; i >= 0; i-- {
+		switch tokens[i].token {
+		case js.CloseBraceToken, js.CloseBracketToken, js.CloseParenToken:
+			depth++
+		case js.OpenBraceToken, js.OpenBracketToken, js.OpenParenToken:
+			depth--
+		}
+		if depth == 0 {
+			open = i
+			break
+		}
+	}
+	if open == -1 {
+		return nil
+	}
+
+	keys := make([]ParamReference, 0)
+	depth = 0
+	for i := open; i < len(tokens); i++ {
+		t := tokens[i]
+		switch t.token {
+		case js.OpenBraceToken, js.OpenBracketToken, js.OpenParenToken, js.TemplateStartToken:
+			depth++
+			continue
+		case js.CloseBraceToken, js.CloseBracketToken, js.CloseParenToken, js.TemplateEndToken:
+			depth--
+			continue
+		}
+		if depth != 1 || !(js.IsIdentifierName(t.token) || t.token == js.StringToken) {
+			continue
+		}
+		// Keys follow the opening brace or a comma, rest elements follow `...`
+		if prev := tokens[i-1].token; prev == js.OpenBraceToken || prev == js.CommaToken {
+			start := t.start
+			if t.token == js.StringToken {
+				start++
+			}
+			keys = append(keys, ParamReference{Name: propertyName(t.token, t.value), Start: start})
+		}
+	}
+	return keys
+}
+
+// GlobCall is a call to `Astro.glob()` with a literal pattern
+type GlobCall struct {
+	Pattern string
+	// The position of the pattern in source, after its opening quote
+	Start int
+}
+
+// AstroGlobs returns every call to `Astro.glob()` in source whose pattern is a
+// string literal, like `Astro.glob('../posts/*.md')`, in order. Patterns which
+// are built at runtime can't be known and are left out.
+func AstroGlobs(source []byte) []GlobCall {
+	tokens := significantTokens(source)
+	globs := make([]GlobCall, 0)
+	for i := 0; i+4 < len(tokens); i++ {
+		if string(tokens[i].value) != "Astro" || tokens[i+1].token != js.DotToken || string(tokens[i+2].value) != "glob" || tokens[i+3].token != js.OpenParenToken {
+			continue
+		}
+		if i > 0 && (tokens[i-1].token == js.DotToken || tokens[i-1].token == js.OptChainToken) {
+			continue
+		}
+		pattern := tokens[i+4]
+		if pattern.token != js.StringToken && pattern.token != js.TemplateToken {
+			continue
+		}
+		globs = append(globs, GlobCall{Pattern: string(pattern.value[1 : len(pattern.value)-1]), Start: pattern.start + 1})
+	}
+	return globs
+}
+
+// exportedDeclaration returns the index in tokens of the name of a top-level
+// declaration which is exported, like `export const name` or
+// `export async function name`, or -1 if there is none.
+func exportedDeclaration(tokens []significantToken, name string) int {
+	depth := 0
+	for i, t := range tokens {
+		switch t.token {
+		case js.OpenBraceToken, js.OpenBracketToken, js.OpenParenToken, js.TemplateStartToken:
+			depth++
+		case js.CloseBraceToken, js.CloseBracketToken, js.CloseParenToken, js.TemplateEndToken:
+			depth--
+		}
+		if depth != 0 || t.token != js.ExportToken {
+			continue
+		}
+		j := i + 1
+		if j < len(tokens) && string(tokens[j].value) == "async" {
+			j++
+		}
+		if j < len(tokens) {
+			switch string(tokens[j].value) {
+			case "const", "let", "var", "function", "class":
+				j++
+			default:
+				continue
+			}
+		}
+		// Generator functions
+		if j < len(tokens) && tokens[j].token == js.MulToken {
+			j++
+		}
+		if j < len(tokens) && string(tokens[j].value) == name {
+			return j
+		}
+	}
+	return -1
+}
+
+// ExportsGetStaticPaths returns whether source exports a `getStaticPaths` function
+func ExportsGetStaticPaths(source []byte) bool {
+	return exportedDeclaration(significantTokens(source), "getStaticPaths") != -1
+}
+
+// Prerender returns the value of the `prerender` export of source, like
+// `export const prerender = true`, and the position of its name. ok is false
+// when there is no such export, or when its value isn't a boolean literal.
+func Prerender(source []byte) (prerender bool, start int, ok bool) {
+	tokens := significantTokens(source)
+	i := exportedDeclaration(tokens, "prerender")
+	if i == -1 || i+2 >= len(tokens) || tokens[i+1].token != js.EqToken {
+		return false, 0, false
+	}
+	switch tokens[i+2].token {
+	case js.TrueToken:
+		return true, tokens[i].start, true
+	case js.FalseToken:
+		return false, tokens[i].start, true
+	}
+	return false, 0, false
+}
+
+// ReplaceDefines replaces every member expression in source which matches a
+// key of defines, like `import.meta.env.MODE`, with the value for that key.
+// The longest match wins, so `a.b.c` is replaced before `a.b` is.
+func ReplaceDefines(source []byte, defines map[string]string) []byte {
+	if len(defines) == 0 {
+		return source
+	}
+	out := make([]byte, 0, len(source))
+	written := 0
+	// The identifiers of the member expression being read, with their end positions
+	start := -1
+	names := make([]string, 0)
+	ends := make([]int, 0)
+	dot := false
+	flush := func() {
+		for k := len(names); k > 0; k-- {
+			if value, ok := defines[strings.Join(names[:k], ".")]; ok {
+				out = append(out, source[written:start]...)
+				out = append(out, value...)
+				written = ends[k-1]
+				break
+			}
+		}
+		start = -1
+		names = names[:0]
+		ends = ends[:0]
+		dot = false
+	}
+
+	l := js.NewLexer(parse.NewInputBytes(source))
+	i := 0
+	// A property of some other expression, like `b` in `a.b`, can't start a match
+	afterDot := false
+	for {
+		token, value := nextToken(l, source, i)
+		if token == js.ErrorToken {
+			// EOF or other error
+			flush()
+			break
+		}
+		if token == js.DotToken && start != -1 && !dot {
+			dot = true
+		} else if js.IsIdentifierName(token) && start != -1 && dot {
+			names = append(names, string(value))
+			ends = append(ends, i+len(value))
+			dot = false
+		} else {
+			flush()
+			if js.IsIdentifierName(token) && !afterDot {
+				start = i
+				names = append(names, string(value))
+				ends = append(ends, i+len(value))
+			}
+		}
+		if token != js.WhitespaceToken && token != js.LineTerminatorToken && token != js.CommentToken && token != js.CommentLineTerminatorToken {
+			afterDot = token == js.DotToken || token == js.OptChainToken
+		}
+		i += len(value)
+	}
+	return append(out, source[written:]...)
+}
+
+// Interpolation is an identifier at the start of a `${}` template literal substitution
+type Interpolation struct {
+	Name  string
+	Start int
+}
+
+// Interpolations returns every `${}` substitution in source that starts with
+// an identifier, like `${name}` or `${user.name}`
+func Interpolations(source []byte) []Interpolation {
+	interpolations := make([]Interpolation, 0)
+	l := js.NewLexer(parse.NewInputBytes(source))
+	i := 0
+	inSubstitution := false
+	for {
+		token, value := nextToken(l, source, i)
+		if token == js.ErrorToken {
+			// EOF or other error
+			return interpolations
+		}
+		if inSubstitution && js.IsIdentifier(token) {
+			interpolations = append(interpolations, Interpolation{Name: string(value), Start: i})
+		}
+		inSubstitution = token == js.TemplateStartToken || token == js.TemplateMiddleToken || (inSubstitution && token == js.WhitespaceToken)
+		i += len(value)
 	}
 }
 
@@ -282,6 +1072,14 @@ type Import struct {
 type ImportStatement struct {
 	Imports   []Import
 	Specifier string
+	// The import assertion or attributes clause following the specifier,
+	// keyword included, like `assert { type: 'json' }`
+	Attributes string
+	// Whether this is a re-export like `export { a as b } from 'b'`, in which
+	// case LocalName is the name each import is exported as
+	IsExport bool
+	// The position of the statement in source
+	Span loc.Span
 }
 
 type ImportState uint32
@@ -291,52 +1089,123 @@ const (
 	ImportNamed
 )
 
+// NextImportStatement returns the first import statement in source after pos,
+// and the position after it, or -1 if there are no more. Imports inside
+// comments, strings and regular expressions aren't statements, so they are
+// skipped.
 func NextImportStatement(source []byte, pos int) (int, ImportStatement) {
+	return nextStatement(source, pos, false)
+}
+
+// NextModuleStatement is like NextImportStatement, but also returns re-exports
+// such as `export * from 'a'` and `export { a as b } from 'b'`, with IsExport
+// set. These depend on a module too, but don't declare any local bindings.
+func NextModuleStatement(source []byte, pos int) (int, ImportStatement) {
+	return nextStatement(source, pos, true)
+}
+
+func nextStatement(source []byte, pos int, exports bool) (int, ImportStatement) {
 	l := js.NewLexer(parse.NewInputBytes(source[pos:]))
 	i := pos
 	for {
-		token, value := l.Next()
+		token, value := nextToken(l, source, i)
 		if token == js.ErrorToken {
 			// EOF or other error
 			return -1, ImportStatement{}
 		}
 		// Imports should be consumed up until we find a specifier,
 		// then we can exit after the following line terminator or semicolon
-		if token == js.ImportToken {
+		if token == js.ImportToken || (exports && token == js.ExportToken) {
+			isExport := token == js.ExportToken
+			start := i
 			i += len(value)
 			specifier := ""
+			attributes := ""
 			imports := make([]Import, 0)
 			importState := ImportDefault
 			currImport := Import{}
+			closedBrace := false
+			foundFrom := false
 			for {
-				next, nextValue := l.Next()
+				next, nextValue := nextToken(l, source, i)
 				i += len(nextValue)
 
 				if next == js.StringToken {
 					specifier = string(nextValue[1 : len(nextValue)-1])
 				}
 
-				if specifier != "" && (next == js.LineTerminatorToken || next == js.SemicolonToken) {
+				// The last statement may end without a line terminator
+				end := next == js.LineTerminatorToken || next == js.SemicolonToken || next == js.CommentLineTerminatorToken || next == js.ErrorToken
+				if specifier != "" && end {
 					if currImport.ExportName != "" {
-						if currImport.LocalName == "" {
+						// `export * from 'a'` re-exports every name as it is
+						if currImport.LocalName == "" && !(isExport && currImport.ExportName == "*") {
 							currImport.LocalName = currImport.ExportName
 						}
 						imports = append(imports, currImport)
 					}
 					return i, ImportStatement{
-						Imports:   imports,
-						Specifier: specifier,
+						Imports:    imports,
+						Specifier:  specifier,
+						Attributes: attributes,
+						IsExport:   isExport,
+						Span:       loc.Span{Start: start, End: i},
 					}
 				}
+				if next == js.ErrorToken {
+					return -1, ImportStatement{}
+				}
 
 				if next == js.WhitespaceToken {
 					continue
 				}
 
+				// Only `export *` and `export { ... } from` depend on another
+				// module, anything else is a declaration or a local export. The
+				// token may start the next statement, so scan again from it
+				if isExport && next != js.CommentToken && next != js.LineTerminatorToken {
+					notReExport := importState == ImportDefault && currImport.ExportName == "" && next != js.MulToken && next != js.OpenBraceToken
+					if notReExport || (closedBrace && !foundFrom && next != js.FromToken) {
+						return nextStatement(source, i-len(nextValue), exports)
+					}
+				}
+				if next == js.FromToken {
+					foundFrom = true
+				}
+
+				// `assert { type: 'json' }` or `with { type: 'json' }`, which may
+				// span several lines, so read up to the closing brace
+				if specifier != "" && attributes == "" && (next == js.WithToken || (next == js.IdentifierToken && string(nextValue) == "assert")) {
+					clauseStart := i - len(nextValue)
+					depth := 0
+					for {
+						next, nextValue = nextToken(l, source, i)
+						i += len(nextValue)
+						if next == js.ErrorToken {
+							return -1, ImportStatement{}
+						}
+						if next == js.OpenBraceToken {
+							depth++
+						}
+						if next == js.CloseBraceToken {
+							depth--
+							if depth == 0 {
+								break
+							}
+						}
+					}
+					attributes = string(source[clauseStart:i])
+					continue
+				}
+
 				if next == js.OpenBraceToken {
 					importState = ImportNamed
 				}
 
+				if next == js.CloseBraceToken {
+					closedBrace = true
+				}
+
 				if next == js.CommaToken {
 					if currImport.LocalName == "" {
 						currImport.LocalName = currImport.ExportName
@@ -345,7 +1214,8 @@ func NextImportStatement(source []byte, pos int) (int, ImportStatement) {
 					currImport = Import{}
 				}
 
-				if next == js.IdentifierToken {
+				// `default` is a keyword, but may be imported or exported by name
+				if next == js.IdentifierToken || (importState == ImportNamed && next == js.DefaultToken) {
 					if currImport.ExportName != "" {
 						currImport.LocalName = string(nextValue)
 					} else if importState == ImportNamed {
@@ -365,8 +1235,63 @@ func NextImportStatement(source []byte, pos int) (int, ImportStatement) {
 					break
 				}
 			}
+			continue
 		}
 
 		i += len(value)
 	}
 }
+
+// nextToken is l.Next, except that a regular expression is read as a single
+// token, so a quote or comment inside of it isn't mistaken for one in the
+// code. The lexer can't tell regular expressions from division on its own. i
+// is the position in source of the next token. One which is never closed is
+// left as a division, since reading it would stop the lexer.
+func nextToken(l *js.Lexer, source []byte, i int) (js.TokenType, []byte) {
+	token, value := l.Next()
+	if (token == js.DivToken || token == js.DivEqToken) && regExpAllowed(source, i) {
+		if _, closed := skipRegExp(source, i); closed {
+			return l.RegExp()
+		}
+	}
+	return token, value
+}
+
+// SideEffectImportsEnd returns the position after the last side-effect import
+// in source, like `import './global.css'`, with only imports and comments
+// before it, or 0 if there is none. Everything up to there can be printed ahead
+// of generated imports without changing the order modules are evaluated in.
+func SideEffectImportsEnd(source []byte) int {
+	end := 0
+	prev := 0
+	pos, statement := NextImportStatement(source, 0)
+	for pos != -1 {
+		if !onlyComments(source[:statement.Span.Start], prev) {
+			break
+		}
+		if len(statement.Imports) == 0 {
+			end = statement.Span.End
+		}
+		prev = statement.Span.End
+		pos, statement = NextImportStatement(source, pos)
+	}
+	return end
+}
+
+// onlyComments reports whether source from start on is only whitespace and
+// comments
+func onlyComments(source []byte, start int) bool {
+	for i := start; i < len(source); {
+		switch source[i] {
+		case ' ', '\t', '\r', '\n':
+			i++
+			continue
+		}
+		kind, end := Skip(source, i)
+		if kind != LineCommentSpan && kind != BlockCommentSpan {
+			return false
+		}
+		i = end
+	}
+	return true
+}
@@ -2,6 +2,7 @@ package js_scanner
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/snowpackjs/astro/internal/test_utils"
@@ -154,6 +155,13 @@ import { c } from "c";
 /import { b } from "b";
 import { c } from "c";`,
 			want: `import { a } from "a";
+`,
+		},
+		{
+			name: "RegExp with a brace in an export",
+			source: `export const r = /[}]/;
+const a = 1;`,
+			want: `export const r = /[}]/;
 `,
 		},
 	}
@@ -175,3 +183,733 @@ import { c } from "c";`,
 		})
 	}
 }
+
+func TestAccessesPrivateVars(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   bool
+	}{
+		{
+			name:   "private var",
+			source: "const a = $$result;",
+			want:   true,
+		},
+		{
+			name:   "inside of a regular expression",
+			source: "const r = /$$result/;",
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AccessesPrivateVars([]byte(tt.source)); got != tt.want {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %v\n  got:  %v", tt.name, tt.want, got))
+			}
+		})
+	}
+}
+
+func TestCustomElementDefinitions(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "basic",
+			source: `customElements.define('my-element', MyElement);`,
+			want:   []string{"my-element"},
+		},
+		{
+			name:   "window",
+			source: `window.customElements.define("my-element", class extends HTMLElement {});`,
+			want:   []string{"my-element"},
+		},
+		{
+			name:   "template literal",
+			source: "customElements.define(`my-element`, MyElement);",
+			want:   []string{"my-element"},
+		},
+		{
+			name:   "after a regular expression",
+			source: `const r = /'/; customElements.define('my-el', X);`,
+			want:   []string{"my-el"},
+		},
+		{
+			name: "whitespace and comments",
+			source: `customElements
+  .define( /* tag */ 'my-element', MyElement);`,
+			want: []string{"my-element"},
+		},
+		{
+			name: "multiple",
+			source: `customElements.define('a-b', A);
+customElements.define('c-d', C);`,
+			want: []string{"a-b", "c-d"},
+		},
+		{
+			name:   "dynamic name",
+			source: `customElements.define(name, MyElement);`,
+			want:   []string{},
+		},
+		{
+			name:   "get is not define",
+			source: `customElements.get('my-element');`,
+			want:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CustomElementDefinitions([]byte(tt.source))
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %v\n  got:  %v", tt.name, tt.want, got))
+			}
+		})
+	}
+}
+
+func TestLocalBindings(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "arrow function",
+			source: `items.map(item => item.name)`,
+			want:   []string{"item"},
+		},
+		{
+			name:   "arrow function parameters",
+			source: `items.map(({ a, b: c }, [d], e = f(1), ...g) => a)`,
+			want:   []string{"a", "c", "d", "e", "g"},
+		},
+		{
+			name:   "functions and declarations",
+			source: `items.map(function each(item, i) { const { name } = item; try { return name } catch (err) { return i } })`,
+			want:   []string{"each", "item", "i", "name", "err"},
+		},
+		{
+			name:   "calls",
+			source: `format(date, (locale))`,
+			want:   []string{},
+		},
+		{
+			name:   "unclosed",
+			source: `items.map(item => `,
+			want:   []string{"item"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := LocalBindings([]byte(tt.source))
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %v\n  got:  %v", tt.name, tt.want, got))
+			}
+		})
+	}
+}
+
+func TestDeclarations(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name: "imports",
+			source: `import A from 'a';
+import { b, c as d } from 'b';
+import * as e from 'e';
+import 'f';`,
+			want: []string{"A", "b", "d", "e"},
+		},
+		{
+			name: "variables",
+			source: `const a = 1;
+let b;
+var c = { d: 1 };
+function e() { const f = 1; }
+class G {}`,
+			want: []string{"a", "b", "c", "e", "G"},
+		},
+		{
+			name:   "nested",
+			source: `if (x) { const a = 1; } const b = () => { let c; }`,
+			want:   []string{"b"},
+		},
+		{
+			name: "destructuring",
+			source: `const { a, b: c, d = { e: 1 }, [f]: g, h: { i }, ...j } = Astro.props;
+let [k, , l = [1, 2], [m], ...n] = list;
+const o = ({ p }) => p;`,
+			want: []string{"a", "c", "d", "g", "i", "j", "k", "l", "m", "n", "o"},
+		},
+		{
+			name:   "template literal",
+			source: "const a = `${b}`; const { c } = d;",
+			want:   []string{"a", "c"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Declarations([]byte(tt.source))
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %v\n  got:  %v", tt.name, tt.want, got))
+			}
+		})
+	}
+}
+
+func TestInterpolations(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "none",
+			source: "const a = `plain`; const b = 'no ${c}';",
+			want:   []string{},
+		},
+		{
+			name:   "identifiers",
+			source: "const a = `${b} and ${ c.d } and ${1 + e}`;",
+			want:   []string{"b@13", "c@23"},
+		},
+		{
+			name:   "after a regular expression",
+			source: "const r = /`/; const a = `${foo}`;",
+			want:   []string{"foo@28"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]string, 0)
+			for _, i := range Interpolations([]byte(tt.source)) {
+				got = append(got, fmt.Sprintf("%s@%d", i.Name, i.Start))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %v\n  got:  %v", tt.name, tt.want, got))
+			}
+		})
+	}
+}
+
+func TestIdentifiers(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "expression",
+			source: "items.map(item => <Card {item} />)",
+			want:   []string{"items", "map", "item", "Card", "item"},
+		},
+		{
+			name:   "strings are skipped",
+			source: "a + 'b' + `c`",
+			want:   []string{"a"},
+		},
+		{
+			name:   "regular expressions are skipped",
+			source: "s.replace(/'/g, x) + y",
+			want:   []string{"s", "replace", "x", "y"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Identifiers([]byte(tt.source))
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %v\n  got:  %v", tt.name, tt.want, got))
+			}
+		})
+	}
+}
+
+func TestObjectLiteralKeys(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "properties",
+			source: `{ a: 1, "b": { c: 2 }, d, 3: [e, f] }`,
+			want:   []string{"a", "b", "d", "3"},
+		},
+		{
+			name:   "methods",
+			source: "{ get a() { return `${x}` }, async b() {}, c() {} }",
+			want:   []string{"a", "b", "c"},
+		},
+		{
+			name:   "computed and spread",
+			source: `{ [a]: 1, ...b, c: d ? e : f }`,
+			want:   []string{"c"},
+		},
+		{
+			name:   "regular expressions",
+			source: `{ a: /}/, b: 1 }`,
+			want:   []string{"a", "b"},
+		},
+		{
+			name:   "not an object literal",
+			source: `props`,
+			want:   []string{},
+		},
+		{
+			name:   "member of an object literal",
+			source: `{ a: 1 }.a`,
+			want:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ObjectLiteralKeys([]byte(tt.source))
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %v\n  got:  %v", tt.name, tt.want, got))
+			}
+		})
+	}
+}
+
+func TestPropsKeys(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+		ok     bool
+	}{
+		{
+			name:   "interface",
+			source: "import type { Item } from './types';\ninterface Props {\n  title: string;\n  items?: Item[]\n  onClick(e: Event): void,\n  readonly 'data-id': string;\n  render<T>(item: T): string;\n}\nconst { title } = Astro.props;",
+			want:   []string{"title", "items", "onClick", "data-id", "render"},
+			ok:     true,
+		},
+		{
+			name:   "exported type literal",
+			source: "export type Props = { href: string; label?: { text: string; icon: string } };",
+			want:   []string{"href", "label"},
+			ok:     true,
+		},
+		{
+			name:   "empty",
+			source: "interface Props {}",
+			want:   []string{},
+			ok:     true,
+		},
+		{
+			name:   "after a regular expression",
+			source: "const r = /{/;\ninterface Props { a: string }",
+			want:   []string{"a"},
+			ok:     true,
+		},
+		{
+			name:   "extends",
+			source: "import type { HTMLAttributes } from 'astro/types';\ninterface Props extends HTMLAttributes<'a'> { href: string }",
+			ok:     false,
+		},
+		{
+			name:   "intersection",
+			source: "type Props = { a: string } & Other;",
+			ok:     false,
+		},
+		{
+			name:   "generic",
+			source: "interface Props<T> { items: T[] }",
+			ok:     false,
+		},
+		{
+			name:   "index signature",
+			source: "interface Props { [key: string]: unknown }",
+			ok:     false,
+		},
+		{
+			name:   "no props",
+			source: "interface ButtonProps { a: string }\nconst props = { b: 1 };",
+			ok:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := PropsKeys([]byte(tt.source))
+			if ok != tt.ok || (ok && fmt.Sprint(got) != fmt.Sprint(tt.want)) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %v %v\n  got:  %v %v", tt.name, tt.want, tt.ok, got, ok))
+			}
+		})
+	}
+}
+
+func TestReplaceDefines(t *testing.T) {
+	defines := map[string]string{
+		"import.meta.env.MODE": `"production"`,
+		"import.meta.env":      `{}`,
+		"DEBUG":                "false",
+	}
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "member expression",
+			source: "if (import.meta.env.MODE === 'dev') {}",
+			want:   `if ("production" === 'dev') {}`,
+		},
+		{
+			name:   "longest match",
+			source: "const env = import.meta.env; const url = import.meta.env.SITE_URL;",
+			want:   "const env = {}; const url = {}.SITE_URL;",
+		},
+		{
+			name:   "identifier",
+			source: "DEBUG && log(DEBUG_LEVEL, options.DEBUG)",
+			want:   "false && log(DEBUG_LEVEL, options.DEBUG)",
+		},
+		{
+			name:   "strings and templates",
+			source: "'import.meta.env.MODE' + `${import.meta.env.MODE}`",
+			want:   "'import.meta.env.MODE' + `${\"production\"}`",
+		},
+		{
+			name:   "regular expressions",
+			source: "const r = /DEBUG'/; const x = DEBUG;",
+			want:   "const r = /DEBUG'/; const x = false;",
+		},
+		{
+			name:   "division",
+			source: "const half = DEBUG / 2 / DEBUG;",
+			want:   "const half = false / 2 / false;",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(ReplaceDefines([]byte(tt.source), defines))
+			if got != tt.want {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %v\n  got:  %v", tt.name, tt.want, got))
+			}
+		})
+	}
+}
+
+func TestToCommonJS(t *testing.T) {
+	esModule := "\nObject.defineProperty(exports, '__esModule', { value: true });"
+	tests := []testcase{
+		{
+			name:   "side effect import",
+			source: "import './global.css';",
+			want:   "require('./global.css');",
+		},
+		{
+			name:   "default and named imports",
+			source: "import A, { b, c as d } from \"a\";",
+			want:   "const { default: A, b, c: d } = require(\"a\");",
+		},
+		{
+			name:   "namespace import",
+			source: "import A, * as ns from 'a';",
+			want:   "const ns = require('a'); const { default: A } = ns;",
+		},
+		{
+			name:   "multiline import keeps its lines",
+			source: "import {\n  a,\n  b\n} from 'a';\nconst c = a + b;",
+			want:   "const { a, b } = require('a');\n\n\n\nconst c = a + b;",
+		},
+		{
+			name:   "type import",
+			source: "import type { Props } from './types';",
+			want:   "import type { Props } from './types';",
+		},
+		{
+			name:   "dynamic import",
+			source: "const a = await import('./a');",
+			want:   "const a = await import('./a');",
+		},
+		{
+			name:   "import.meta.url",
+			source: "const url = import.meta.url;\nconst env = import.meta.env;",
+			want:   "const url = require('url').pathToFileURL(__filename).href;\nconst env = import.meta.env;",
+		},
+		{
+			name:   "exported declarations",
+			source: "export const a = 1, b = 2;\nexport async function getStaticPaths() {}\nexport class C {}",
+			want:   "const a = 1, b = 2;\nasync function getStaticPaths() {}\nclass C {}\nexports.a = a;\nexports.b = b;\nexports.getStaticPaths = getStaticPaths;\nexports.C = C;",
+		},
+		{
+			name:   "export clause",
+			source: "const a = 1;\nexport { a, a as b };",
+			want:   "const a = 1;\nexports.a = a;\nexports.b = a;",
+		},
+		{
+			name:   "re-exports",
+			source: "export * from 'a';\nexport * as b from 'b';\nexport { c as d } from 'c';",
+			want:   "for (const [key, value] of Object.entries(require('a'))) if (key !== 'default') exports[key] = value;\nexports.b = require('b');\nexports.d = require('c').c;",
+		},
+		{
+			name:   "default export",
+			source: "const $$Component = 1;\nexport default $$Component;",
+			want:   "const $$Component = 1;\nexports.default = $$Component;",
+		},
+		{
+			name:   "nested",
+			source: "function f() {\n  const s = `export const a = ${'import b'}`;\n}",
+			want:   "function f() {\n  const s = `export const a = ${'import b'}`;\n}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(ToCommonJS([]byte(tt.source), NodeModuleURL))
+			want := tt.want + esModule
+			if got != want {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %v\n  got:  %v", tt.name, want, got))
+			}
+		})
+	}
+}
+
+func TestNextModuleStatement(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "re-exports",
+			source: "export * from 'a';\nexport * as b from 'b'\nexport { c, d as e, default as f } from 'c'\n",
+			want:   []string{"export a: * as ", "export b: * as b", "export c: c as c, d as e, default as f"},
+		},
+		{
+			name:   "local exports",
+			source: "export const a = 'a';\nexport { a as b }\nimport c from 'c'\nexport default 'd'\n",
+			want:   []string{"c: default as c"},
+		},
+		{
+			name:   "imports and re-exports",
+			source: "import { default as a } from 'a'\nexport { b } from 'b'\n",
+			want:   []string{"a: default as a", "export b: b as b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]string, 0)
+			pos, statement := NextModuleStatement([]byte(tt.source), 0)
+			for pos != -1 {
+				imports := make([]string, 0)
+				for _, i := range statement.Imports {
+					imports = append(imports, fmt.Sprintf("%s as %s", i.ExportName, i.LocalName))
+				}
+				entry := fmt.Sprintf("%s: %s", statement.Specifier, strings.Join(imports, ", "))
+				if statement.IsExport {
+					entry = "export " + entry
+				}
+				got = append(got, entry)
+				pos, statement = NextModuleStatement([]byte(tt.source), pos)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %v\n  got:  %v", tt.name, tt.want, got))
+			}
+		})
+	}
+}
+
+func TestNextImportStatement(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "imports",
+			source: "import a from 'a';\nimport { b, c as d } from \"b\"\nimport * as e from 'e'\n",
+			want:   []string{"a: default as a", "b: b as b, c as d", "e: * as e"},
+		},
+		{
+			name:   "commented out imports",
+			source: "// import a from 'a'\n/* import b from 'b' */\nimport c from 'c'\n",
+			want:   []string{"c: default as c"},
+		},
+		{
+			name:   "imports in strings",
+			source: "const a = 'import a from \"a\"';\nconst b = `import b from 'b'`;\nimport c from 'c'\n",
+			want:   []string{"c: default as c"},
+		},
+		{
+			name:   "imports in regular expressions",
+			source: "const a = /import a from 'a'/;\nimport c from 'c'\n",
+			want:   []string{"c: default as c"},
+		},
+		{
+			name:   "comments inside imports",
+			source: "import { a, // 'x'\n b /* , c */ } from 'b' // import d from 'd'\nimport e from 'e' /* x\n */\n",
+			want:   []string{"b: a as a, b as b", "e: default as e"},
+		},
+		{
+			name:   "no line terminator",
+			source: "import a from 'a'",
+			want:   []string{"a: default as a"},
+		},
+		{
+			name:   "import meta",
+			source: "const a = import.meta.env;\nimport b from 'b'\n",
+			want:   []string{"b: default as b"},
+		},
+		{
+			name:   "import assertions",
+			source: "import a from './a.json' assert { type: 'json' };\nimport b from 'b'\n",
+			want:   []string{"./a.json: default as a assert { type: 'json' }", "b: default as b"},
+		},
+		{
+			name:   "import attributes",
+			source: "import { a } from './a.json' with {\n  type: 'json'\n}\nimport b from 'b'\n",
+			want:   []string{"./a.json: a as a with {\n  type: 'json'\n}", "b: default as b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]string, 0)
+			pos, statement := NextImportStatement([]byte(tt.source), 0)
+			for pos != -1 {
+				imports := make([]string, 0)
+				for _, i := range statement.Imports {
+					imports = append(imports, fmt.Sprintf("%s as %s", i.ExportName, i.LocalName))
+				}
+				entry := fmt.Sprintf("%s: %s", statement.Specifier, strings.Join(imports, ", "))
+				if statement.Attributes != "" {
+					entry += " " + statement.Attributes
+				}
+				got = append(got, entry)
+				pos, statement = NextImportStatement([]byte(tt.source), pos)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %v\n  got:  %v", tt.name, tt.want, got))
+			}
+		})
+	}
+}
+
+func TestAstroParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "member",
+			source: "const post = await getPost(Astro.params.slug);",
+			want:   []string{"slug@40"},
+		},
+		{
+			name:   "optional chaining",
+			source: "const lang = Astro.params?.lang;",
+			want:   []string{"lang@27"},
+		},
+		{
+			name:   "string key",
+			source: "const id = Astro.params['id'];",
+			want:   []string{"id@25"},
+		},
+		{
+			name:   "destructuring",
+			source: "const { slug, 'lang': l, page = 1, ...rest } = Astro.params;",
+			want:   []string{"slug@8", "lang@15", "page@25"},
+		},
+		{
+			name:   "nested pattern",
+			source: "const { a: { b }, c } = Astro.params;",
+			want:   []string{"a@8", "c@18"},
+		},
+		{
+			name:   "computed key",
+			source: "const key = 'slug';\nconst value = Astro.params[key];",
+			want:   []string{},
+		},
+		{
+			name:   "not astro",
+			source: "const slug = props.Astro.params.slug;\nconst params = Astro.params;",
+			want:   []string{},
+		},
+		{
+			name:   "template literal",
+			source: "const url = `/blog/${Astro.params.slug}/`;",
+			want:   []string{"slug@34"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]string, 0)
+			for _, p := range AstroParams([]byte(tt.source)) {
+				got = append(got, fmt.Sprintf("%s@%d", p.Name, p.Start))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %v\n  got:  %v", tt.name, tt.want, got))
+			}
+		})
+	}
+}
+
+func TestRouteExports(t *testing.T) {
+	tests := []struct {
+		name           string
+		source         string
+		getStaticPaths bool
+		prerender      string
+	}{
+		{
+			name:           "none",
+			source:         "const a = 1;",
+			getStaticPaths: false,
+			prerender:      "unset",
+		},
+		{
+			name:           "function",
+			source:         "export async function getStaticPaths() {\n  return [];\n}",
+			getStaticPaths: true,
+			prerender:      "unset",
+		},
+		{
+			name:           "const",
+			source:         "export const getStaticPaths = () => [];\nexport const prerender = true;",
+			getStaticPaths: true,
+			prerender:      "true",
+		},
+		{
+			name:           "prerender false",
+			source:         "export const prerender = false",
+			getStaticPaths: false,
+			prerender:      "false",
+		},
+		{
+			name:           "prerender expression",
+			source:         "export const prerender = import.meta.env.PRERENDER;",
+			getStaticPaths: false,
+			prerender:      "unset",
+		},
+		{
+			name:           "nested",
+			source:         "function f() {\n  const getStaticPaths = 1;\n}\nconst prerender = true;",
+			getStaticPaths: false,
+			prerender:      "unset",
+		},
+		{
+			name:           "mentioned",
+			source:         "// export getStaticPaths here\nconst s = 'getStaticPaths';",
+			getStaticPaths: false,
+			prerender:      "unset",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getStaticPaths := ExportsGetStaticPaths([]byte(tt.source))
+			prerender := "unset"
+			if value, _, ok := Prerender([]byte(tt.source)); ok {
+				prerender = fmt.Sprint(value)
+			}
+			if getStaticPaths != tt.getStaticPaths || prerender != tt.prerender {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %v %v\n  got:  %v %v", tt.name, tt.getStaticPaths, tt.prerender, getStaticPaths, prerender))
+			}
+		})
+	}
+}
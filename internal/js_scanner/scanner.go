@@ -0,0 +1,203 @@
+package js_scanner
+
+// The functions in this file find where literals and comments end in
+// JavaScript embedded in a document, like frontmatter and expressions. Unlike
+// the lexer, they work on byte positions, don't need the rest of the source
+// to be valid JavaScript or TypeScript, and can stop at any point.
+
+// SpanKind is the kind of span returned by Skip
+type SpanKind uint8
+
+const (
+	// CodeSpan is any single byte which doesn't start a literal or comment
+	CodeSpan SpanKind = iota
+	StringSpan
+	TemplateSpan
+	LineCommentSpan
+	BlockCommentSpan
+	RegExpSpan
+)
+
+// Skip returns the kind of the span starting at source[start], and the
+// position just past it. Literals and comments which are never closed end at
+// the end of source, except strings and regular expressions which can't span
+// lines and end before the line break.
+func Skip(source []byte, start int) (SpanKind, int) {
+	switch source[start] {
+	case '\'', '"':
+		return StringSpan, skipString(source, start)
+	case '`':
+		return TemplateSpan, skipTemplate(source, start)
+	case '/':
+		if start+1 < len(source) {
+			switch source[start+1] {
+			case '/':
+				return LineCommentSpan, skipLineComment(source, start)
+			case '*':
+				return BlockCommentSpan, skipBlockComment(source, start)
+			}
+		}
+		if regExpAllowed(source, start) {
+			end, _ := skipRegExp(source, start)
+			return RegExpSpan, end
+		}
+	}
+	return CodeSpan, start + 1
+}
+
+// Keywords after which an expression, and so a regular expression, can start
+var keywordsBeforeExpression = map[string]bool{
+	"return": true, "typeof": true, "instanceof": true, "in": true, "of": true,
+	"new": true, "delete": true, "void": true, "throw": true, "case": true,
+	"do": true, "else": true, "yield": true, "await": true,
+}
+
+// regExpAllowed reports whether the '/' at source[start] starts a regular
+// expression rather than being a division, from the token before it. A
+// division follows something with a value, like `a / b` or `(a) / b`, while a
+// regular expression follows an operator or keyword, like `x => /a/`.
+func regExpAllowed(source []byte, start int) bool {
+	i := start - 1
+	for i >= 0 && (source[i] == ' ' || source[i] == '\t' || isLineTerminator(source[i])) {
+		i--
+	}
+	if i < 0 {
+		return true
+	}
+	switch c := source[i]; {
+	case c == ')' || c == ']' || c == '\'' || c == '"' || c == '`':
+		return false
+	case c == '.' && i > 0 && '0' <= source[i-1] && source[i-1] <= '9':
+		// A number, like `1.`
+		return false
+	case (c == '+' || c == '-') && i > 0 && source[i-1] == c:
+		// A postfix `++` or `--` ends a value, like `a++ / 2`, but a prefix
+		// one is followed by its operand
+		return regExpAllowed(source, i-1)
+	case isIdentifierPart(c):
+		end := i + 1
+		for i >= 0 && isIdentifierPart(source[i]) {
+			i--
+		}
+		// A property named like a keyword, like `a.return / 2`, is still a value
+		if i >= 0 && source[i] == '.' {
+			return false
+		}
+		return keywordsBeforeExpression[string(source[i+1:end])]
+	}
+	return true
+}
+
+// MatchingBrace returns the position of the `}` which closes the `{` at
+// source[start], ignoring braces inside literals and comments, or -1 if it is
+// never closed
+func MatchingBrace(source []byte, start int) int {
+	depth := 0
+	for i := start; i < len(source); {
+		kind, end := Skip(source, i)
+		if kind == CodeSpan {
+			switch source[i] {
+			case '{':
+				depth++
+			case '}':
+				depth--
+				if depth == 0 {
+					return i
+				}
+			}
+		}
+		i = end
+	}
+	return -1
+}
+
+func isLineTerminator(c byte) bool {
+	return c == '\n' || c == '\r'
+}
+
+func skipString(source []byte, start int) int {
+	quote := source[start]
+	for i := start + 1; i < len(source); i++ {
+		switch c := source[i]; {
+		case c == '\\':
+			// Escaped line breaks continue the string
+			if i+2 < len(source) && source[i+1] == '\r' && source[i+2] == '\n' {
+				i++
+			}
+			i++
+		case c == quote:
+			return i + 1
+		case isLineTerminator(c):
+			return i
+		}
+	}
+	return len(source)
+}
+
+func skipTemplate(source []byte, start int) int {
+	for i := start + 1; i < len(source); i++ {
+		switch source[i] {
+		case '\\':
+			i++
+		case '`':
+			return i + 1
+		case '$':
+			if i+1 < len(source) && source[i+1] == '{' {
+				end := MatchingBrace(source, i+1)
+				if end == -1 {
+					return len(source)
+				}
+				i = end
+			}
+		}
+	}
+	return len(source)
+}
+
+func skipLineComment(source []byte, start int) int {
+	for i := start + 2; i < len(source); i++ {
+		if isLineTerminator(source[i]) {
+			return i
+		}
+	}
+	return len(source)
+}
+
+func skipBlockComment(source []byte, start int) int {
+	for i := start + 2; i+1 < len(source); i++ {
+		if source[i] == '*' && source[i+1] == '/' {
+			return i + 2
+		}
+	}
+	return len(source)
+}
+
+// skipRegExp returns the position past the regular expression at start, and
+// whether it's closed before the end of its line
+func skipRegExp(source []byte, start int) (int, bool) {
+	inClass := false
+	for i := start + 1; i < len(source); i++ {
+		switch c := source[i]; {
+		case c == '\\':
+			i++
+		case c == '[':
+			inClass = true
+		case c == ']':
+			inClass = false
+		case c == '/' && !inClass:
+			// Flags, like `g` in `/a/g`
+			i++
+			for i < len(source) && isIdentifierPart(source[i]) {
+				i++
+			}
+			return i, true
+		case isLineTerminator(c):
+			return i, false
+		}
+	}
+	return len(source), false
+}
+
+func isIdentifierPart(c byte) bool {
+	return c == '_' || c == '$' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c >= 0x80
+}
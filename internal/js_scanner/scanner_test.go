@@ -0,0 +1,239 @@
+package js_scanner
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSkip(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		kind   SpanKind
+		want   string
+	}{
+		{
+			name:   "code",
+			source: "a + b",
+			kind:   CodeSpan,
+			want:   "a",
+		},
+		{
+			name:   "double quoted string",
+			source: `"a \" } b" + c`,
+			kind:   StringSpan,
+			want:   `"a \" } b"`,
+		},
+		{
+			name:   "single quoted string",
+			source: `'it\'s' + c`,
+			kind:   StringSpan,
+			want:   `'it\'s'`,
+		},
+		{
+			name:   "unterminated string",
+			source: "'a\nb'",
+			kind:   StringSpan,
+			want:   "'a",
+		},
+		{
+			name:   "escaped line break",
+			source: "'a\\\nb' + c",
+			kind:   StringSpan,
+			want:   "'a\\\nb'",
+		},
+		{
+			name:   "template literal",
+			source: "`a ${b} c` + d",
+			kind:   TemplateSpan,
+			want:   "`a ${b} c`",
+		},
+		{
+			name:   "nested template literal",
+			source: "`${`${`${foo}`}`}` + d",
+			kind:   TemplateSpan,
+			want:   "`${`${`${foo}`}`}`",
+		},
+		{
+			name:   "template literal with braces",
+			source: "`${ { a: '}' }.a }` + d",
+			kind:   TemplateSpan,
+			want:   "`${ { a: '}' }.a }`",
+		},
+		{
+			name:   "escaped template literal",
+			source: "`\\` \\${a}` + d",
+			kind:   TemplateSpan,
+			want:   "`\\` \\${a}`",
+		},
+		{
+			name:   "line comment",
+			source: "// a } b\nc",
+			kind:   LineCommentSpan,
+			want:   "// a } b",
+		},
+		{
+			name:   "block comment",
+			source: "/* a } \n b */ c",
+			kind:   BlockCommentSpan,
+			want:   "/* a } \n b */",
+		},
+		{
+			name:   "unterminated block comment",
+			source: "/* a",
+			kind:   BlockCommentSpan,
+			want:   "/* a",
+		},
+		{
+			name:   "regular expression",
+			source: "/a\\/b/g.test(c)",
+			kind:   RegExpSpan,
+			want:   "/a\\/b/g",
+		},
+		{
+			name:   "regular expression with class",
+			source: "/[/}]/.test(c)",
+			kind:   RegExpSpan,
+			want:   "/[/}]/",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, end := Skip([]byte(tt.source), 0)
+			if kind != tt.kind || tt.source[:end] != tt.want {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %d %q\n  got:  %d %q", tt.name, tt.kind, tt.want, kind, tt.source[:end]))
+			}
+		})
+	}
+}
+
+func TestSkipSlash(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		kind   SpanKind
+		want   string
+	}{
+		{
+			name:   "regular expression after an arrow",
+			source: "i => /a\\/b/.test(i)",
+			kind:   RegExpSpan,
+			want:   "/a\\/b/",
+		},
+		{
+			name:   "regular expression after an operator",
+			source: "a = /b/g",
+			kind:   RegExpSpan,
+			want:   "/b/g",
+		},
+		{
+			name:   "regular expression after a keyword",
+			source: "return /b/",
+			kind:   RegExpSpan,
+			want:   "/b/",
+		},
+		{
+			name:   "division after an identifier",
+			source: "a / b / c",
+			kind:   CodeSpan,
+			want:   "/",
+		},
+		{
+			name:   "division after a number",
+			source: "10 / 2 / 1",
+			kind:   CodeSpan,
+			want:   "/",
+		},
+		{
+			name:   "division after parentheses",
+			source: "(a + b) / 2 / c",
+			kind:   CodeSpan,
+			want:   "/",
+		},
+		{
+			name:   "division after a postfix increment",
+			source: "a++ / 2 / c",
+			kind:   CodeSpan,
+			want:   "/",
+		},
+		{
+			name:   "division after a postfix decrement",
+			source: "list[i]-- / 2 / c",
+			kind:   CodeSpan,
+			want:   "/",
+		},
+		{
+			name:   "regular expression after a prefix increment",
+			source: "x = ++/a/.lastIndex",
+			kind:   RegExpSpan,
+			want:   "/a/",
+		},
+		{
+			name:   "division after a property named like a keyword",
+			source: "a.return / 2 / c",
+			kind:   CodeSpan,
+			want:   "/",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := strings.IndexByte(tt.source, '/')
+			kind, end := Skip([]byte(tt.source), start)
+			if kind != tt.kind || tt.source[start:end] != tt.want {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %d %q\n  got:  %d %q", tt.name, tt.kind, tt.want, kind, tt.source[start:end]))
+			}
+		})
+	}
+}
+
+func TestMatchingBrace(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   int
+	}{
+		{
+			name:   "simple",
+			source: "{a}",
+			want:   2,
+		},
+		{
+			name:   "nested",
+			source: "{{a: {b}}} c}",
+			want:   9,
+		},
+		{
+			name:   "strings",
+			source: `{"}" + '{' + c}`,
+			want:   14,
+		},
+		{
+			name:   "template literal",
+			source: "{`}${ '}' }`}",
+			want:   12,
+		},
+		{
+			name:   "comments",
+			source: "{a /* } */ // }\n}",
+			want:   16,
+		},
+		{
+			name:   "regular expression and division",
+			source: "{a / b + /}/.test(c) / d}",
+			want:   24,
+		},
+		{
+			name:   "unclosed",
+			source: "{a {b}",
+			want:   -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchingBrace([]byte(tt.source), 0); got != tt.want {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %d\n  got:  %d", tt.name, tt.want, got))
+			}
+		})
+	}
+}
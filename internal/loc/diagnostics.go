@@ -0,0 +1,160 @@
+package loc
+
+import "fmt"
+
+type DiagnosticSeverity int
+
+const (
+	ErrorType       DiagnosticSeverity = 1
+	WarningType     DiagnosticSeverity = 2
+	InformationType DiagnosticSeverity = 3
+	HintType        DiagnosticSeverity = 4
+)
+
+type DiagnosticCode int
+
+const (
+	ERROR   DiagnosticCode = 1000
+	WARNING DiagnosticCode = 2000
+
+	ERROR_INPUT_TOO_LARGE  DiagnosticCode = 1001
+	ERROR_TOO_MANY_NODES   DiagnosticCode = 1002
+	ERROR_NESTING_TOO_DEEP DiagnosticCode = 1003
+	ERROR_COMPILE_TIMEOUT  DiagnosticCode = 1004
+
+	WARNING_IMAGE_MISSING_ALT        DiagnosticCode = 2001
+	WARNING_IMAGE_MISSING_DIMENSIONS DiagnosticCode = 2002
+	WARNING_IMAGE_LOCAL_PATH         DiagnosticCode = 2003
+	WARNING_UNKNOWN_DIRECTIVE        DiagnosticCode = 2004
+	WARNING_UNCLOSED_ELEMENT         DiagnosticCode = 2005
+	WARNING_DUPLICATE_ATTRIBUTE      DiagnosticCode = 2006
+	WARNING_SCRIPT_INTERPOLATION     DiagnosticCode = 2007
+	WARNING_UNUSED_IMPORT            DiagnosticCode = 2008
+	WARNING_UNDEFINED_COMPONENT      DiagnosticCode = 2009
+	WARNING_DUPLICATE_PROP           DiagnosticCode = 2010
+	WARNING_UNKNOWN_AT_RULE          DiagnosticCode = 2011
+	WARNING_UNUSED_SELECTOR          DiagnosticCode = 2012
+	WARNING_UNUSED_COMPONENT         DiagnosticCode = 2013
+	WARNING_UNDECLARED_PROP          DiagnosticCode = 2014
+	WARNING_UNRENDERED_SLOT          DiagnosticCode = 2015
+	WARNING_UNKNOWN_ROUTE_PARAM      DiagnosticCode = 2016
+	WARNING_MISSING_STATIC_PATHS     DiagnosticCode = 2017
+	WARNING_UNCLOSED_FRONTMATTER     DiagnosticCode = 2018
+	WARNING_ISLAND_BUDGET            DiagnosticCode = 2019
+	WARNING_INLINE_SCRIPT_BUDGET     DiagnosticCode = 2020
+
+	WARNING_A11Y_UNKNOWN_ARIA_ATTRIBUTE DiagnosticCode = 2101
+	WARNING_A11Y_UNKNOWN_ROLE           DiagnosticCode = 2102
+	WARNING_A11Y_CLICK_NON_INTERACTIVE  DiagnosticCode = 2103
+	WARNING_A11Y_LABEL_WITHOUT_CONTROL  DiagnosticCode = 2104
+)
+
+// The names of the diagnostic codes. Like the codes, they never change once
+// released, so tools can map them to documentation and quick fixes.
+var diagnosticNames = map[DiagnosticCode]string{
+	ERROR:   "Error",
+	WARNING: "Warning",
+
+	ERROR_INPUT_TOO_LARGE:  "InputTooLarge",
+	ERROR_TOO_MANY_NODES:   "TooManyNodes",
+	ERROR_NESTING_TOO_DEEP: "NestingTooDeep",
+	ERROR_COMPILE_TIMEOUT:  "CompileTimeout",
+
+	WARNING_IMAGE_MISSING_ALT:        "ImageMissingAlt",
+	WARNING_IMAGE_MISSING_DIMENSIONS: "ImageMissingDimensions",
+	WARNING_IMAGE_LOCAL_PATH:         "ImageLocalPath",
+	WARNING_UNKNOWN_DIRECTIVE:        "UnknownDirective",
+	WARNING_UNCLOSED_ELEMENT:         "UnclosedElement",
+	WARNING_DUPLICATE_ATTRIBUTE:      "DuplicateAttribute",
+	WARNING_SCRIPT_INTERPOLATION:     "ScriptInterpolation",
+	WARNING_UNUSED_IMPORT:            "UnusedImport",
+	WARNING_UNDEFINED_COMPONENT:      "UndefinedComponent",
+	WARNING_DUPLICATE_PROP:           "DuplicateProp",
+	WARNING_UNKNOWN_AT_RULE:          "UnknownAtRule",
+	WARNING_UNUSED_SELECTOR:          "UnusedSelector",
+	WARNING_UNUSED_COMPONENT:         "UnusedComponent",
+	WARNING_UNDECLARED_PROP:          "UndeclaredProp",
+	WARNING_UNRENDERED_SLOT:          "UnrenderedSlot",
+	WARNING_UNKNOWN_ROUTE_PARAM:      "UnknownRouteParam",
+	WARNING_MISSING_STATIC_PATHS:     "MissingStaticPaths",
+	WARNING_UNCLOSED_FRONTMATTER:     "UnclosedFrontmatter",
+	WARNING_ISLAND_BUDGET:            "IslandBudget",
+	WARNING_INLINE_SCRIPT_BUDGET:     "InlineScriptBudget",
+
+	WARNING_A11Y_UNKNOWN_ARIA_ATTRIBUTE: "A11yUnknownAriaAttribute",
+	WARNING_A11Y_UNKNOWN_ROLE:           "A11yUnknownRole",
+	WARNING_A11Y_CLICK_NON_INTERACTIVE:  "A11yClickNonInteractive",
+	WARNING_A11Y_LABEL_WITHOUT_CONTROL:  "A11yLabelWithoutControl",
+}
+
+// ID returns the stable identifier of c, like "AST2005"
+func (c DiagnosticCode) ID() string {
+	return fmt.Sprintf("AST%d", c)
+}
+
+// Name returns the stable name of c, like "UnclosedElement"
+func (c DiagnosticCode) Name() string {
+	return diagnosticNames[c]
+}
+
+// ErrorWithRange is an error tied to a range of the source text
+type ErrorWithRange struct {
+	Code  DiagnosticCode
+	Text  string
+	Hint  string
+	Range Range
+	// Edits which resolve the problem, for editors to offer as quick fixes
+	Fixes []Fix
+}
+
+// Fix replaces Range of the source text with Text. A range of length zero
+// inserts Text.
+type Fix struct {
+	Title string
+	Range Range
+	Text  string
+}
+
+func (e *ErrorWithRange) Error() string {
+	return e.Text
+}
+
+func (e *ErrorWithRange) ToMessage(location *DiagnosticLocation) DiagnosticMessage {
+	return DiagnosticMessage{
+		Code:     int(e.Code),
+		ID:       e.Code.ID(),
+		Name:     e.Code.Name(),
+		Text:     e.Text,
+		Hint:     e.Hint,
+		Location: location,
+	}
+}
+
+type DiagnosticMessage struct {
+	Severity int                 `js:"severity" json:"severity"`
+	Code     int                 `js:"code" json:"code"`
+	ID       string              `js:"id" json:"id"`
+	Name     string              `js:"name" json:"name"`
+	Text     string              `js:"text" json:"text"`
+	Hint     string              `js:"hint" json:"hint"`
+	Location *DiagnosticLocation `js:"location" json:"location"`
+	Fixes    []DiagnosticFix     `js:"fixes" json:"fixes,omitempty"`
+}
+
+// DiagnosticFix is a Fix with its range as a location, which language
+// servers can turn into a code action directly
+type DiagnosticFix struct {
+	Title    string              `js:"title" json:"title"`
+	Location *DiagnosticLocation `js:"location" json:"location"`
+	Text     string              `js:"text" json:"text"`
+}
+
+// DiagnosticLocation uses 1-based lines and columns, with columns counted in
+// UTF-16 code units to match JavaScript tooling
+type DiagnosticLocation struct {
+	File     string `js:"file" json:"file"`
+	Line     int    `js:"line" json:"line"`
+	Column   int    `js:"column" json:"column"`
+	Length   int    `js:"length" json:"length"`
+	LineText string `js:"lineText" json:"lineText"`
+}
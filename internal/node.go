@@ -28,6 +28,11 @@ const (
 	// Extensions
 	FrontmatterNode
 	ExpressionNode
+	// Processing instructions like `<?xml ...?>` and CDATA sections outside
+	// of foreign content in XML documents, which keep their own type so they
+	// can be printed as authored
+	ProcessingInstructionNode
+	CDATANode
 )
 
 // Used as an Attribute Key to mark implicit nodes
@@ -60,6 +65,8 @@ type Node struct {
 	Styles, Scripts      []*Node
 	HydratedComponents   []*Node
 	ClientOnlyComponents []*Node
+	// The define:vars expressions of hoisted styles, set on root elements
+	DefinedVars []string
 
 	Type      NodeType
 	DataAtom  atom.Atom
@@ -67,6 +74,9 @@ type Node struct {
 	Namespace string
 	Attr      []Attribute
 	Loc       []loc.Loc
+
+	// The nodes of a parsed document, only set on its root
+	arena *nodeArena
 }
 
 // InsertBefore inserts newChild as a child of n, immediately before oldChild
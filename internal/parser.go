@@ -5,11 +5,13 @@
 package astro
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"strings"
 
+	"github.com/snowpackjs/astro/internal/handler"
 	"github.com/snowpackjs/astro/internal/loc"
 	a "golang.org/x/net/html/atom"
 )
@@ -19,6 +21,8 @@ import (
 type parser struct {
 	// tokenizer provides the tokens for the parser.
 	tokenizer *Tokenizer
+	// arena allocates the nodes of the document.
+	arena *nodeArena
 	// tok is the most recently read token.
 	tok  Token
 	ltok Token
@@ -56,6 +60,16 @@ type parser struct {
 	// context is the context element when parsing an HTML fragment
 	// (section 12.4).
 	context *Node
+	// handler collects any warnings found while parsing. It may be nil.
+	handler *handler.Handler
+	// ctx abandons the parse once it's done. It may be nil.
+	ctx context.Context
+	// The most nodes and the deepest nesting of expressions which the document
+	// may have, or zero for no limit
+	maxNodes, maxExpressionDepth int
+	nodeCount                    int
+	// limitErr is the limit the document went over, which ends the parse
+	limitErr error
 }
 
 func (p *parser) top() *Node {
@@ -105,6 +119,9 @@ const (
 // ["html", "body", "font"]
 func (p *parser) popUntil(s scope, matchTags ...a.Atom) bool {
 	if i := p.indexOfElementInScope(s, matchTags...); i != -1 {
+		for _, n := range p.oe[i+1:] {
+			p.warnUnclosed(n)
+		}
 		p.oe = p.oe[:i]
 		return true
 	}
@@ -323,11 +340,11 @@ func (p *parser) addText(text string) {
 	}
 
 	if p.shouldFosterParent() {
-		p.fosterParent(&Node{
+		p.fosterParent(p.newNode(Node{
 			Type: TextNode,
 			Data: text,
 			Loc:  p.generateLoc(),
-		})
+		}))
 		return
 	}
 
@@ -341,26 +358,26 @@ func (p *parser) addText(text string) {
 		n.Data += text
 		return
 	}
-	p.addChild(&Node{
+	p.addChild(p.newNode(Node{
 		Type: TextNode,
 		Data: text,
 		Loc:  p.generateLoc(),
-	})
+	}))
 }
 
 func (p *parser) addFrontmatter(empty bool) {
 	if p.frontmatterState == FrontmatterInitial {
 		if p.doc.FirstChild != nil {
-			p.fm = &Node{
+			p.fm = p.newNode(Node{
 				Type: FrontmatterNode,
 				Loc:  p.generateLoc(),
-			}
+			})
 			p.doc.InsertBefore(p.fm, p.doc.FirstChild)
 		} else {
-			p.fm = &Node{
+			p.fm = p.newNode(Node{
 				Type: FrontmatterNode,
 				Loc:  p.generateLoc(),
-			}
+			})
 			p.doc.AppendChild(p.fm)
 		}
 		if empty {
@@ -374,7 +391,22 @@ func (p *parser) addFrontmatter(empty bool) {
 
 // addExpression adds a child expression based on the current token.
 func (p *parser) addExpression() {
-	p.addChild(&Node{
+	if p.maxExpressionDepth > 0 && p.limitErr == nil {
+		depth := 1
+		for _, n := range p.oe {
+			if n.Expression {
+				depth++
+			}
+		}
+		if depth > p.maxExpressionDepth {
+			p.limitErr = &loc.ErrorWithRange{
+				Code:  loc.ERROR_NESTING_TOO_DEEP,
+				Text:  fmt.Sprintf("Expressions are nested more than the limit of %d levels deep", p.maxExpressionDepth),
+				Range: loc.Range{Loc: p.generateLoc()[0], Len: 1},
+			}
+		}
+	}
+	p.addChild(p.newNode(Node{
 		Type:          ElementNode,
 		DataAtom:      a.Template,
 		Data:          "astro:expression",
@@ -383,7 +415,7 @@ func (p *parser) addExpression() {
 		Component:     false,
 		CustomElement: false,
 		Loc:           p.generateLoc(),
-	})
+	}))
 }
 
 func isFragment(data string) bool {
@@ -414,7 +446,7 @@ func (p *parser) isInsideHead() bool {
 
 // addElement adds a child element based on the current token.
 func (p *parser) addElement() {
-	p.addChild(&Node{
+	p.addChild(p.newNode(Node{
 		Type:          ElementNode,
 		DataAtom:      p.tok.DataAtom,
 		Data:          p.tok.Data,
@@ -423,7 +455,7 @@ func (p *parser) addElement() {
 		Component:     isComponent(p.tok.Data),
 		CustomElement: isCustomElement(p.tok.Data),
 		Loc:           p.generateLoc(),
-	})
+	}))
 }
 
 // Section 12.2.4.3.
@@ -621,11 +653,11 @@ func initialIM(p *parser) bool {
 		}
 		p.addText(p.tok.Data)
 	case CommentToken:
-		p.doc.AppendChild(&Node{
-			Type: CommentNode,
+		p.doc.AppendChild(p.newNode(Node{
+			Type: p.tokenizer.commentType,
 			Data: p.tok.Data,
 			Loc:  p.generateLoc(),
-		})
+		}))
 		return true
 	case DoctypeToken:
 		n, quirks := parseDoctype(p.tok.Data)
@@ -679,11 +711,11 @@ func beforeHTMLIM(p *parser) bool {
 			return true
 		}
 	case CommentToken:
-		p.doc.AppendChild(&Node{
-			Type: CommentNode,
+		p.doc.AppendChild(p.newNode(Node{
+			Type: p.tokenizer.commentType,
 			Data: p.tok.Data,
 			Loc:  p.generateLoc(),
-		})
+		}))
 		return true
 	}
 	p.parseImpliedToken(StartTagToken, a.Html, a.Html.String())
@@ -716,11 +748,11 @@ func beforeHeadIM(p *parser) bool {
 			return true
 		}
 	case CommentToken:
-		p.addChild(&Node{
-			Type: CommentNode,
+		p.addChild(p.newNode(Node{
+			Type: p.tokenizer.commentType,
 			Data: p.tok.Data,
 			Loc:  p.generateLoc(),
-		})
+		}))
 		return true
 	case DoctypeToken:
 		// Ignore the token.
@@ -840,7 +872,7 @@ func inHeadIM(p *parser) bool {
 	case EndTagToken:
 		if isComponent(p.tok.Data) || isFragment(p.tok.Data) {
 			p.addLoc()
-			p.oe.pop()
+			p.popComponent()
 			return true
 		}
 
@@ -879,11 +911,11 @@ func inHeadIM(p *parser) bool {
 			return true
 		}
 	case CommentToken:
-		p.addChild(&Node{
-			Type: CommentNode,
+		p.addChild(p.newNode(Node{
+			Type: p.tokenizer.commentType,
 			Data: p.tok.Data,
 			Loc:  p.generateLoc(),
-		})
+		}))
 		return true
 	case DoctypeToken:
 		// Ignore the token.
@@ -996,11 +1028,11 @@ func afterHeadIM(p *parser) bool {
 			return true
 		}
 	case CommentToken:
-		p.addChild(&Node{
-			Type: CommentNode,
+		p.addChild(p.newNode(Node{
+			Type: p.tokenizer.commentType,
 			Data: p.tok.Data,
 			Loc:  p.generateLoc(),
-		})
+		}))
 		return true
 	case DoctypeToken:
 		// Ignore the token.
@@ -1045,19 +1077,10 @@ func inBodyIM(p *parser) bool {
 		p.im = frontmatterIM
 		return false
 	case TextToken:
+		// Unlike the HTML5 spec, a newline at the start of a <pre> block is
+		// preserved. The browser will ignore it when the output is rendered,
+		// but this keeps the whitespace byte-for-byte identical to the source.
 		d := p.tok.Data
-		switch n := p.top(); n.DataAtom {
-		case a.Pre, a.Listing:
-			if n.FirstChild == nil {
-				// Ignore a newline at the start of a <pre> block.
-				if d != "" && d[0] == '\r' {
-					d = d[1:]
-				}
-				if d != "" && d[0] == '\n' {
-					d = d[1:]
-				}
-			}
-		}
 		d = strings.Replace(d, "\x00", "", -1)
 		if d == "" {
 			return true
@@ -1335,7 +1358,7 @@ func inBodyIM(p *parser) bool {
 	case EndTagToken:
 		if isComponent(p.tok.Data) {
 			p.addLoc()
-			p.oe.pop()
+			p.popComponent()
 			return true
 		}
 
@@ -1343,6 +1366,9 @@ func inBodyIM(p *parser) bool {
 		case a.Body:
 			p.addLoc()
 			if p.elementInScope(defaultScope, a.Body) {
+				for _, e := range p.oe {
+					p.warnUnclosed(e)
+				}
 				p.im = afterBodyIM
 			}
 		case a.Html:
@@ -1412,11 +1438,11 @@ func inBodyIM(p *parser) bool {
 			p.inBodyEndTagOther(p.tok.DataAtom, p.tok.Data)
 		}
 	case CommentToken:
-		p.addChild(&Node{
-			Type: CommentNode,
+		p.addChild(p.newNode(Node{
+			Type: p.tokenizer.commentType,
 			Data: p.tok.Data,
 			Loc:  p.generateLoc(),
-		})
+		}))
 	case StartExpressionToken:
 		p.reconstructActiveFormattingElements()
 		p.addExpression()
@@ -1431,14 +1457,16 @@ func inBodyIM(p *parser) bool {
 			p.im = inTemplateIM
 			return false
 		}
+		unclosed := false
 		for _, e := range p.oe {
-			switch e.DataAtom {
-			case a.Dd, a.Dt, a.Li, a.Optgroup, a.Option, a.P, a.Rb, a.Rp, a.Rt, a.Rtc, a.Tbody, a.Td, a.Tfoot, a.Th,
-				a.Thead, a.Tr, a.Body, a.Html:
-			default:
-				return true
+			if !hasOptionalEndTag(e) {
+				p.warnUnclosed(e)
+				unclosed = true
 			}
 		}
+		if unclosed {
+			return true
+		}
 	}
 	if p.frontmatterState == FrontmatterInitial {
 		p.addFrontmatter(true)
@@ -1616,6 +1644,9 @@ func (p *parser) inBodyEndTagOther(tagAtom a.Atom, tagName string) {
 			p.addLoc()
 			// If we only have a single element, just ignore it
 			if len(p.oe) > 1 {
+				for _, n := range p.oe[i+1:] {
+					p.warnUnclosed(n)
+				}
 				p.oe = p.oe[:i]
 			}
 			break
@@ -1627,22 +1658,86 @@ func (p *parser) inBodyEndTagOther(tagAtom a.Atom, tagName string) {
 	}
 }
 
+// popComponent closes the component or fragment named by the current end tag.
+// Components left open inside of it were probably meant to be self-closing, so
+// they are closed too rather than swallowing the siblings that follow.
+func (p *parser) popComponent() {
+	for i := len(p.oe) - 1; i >= 0; i-- {
+		n := p.oe[i]
+		if n.Data == p.tok.Data {
+			for _, e := range p.oe[i+1:] {
+				p.warnUnclosed(e)
+			}
+			p.oe = p.oe[:i]
+			return
+		}
+		if n.Expression || !(n.Component || n.Fragment) {
+			break
+		}
+	}
+	p.oe.pop()
+}
+
+// hasOptionalEndTag returns true for elements which are allowed to be closed
+// implicitly at the end of the file.
+func hasOptionalEndTag(n *Node) bool {
+	switch n.DataAtom {
+	case a.Dd, a.Dt, a.Li, a.Optgroup, a.Option, a.P, a.Rb, a.Rp, a.Rt, a.Rtc, a.Tbody, a.Td, a.Tfoot, a.Th,
+		a.Thead, a.Tr, a.Body, a.Html:
+		return true
+	}
+	return false
+}
+
+// warnUnclosed reports an element which requires an end tag but was closed
+// implicitly, either by an ancestor's end tag or by the end of the file.
+func (p *parser) warnUnclosed(n *Node) {
+	if p.handler == nil || n.Type != ElementNode || n.Expression || len(n.Loc) == 0 {
+		return
+	}
+	if hasOptionalEndTag(n) || n.DataAtom == a.Head || n.DataAtom == a.Colgroup || n.DataAtom == a.Caption {
+		return
+	}
+	for _, attr := range n.Attr {
+		if attr.Key == ImplicitNodeMarker {
+			return
+		}
+	}
+	hint := fmt.Sprintf("Add a closing </%s> tag, or self-close it with <%s />", n.Data, n.Data)
+	// Components are usually meant to be self-closing, and everything after them ends up as a child
+	if n.Component && n.FirstChild != nil {
+		hint = fmt.Sprintf("Everything after <%s> became its children. %s", n.Data, hint)
+	}
+	p.handler.AppendWarning(&loc.ErrorWithRange{
+		Code:  loc.WARNING_UNCLOSED_ELEMENT,
+		Text:  fmt.Sprintf("<%s> was not closed", n.Data),
+		Hint:  hint,
+		Range: loc.Range{Loc: n.Loc[0], Len: len(n.Data) + 1},
+		Fixes: []loc.Fix{{
+			Title: fmt.Sprintf("Add a closing </%s> tag", n.Data),
+			Range: loc.Range{Loc: p.implicitEndLoc()},
+			Text:  fmt.Sprintf("</%s>", n.Data),
+		}},
+	})
+}
+
+// implicitEndLoc returns where elements closed implicitly by the current
+// token end: before an end tag, or at the end of the file
+func (p *parser) implicitEndLoc() loc.Loc {
+	if p.tok.Type == ErrorToken {
+		return loc.Loc{Start: len(p.tokenizer.buf)}
+	}
+	return p.tok.Loc
+}
+
 // Section 12.2.6.4.8.
 func textIM(p *parser) bool {
 	switch p.tok.Type {
 	case ErrorToken:
 		break
 	case TextToken:
+		// A newline at the start of a <textarea> block is preserved, see inBodyIM.
 		d := p.tok.Data
-		if n := p.oe.top(); n.DataAtom == a.Textarea && n.FirstChild == nil {
-			// Ignore a newline at the start of a <textarea> block.
-			if d != "" && d[0] == '\r' {
-				d = d[1:]
-			}
-			if d != "" && d[0] == '\n' {
-				d = d[1:]
-			}
-		}
 		if d == "" {
 			return true
 		}
@@ -1755,11 +1850,11 @@ func inTableIM(p *parser) bool {
 			return inHeadIM(p)
 		}
 	case CommentToken:
-		p.addChild(&Node{
-			Type: CommentNode,
+		p.addChild(p.newNode(Node{
+			Type: p.tokenizer.commentType,
 			Data: p.tok.Data,
 			Loc:  p.generateLoc(),
-		})
+		}))
 		return true
 	case DoctypeToken:
 		// Ignore the token.
@@ -1832,11 +1927,11 @@ func inColumnGroupIM(p *parser) bool {
 			p.tok.Data = s
 		}
 	case CommentToken:
-		p.addChild(&Node{
-			Type: CommentNode,
+		p.addChild(p.newNode(Node{
+			Type: p.tokenizer.commentType,
 			Data: p.tok.Data,
 			Loc:  p.generateLoc(),
-		})
+		}))
 		return true
 	case DoctypeToken:
 		// Ignore the token.
@@ -1920,11 +2015,11 @@ func inTableBodyIM(p *parser) bool {
 			return true
 		}
 	case CommentToken:
-		p.addChild(&Node{
-			Type: CommentNode,
+		p.addChild(p.newNode(Node{
+			Type: p.tokenizer.commentType,
 			Data: p.tok.Data,
 			Loc:  p.generateLoc(),
-		})
+		}))
 		return true
 	}
 
@@ -2103,11 +2198,11 @@ func inSelectIM(p *parser) bool {
 			return inHeadIM(p)
 		}
 	case CommentToken:
-		p.addChild(&Node{
-			Type: CommentNode,
+		p.addChild(p.newNode(Node{
+			Type: p.tokenizer.commentType,
 			Data: p.tok.Data,
 			Loc:  p.generateLoc(),
-		})
+		}))
 	case StartExpressionToken:
 		p.addExpression()
 		p.setOriginalIM()
@@ -2248,11 +2343,11 @@ func afterBodyIM(p *parser) bool {
 		if len(p.oe) < 1 || p.oe[0].DataAtom != a.Html {
 			panic("html: bad parser state: <html> element not found, in the after-body insertion mode")
 		}
-		p.oe[0].AppendChild(&Node{
-			Type: CommentNode,
+		p.oe[0].AppendChild(p.newNode(Node{
+			Type: p.tokenizer.commentType,
 			Data: p.tok.Data,
 			Loc:  p.generateLoc(),
-		})
+		}))
 		return true
 	}
 	p.im = inBodyIM
@@ -2263,11 +2358,11 @@ func afterBodyIM(p *parser) bool {
 func inFramesetIM(p *parser) bool {
 	switch p.tok.Type {
 	case CommentToken:
-		p.addChild(&Node{
-			Type: CommentNode,
+		p.addChild(p.newNode(Node{
+			Type: p.tokenizer.commentType,
 			Data: p.tok.Data,
 			Loc:  p.generateLoc(),
-		})
+		}))
 	case TextToken:
 		// Ignore all text but whitespace.
 		s := strings.Map(func(c rune) rune {
@@ -2314,11 +2409,11 @@ func inFramesetIM(p *parser) bool {
 func afterFramesetIM(p *parser) bool {
 	switch p.tok.Type {
 	case CommentToken:
-		p.addChild(&Node{
-			Type: CommentNode,
+		p.addChild(p.newNode(Node{
+			Type: p.tokenizer.commentType,
 			Data: p.tok.Data,
 			Loc:  p.generateLoc(),
-		})
+		}))
 	case TextToken:
 		// Ignore all text but whitespace.
 		s := strings.Map(func(c rune) rune {
@@ -2367,11 +2462,11 @@ func afterAfterBodyIM(p *parser) bool {
 			return inBodyIM(p)
 		}
 	case CommentToken:
-		p.doc.AppendChild(&Node{
-			Type: CommentNode,
+		p.doc.AppendChild(p.newNode(Node{
+			Type: p.tokenizer.commentType,
 			Data: p.tok.Data,
 			Loc:  p.generateLoc(),
-		})
+		}))
 		return true
 	case DoctypeToken:
 		return inBodyIM(p)
@@ -2384,11 +2479,11 @@ func afterAfterBodyIM(p *parser) bool {
 func afterAfterFramesetIM(p *parser) bool {
 	switch p.tok.Type {
 	case CommentToken:
-		p.doc.AppendChild(&Node{
-			Type: CommentNode,
+		p.doc.AppendChild(p.newNode(Node{
+			Type: p.tokenizer.commentType,
 			Data: p.tok.Data,
 			Loc:  p.generateLoc(),
-		})
+		}))
 	case TextToken:
 		// Ignore all text but whitespace.
 		s := strings.Map(func(c rune) rune {
@@ -2543,11 +2638,11 @@ func parseForeignContent(p *parser) bool {
 		p.tok.Data = strings.Replace(p.tok.Data, "\x00", "\ufffd", -1)
 		p.addText(p.tok.Data)
 	case CommentToken:
-		p.addChild(&Node{
-			Type: CommentNode,
+		p.addChild(p.newNode(Node{
+			Type: p.tokenizer.commentType,
 			Data: p.tok.Data,
 			Loc:  p.generateLoc(),
-		})
+		}))
 	case StartTagToken:
 		if !p.fragment {
 			b := breakout[p.tok.Data]
@@ -2718,10 +2813,33 @@ func (p *parser) parse() error {
 			}
 		}
 		p.parseCurrentToken()
+		if p.limitErr != nil {
+			return p.limitErr
+		}
+		if p.ctx != nil {
+			if err := p.ctx.Err(); err != nil {
+				return err
+			}
+		}
 	}
+	p.warnUnclosedFrontmatter()
 	return nil
 }
 
+// warnUnclosedFrontmatter reports an opening frontmatter fence without a
+// closing one, which makes the whole file part of the component script
+func (p *parser) warnUnclosedFrontmatter() {
+	if p.handler == nil || p.frontmatterState != FrontmatterOpen || p.fm == nil || len(p.fm.Loc) == 0 {
+		return
+	}
+	p.handler.AppendWarning(&loc.ErrorWithRange{
+		Code:  loc.WARNING_UNCLOSED_FRONTMATTER,
+		Text:  "The frontmatter fence --- was not closed",
+		Hint:  "Add a closing --- after the component script, otherwise the whole file is part of it",
+		Range: loc.Range{Loc: p.fm.Loc[0], Len: len("---")},
+	})
+}
+
 // Parse returns the parse tree for the HTML from the given Reader.
 //
 // It implements the HTML5 parsing algorithm
@@ -2759,6 +2877,61 @@ func ParseOptionEnableScripting(enable bool) ParseOption {
 	}
 }
 
+// ParseOptionXML configures the parser for XML documents, which don't follow
+// any of the HTML-specific parsing rules.
+func ParseOptionXML(enable bool) ParseOption {
+	return func(p *parser) {
+		if enable {
+			p.im = xmlIM
+			p.tokenizer.xml = true
+		}
+	}
+}
+
+// ParseOptionWithHandler configures a handler which collects any warnings
+// found while parsing, like elements that were never closed.
+func ParseOptionWithHandler(h *handler.Handler) ParseOption {
+	return func(p *parser) {
+		p.handler = h
+	}
+}
+
+// ParseOptionContext abandons the parse with ctx.Err() once ctx is done, so
+// parsing a huge document can be cut short.
+func ParseOptionContext(ctx context.Context) ParseOption {
+	return func(p *parser) {
+		p.ctx = ctx
+	}
+}
+
+// ParseOptionLimits ends the parse with a *loc.ErrorWithRange as soon as the
+// document has more than maxNodes nodes, or expressions nested more than
+// maxExpressionDepth levels deep. Zero means no limit.
+func ParseOptionLimits(maxNodes int, maxExpressionDepth int) ParseOption {
+	return func(p *parser) {
+		p.maxNodes = maxNodes
+		p.maxExpressionDepth = maxExpressionDepth
+	}
+}
+
+// newNode allocates n from the arena of the parse, counting it against the
+// limit of nodes
+func (p *parser) newNode(n Node) *Node {
+	p.nodeCount++
+	if p.maxNodes > 0 && p.nodeCount > p.maxNodes && p.limitErr == nil {
+		r := loc.Range{Loc: loc.Loc{Start: 0}}
+		if len(n.Loc) > 0 {
+			r = loc.Range{Loc: n.Loc[0], Len: len(n.Data) + 1}
+		}
+		p.limitErr = &loc.ErrorWithRange{
+			Code:  loc.ERROR_TOO_MANY_NODES,
+			Text:  fmt.Sprintf("Document has more than the limit of %d nodes", p.maxNodes),
+			Range: r,
+		}
+	}
+	return p.arena.alloc(n)
+}
+
 // ParseWithOptions is like Parse, with options.
 func ParseWithOptions(r io.Reader, opts ...ParseOption) (*Node, error) {
 	p := &parser{
@@ -2766,6 +2939,7 @@ func ParseWithOptions(r io.Reader, opts ...ParseOption) (*Node, error) {
 		doc: &Node{
 			Type: DocumentNode,
 		},
+		arena:            &nodeArena{},
 		scripting:        true,
 		framesetOK:       true,
 		im:               initialIM,
@@ -2779,6 +2953,7 @@ func ParseWithOptions(r io.Reader, opts ...ParseOption) (*Node, error) {
 	if err := p.parse(); err != nil {
 		return nil, err
 	}
+	p.doc.arena = p.arena
 	return p.doc, nil
 }
 
@@ -2801,6 +2976,7 @@ func ParseFragmentWithOptions(r io.Reader, context *Node, opts ...ParseOption) (
 		doc: &Node{
 			Type: DocumentNode,
 		},
+		arena:            &nodeArena{},
 		scripting:        true,
 		fragment:         true,
 		context:          context,
@@ -2815,13 +2991,18 @@ func ParseFragmentWithOptions(r io.Reader, context *Node, opts ...ParseOption) (
 	for _, f := range opts {
 		f(p)
 	}
+	return p.parseFragment()
+}
 
-	root := &Node{
+// parseFragment parses the children of p.context
+func (p *parser) parseFragment() ([]*Node, error) {
+	context := p.context
+	root := p.newNode(Node{
 		Type:     ElementNode,
 		DataAtom: a.Html,
 		Data:     a.Html.String(),
 		Loc:      p.generateLoc(),
-	}
+	})
 	p.doc.AppendChild(root)
 	p.oe = nodeStack{root}
 	if context != nil && context.DataAtom == a.Template {
@@ -2846,7 +3027,7 @@ func ParseFragmentWithOptions(r io.Reader, context *Node, opts ...ParseOption) (
 	}
 
 	var result []*Node
-	if p.fm.FirstChild != nil {
+	if p.fm != nil && p.fm.FirstChild != nil {
 		p.fm.Parent.RemoveChild(p.fm)
 		result = append(result, p.fm)
 	}
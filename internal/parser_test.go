@@ -0,0 +1,168 @@
+package astro
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/snowpackjs/astro/internal/handler"
+)
+
+func TestUnclosedElements(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "closed",
+			source: `<div><span>a</span><img><br/></div>`,
+			want:   []string{},
+		},
+		{
+			name:   "optional end tags",
+			source: `<ul><li>a<li>b</ul><p>c<table><tr><td>d</table>`,
+			want:   []string{},
+		},
+		{
+			name:   "closed by parent",
+			source: `<div><span>a</div>`,
+			want:   []string{"<span> was not closed"},
+		},
+		{
+			name:   "end of file",
+			source: `<main><section>a`,
+			want:   []string{"<main> was not closed", "<section> was not closed"},
+		},
+		{
+			name:   "component",
+			source: `<Layout><div>a</div>`,
+			want:   []string{"<Layout> was not closed"},
+		},
+		{
+			name:   "component closed by parent component",
+			source: `<Layout><Card><p>a</p></Layout><footer />`,
+			want:   []string{"<Card> was not closed"},
+		},
+		{
+			name:   "component closed by body",
+			source: `<html><body><Card></body></html>`,
+			want:   []string{"<Card> was not closed"},
+		},
+		{
+			name:   "frontmatter",
+			source: "---\nconst a = 1;\n<div>{a}</div>",
+			want:   []string{"The frontmatter fence --- was not closed"},
+		},
+		{
+			name:   "closed frontmatter",
+			source: "---\nconst a = 1;\n---\n<div>{a}</div>\n---",
+			want:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := handler.NewHandler(tt.source, "TestUnclosedElements.astro")
+			_, err := ParseWithOptions(strings.NewReader(tt.source), ParseOptionWithHandler(h))
+			if err != nil {
+				t.Error(err)
+			}
+			got := make([]string, 0)
+			for _, w := range h.Warnings() {
+				got = append(got, w.Text)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %q\n  got:  %q", tt.name, tt.want, got))
+			}
+		})
+	}
+}
+
+func TestXML(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "no implied elements",
+			source: `<rss><channel><title>a</title></channel></rss>`,
+			want:   `<rss><channel><title>a</title></channel></rss>`,
+		},
+		{
+			name:   "no void elements",
+			source: `<item><link>https://example.com</link><br></br></item>`,
+			want:   `<item><link>https://example.com</link><br></br></item>`,
+		},
+		{
+			name:   "self-closing",
+			source: `<feed><link href="/" /><entry/></feed>`,
+			want:   `<feed><link href="/"></link><entry></entry></feed>`,
+		},
+		{
+			name:   "case is preserved",
+			source: `<svg viewBox="0 0 1 1"><linearGradient gradientUnits="userSpaceOnUse"/></svg>`,
+			want:   `<svg viewBox="0 0 1 1"><linearGradient gradientUnits="userSpaceOnUse"></linearGradient></svg>`,
+		},
+		{
+			name:   "unclosed",
+			source: `<urlset><url><loc>a</url></urlset>`,
+			want:   `<urlset><url><loc>a</loc></url></urlset>`,
+		},
+		{
+			name:   "processing instructions and CDATA",
+			source: `<?xml-stylesheet href="feed.xsl"?><rss><description><![CDATA[<p>a > b</p>]]></description></rss>`,
+			want:   `<?xml-stylesheet href="feed.xsl"?><rss><description><![CDATA[<p>a > b</p>]]></description></rss>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := ParseWithOptions(strings.NewReader(tt.source), ParseOptionXML(true))
+			if err != nil {
+				t.Error(err)
+			}
+			var b strings.Builder
+			PrintToSource(&b, doc)
+			if got := b.String(); got != tt.want {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %s\n  got:  %s", tt.name, tt.want, got))
+			}
+		})
+	}
+}
+
+func TestRelease(t *testing.T) {
+	items := strings.Repeat("<li>item</li>", 500)
+	first, err := Parse(strings.NewReader("<ul>" + items + "</ul>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.Release()
+	if first.FirstChild != nil {
+		t.Error("expected a released document to have no children")
+	}
+	// Releasing twice, or a node which isn't a parsed document, does nothing
+	first.Release()
+	(&Node{Type: ElementNode}).Release()
+
+	// The next parse reuses the released nodes
+	second, err := Parse(strings.NewReader("<ol>" + items + "</ol>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Release()
+	var b strings.Builder
+	PrintToSource(&b, second)
+	if want := "<ol>" + items + "</ol>"; !strings.Contains(b.String(), want) {
+		t.Errorf("\nFAIL: release\n  want to contain: %s\n  got: %s", want, b.String())
+	}
+}
+
+func TestParseContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := ParseWithOptions(strings.NewReader("<div><p>a</p></div>"), ParseOptionContext(ctx))
+	if err != context.Canceled {
+		t.Errorf("\nFAIL: canceled parse\n  want: %v\n  got:  %v", context.Canceled, err)
+	}
+}
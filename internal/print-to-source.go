@@ -13,6 +13,10 @@ func PrintToSource(buf *strings.Builder, node *Node) {
 		}
 	case TextNode:
 		buf.WriteString(node.Data)
+	case ProcessingInstructionNode:
+		buf.WriteString("<?" + node.Data + "?>")
+	case CDATANode:
+		buf.WriteString("<![CDATA[" + node.Data + "]]>")
 	case ElementNode:
 		buf.WriteString(fmt.Sprintf(`<%s`, node.Data))
 		for _, attr := range node.Attr {
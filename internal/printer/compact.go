@@ -0,0 +1,90 @@
+package printer
+
+import (
+	"strings"
+
+	"github.com/snowpackjs/astro/internal/js_scanner"
+	"github.com/snowpackjs/astro/internal/sourcemap"
+)
+
+// removeBlankLines removes the blank lines outside of literals and comments
+// from the output, along with their lines of the source map. A blank line
+// which is mapped to the source is kept.
+func (p *printer) removeBlankLines(chunk *sourcemap.Chunk) {
+	starts := generatedLineStarts(p.output)
+	literals := literalSpans(p.output)
+	mappings := strings.Split(string(chunk.Buffer), ";")
+
+	lines := make([]int, 0)
+	literal := 0
+	for line := 0; line+1 < len(starts); line++ {
+		start, end := starts[line], starts[line+1]
+		for literal < len(literals) && literals[literal].end <= start {
+			literal++
+		}
+		if literal < len(literals) && literals[literal].start < start {
+			continue
+		}
+		if strings.TrimSpace(string(p.output[start:end])) != "" {
+			continue
+		}
+		if line < len(mappings) && mappings[line] != "" && mappings[line] != "AAAA" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return
+	}
+	buffer, ok := removeUnmappedLines(chunk.Buffer, lines)
+	if !ok {
+		return
+	}
+	chunk.Buffer = buffer
+	chunk.EndState.GeneratedLine -= len(lines)
+
+	output := make([]byte, 0, len(p.output))
+	written := 0
+	for _, line := range lines {
+		output = append(output, p.output[written:starts[line]]...)
+		written = starts[line+1]
+	}
+	p.output = append(output, p.output[written:]...)
+}
+
+// generatedLineStarts returns the offset of every line of output, with line
+// breaks counted the way the source map builder does, like `\u2028`
+func generatedLineStarts(output []byte) []int {
+	starts := []int{0}
+	for i, c := range string(output) {
+		switch c {
+		case '\r':
+			if i+1 >= len(output) || output[i+1] != '\n' {
+				starts = append(starts, i+1)
+			}
+		case '\n':
+			starts = append(starts, i+1)
+		case '\u2028', '\u2029':
+			starts = append(starts, i+len(string(c)))
+		}
+	}
+	return starts
+}
+
+type span struct {
+	start, end int
+}
+
+// literalSpans returns the strings, template literals and comments of
+// output, in order
+func literalSpans(output []byte) []span {
+	spans := make([]span, 0)
+	for i := 0; i < len(output); {
+		kind, end := js_scanner.Skip(output, i)
+		if kind != js_scanner.CodeSpan {
+			spans = append(spans, span{i, end})
+		}
+		i = end
+	}
+	return spans
+}
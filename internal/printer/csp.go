@@ -0,0 +1,102 @@
+package printer
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"sort"
+	"strings"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/loc"
+	"github.com/snowpackjs/astro/internal/transform"
+	"golang.org/x/net/html/atom"
+)
+
+// InlineSource is something the rendered page runs or applies inline, which a
+// Content Security Policy has to allow: a <script> or <style> with content,
+// hoisted or not, or an event handler attribute like onclick. Build tools can
+// allow every one by its Hash instead of with 'unsafe-inline'.
+type InlineSource struct {
+	// "script", "style" or "event-handler"
+	Kind string
+	Loc  loc.Loc
+	// The name of an event handler attribute
+	Attribute string
+	// The CSP hash source of the content as the page renders it, like
+	// "sha256-...", or empty if the content isn't known until runtime, like
+	// that of an expression or of define:vars, which needs a nonce instead
+	Hash string
+}
+
+// collectInlineSources returns the inline sources of doc, in document order
+func (p *printer) collectInlineSources(doc *astro.Node) []InlineSource {
+	sources := make([]InlineSource, 0)
+	for _, hoisted := range [][]*astro.Node{doc.Styles, doc.Scripts} {
+		for _, n := range hoisted {
+			if transform.HasAttr(n, "src") || n.FirstChild == nil || strings.TrimSpace(n.FirstChild.Data) == "" {
+				continue
+			}
+			content, _ := p.trimWhitespace(n.FirstChild.Data)
+			sources = append(sources, inlineContent(n, rawContent(n, content), transform.HasAttr(n, "define:vars")))
+		}
+	}
+	var walk func(n *astro.Node)
+	walk = func(n *astro.Node) {
+		if n.Type == astro.ElementNode && !n.Component && !n.CustomElement && !n.Expression && !n.Fragment {
+			sources = append(sources, eventHandlers(n)...)
+			if (n.DataAtom == atom.Script || n.DataAtom == atom.Style) && !transform.HasAttr(n, "src") && n.FirstChild != nil {
+				var content strings.Builder
+				dynamic := transform.HasAttr(n, "define:vars")
+				for c := n.FirstChild; c != nil; c = c.NextSibling {
+					if c.Type == astro.TextNode {
+						content.WriteString(rawContent(n, c.Data))
+					} else {
+						dynamic = true
+					}
+				}
+				sources = append(sources, inlineContent(n, content.String(), dynamic))
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	sort.SliceStable(sources, func(i, j int) bool {
+		return sources[i].Loc.Start < sources[j].Loc.Start
+	})
+	return sources
+}
+
+func inlineContent(n *astro.Node, content string, dynamic bool) InlineSource {
+	source := InlineSource{Kind: n.Data}
+	if len(n.Loc) > 0 {
+		source.Loc = n.Loc[0]
+	}
+	if !dynamic {
+		source.Hash = cspHash(content)
+	}
+	return source
+}
+
+// eventHandlers returns the event handler attributes of n
+func eventHandlers(n *astro.Node) []InlineSource {
+	sources := make([]InlineSource, 0)
+	for _, attr := range n.Attr {
+		if attr.Namespace != "" || len(attr.Key) <= 2 || !strings.HasPrefix(strings.ToLower(attr.Key), "on") || attr.Type == astro.SpreadAttribute {
+			continue
+		}
+		source := InlineSource{Kind: "event-handler", Loc: attr.KeyLoc, Attribute: attr.Key}
+		switch attr.Type {
+		case astro.QuotedAttribute, astro.EmptyAttribute:
+			source.Hash = cspHash(astro.UnescapeAttributeString(attr.Val))
+		}
+		sources = append(sources, source)
+	}
+	return sources
+}
+
+func cspHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return "sha256-" + base64.StdEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,76 @@
+package printer
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tycho "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/handler"
+	"github.com/snowpackjs/astro/internal/test_utils"
+	"github.com/snowpackjs/astro/internal/transform"
+)
+
+// Run `go test ./internal/printer -run TestGolden -update` to rewrite the golden files
+var update = flag.Bool("update", false, "update golden files")
+
+// Each fixture is compiled this many times, and every run must be byte-identical
+const goldenRuns = 5
+
+func compileGolden(source string) string {
+	doc, err := tycho.Parse(strings.NewReader(source))
+	if err != nil {
+		panic(err)
+	}
+	opts := transform.TransformOptions{
+		Scope:       tycho.HashFromSource(source),
+		Site:        "https://astro.build",
+		InternalURL: "http://localhost:3000/",
+	}
+	transform.ExtractStyles(doc)
+	transform.Transform(doc, opts, handler.NewHandler(source, "<stdin>"))
+	result := PrintToJS(source, doc, opts)
+	return string(result.Output)
+}
+
+func TestGolden(t *testing.T) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "golden", "*.astro"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("no golden fixtures found")
+	}
+	for _, fixture := range fixtures {
+		name := strings.TrimSuffix(filepath.Base(fixture), ".astro")
+		t.Run(name, func(t *testing.T) {
+			source, err := os.ReadFile(fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := compileGolden(string(source))
+			for i := 1; i < goldenRuns; i++ {
+				if next := compileGolden(string(source)); next != got {
+					t.Fatalf("output of run %d differs from the first run:\n%s", i+1, test_utils.ANSIDiff(got, next))
+				}
+			}
+
+			golden := strings.TrimSuffix(fixture, ".astro") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%s (run with -update to create it)", err)
+			}
+			if diff := test_utils.ANSIDiff(string(want), got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package printer
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/snowpackjs/astro/internal/sourcemap"
+)
+
+type internalImports struct {
+	// The offset of the import block in the output
+	start      int
+	specifiers []string
+}
+
+func internalImportsBlock(specifiers []string, importSpecifier string) string {
+	return fmt.Sprintf("import {\n  %s\n} from \"%s\";\n", strings.Join(specifiers, ",\n  "), importSpecifier)
+}
+
+// pruneInternalImports drops the runtime helpers which the printed module
+// never uses from its internal imports, so bundles only pull in what simple
+// components need. Every specifier is printed on a line of its own, before
+// anything is mapped to it, so dropping one only drops a line of chunk.
+func (p *printer) pruneInternalImports(chunk *sourcemap.Chunk) {
+	if !p.hasInternalImports {
+		return
+	}
+	start := p.internalImports.start
+	end := start + len(internalImportsBlock(p.internalImports.specifiers, p.opts.InternalURL))
+	before, after := p.output[:start], p.output[end:]
+
+	used := make([]string, 0, len(p.internalImports.specifiers))
+	unusedLines := make([]int, 0)
+	firstLine := len(generatedLineStarts(before))
+	for i, specifier := range p.internalImports.specifiers {
+		local := specifier[strings.LastIndex(specifier, " ")+1:]
+		if usesIdentifier(before, local) || usesIdentifier(after, local) {
+			used = append(used, specifier)
+		} else {
+			unusedLines = append(unusedLines, firstLine+i)
+		}
+	}
+	if len(unusedLines) == 0 {
+		return
+	}
+	mappings, ok := removeUnmappedLines(chunk.Buffer, unusedLines)
+	if !ok {
+		return
+	}
+	chunk.Buffer = mappings
+	chunk.EndState.GeneratedLine -= len(unusedLines)
+
+	block := internalImportsBlock(used, p.opts.InternalURL)
+	output := make([]byte, 0, len(p.output)-(end-start)+len(block))
+	output = append(output, before...)
+	output = append(output, block...)
+	output = append(output, after...)
+	p.output = output
+}
+
+func isIdentifierByte(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// usesIdentifier returns whether name appears in output as a whole
+// identifier. Strings and comments aren't told apart, which at worst keeps an
+// import which isn't needed.
+func usesIdentifier(output []byte, name string) bool {
+	for offset := 0; offset < len(output); {
+		i := bytes.Index(output[offset:], []byte(name))
+		if i == -1 {
+			return false
+		}
+		i += offset
+		end := i + len(name)
+		if (i == 0 || !isIdentifierByte(output[i-1])) && (end == len(output) || !isIdentifierByte(output[end])) {
+			return true
+		}
+		offset = i + 1
+	}
+	return false
+}
+
+// removeUnmappedLines removes the given ascending generated lines from VLQ
+// mappings. A line may only be removed if it has no mappings, or only the
+// mapping which the builder adds to the start of lines without any, since
+// that repeats the previous mapping and removing it doesn't change the
+// deltas after it.
+func removeUnmappedLines(mappings []byte, lines []int) ([]byte, bool) {
+	out := make([]byte, 0, len(mappings))
+	line, next, lineStart := 0, 0, 0
+	removable := func() bool {
+		content := string(out[lineStart:])
+		return content == "" || content == "AAAA"
+	}
+	for _, c := range mappings {
+		if c != ';' {
+			out = append(out, c)
+			continue
+		}
+		if next < len(lines) && lines[next] == line {
+			if !removable() {
+				return nil, false
+			}
+			out = out[:lineStart]
+			next++
+		} else {
+			out = append(out, c)
+			lineStart = len(out)
+		}
+		line++
+	}
+	// Lines past the last separator have no mappings, except for the last one
+	if next < len(lines) && lines[next] == line && !removable() {
+		return nil, false
+	}
+	return out, true
+}
@@ -0,0 +1,60 @@
+package printer
+
+import (
+	"strings"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/transform"
+)
+
+// isLean returns whether doc can be printed without the `$$metadata` export
+// and the `Astro` global. That's the case when it has no frontmatter, renders
+// no components, never mentions `Astro` and would have empty metadata.
+func isLean(doc *astro.Node, opts transform.TransformOptions) bool {
+	if opts.FullOutput || opts.IsPage || opts.StampVersion {
+		return false
+	}
+	if len(doc.Scripts) > 0 || len(doc.HydratedComponents) > 0 || len(doc.ClientOnlyComponents) > 0 || len(transform.Transitions(doc, opts)) > 0 {
+		return false
+	}
+	for _, style := range doc.Styles {
+		if mentionsAstro(style) {
+			return false
+		}
+	}
+
+	lean := true
+	var visit func(n *astro.Node)
+	visit = func(n *astro.Node) {
+		switch {
+		case n.Type == astro.FrontmatterNode:
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if strings.TrimSpace(c.Data) != "" {
+					lean = false
+				}
+			}
+		case n.Type == astro.ElementNode && n.Component && !n.Fragment:
+			lean = false
+		case n.Type == astro.TextNode && n.Parent != nil && n.Parent.Expression && strings.Contains(n.Data, "Astro"):
+			lean = false
+		case mentionsAstro(n) || astro.GetAttribute(n, transform.ServerDeferDirective) != nil:
+			lean = false
+		}
+		for c := n.FirstChild; c != nil && lean; c = c.NextSibling {
+			visit(c)
+		}
+	}
+	visit(doc)
+	return lean
+}
+
+// mentionsAstro returns whether an attribute of n which is evaluated at
+// runtime mentions `Astro`
+func mentionsAstro(n *astro.Node) bool {
+	for _, attr := range n.Attr {
+		if attr.Type != astro.QuotedAttribute && attr.Type != astro.EmptyAttribute && strings.Contains(attr.Key+attr.Val, "Astro") {
+			return true
+		}
+	}
+	return false
+}
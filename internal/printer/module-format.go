@@ -0,0 +1,74 @@
+package printer
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/snowpackjs/astro/internal/escape"
+	"github.com/snowpackjs/astro/internal/js_scanner"
+	"github.com/snowpackjs/astro/internal/sourcemap"
+)
+
+// The URL of a script to replace `import.meta.url` with in an IIFE, which is
+// the page when the script is inline or evaluated
+const scriptURL = "(globalThis.document?.currentScript?.src || globalThis.location?.href)"
+
+// convertModuleFormat rewrites the printed ES module to opts.ModuleFormat.
+// Statements keep their lines, so the source map still applies.
+func (p *printer) convertModuleFormat(chunk *sourcemap.Chunk) {
+	switch p.opts.ModuleFormat {
+	case "cjs":
+		p.output = js_scanner.ToCommonJS(p.output, js_scanner.NodeModuleURL)
+	case "iife":
+		// An IIFE which takes the runtime as a parameter, and assigns the
+		// exports of the component to a global so a script tag can use it
+		// without a bundler. Other modules are left to a global `require()`,
+		// if the page has one. The prelude goes on the first line, so only
+		// that line of the source map moves.
+		prelude := fmt.Sprintf("var %s = (function ($$runtime) { var exports = {}; function require(specifier) { if (specifier === %s) return $$runtime; if (typeof globalThis.require === 'function') return globalThis.require(specifier); throw new Error('Cannot find module ' + specifier); } ", p.opts.GlobalName, escape.JSONString(p.opts.InternalURL))
+		output := []byte(prelude)
+		output = append(output, js_scanner.ToCommonJS(p.output, scriptURL)...)
+		output = append(output, fmt.Sprintf("\nreturn exports;\n})(globalThis.%s);\n", p.opts.RuntimeGlobal)...)
+		p.output = output
+		shiftFirstLine(chunk, len(utf16.Encode([]rune(prelude))))
+	}
+}
+
+// shiftFirstLine moves the mappings of the first generated line of chunk
+// right by columns. Only the first mapping on a line has an absolute column,
+// the others are relative to it.
+func shiftFirstLine(chunk *sourcemap.Chunk, columns int) {
+	if len(chunk.Buffer) == 0 || chunk.Buffer[0] == ';' {
+		return
+	}
+	column, end := sourcemap.DecodeVLQ(chunk.Buffer, 0)
+	mappings := sourcemap.EncodeVLQ(column + columns)
+	chunk.Buffer = append(mappings, chunk.Buffer[end:]...)
+}
+
+// addBannerAndFooter adds opts.Banner to the top of the output and
+// opts.Footer to the bottom, as they are. The lines of the banner are added
+// to the source map without mappings.
+func (p *printer) addBannerAndFooter(chunk *sourcemap.Chunk) {
+	if p.opts.Banner != "" {
+		banner := p.opts.Banner
+		if !strings.HasSuffix(banner, "\n") {
+			banner += "\n"
+		}
+		lines := len(generatedLineStarts([]byte(banner))) - 1
+		chunk.Buffer = append(bytes.Repeat([]byte{';'}, lines), chunk.Buffer...)
+		chunk.EndState.GeneratedLine += lines
+		p.output = append([]byte(banner), p.output...)
+	}
+	if p.opts.Footer != "" {
+		if len(p.output) > 0 && p.output[len(p.output)-1] != '\n' {
+			p.output = append(p.output, '\n')
+		}
+		p.output = append(p.output, p.opts.Footer...)
+		if !strings.HasSuffix(p.opts.Footer, "\n") {
+			p.output = append(p.output, '\n')
+		}
+	}
+}
@@ -9,8 +9,11 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	. "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/escape"
 	"github.com/snowpackjs/astro/internal/js_scanner"
 	"github.com/snowpackjs/astro/internal/loc"
 	"github.com/snowpackjs/astro/internal/sourcemap"
@@ -43,19 +46,49 @@ import (
 // Another example is that the programmatic equivalent of "a<head>b</head>c"
 // becomes "<html><head><head/><body>abc</body></html>".
 func PrintToJS(sourcetext string, n *Node, opts transform.TransformOptions) PrintResult {
-	p := &printer{
-		opts:    opts,
-		builder: sourcemap.MakeChunkBuilder(nil, sourcemap.GenerateLineOffsetTables(sourcetext, len(strings.Split(sourcetext, "\n")))),
-	}
-	return printToJs(p, n)
+	opts.Normalize()
+	p := newPrinter(sourcetext, opts)
+	return printToJs(p, sourcetext, n)
 }
 
 func PrintToJSFragment(sourcetext string, n *Node, opts transform.TransformOptions) PrintResult {
-	p := &printer{
+	opts.Normalize()
+	p := newPrinter(sourcetext, opts)
+	return printToJs(p, sourcetext, n)
+}
+
+// Output buffers are reused between compiles, so a long-running process which
+// compiles many files doesn't grow a new buffer for each of them
+var outputPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 16*1024)
+		return &buf
+	},
+}
+
+// Larger buffers are left to the garbage collector, so one large file doesn't
+// keep its memory for the lifetime of the process
+const maxPooledOutput = 1024 * 1024
+
+func newPrinter(sourcetext string, opts transform.TransformOptions) *printer {
+	return &printer{
 		opts:    opts,
-		builder: sourcemap.MakeChunkBuilder(nil, sourcemap.GenerateLineOffsetTables(sourcetext, len(strings.Split(sourcetext, "\n")))),
+		output:  (*outputPool.Get().(*[]byte))[:0],
+		builder: sourcemap.MakeChunkBuilder(nil, sourcemap.GenerateLineOffsetTables(sourcetext, strings.Count(sourcetext, "\n")+1)),
+	}
+}
+
+// releaseOutput returns the output buffer of p to the pool and returns a copy
+// of the output
+func (p *printer) releaseOutput() []byte {
+	output := make([]byte, len(p.output))
+	copy(output, p.output)
+	if cap(p.output) <= maxPooledOutput {
+		buf := p.output[:0]
+		outputPool.Put(&buf)
 	}
-	return printToJs(p, n)
+	p.output = nil
+	return output
 }
 
 type RenderOptions struct {
@@ -69,16 +102,43 @@ type ExtractedStatement struct {
 	Loc     loc.Loc
 }
 
-func printToJs(p *printer, n *Node) PrintResult {
+func printToJs(p *printer, sourcetext string, n *Node) PrintResult {
+	start := time.Now()
+	p.lean = isLean(n, p.opts)
+	if p.opts.IslandMarkers {
+		p.islands = make(map[*Node]bool)
+		for _, island := range transform.IslandNodes(n) {
+			p.islands[island] = true
+		}
+	}
+	stats := collectStats(n)
 	render1(p, n, RenderOptions{
 		isRoot:       true,
 		isExpression: false,
 		depth:        0,
 	})
+	sourceMapChunk := p.builder.GenerateChunk(p.output)
+	p.pruneInternalImports(&sourceMapChunk)
+	p.convertModuleFormat(&sourceMapChunk)
+	if p.opts.CompactOutput {
+		p.removeBlankLines(&sourceMapChunk)
+	}
+	p.addBannerAndFooter(&sourceMapChunk)
+	stats.OutputSize = len(p.output)
+	stats.Print = time.Since(start)
 
+	styles, styleRanges := collectTags(sourcetext, n.Styles, "style")
+	scripts, scriptRanges := collectTags(sourcetext, n.Scripts, "script")
 	return PrintResult{
-		Output:         p.output,
-		SourceMapChunk: p.builder.GenerateChunk(p.output),
+		Output:         p.releaseOutput(),
+		SourceMapChunk: sourceMapChunk,
+		Stats:          stats,
+		Styles:         styles,
+		Scripts:        scripts,
+		Hashes:         collectHashes(sourcetext, n, append(styleRanges, scriptRanges...)),
+		InlineSources:  p.collectInlineSources(n),
+		Preloads:       transform.Preloads(n, p.opts),
+		Prerender:      transform.Prerender(n),
 	}
 }
 
@@ -87,7 +147,11 @@ func render1(p *printer, n *Node, opts RenderOptions) {
 
 	// Root of the document, print all children
 	if n.Type == DocumentNode {
-		p.printInternalImports(p.opts.InternalURL)
+		// Frontmatter prints the internal imports itself, after any side-effect
+		// imports which have to run first
+		if fm := n.FirstChild; fm == nil || fm.Type != FrontmatterNode || fm.FirstChild == nil || fm.FirstChild.Type != TextNode {
+			p.printInternalImports(p.opts.InternalURL)
+		}
 
 		for c := n.FirstChild; c != nil; c = c.NextSibling {
 			render1(p, c, RenderOptions{
@@ -100,6 +164,7 @@ func render1(p *printer, n *Node, opts RenderOptions) {
 		p.printReturnClose()
 		// TODO: use proper component name
 		p.printFuncSuffix("$$Component")
+		p.printDeferred()
 		return
 	}
 
@@ -107,21 +172,35 @@ func render1(p *printer, n *Node, opts RenderOptions) {
 	if n.Type == FrontmatterNode {
 		for c := n.FirstChild; c != nil; c = c.NextSibling {
 			if c.Type == TextNode {
+				// Imports like `import './global.css'` may depend on running before
+				// anything else, so they and the imports before them go first
+				sideEffectsEnd := js_scanner.SideEffectImportsEnd([]byte(c.Data))
+				if sideEffectsEnd > 0 {
+					if len(c.Loc) > 0 {
+						p.addSourceMapping(c.Loc[0])
+					}
+					p.println(strings.TrimSpace(c.Data[:sideEffectsEnd]))
+				}
 				p.printInternalImports(p.opts.InternalURL)
+				text := c.Data[sideEffectsEnd:]
+				textStart := 0
+				if len(c.Loc) > 0 {
+					textStart = c.Loc[0].Start + sideEffectsEnd
+				}
 
 				// This scanner returns a position where we should slice the frontmatter.
 				// If it encounters any `await`ed code or code that accesses the `Astro` global,
 				// `renderBodyStart` will be the index where we should split the frontmatter.
 				// If we don't encounter any of those, `renderBodyStart` will be `-1`
-				renderBodyStart := js_scanner.FindRenderBody([]byte(c.Data))
+				renderBodyStart := js_scanner.FindRenderBody([]byte(text))
 				if len(n.Loc) > 0 {
 					p.addSourceMapping(n.Loc[0])
 				}
 				if renderBodyStart == -1 {
 					if len(c.Loc) > 0 {
-						p.addSourceMapping(c.Loc[0])
+						p.addSourceMapping(loc.Loc{Start: textStart})
 					}
-					preprocessed := js_scanner.HoistExports([]byte(c.Data))
+					preprocessed := js_scanner.HoistExports([]byte(text))
 
 					// 1. After imports put in the top-level Astro.
 					p.printTopLevelAstro()
@@ -133,7 +212,7 @@ func render1(p *printer, n *Node, opts RenderOptions) {
 					}
 
 					// 2. The frontmatter.
-					p.print(strings.TrimSpace(c.Data))
+					p.print(strings.TrimSpace(text))
 
 					// 3. The metadata object
 					p.printComponentMetadata(n.Parent, []byte(c.Data))
@@ -141,8 +220,8 @@ func render1(p *printer, n *Node, opts RenderOptions) {
 					// TODO: use the proper component name
 					p.printFuncPrelude("$$Component")
 				} else {
-					importStatements := c.Data[0:renderBodyStart]
-					content := c.Data[renderBodyStart:]
+					importStatements := text[0:renderBodyStart]
+					content := text[renderBodyStart:]
 					preprocessed := js_scanner.HoistExports([]byte(content))
 					renderBody := preprocessed.Body
 
@@ -150,12 +229,12 @@ func render1(p *printer, n *Node, opts RenderOptions) {
 						panic(errors.New("Export statements must be placed at the top of .astro files!"))
 					}
 					if len(c.Loc) > 0 {
-						p.addSourceMapping(c.Loc[0])
+						p.addSourceMapping(loc.Loc{Start: textStart})
 					}
 					p.println(strings.TrimSpace(importStatements))
 
 					// 1. Component imports, if any exist.
-					p.printComponentMetadata(n.Parent, []byte(importStatements))
+					p.printComponentMetadata(n.Parent, []byte(c.Data[:sideEffectsEnd+renderBodyStart]))
 					// 2. Top-level Astro global.
 					p.printTopLevelAstro()
 
@@ -168,7 +247,7 @@ func render1(p *printer, n *Node, opts RenderOptions) {
 					// TODO: use the proper component name
 					p.printFuncPrelude("$$Component")
 					if len(c.Loc) > 0 {
-						p.addSourceMapping(loc.Loc{Start: c.Loc[0].Start + renderBodyStart})
+						p.addSourceMapping(loc.Loc{Start: textStart + renderBodyStart})
 					}
 					p.print(strings.TrimSpace(string(preprocessed.Body)))
 				}
@@ -184,6 +263,7 @@ func render1(p *printer, n *Node, opts RenderOptions) {
 					p.addNilSourceMapping()
 					p.println(fmt.Sprintf("for (const STYLE of STYLES) %s.styles.add(STYLE);", RESULT))
 				}
+				p.printDefinedVars(n.Parent)
 
 				if len(n.Parent.Scripts) > 0 {
 					p.println("const SCRIPTS = [")
@@ -226,6 +306,7 @@ func render1(p *printer, n *Node, opts RenderOptions) {
 			p.addNilSourceMapping()
 			p.println(fmt.Sprintf("for (const STYLE of STYLES) %s.styles.add(STYLE);", RESULT))
 		}
+		p.printDefinedVars(n.Parent)
 		if len(n.Parent.Scripts) > 0 {
 			p.println("const SCRIPTS = [")
 			for _, script := range n.Parent.Scripts {
@@ -242,10 +323,18 @@ func render1(p *printer, n *Node, opts RenderOptions) {
 	case TextNode:
 		if strings.TrimSpace(n.Data) == "" {
 			p.addSourceMapping(n.Loc[0])
-			p.print(n.Data)
+			if p.opts.TrimWhitespace == "aggressive" && !preservesWhitespace(n) {
+				p.print(collapseWhitespace(n.Data))
+			} else {
+				p.print(n.Data)
+			}
 			return
 		}
-		text := escapeText(n.Data)
+		text := n.Data
+		if p.opts.Entities == "normalize" {
+			text = normalizeTextEntities(text)
+		}
+		text = escape.TemplateLiteral(text)
 		p.addSourceMapping(n.Loc[0])
 		p.print(text)
 		return
@@ -254,7 +343,7 @@ func render1(p *printer, n *Node, opts RenderOptions) {
 	case CommentNode:
 		p.addSourceMapping(n.Loc[0])
 		p.print("<!--")
-		p.print(escapeText(n.Data))
+		p.print(escape.TemplateLiteral(n.Data))
 		p.print("-->")
 		return
 	case DoctypeNode:
@@ -287,6 +376,14 @@ func render1(p *printer, n *Node, opts RenderOptions) {
 	case RawNode:
 		p.print(n.Data)
 		return
+	case ProcessingInstructionNode:
+		p.addSourceMapping(n.Loc[0])
+		p.print("<?" + escape.TemplateLiteral(n.Data) + "?>")
+		return
+	case CDATANode:
+		p.addSourceMapping(n.Loc[0])
+		p.print("<![CDATA[" + escape.TemplateLiteral(n.Data) + "]]>")
+		return
 	}
 
 	// Tip! Comment this block out to debug expressions
@@ -320,10 +417,27 @@ func render1(p *printer, n *Node, opts RenderOptions) {
 		return
 	}
 
+	if transform.IsDeferred(n) {
+		p.printDeferredPlaceholder(n)
+		return
+	}
+
 	isFragment := n.Fragment
 	isComponent := isFragment || n.Component || n.CustomElement
 	isClientOnly := isComponent && transform.HasAttr(n, "client:only")
 	isSlot := n.DataAtom == atom.Slot
+	dynamicTag := transform.DynamicTag(n)
+	// Markers are rendered in order with the component, so the runtime can time it
+	hasMarkers := p.opts.RenderTelemetry && isComponent && !isFragment
+	if hasMarkers {
+		p.print(fmt.Sprintf("${%s.renderTelemetry && %s(%s,'%s')}", RESULT, MARK_RENDER_START, RESULT, escape.SingleQuoted(n.Data)))
+	}
+	// The ID is made as the island renders, so it's new for every render of
+	// an island in a loop or of a component used twice on a page
+	isIsland := p.islands[n]
+	if isIsland {
+		p.print(fmt.Sprintf("<!--astro:island-start:${%s(%s,'%s')}-->", OPEN_ISLAND, RESULT, escape.SingleQuoted(p.opts.Scope)))
+	}
 
 	p.addSourceMapping(n.Loc[0])
 	switch true {
@@ -346,6 +460,9 @@ func render1(p *printer, n *Node, opts RenderOptions) {
 		p.print("null")
 	case !isSlot && n.CustomElement:
 		p.print(fmt.Sprintf("'%s'", n.Data))
+	case dynamicTag != nil:
+		p.addSourceMapping(dynamicTag.ValLoc)
+		p.print("(" + dynamicTag.Val + ")")
 	case !isSlot:
 		p.print(n.Data)
 	}
@@ -385,6 +502,7 @@ func render1(p *printer, n *Node, opts RenderOptions) {
 			if transform.IsImplictNodeMarker(a) {
 				continue
 			}
+			a = transform.RewriteAsset(n, a, p.opts.RewriteAsset)
 			if a.Key == "slot" {
 				if !(n.Parent.Component || n.Parent.CustomElement) {
 					panic(`Element with a slot='...' attribute must be a child of a component or a descendant of a custom element`)
@@ -398,24 +516,23 @@ func render1(p *printer, n *Node, opts RenderOptions) {
 				p.addSourceMapping(n.Loc[0])
 			}
 		}
+		if nonce, ok := p.nonceAttribute(n); ok {
+			p.printAttribute(nonce)
+		}
 		p.addSourceMapping(n.Loc[0])
 		p.print(">")
 	}
 
-	if voidElements[n.Data] {
+	// XML has no void elements, so something like <link> may have children
+	if voidElements[n.Data] && p.opts.ContentType != "xml" {
 		if n.FirstChild != nil {
 			// return fmt.Errorf("html: void element <%s> has child nodes", n.Data)
 		}
 		return
 	}
 
-	// Add initial newline where there is danger of a newline beging ignored.
-	if c := n.FirstChild; c != nil && c.Type == TextNode && strings.HasPrefix(c.Data, "\n") {
-		switch n.Data {
-		case "pre", "listing", "textarea":
-			p.print("\n")
-		}
-	}
+	// Note: the parser preserves the leading newline of "pre", "listing" and "textarea"
+	// elements, so there is no need to add one back here.
 
 	if n.DataAtom == atom.Script || n.DataAtom == atom.Style {
 		p.printDefineVars(n)
@@ -426,7 +543,7 @@ func render1(p *printer, n *Node, opts RenderOptions) {
 	case "iframe", "noembed", "noframes", "noscript", "plaintext", "script", "style", "xmp":
 		for c := n.FirstChild; c != nil; c = c.NextSibling {
 			if c.Type == TextNode {
-				p.print(escapeText(c.Data))
+				p.print(escape.TemplateLiteral(rawContent(n, c.Data)))
 			} else {
 				render1(p, c, RenderOptions{
 					isRoot: false,
@@ -542,6 +659,12 @@ func render1(p *printer, n *Node, opts RenderOptions) {
 	} else {
 		p.print(`</` + n.Data + `>`)
 	}
+	if isIsland {
+		p.print(fmt.Sprintf("<!--astro:island-end:${%s(%s)}-->", CLOSE_ISLAND, RESULT))
+	}
+	if hasMarkers {
+		p.print(fmt.Sprintf("${%s.renderTelemetry && %s(%s,'%s')}", RESULT, MARK_RENDER_END, RESULT, escape.SingleQuoted(n.Data)))
+	}
 }
 
 // Section 12.1.2, "Elements", gives this list of void elements. Void elements
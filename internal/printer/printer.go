@@ -3,8 +3,10 @@ package printer
 import (
 	"fmt"
 	"strings"
+	"unicode"
 
 	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/escape"
 	"github.com/snowpackjs/astro/internal/js_scanner"
 	"github.com/snowpackjs/astro/internal/loc"
 	"github.com/snowpackjs/astro/internal/sourcemap"
@@ -15,6 +17,20 @@ import (
 type PrintResult struct {
 	Output         []byte
 	SourceMapChunk sourcemap.Chunk
+	Stats          Stats
+	// Styles and hoisted scripts, in the order they're printed
+	Styles  []Tag
+	Scripts []Tag
+	Hashes  Hashes
+	// Inline scripts, styles and event handlers, for Content Security Policies
+	InlineSources []InlineSource
+	// See transform.Preloads
+	Preloads []transform.Preload
+	// See transform.Prerender
+	Prerender *bool
+	// Set by the caller when the DataFrontmatter option is, since the data
+	// isn't part of the document
+	DataFrontmatter *transform.DataFrontmatter
 }
 
 type printer struct {
@@ -23,6 +39,14 @@ type printer struct {
 	builder            sourcemap.ChunkBuilder
 	hasFuncPrelude     bool
 	hasInternalImports bool
+	// Where the internal imports were printed, so unused ones can be pruned
+	internalImports internalImports
+	// Whether the `$$metadata` export and the `Astro` global are left out, see isLean
+	lean bool
+	// Subtrees marked with `server:defer`, which are printed as their own components
+	deferred []*astro.Node
+	// The hydrated components which get island markers, see IslandMarkers
+	islands map[*astro.Node]bool
 }
 
 var TEMPLATE_TAG = "$$render"
@@ -31,10 +55,18 @@ var CREATE_COMPONENT = "$$createComponent"
 var RENDER_COMPONENT = "$$renderComponent"
 var RENDER_SLOT = "$$renderSlot"
 var ADD_ATTRIBUTE = "$$addAttribute"
+var JOIN_CANDIDATES = "$$joinCandidates"
 var SPREAD_ATTRIBUTES = "$$spreadAttributes"
+var MERGE_ATTR = "$$mergeAttr"
 var DEFINE_STYLE_VARS = "$$defineStyleVars"
 var DEFINE_SCRIPT_VARS = "$$defineScriptVars"
 var CREATE_METADATA = "$$createMetadata"
+var RENDER_TRANSITION = "$$renderTransition"
+var CREATE_TRANSITION_SCOPE = "$$createTransitionScope"
+var MARK_RENDER_START = "$$markRenderStart"
+var MARK_RENDER_END = "$$markRenderEnd"
+var OPEN_ISLAND = "$$openIsland"
+var CLOSE_ISLAND = "$$closeIsland"
 var METADATA = "$$metadata"
 var RESULT = "$$result"
 var SLOTS = "$$slots"
@@ -53,21 +85,31 @@ func (p *printer) printInternalImports(importSpecifier string) {
 	if p.hasInternalImports {
 		return
 	}
-	p.print("import {\n  ")
-	p.print(FRAGMENT + ",\n  ")
-	p.print("render as " + TEMPLATE_TAG + ",\n  ")
-	p.print("createAstro as " + CREATE_ASTRO + ",\n  ")
-	p.print("createComponent as " + CREATE_COMPONENT + ",\n  ")
-	p.print("renderComponent as " + RENDER_COMPONENT + ",\n  ")
-	p.print("renderSlot as " + RENDER_SLOT + ",\n  ")
-	p.print("addAttribute as " + ADD_ATTRIBUTE + ",\n  ")
-	p.print("spreadAttributes as " + SPREAD_ATTRIBUTES + ",\n  ")
-	p.print("defineStyleVars as " + DEFINE_STYLE_VARS + ",\n  ")
-	p.print("defineScriptVars as " + DEFINE_SCRIPT_VARS + ",\n  ")
-	p.print("createMetadata as " + CREATE_METADATA)
-	p.print("\n} from \"")
-	p.print(importSpecifier)
-	p.print("\";\n")
+	specifiers := []string{
+		FRAGMENT,
+		"render as " + TEMPLATE_TAG,
+		"createAstro as " + CREATE_ASTRO,
+		"createComponent as " + CREATE_COMPONENT,
+		"renderComponent as " + RENDER_COMPONENT,
+		"renderSlot as " + RENDER_SLOT,
+		"addAttribute as " + ADD_ATTRIBUTE,
+		"joinCandidates as " + JOIN_CANDIDATES,
+		"spreadAttributes as " + SPREAD_ATTRIBUTES,
+		"mergeAttr as " + MERGE_ATTR,
+		"defineStyleVars as " + DEFINE_STYLE_VARS,
+		"defineScriptVars as " + DEFINE_SCRIPT_VARS,
+		"renderTransition as " + RENDER_TRANSITION,
+		"createTransitionScope as " + CREATE_TRANSITION_SCOPE,
+	}
+	if p.opts.RenderTelemetry {
+		specifiers = append(specifiers, "markRenderStart as "+MARK_RENDER_START, "markRenderEnd as "+MARK_RENDER_END)
+	}
+	if p.opts.IslandMarkers {
+		specifiers = append(specifiers, "openIsland as "+OPEN_ISLAND, "closeIsland as "+CLOSE_ISLAND)
+	}
+	specifiers = append(specifiers, "createMetadata as "+CREATE_METADATA)
+	p.internalImports = internalImports{start: len(p.output), specifiers: specifiers}
+	p.print(internalImportsBlock(specifiers, importSpecifier))
 	p.hasInternalImports = true
 }
 
@@ -117,54 +159,193 @@ func (p *printer) printDefineVars(n *astro.Node) {
 				value = strings.TrimSpace(attr.Val)
 			}
 			p.addNilSourceMapping()
+			// Styles are only given the custom properties, so they are set on :root
+			if n.DataAtom == atom.Style {
+				p.print(":root{")
+			}
 			p.print(fmt.Sprintf("${%s(", defineCall))
 			p.addSourceMapping(attr.ValLoc)
 			p.print(value)
 			p.addNilSourceMapping()
 			p.print(")}")
+			if n.DataAtom == atom.Style {
+				p.print("}")
+			}
 			return
 		}
 	}
 }
 
+// printDefinedVars declares the custom properties which the transform moved
+// from define:vars onto the root elements
+func (p *printer) printDefinedVars(doc *astro.Node) {
+	if len(doc.DefinedVars) == 0 {
+		return
+	}
+	p.addNilSourceMapping()
+	p.println(fmt.Sprintf("const %s = %s([%s]);", transform.DefinedVars, DEFINE_STYLE_VARS, strings.Join(doc.DefinedVars, ",")))
+}
+
 func (p *printer) printFuncPrelude(componentName string) {
 	if p.hasFuncPrelude {
 		return
 	}
 	p.addNilSourceMapping()
-	p.println("\n//@ts-ignore")
+	p.printTSIgnore()
 	p.println(fmt.Sprintf("const %s = %s(async (%s, $$props, %s) => {", componentName, CREATE_COMPONENT, RESULT, SLOTS))
-	p.println(fmt.Sprintf("const Astro = %s.createAstro($$Astro, $$props, %s);", RESULT, SLOTS))
+	if !p.lean {
+		p.println(fmt.Sprintf("const Astro = %s.createAstro($$Astro, $$props, %s);", RESULT, SLOTS))
+	}
 	p.hasFuncPrelude = true
 }
 
+// printTSIgnore keeps type checkers from checking a generated component
+func (p *printer) printTSIgnore() {
+	if !p.opts.CompactOutput {
+		p.println("\n//@ts-ignore")
+	}
+}
+
 func (p *printer) printFuncSuffix(componentName string) {
 	p.addNilSourceMapping()
 	p.println("});")
 	p.println(fmt.Sprintf("export default %s;", componentName))
 }
 
+// printDeferredPlaceholder renders the component which a `server:defer`
+// subtree is split into, passing along the frontmatter variables it needs
+func (p *printer) printDeferredPlaceholder(n *astro.Node) {
+	name := fmt.Sprintf("$$Deferred%d", len(p.deferred))
+	p.deferred = append(p.deferred, n)
+	p.addSourceMapping(n.Loc[0])
+	p.print(fmt.Sprintf("${%s(%s,'%s',%s,{\"%s\":true", RENDER_COMPONENT, RESULT, name, name, transform.ServerDeferDirective))
+	for _, prop := range transform.DeferredProps(rootOf(n), n) {
+		p.print(fmt.Sprintf(`,"%s":(%s)`, prop, prop))
+	}
+	p.print("})}")
+}
+
+// printDeferred prints every `server:defer` subtree as an exported component
+func (p *printer) printDeferred() {
+	// Deferred subtrees may contain more of them, which are appended as they're printed
+	for i := 0; i < len(p.deferred); i++ {
+		n := p.deferred[i]
+		attrs := make([]astro.Attribute, 0, len(n.Attr))
+		for _, attr := range n.Attr {
+			if attr.Key != transform.ServerDeferDirective {
+				attrs = append(attrs, attr)
+			}
+		}
+		n.Attr = attrs
+
+		p.addNilSourceMapping()
+		p.printTSIgnore()
+		p.println(fmt.Sprintf("export const $$Deferred%d = %s(async (%s, $$props, %s) => {", i, CREATE_COMPONENT, RESULT, SLOTS))
+		p.println(fmt.Sprintf("const Astro = %s.createAstro($$Astro, $$props, %s);", RESULT, SLOTS))
+		if props := transform.DeferredProps(rootOf(n), n); len(props) > 0 {
+			p.println(fmt.Sprintf("const { %s } = $$props;", strings.Join(props, ", ")))
+		}
+		p.printReturnOpen()
+		render1(p, n, RenderOptions{
+			isRoot:       false,
+			isExpression: false,
+			depth:        1,
+		})
+		p.printReturnClose()
+		p.println("});")
+	}
+}
+
+// staticValue prints the value of a quoted attribute as a string, or null if
+// it isn't known until runtime
+func staticValue(attr *astro.Attribute) string {
+	if attr == nil || attr.Type != astro.QuotedAttribute {
+		return "null"
+	}
+	return fmt.Sprintf("'%s'", escape.SingleQuoted(astro.UnescapeAttributeString(attr.Val)))
+}
+
+// remoteScriptMetadata prints the hoisted entry of a remote script, with the
+// attributes which change how its src is fetched and run, so subresource
+// integrity and the loading behavior survive hoisting. Attributes which
+// aren't known until runtime are left out.
+func remoteScriptMetadata(n *astro.Node, src *astro.Attribute) string {
+	entry := fmt.Sprintf("{ type: 'remote', src: %s", escape.JSONString(astro.UnescapeAttributeString(src.Val)))
+	for _, key := range []string{"integrity", "crossorigin"} {
+		if attr := astro.GetAttribute(n, key); attr != nil && (attr.Type == astro.QuotedAttribute || attr.Type == astro.EmptyAttribute) {
+			entry += fmt.Sprintf(", %s: %s", key, escape.JSONString(astro.UnescapeAttributeString(attr.Val)))
+		}
+	}
+	for _, key := range []string{"defer", "async"} {
+		if attr := astro.GetAttribute(n, key); attr != nil && (attr.Type == astro.QuotedAttribute || attr.Type == astro.EmptyAttribute) {
+			entry += fmt.Sprintf(", %s: true", key)
+		}
+	}
+	return entry + " }"
+}
+
+func rootOf(n *astro.Node) *astro.Node {
+	for n.Parent != nil {
+		n = n.Parent
+	}
+	return n
+}
+
+// hydrationAfterSpread moves `client:` directives which come before a spread
+// to just after the last spread, so the spread can't override them
+func hydrationAfterSpread(attrs []astro.Attribute) []astro.Attribute {
+	last := -1
+	for i, a := range attrs {
+		if a.Type == astro.SpreadAttribute {
+			last = i
+		}
+	}
+	if last == -1 {
+		return attrs
+	}
+	ordered := make([]astro.Attribute, 0, len(attrs))
+	directives := make([]astro.Attribute, 0)
+	for i, a := range attrs {
+		if i < last && strings.HasPrefix(a.Key, "client:") {
+			directives = append(directives, a)
+			continue
+		}
+		ordered = append(ordered, a)
+		if i == last {
+			ordered = append(ordered, directives...)
+		}
+	}
+	return ordered
+}
+
 func (p *printer) printAttributesToObject(n *astro.Node) {
 	p.print("{")
-	for i, a := range n.Attr {
-		if i != 0 {
+	dynamic := transform.DynamicTag(n) != nil
+	first := true
+	for _, a := range hydrationAfterSpread(n.Attr) {
+		// The `is` attribute of a dynamic element selects the tag, it isn't a prop
+		if (dynamic && a.Key == "is") || transform.IsTransitionDirective(a.Key) || a.Key == transform.CriticalDirective {
+			continue
+		}
+		if !first {
 			p.print(",")
 		}
+		first = false
 		switch a.Type {
 		case astro.QuotedAttribute:
 			p.addSourceMapping(a.KeyLoc)
-			p.print(`"` + a.Key + `"`)
+			p.print(quoteAttributeKey(a.Key))
 			p.print(":")
 			p.addSourceMapping(a.ValLoc)
-			p.print(`"` + a.Val + `"`)
+			p.print(escape.JSONString(astro.UnescapeAttributeString(a.Val)))
 		case astro.EmptyAttribute:
 			p.addSourceMapping(a.KeyLoc)
-			p.print(`"` + a.Key + `"`)
+			p.print(quoteAttributeKey(a.Key))
 			p.print(":")
 			p.print("true")
 		case astro.ExpressionAttribute:
 			p.addSourceMapping(a.KeyLoc)
-			p.print(`"` + a.Key + `"`)
+			p.print(quoteAttributeKey(a.Key))
 			p.print(":")
 			p.addSourceMapping(a.ValLoc)
 			p.print(`(` + a.Val + `)`)
@@ -173,36 +354,72 @@ func (p *printer) printAttributesToObject(n *astro.Node) {
 			p.print(`...(` + strings.TrimSpace(a.Key) + `)`)
 		case astro.ShorthandAttribute:
 			p.addSourceMapping(a.KeyLoc)
-			p.print(`"` + strings.TrimSpace(a.Key) + `"`)
+			p.print(quoteAttributeKey(strings.TrimSpace(a.Key)))
 			p.print(":")
 			p.addSourceMapping(a.KeyLoc)
 			p.print(`(` + strings.TrimSpace(a.Key) + `)`)
 		case astro.TemplateLiteralAttribute:
 			p.addSourceMapping(a.KeyLoc)
-			p.print(`"` + strings.TrimSpace(a.Key) + `"`)
+			p.print(quoteAttributeKey(strings.TrimSpace(a.Key)))
 			p.print(":")
-			p.print("`" + strings.TrimSpace(a.Key) + "`")
+			p.addSourceMapping(a.ValLoc)
+			p.print("`" + a.Val + "`")
 		}
 	}
 	p.print("}")
 }
 
+// trimWhitespace removes the whitespace around JavaScript or CSS source,
+// unless opts.TrimWhitespace is "none". The number of bytes removed from the
+// start is returned too, so source mappings still point at the content.
+func (p *printer) trimWhitespace(str string) (string, int) {
+	if p.opts.TrimWhitespace == "none" {
+		return str, 0
+	}
+	trimmed := strings.TrimLeftFunc(str, unicode.IsSpace)
+	offset := len(str) - len(trimmed)
+	return strings.TrimRightFunc(trimmed, unicode.IsSpace), offset
+}
+
 func (p *printer) printStyleOrScript(n *astro.Node) {
 	p.addNilSourceMapping()
 	p.print("{props:")
-	p.printAttributesToObject(n)
+	if nonce, ok := p.nonceAttribute(n); ok {
+		withNonce := *n
+		withNonce.Attr = append(append(make([]astro.Attribute, 0, len(n.Attr)+1), n.Attr...), nonce)
+		p.printAttributesToObject(&withNonce)
+	} else {
+		p.printAttributesToObject(n)
+	}
 	if n.FirstChild != nil && strings.TrimSpace(n.FirstChild.Data) != "" {
 		p.print(",children:`")
-		p.addSourceMapping(n.Loc[0])
-		p.print(escapeText(strings.TrimSpace(n.FirstChild.Data)))
+		content, offset := p.trimWhitespace(n.FirstChild.Data)
+		if len(n.FirstChild.Loc) > 0 {
+			p.addSourceMapping(loc.Loc{Start: n.FirstChild.Loc[0].Start + offset})
+		} else {
+			p.addSourceMapping(n.Loc[0])
+		}
+		p.print(escape.TemplateLiteral(rawContent(n, content)))
 		p.addNilSourceMapping()
 		p.print("`")
 	}
+	if n.DataAtom == atom.Style && transform.IsCritical(n) {
+		p.print(",critical:true")
+	}
 	p.print("},\n")
 }
 
+// nonceAttribute returns the nonce attribute a <script> or <style> is
+// rendered with when the CSPNonceVar option is set, unless it has its own
+func (p *printer) nonceAttribute(n *astro.Node) (astro.Attribute, bool) {
+	if p.opts.CSPNonceVar == "" || !(n.DataAtom == atom.Script || n.DataAtom == atom.Style) || transform.HasAttr(n, "nonce") {
+		return astro.Attribute{}, false
+	}
+	return astro.Attribute{Key: "nonce", Type: astro.ExpressionAttribute, Val: p.opts.CSPNonceVar}, true
+}
+
 func (p *printer) printAttribute(attr astro.Attribute) {
-	if attr.Key == "define:vars" {
+	if attr.Key == "define:vars" || attr.Key == transform.IgnoreDirective || attr.Key == transform.CriticalDirective || transform.IsTransitionDirective(attr.Key) {
 		return
 	}
 
@@ -218,36 +435,54 @@ func (p *printer) printAttribute(attr astro.Attribute) {
 	switch attr.Type {
 	case astro.QuotedAttribute:
 		p.addSourceMapping(attr.KeyLoc)
-		p.print(attr.Key)
+		p.print(escape.TemplateLiteral(attr.Key))
 		p.print("=")
 		p.addSourceMapping(attr.ValLoc)
-		p.print(`"` + encodeDoubleQuote(attr.Val) + `"`)
+		if p.opts.Entities == "normalize" {
+			p.print(`"` + escape.TemplateLiteral(normalizeAttributeEntities(attr.Val)) + `"`)
+		} else {
+			p.print(`"` + escape.TemplateLiteral(escape.DoubleQuotes(attr.Val)) + `"`)
+		}
 	case astro.EmptyAttribute:
 		p.addSourceMapping(attr.KeyLoc)
-		p.print(attr.Key)
+		p.print(escape.TemplateLiteral(attr.Key))
 	case astro.ExpressionAttribute:
 		p.print(fmt.Sprintf("${%s(", ADD_ATTRIBUTE))
-		p.addSourceMapping(attr.ValLoc)
-		p.print(strings.TrimSpace(attr.Val))
+		value, offset := p.trimWhitespace(attr.Val)
+		if candidateListAttributes[attr.Key] {
+			// Candidate lists may also be passed as an array of candidates
+			p.print(JOIN_CANDIDATES + "(")
+			p.addSourceMapping(loc.Loc{Start: attr.ValLoc.Start + offset})
+			p.print(value)
+			p.print(")")
+		} else {
+			p.addSourceMapping(loc.Loc{Start: attr.ValLoc.Start + offset})
+			p.print(value)
+		}
 		p.addSourceMapping(attr.KeyLoc)
-		p.print(`, "` + strings.TrimSpace(attr.Key) + `")}`)
+		p.print(", " + quoteAttributeKey(strings.TrimSpace(attr.Key)) + ")}")
 	case astro.SpreadAttribute:
 		p.print(fmt.Sprintf("${%s(", SPREAD_ATTRIBUTES))
 		p.addSourceMapping(loc.Loc{Start: attr.KeyLoc.Start - 3})
 		p.print(strings.TrimSpace(attr.Key))
-		p.print(`, "` + strings.TrimSpace(attr.Key) + `")}`)
+		p.print(", " + quoteAttributeKey(strings.TrimSpace(attr.Key)) + ")}")
 	case astro.ShorthandAttribute:
 		p.print(fmt.Sprintf("${%s(", ADD_ATTRIBUTE))
 		p.addSourceMapping(attr.KeyLoc)
 		p.print(strings.TrimSpace(attr.Key))
 		p.addSourceMapping(attr.KeyLoc)
-		p.print(`, "` + strings.TrimSpace(attr.Key) + `")}`)
+		p.print(", " + quoteAttributeKey(strings.TrimSpace(attr.Key)) + ")}")
 	case astro.TemplateLiteralAttribute:
 		p.print(fmt.Sprintf("${%s(`", ADD_ATTRIBUTE))
-		p.addSourceMapping(attr.ValLoc)
-		p.print(strings.TrimSpace(attr.Val))
+		// Whitespace is part of the string, so it's only removed when asked to
+		value, offset := attr.Val, 0
+		if p.opts.TrimWhitespace == "aggressive" {
+			value, offset = p.trimWhitespace(attr.Val)
+		}
+		p.addSourceMapping(loc.Loc{Start: attr.ValLoc.Start + offset})
+		p.print(value)
 		p.addSourceMapping(attr.KeyLoc)
-		p.print("`" + `, "` + strings.TrimSpace(attr.Key) + `")}`)
+		p.print("`, " + quoteAttributeKey(strings.TrimSpace(attr.Key)) + ")}")
 	}
 }
 
@@ -260,17 +495,84 @@ func (p *printer) addNilSourceMapping() {
 }
 
 func (p *printer) printTopLevelAstro() {
+	if p.lean {
+		return
+	}
 	p.println(fmt.Sprintf("const $$Astro = %s(import.meta.url, '%s');\nconst Astro = $$Astro;", CREATE_ASTRO, p.opts.Site))
 }
 
+func routeMetadata(route transform.Route) string {
+	pattern := "undefined"
+	if route.Pattern != "" {
+		pattern = fmt.Sprintf("'%s'", escape.SingleQuoted(route.Pattern))
+	}
+	prerender := "undefined"
+	if route.Prerender != nil {
+		prerender = fmt.Sprint(*route.Prerender)
+	}
+	return fmt.Sprintf("{ pattern: %s, params: %s, readParams: %s, getStaticPaths: %t, prerender: %s }", pattern, printStringArray(route.Params), printStringArray(route.ReadParams), route.GetStaticPaths, prerender)
+}
+
+// metadataEntry is a property of the object passed to createMetadata, an
+// array of items if array is set
+type metadataEntry struct {
+	key   string
+	value string
+	items []string
+	array bool
+}
+
+// printMetadataObject prints entries as an object on a single line, or with
+// a line for every property and every item of an array for the "multi-line"
+// MetadataFormat, so a change to one item changes one line of the output
+func (p *printer) printMetadataObject(entries []metadataEntry) {
+	if p.opts.MetadataFormat != "multi-line" {
+		properties := make([]string, 0, len(entries))
+		for _, e := range entries {
+			if e.array {
+				properties = append(properties, fmt.Sprintf("%s: [%s]", e.key, strings.Join(e.items, ", ")))
+			} else {
+				properties = append(properties, fmt.Sprintf("%s: %s", e.key, e.value))
+			}
+		}
+		p.print("{ " + strings.Join(properties, ", ") + " }")
+		return
+	}
+	var b strings.Builder
+	b.WriteString("{\n")
+	for _, e := range entries {
+		switch {
+		case !e.array:
+			fmt.Fprintf(&b, "  %s: %s,\n", e.key, e.value)
+		case len(e.items) == 0:
+			fmt.Fprintf(&b, "  %s: [],\n", e.key)
+		default:
+			fmt.Fprintf(&b, "  %s: [\n", e.key)
+			for _, item := range e.items {
+				fmt.Fprintf(&b, "    %s,\n", item)
+			}
+			b.WriteString("  ],\n")
+		}
+	}
+	b.WriteString("}")
+	p.print(b.String())
+}
+
 func (p *printer) printComponentMetadata(doc *astro.Node, source []byte) {
+	if p.lean {
+		return
+	}
 	var specs []string
 
 	modCount := 1
-	loc, statement := js_scanner.NextImportStatement(source, 0)
+	loc, statement := js_scanner.NextModuleStatement(source, 0)
 	for loc != -1 {
 		isClientOnlyImport := false
 		for _, n := range doc.ClientOnlyComponents {
+			// A re-export doesn't bind anything the template could render
+			if statement.IsExport {
+				break
+			}
 			for _, imported := range statement.Imports {
 				if imported.ExportName == "*" {
 					prefix := fmt.Sprintf("%s.", imported.LocalName)
@@ -281,7 +583,7 @@ func (p *printer) printComponentMetadata(doc *astro.Node, source []byte) {
 						// Inject metadata attributes to `client:only` Component
 						pathAttr := astro.Attribute{
 							Key:  "client:component-path",
-							Val:  fmt.Sprintf(`$$metadata.resolvePath("%s")`, statement.Specifier),
+							Val:  fmt.Sprintf(`$$metadata.resolvePath("%s")`, escape.Requote(statement.Specifier, '"')),
 							Type: astro.ExpressionAttribute,
 						}
 						n.Attr = append(n.Attr, pathAttr)
@@ -300,7 +602,7 @@ func (p *printer) printComponentMetadata(doc *astro.Node, source []byte) {
 					// Inject metadata attributes to `client:only` Component
 					pathAttr := astro.Attribute{
 						Key:  "client:component-path",
-						Val:  fmt.Sprintf(`$$metadata.resolvePath("%s")`, statement.Specifier),
+						Val:  fmt.Sprintf(`$$metadata.resolvePath("%s")`, escape.Requote(statement.Specifier, '"')),
 						Type: astro.ExpressionAttribute,
 					}
 					n.Attr = append(n.Attr, pathAttr)
@@ -321,55 +623,68 @@ func (p *printer) printComponentMetadata(doc *astro.Node, source []byte) {
 			}
 		}
 		if !isClientOnlyImport {
-			p.print(fmt.Sprintf("\nimport * as $$module%v from '%s';", modCount, statement.Specifier))
+			// Every import is a line of its own whatever the MetadataFormat, so
+			// adding a module already changes a single line
+			if statement.Attributes != "" {
+				// A JSON module can't be imported without its assertion
+				p.print(fmt.Sprintf("\nimport * as $$module%v from '%s' %s;", modCount, escape.Requote(statement.Specifier, '\''), statement.Attributes))
+			} else {
+				p.print(fmt.Sprintf("\nimport * as $$module%v from '%s';", modCount, escape.Requote(statement.Specifier, '\'')))
+			}
 			specs = append(specs, statement.Specifier)
 			modCount++
 		}
-		loc, statement = js_scanner.NextImportStatement(source, loc)
+		loc, statement = js_scanner.NextModuleStatement(source, loc)
 	}
 	// If we added imports, add a line break.
 	if modCount > 1 {
 		p.print("\n")
 	}
 
-	// Call createMetadata
-	p.print(fmt.Sprintf("\nexport const $$metadata = %s(import.meta.url, { ", CREATE_METADATA))
-
-	// Add modules
-	p.print("modules: [")
+	modules := metadataEntry{key: "modules", array: true}
 	for i := 1; i < modCount; i++ {
-		if i > 1 {
-			p.print(", ")
-		}
-		p.print(fmt.Sprintf("{ module: $$module%v, specifier: '%s' }", i, specs[i-1]))
+		modules.items = append(modules.items, fmt.Sprintf("{ module: $$module%v, specifier: '%s' }", i, escape.Requote(specs[i-1], '\'')))
 	}
-	p.print("]")
-
-	// Hydrated Components
-	p.print(", hydratedComponents: [")
-	for i, node := range doc.HydratedComponents {
-		if i > 0 {
-			p.print(", ")
-		}
-
+	hydratedComponents := metadataEntry{key: "hydratedComponents", array: true}
+	for _, node := range doc.HydratedComponents {
 		if node.CustomElement {
-			p.print(fmt.Sprintf("'%s'", node.Data))
+			hydratedComponents.items = append(hydratedComponents.items, fmt.Sprintf("'%s'", escape.SingleQuoted(node.Data)))
 		} else {
-			p.print(node.Data)
+			hydratedComponents.items = append(hydratedComponents.items, node.Data)
 		}
 	}
-	p.print("], hoisted: [")
-	for i, node := range doc.Scripts {
-		if i > 0 {
-			p.print(", ")
-		}
-
+	islands := metadataEntry{key: "islands", array: true}
+	for _, island := range transform.Islands(doc) {
+		islands.items = append(islands.items, fmt.Sprintf("{ name: '%s', directive: '%s' }", escape.SingleQuoted(island.Name), escape.SingleQuoted(island.Directive)))
+	}
+	transitions := metadataEntry{key: "transitions", array: true}
+	for _, t := range transform.Transitions(doc, p.opts) {
+		transitions.items = append(transitions.items, fmt.Sprintf("{ name: %s, animate: %s, persist: %t }", staticValue(t.Name), staticValue(t.Animate), t.Persist != nil))
+	}
+	hoisted := metadataEntry{key: "hoisted", array: true}
+	links := transform.LinkCustomElements(doc)
+	for _, node := range doc.Scripts {
 		src := astro.GetAttribute(node, "src")
 		if src != nil {
-			p.print(fmt.Sprintf("{ type: 'remote', src: '%s' }", escapeSingleQuote(src.Val)))
+			hoisted.items = append(hoisted.items, remoteScriptMetadata(node, src))
 		} else if node.FirstChild != nil {
-			p.print(fmt.Sprintf("{ type: 'inline', value: `%s` }", escapeInterpolation(escapeBackticks(node.FirstChild.Data))))
+			script := fmt.Sprintf("{ type: 'inline', value: %s", escape.ScriptContent(escape.JSONString(node.FirstChild.Data)))
+			if link, ok := links[node]; ok {
+				script += fmt.Sprintf(", defines: %s, used: %s", printStringArray(link.Defines), printStringArray(link.Used))
+			}
+			hoisted.items = append(hoisted.items, script+" }")
 		}
 	}
-	p.print("] });\n\n")
+	entries := []metadataEntry{modules, hydratedComponents, islands, transitions, hoisted}
+	if p.opts.IsPage {
+		entries = append(entries, metadataEntry{key: "route", value: routeMetadata(transform.RouteInfo(doc, p.opts))})
+	}
+	if p.opts.StampVersion {
+		entries = append(entries, metadataEntry{key: "compilerVersion", value: fmt.Sprintf("'%s'", astro.Version)})
+	}
+
+	// Call createMetadata
+	p.print(fmt.Sprintf("\nexport const $$metadata = %s(import.meta.url, ", CREATE_METADATA))
+	p.printMetadataObject(entries)
+	p.print(");\n\n")
 }
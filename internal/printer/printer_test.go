@@ -9,11 +9,14 @@ import (
 	"testing"
 
 	tycho "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/handler"
+	"github.com/snowpackjs/astro/internal/loc"
+	"github.com/snowpackjs/astro/internal/sourcemap"
 	"github.com/snowpackjs/astro/internal/test_utils"
 	"github.com/snowpackjs/astro/internal/transform"
 )
 
-var INTERNAL_IMPORTS = fmt.Sprintf("import {\n  %s\n} from \"%s\";\n", strings.Join([]string{
+var INTERNAL_SPECIFIERS = []string{
 	FRAGMENT,
 	"render as " + TEMPLATE_TAG,
 	"createAstro as " + CREATE_ASTRO,
@@ -21,14 +24,49 @@ var INTERNAL_IMPORTS = fmt.Sprintf("import {\n  %s\n} from \"%s\";\n", strings.J
 	"renderComponent as " + RENDER_COMPONENT,
 	"renderSlot as " + RENDER_SLOT,
 	"addAttribute as " + ADD_ATTRIBUTE,
+	"joinCandidates as " + JOIN_CANDIDATES,
 	"spreadAttributes as " + SPREAD_ATTRIBUTES,
+	"mergeAttr as " + MERGE_ATTR,
 	"defineStyleVars as " + DEFINE_STYLE_VARS,
 	"defineScriptVars as " + DEFINE_SCRIPT_VARS,
+	"renderTransition as " + RENDER_TRANSITION,
+	"createTransitionScope as " + CREATE_TRANSITION_SCOPE,
+	"markRenderStart as " + MARK_RENDER_START,
+	"markRenderEnd as " + MARK_RENDER_END,
+	"openIsland as " + OPEN_ISLAND,
+	"closeIsland as " + CLOSE_ISLAND,
 	"createMetadata as " + CREATE_METADATA,
-}, ",\n  "), "http://localhost:3000/")
+}
+
+// The placeholder for the internal imports in an expected module, which
+// expectedInternalImports replaces with the helpers the case imports
+var INTERNAL_IMPORTS = "%%INTERNAL_IMPORTS%%\n"
+
+// expectedInternalImports returns the internal imports of a module which uses
+// the helpers of w, besides the ones every module uses
+func expectedInternalImports(w want) string {
+	imported := map[string]bool{TEMPLATE_TAG: true, CREATE_COMPONENT: true}
+	if !w.lean {
+		imported[CREATE_ASTRO] = true
+		imported[CREATE_METADATA] = true
+	}
+	for _, helper := range w.helpers {
+		imported[helper] = true
+	}
+	used := make([]string, 0)
+	for _, specifier := range INTERNAL_SPECIFIERS {
+		if imported[specifier[strings.LastIndex(specifier, " ")+1:]] {
+			used = append(used, specifier)
+		}
+	}
+	return fmt.Sprintf("import {\n  %s\n} from \"%s\";\n", strings.Join(used, ",\n  "), "http://localhost:3000/")
+}
+
 var PRELUDE = fmt.Sprintf(`//@ts-ignore
 const $$Component = %s(async ($$result, $$props, %s) => {
 const Astro = $$result.createAstro($$Astro, $$props, %s);%s`, CREATE_COMPONENT, SLOTS, SLOTS, "\n")
+var LEAN_PRELUDE = fmt.Sprintf(`//@ts-ignore
+const $$Component = %s(async ($$result, $$props, %s) => {%s`, CREATE_COMPONENT, SLOTS, "\n")
 var RETURN = fmt.Sprintf("return %s%s", TEMPLATE_TAG, BACKTICK)
 var SUFFIX = fmt.Sprintf("%s;", BACKTICK) + `
 });
@@ -43,26 +81,35 @@ var CREATE_ASTRO_CALL = "const $$Astro = $$createAstro(import.meta.url, 'https:/
 var NON_WHITESPACE_CHARS = []byte("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!@#$%^&*()-_=+[];:'\",.?")
 
 type want struct {
+	sideEffects    string   // imports printed before the internal imports
+	helpers        []string // runtime helpers imported besides the ones every module uses
 	frontmatter    []string
 	styles         []string
+	definedVars    string // the define:vars moved onto root elements
 	scripts        []string
 	getStaticPaths string
 	code           string
-	skipHoist      bool // HACK: sometimes `getStaticPaths()` appears in a slightly-different location. Only use this if needed!
+	deferred       []string // components split out with server:defer, printed after the default export
+	skipHoist      bool     // HACK: sometimes `getStaticPaths()` appears in a slightly-different location. Only use this if needed!
+	lean           bool     // no `$$metadata` export or `Astro` global
 	metadata
 }
 
 type metadata struct {
 	hoisted            []string
 	hydratedComponents []string
+	islands            []string
+	transitions        []string
 	modules            []string
+	route              string
 }
 
 type testcase struct {
-	name   string
-	source string
-	only   bool
-	want   want
+	name             string
+	source           string
+	only             bool
+	transformOptions transform.TransformOptions
+	want             want
 }
 
 func TestPrinter(t *testing.T) {
@@ -76,6 +123,7 @@ func TestPrinter(t *testing.T) {
 			name:   "basic (no frontmatter)",
 			source: `<button>Click</button>`,
 			want: want{
+				lean: true,
 				code: `<html><head></head><body><button>Click</button></body></html>`,
 			},
 		},
@@ -86,6 +134,7 @@ const href = '/about';
 ---
 <a href={href}>About</a>`,
 			want: want{
+				helpers:     []string{ADD_ATTRIBUTE},
 				frontmatter: []string{"", "const href = '/about';"},
 				code:        `<html><head></head><body><a${` + ADD_ATTRIBUTE + `(href, "href")}>About</a></body></html>`,
 			},
@@ -155,6 +204,7 @@ import VueComponent from '../components/Vue.vue';
   </body>
 </html>`,
 			want: want{
+				helpers: []string{RENDER_COMPONENT},
 				frontmatter: []string{
 					`import VueComponent from '../components/Vue.vue';`,
 				},
@@ -182,6 +232,7 @@ import * as ns from '../components';
   </body>
 </html>`,
 			want: want{
+				helpers:     []string{RENDER_COMPONENT},
 				frontmatter: []string{`import * as ns from '../components';`},
 				styles:      []string{},
 				metadata:    metadata{modules: []string{`{ module: $$module1, specifier: '../components' }`}},
@@ -208,6 +259,8 @@ import Component from '../components';
   </body>
 </html>`,
 			want: want{
+				helpers:     []string{RENDER_COMPONENT},
+				metadata:    metadata{islands: []string{`{ name: 'Component', directive: 'only' }`}},
 				frontmatter: []string{"import Component from '../components';"},
 				// Specifically do NOT render any metadata here, we need to skip this import
 				code: `<html>
@@ -233,6 +286,8 @@ import { Component } from '../components';
   </body>
 </html>`,
 			want: want{
+				helpers:     []string{RENDER_COMPONENT},
+				metadata:    metadata{islands: []string{`{ name: 'Component', directive: 'only' }`}},
 				frontmatter: []string{"import { Component } from '../components';"},
 				// Specifically do NOT render any metadata here, we need to skip this import
 				code: `<html>
@@ -258,6 +313,8 @@ import * as components from '../components';
   </body>
 </html>`,
 			want: want{
+				helpers:     []string{RENDER_COMPONENT},
+				metadata:    metadata{islands: []string{`{ name: 'components.A', directive: 'only' }`}},
 				frontmatter: []string{"import * as components from '../components';"},
 				// Specifically do NOT render any metadata here, we need to skip this import
 				code: `<html>
@@ -273,6 +330,7 @@ import * as components from '../components';
 			name:   "conditional render",
 			source: `<body>{false ? <div>#f</div> : <div>#t</div>}</body>`,
 			want: want{
+				lean: true,
 				code: "<html><head></head><body>${false ? $$render`<div>#f</div>` : $$render`<div>#t</div>`}</body></html>",
 			},
 		},
@@ -326,9 +384,18 @@ const groups = [[0, 1, 2], [3, 4, 5]];
 			name:   "backtick in HTML comment",
 			source: "<body><!-- `npm install astro` --></body>",
 			want: want{
+				lean: true,
 				code: "<html><head></head><body><!-- \\`npm install astro\\` --></body></html>",
 			},
 		},
+		{
+			name:   "expressions with regular expressions and division",
+			source: `<ul>{items.filter((i) => /a\/b}/.test(i)).map((i) => <li>{i}</li>)}</ul><p>{a / b} and {c / d}</p>`,
+			want: want{
+				lean: true,
+				code: `<html><head></head><body><ul>${items.filter((i) => /a\/b}/.test(i)).map((i) => $$render` + "`" + `<li>${i}</li>` + "`" + `)}</ul><p>${a / b} and ${c / d}</p></body></html>`,
+			},
+		},
 		{
 			name: "expressions with JS comments",
 			source: `---
@@ -344,6 +411,7 @@ const items = ['red', 'yellow', 'blue'];
   ))}
 </div>`,
 			want: want{
+				helpers:     []string{ADD_ATTRIBUTE},
 				frontmatter: []string{"", "const items = ['red', 'yellow', 'blue'];"},
 				code: `<html><head></head><body><div>
   ${items.map((item) => (
@@ -356,6 +424,61 @@ $$render` + "`" + `<div${$$addAttribute(color, "id")}>color</div>` + "`" + `
 </div></body></html>`,
 			},
 		},
+		{
+			name:             "scoped style attribute strategy",
+			source:           `<style>.a{color:red}</style><div class="a"><Component /></div>`,
+			transformOptions: transform.TransformOptions{ScopedStyleStrategy: "attribute"},
+			want: want{
+				helpers: []string{RENDER_COMPONENT},
+				styles:  []string{"{props:{\"data-astro-id\":\"QQHERAIL\"},children:`.a[data-astro-cid-QQHERAIL]{color:red;}`}"},
+				code:    `<html data-astro-cid-QQHERAIL><head></head><body><div class="a" data-astro-cid-QQHERAIL>${$$renderComponent($$result,'Component',Component,{"data-astro-cid-QQHERAIL":true})}</div></body></html>`,
+			},
+		},
+		{
+			name:             "scope root elements",
+			source:           `<div {...props}><span /></div><p class:list={["a", b]} />`,
+			transformOptions: transform.TransformOptions{ScopeRootElements: true},
+			want: want{
+				helpers: []string{ADD_ATTRIBUTE, SPREAD_ATTRIBUTES, MERGE_ATTR},
+				lean:    true,
+				code:    `<html><head></head><body><div${$$spreadAttributes($$mergeAttr(props, "class", "astro-OL7B7QO2"), "$$mergeAttr(props, \"class\", \"astro-OL7B7QO2\")")}><span></span></div><p${$$addAttribute([["a", b], "astro-OL7B7QO2"], "class:list")}></p></body></html>`,
+			},
+		},
+		{
+			name: "side-effect imports",
+			source: `---
+// Global styles first
+import Component from "test";
+import "./global.css";
+import Other from "other";
+---
+<Component /><Other />`,
+			want: want{
+				helpers: []string{RENDER_COMPONENT},
+				sideEffects: `// Global styles first
+import Component from "test";
+import "./global.css";`,
+				frontmatter: []string{`import Other from "other";`},
+				metadata:    metadata{modules: []string{`{ module: $$module1, specifier: 'test' }`, `{ module: $$module2, specifier: './global.css' }`, `{ module: $$module3, specifier: 'other' }`}},
+				code:        `${$$renderComponent($$result,'Component',Component,{})}${$$renderComponent($$result,'Other',Other,{})}`,
+			},
+		},
+		{
+			name: "commented out imports",
+			source: `---
+// import Old from "old";
+import Component from "test";
+const pattern = /import x from 'x'/;
+---
+<Component />`,
+			want: want{
+				helpers: []string{RENDER_COMPONENT},
+				frontmatter: []string{`// import Old from "old";
+import Component from "test";`, `const pattern = /import x from 'x'/;`},
+				metadata: metadata{modules: []string{`{ module: $$module1, specifier: 'test' }`}},
+				code:     `${$$renderComponent($$result,'Component',Component,{})}`,
+			},
+		},
 		{
 			name: "slots (basic)",
 			source: `---
@@ -366,6 +489,7 @@ import Component from "test";
 	<div slot="named">Named</div>
 </Component>`,
 			want: want{
+				helpers:     []string{RENDER_COMPONENT},
 				frontmatter: []string{`import Component from "test";`},
 				metadata:    metadata{modules: []string{`{ module: $$module1, specifier: 'test' }`}},
 				code:        `${$$renderComponent($$result,'Component',Component,{},{"default": () => $$render` + "`" + `<div>Default</div>` + "`" + `,"named": () => $$render` + "`" + `<div>Named</div>` + "`" + `,})}`,
@@ -382,6 +506,7 @@ import Component from 'test';
 	<div slot="named">Named</div>
 </Component>`,
 			want: want{
+				helpers:     []string{RENDER_COMPONENT},
 				frontmatter: []string{`import Component from 'test';`},
 				metadata:    metadata{modules: []string{`{ module: $$module1, specifier: 'test' }`}},
 				code:        `${$$renderComponent($$result,'Component',Component,{},{"default": () => $$render` + "`" + `<div>Default</div>` + "`" + `,"named": () => $$render` + "`" + `<div>Named</div>` + "`" + `,})}`,
@@ -427,6 +552,7 @@ const name = "world";
 		<h1 class="title">Page Title</h1>
 		<p class="body">I’m a page</p>`,
 			want: want{
+				lean:   true,
 				styles: []string{"{props:{\"data-astro-id\":\"DPOHFLYM\"},children:`.title.astro-DPOHFLYM{font-family:fantasy;font-size:28px;}.body.astro-DPOHFLYM{font-size:1em;}`}"},
 				code: `<html class="astro-DPOHFLYM"><head>
 
@@ -467,6 +593,7 @@ const name = "world";
   </body>
 </html>`,
 			want: want{
+				lean: true,
 				code: `<!DOCTYPE html><html lang="en">
 <head>
   <meta charset="utf-8">
@@ -544,6 +671,7 @@ const someProps = {
   </body>
 </html>`,
 			want: want{
+				helpers: []string{RENDER_COMPONENT},
 				frontmatter: []string{`// Component Imports
 import Counter from '../components/Counter.jsx'`,
 					`const someProps = {
@@ -554,6 +682,7 @@ import Counter from '../components/Counter.jsx'`,
 // https://docs.astro.build/core-concepts/astro-components/`},
 				styles: []string{fmt.Sprintf(`{props:{"data-astro-id":"HMNNHVCQ"},children:%s:root{font-family:system-ui;padding:2em 0;}.counter{display:grid;grid-template-columns:repeat(3,minmax(0,1fr));place-items:center;font-size:2em;margin-top:2em;}.children{display:grid;place-items:center;margin-bottom:2em;}%s}`, BACKTICK, BACKTICK)},
 				metadata: metadata{
+					islands:            []string{`{ name: 'Counter', directive: 'visible' }`},
 					modules:            []string{`{ module: $$module1, specifier: '../components/Counter.jsx' }`},
 					hydratedComponents: []string{`Counter`},
 				},
@@ -605,7 +734,7 @@ import Widget2 from '../components/Widget2.astro';`},
 				frontmatter: []string{""},
 				styles:      []string{},
 				scripts:     []string{fmt.Sprintf(`{props:{"type":"module","hoist":true},children:%sconsole.log("Hello");%s}`, BACKTICK, BACKTICK)},
-				metadata:    metadata{hoisted: []string{fmt.Sprintf(`{ type: 'inline', value: %sconsole.log("Hello");%s }`, BACKTICK, BACKTICK)}},
+				metadata:    metadata{hoisted: []string{`{ type: 'inline', value: "console.log(\"Hello\");" }`}},
 				code:        `<html><head></head><body></body></html>`,
 			},
 		},
@@ -618,10 +747,55 @@ import Widget2 from '../components/Widget2.astro';`},
 				frontmatter: []string{"\n"},
 				styles:      []string{},
 				scripts:     []string{`{props:{"type":"module","hoist":true,"src":"url"}}`},
-				metadata:    metadata{hoisted: []string{`{ type: 'remote', src: 'url' }`}},
+				metadata:    metadata{hoisted: []string{`{ type: 'remote', src: "url" }`}},
+				code:        "<html><head></head><body></body></html>",
+			},
+		},
+		{
+			name: "script hoist remote with quotes",
+			source: `---
+---
+<script type="module" hoist src="/it's ` + "`quoted`" + `&quot;.js" />`,
+			want: want{
+				frontmatter: []string{"\n"},
+				styles:      []string{},
+				scripts:     []string{`{props:{"type":"module","hoist":true,"src":"/it's ` + "`quoted`" + `\".js"}}`},
+				metadata:    metadata{hoisted: []string{`{ type: 'remote', src: "/it's ` + "`quoted`" + `\".js" }`}},
+				code:        "<html><head></head><body></body></html>",
+			},
+		},
+		{
+			name: "script hoist remote with integrity",
+			source: `---
+---
+<script type="module" hoist src="https://cdn.example.com/a.js" integrity="sha384-abc" crossorigin defer async={lazy} />`,
+			want: want{
+				frontmatter: []string{"\n"},
+				styles:      []string{},
+				scripts:     []string{`{props:{"type":"module","hoist":true,"src":"https://cdn.example.com/a.js","integrity":"sha384-abc","crossorigin":true,"defer":true,"async":(lazy)}}`},
+				metadata:    metadata{hoisted: []string{`{ type: 'remote', src: "https://cdn.example.com/a.js", integrity: "sha384-abc", crossorigin: "", defer: true }`}},
 				code:        "<html><head></head><body></body></html>",
 			},
 		},
+		{
+			name:             "csp nonce",
+			transformOptions: transform.TransformOptions{CSPNonceVar: "Astro.locals.nonce"},
+			source: `---
+---
+<script type="module" hoist>run()</script>
+<script is:inline>inline()</script>
+<script src="/a.js" nonce="fixed"></script>`,
+			want: want{
+				helpers:     []string{ADD_ATTRIBUTE},
+				frontmatter: []string{"\n"},
+				styles:      []string{},
+				scripts:     []string{"{props:{\"type\":\"module\",\"hoist\":true,\"nonce\":(Astro.locals.nonce)},children:`run()`}"},
+				metadata:    metadata{hoisted: []string{`{ type: 'inline', value: "run()" }`}},
+				code: `<html><head>
+<script is:inline${` + ADD_ATTRIBUTE + `(Astro.locals.nonce, "nonce")}>inline()</script>
+<script src="/a.js" nonce="fixed"></script></head><body></body></html>`,
+			},
+		},
 		{
 			name: "script hoist without frontmatter",
 			source: `
@@ -631,7 +805,7 @@ import Widget2 from '../components/Widget2.astro';`},
 			want: want{
 				styles:   []string{},
 				scripts:  []string{"{props:{\"type\":\"module\",\"hoist\":true},children:`console.log(\"Hello\");`}"},
-				metadata: metadata{hoisted: []string{fmt.Sprintf(`{ type: 'inline', value: %sconsole.log("Hello");%s }`, BACKTICK, BACKTICK)}},
+				metadata: metadata{hoisted: []string{`{ type: 'inline', value: "console.log(\"Hello\");" }`}},
 				code: `<html><head></head><body><main>
 
 </main></body></html>`,
@@ -641,6 +815,7 @@ import Widget2 from '../components/Widget2.astro';`},
 			name:   "script nohoist",
 			source: `<main><script type="module">console.log("Hello");</script>`,
 			want: want{
+				lean: true,
 				code: `<html><head></head><body><main><script type="module">console.log("Hello");</script></main></body></html>`,
 			},
 		},
@@ -648,13 +823,16 @@ import Widget2 from '../components/Widget2.astro';`},
 			name:   "script define:vars",
 			source: `<main><script define:vars={{ value: 0 }} type="module">console.log(value);</script>`,
 			want: want{
-				code: fmt.Sprintf(`<html><head></head><body><main><script type="module">${%s({ value: 0 })}console.log(value);</script></main></body></html>`, DEFINE_SCRIPT_VARS),
+				helpers: []string{DEFINE_SCRIPT_VARS},
+				lean:    true,
+				code:    fmt.Sprintf(`<html><head></head><body><main><script type="module">${%s({ value: 0 })}console.log(value);</script></main></body></html>`, DEFINE_SCRIPT_VARS),
 			},
 		},
 		{
 			name:   "text after title expression",
 			source: `<title>a {expr} b</title>`,
 			want: want{
+				lean: true,
 				code: `<html><head><title>a ${expr} b</title></head><body></body></html>`,
 			},
 		},
@@ -662,6 +840,7 @@ import Widget2 from '../components/Widget2.astro';`},
 			name:   "text after title expressions",
 			source: `<title>a {expr} b {expr} c</title>`,
 			want: want{
+				lean: true,
 				code: `<html><head><title>a ${expr} b ${expr} c</title></head><body></body></html>`,
 			},
 		},
@@ -675,6 +854,7 @@ import Widget2 from '../components/Widget2.astro';`},
 			<div slot={name}>Named</div>
 		</Component>`,
 			want: want{
+				helpers:     []string{RENDER_COMPONENT},
 				frontmatter: []string{`import Component from 'test';`, `const name = 'named';`},
 				styles:      []string{},
 				metadata:    metadata{modules: []string{`{ module: $$module1, specifier: 'test' }`}},
@@ -685,6 +865,7 @@ import Widget2 from '../components/Widget2.astro';`},
 			name:   "condition expressions at the top-level",
 			source: `{cond && <span></span>}{cond && <strong></strong>}`,
 			want: want{
+				lean: true,
 				code: "<html><head></head><body>${cond && $$render`<span></span>`}${cond && $$render`<strong></strong>`}</body></html>",
 			},
 		},
@@ -692,6 +873,7 @@ import Widget2 from '../components/Widget2.astro';`},
 			name:   "condition expressions at the top-level with head content",
 			source: `{cond && <meta charset=utf8>}{cond && <title>My title</title>}`,
 			want: want{
+				lean: true,
 				code: "<html><head>${cond && $$render`<meta charset=\"utf8\">`}${cond && $$render`<title>My title</title>`}</head><body></body></html>",
 			},
 		},
@@ -702,7 +884,9 @@ import 'test';
 ---
 <my-element></my-element>`,
 			want: want{
-				frontmatter: []string{`import 'test';`},
+				helpers:     []string{RENDER_COMPONENT},
+				sideEffects: `import 'test';`,
+				frontmatter: []string{``},
 				styles:      []string{},
 				metadata:    metadata{modules: []string{`{ module: $$module1, specifier: 'test' }`}},
 				code:        `<html><head></head><body>${$$renderComponent($$result,'my-element','my-element',{})}</body></html>`,
@@ -721,11 +905,13 @@ const name = 'world';
 <my-element client:load />
 `,
 			want: want{
-				frontmatter: []string{`import One from 'one';
+				helpers: []string{RENDER_COMPONENT},
+				sideEffects: `import One from 'one';
 import Two from 'two';
 import 'custom-element';`,
-					`const name = 'world';`},
+				frontmatter: []string{``, `const name = 'world';`},
 				metadata: metadata{
+					islands: []string{`{ name: 'One', directive: 'load' }`, `{ name: 'Two', directive: 'load' }`, `{ name: 'my-element', directive: 'load' }`},
 					modules: []string{
 						`{ module: $$module1, specifier: 'one' }`,
 						`{ module: $$module2, specifier: 'two' }`,
@@ -739,205 +925,868 @@ ${$$renderComponent($$result,'my-element','my-element',{"client:load":true,"clie
 			},
 		},
 		{
-			name:   "Component siblings are siblings",
-			source: `<BaseHead></BaseHead><link href="test">`,
+			name: "hydrated custom element with configured module",
+			source: `---
+import '../elements/my-element.js';
+---
+<my-element client:visible />`,
+			transformOptions: transform.TransformOptions{
+				CustomElements: map[string]string{"my-element": "../elements/my-element.js"},
+			},
 			want: want{
-				code: `${$$renderComponent($$result,'BaseHead',BaseHead,{})}<link href="test">`,
+				helpers:     []string{RENDER_COMPONENT},
+				sideEffects: `import '../elements/my-element.js';`,
+				frontmatter: []string{``},
+				metadata: metadata{
+					islands:            []string{`{ name: 'my-element', directive: 'visible' }`},
+					modules:            []string{`{ module: $$module1, specifier: '../elements/my-element.js' }`},
+					hydratedComponents: []string{"'my-element'"},
+				},
+				code: `<html><head></head><body>${$$renderComponent($$result,'my-element','my-element',{"client:visible":true,"client:component-path":($$metadata.resolvePath("../elements/my-element.js"))})}</body></html>`,
 			},
 		},
 		{
-			name:   "Self-closing components siblings are siblings",
-			source: `<BaseHead /><link href="test">`,
+			name:   "client:only custom element with configured module",
+			source: `<my-element client:only />`,
+			transformOptions: transform.TransformOptions{
+				CustomElements: map[string]string{"my-element": "../elements/my-element.js"},
+			},
 			want: want{
-				code: `${$$renderComponent($$result,'BaseHead',BaseHead,{})}<link href="test">`,
+				helpers:  []string{RENDER_COMPONENT},
+				metadata: metadata{islands: []string{`{ name: 'my-element', directive: 'only' }`}},
+				code:     `<html><head></head><body>${$$renderComponent($$result,'my-element',null,{"client:only":true,"client:component-path":($$metadata.resolvePath("../elements/my-element.js"))})}</body></html>`,
 			},
 		},
 		{
-			name:   "Self-closing script in head works",
-			source: `<html><head><script /></head><html>`,
+			name:   "client:only custom element with quoted module",
+			source: `<my-element client:only />`,
+			transformOptions: transform.TransformOptions{
+				CustomElements: map[string]string{"my-element": `../elements/"my-element".js`},
+			},
 			want: want{
-				code: `<html><head><script></script></head><body></body></html>`,
+				helpers:  []string{RENDER_COMPONENT},
+				metadata: metadata{islands: []string{`{ name: 'my-element', directive: 'only' }`}},
+				code:     `<html><head></head><body>${$$renderComponent($$result,'my-element',null,{"client:only":true,"client:component-path":($$metadata.resolvePath("../elements/\"my-element\".js"))})}</body></html>`,
 			},
 		},
 		{
-			name:   "Self-closing components in head can have siblings",
-			source: `<html><head><BaseHead /><link href="test"></head><html>`,
+			name:   "hoisted custom element definition",
+			source: "<script hoist>customElements.define('my-element', MyElement);</script><my-element></my-element>",
 			want: want{
-				code: `<html><head>${$$renderComponent($$result,'BaseHead',BaseHead,{})}<link href="test"></head><body></body></html>`,
+				helpers:  []string{RENDER_COMPONENT},
+				scripts:  []string{"{props:{\"hoist\":true},children:`customElements.define('my-element', MyElement);`}"},
+				metadata: metadata{hoisted: []string{`{ type: 'inline', value: "customElements.define('my-element', MyElement);", defines: ['my-element'], used: ['my-element'] }`}},
+				code:     "<html><head></head><body>${$$renderComponent($$result,'my-element','my-element',{})}</body></html>",
 			},
 		},
 		{
-			name: "Nested HTML in expressions, wrapped in parens",
+			name:   "hoisted custom element definition without usage",
+			source: "<script hoist>customElements.define('my-element', MyElement);</script><div></div>",
+			want: want{
+				scripts:  []string{"{props:{\"hoist\":true},children:`customElements.define('my-element', MyElement);`}"},
+				metadata: metadata{hoisted: []string{`{ type: 'inline', value: "customElements.define('my-element', MyElement);", defines: ['my-element'], used: [] }`}},
+				code:     "<html><head></head><body><div></div></body></html>",
+			},
+		},
+		{
+			name:   "rewrite assets",
+			source: `<img src="/logo.png" srcset="a.png 1x, b.png 2x" alt="/logo.png"><a href="/about">About</a><img src="https://astro.build/logo.png">`,
+			transformOptions: transform.TransformOptions{
+				RewriteAsset: func(url string) string {
+					return "/_astro/" + strings.TrimPrefix(url, "/")
+				},
+			},
+			want: want{
+				lean: true,
+				code: `<html><head></head><body><img src="/_astro/logo.png" srcset="/_astro/a.png 1x, /_astro/b.png 2x" alt="/logo.png"><a href="/about">About</a><img src="https://astro.build/logo.png"></body></html>`,
+			},
+		},
+		{
+			name:   "srcset and sizes expressions",
+			source: "<img srcset={`${a} 1x, ${b} 2x`} sizes={sizes}>",
+			want: want{
+				helpers: []string{ADD_ATTRIBUTE, JOIN_CANDIDATES},
+				lean:    true,
+				code:    "<html><head></head><body><img${$$addAttribute($$joinCandidates(`${a} 1x, ${b} 2x`), \"srcset\")}${$$addAttribute($$joinCandidates(sizes), \"sizes\")}></body></html>",
+			},
+		},
+		{
+			name:   "srcset array expression",
+			source: "<img srcset={[`${a} 1x`, `${b} 2x`]}>",
+			want: want{
+				helpers: []string{ADD_ATTRIBUTE, JOIN_CANDIDATES},
+				lean:    true,
+				code:    "<html><head></head><body><img${$$addAttribute($$joinCandidates([`${a} 1x`, `${b} 2x`]), \"srcset\")}></body></html>",
+			},
+		},
+		{
+			name:   "srcset and sizes template literals",
+			source: "<img srcset=`${a} 1x, ${b} 2x` sizes=`(max-width: 600px) ${w}px, 100vw`>",
+			want: want{
+				helpers: []string{ADD_ATTRIBUTE},
+				lean:    true,
+				code:    "<html><head></head><body><img${$$addAttribute(`${a} 1x, ${b} 2x`, \"srcset\")}${$$addAttribute(`(max-width: 600px) ${w}px, 100vw`, \"sizes\")}></body></html>",
+			},
+		},
+		{
+			name:   "srcset template literal on component",
+			source: "<Image srcset=`${a} 1x, ${b} 2x` sizes={sizes} />",
+			want: want{
+				helpers: []string{RENDER_COMPONENT},
+				code:    "${$$renderComponent($$result,'Image',Image,{\"srcset\":`${a} 1x, ${b} 2x`,\"sizes\":(sizes)})}",
+			},
+		},
+		{
+			name:   "srcset with backticks",
+			source: "<img srcset=\"a`b.png 1x, ${c}.png 2x\">",
+			want: want{
+				lean: true,
+				code: "<html><head></head><body><img srcset=\"a\\`b.png 1x, \\${c}.png 2x\"></body></html>",
+			},
+		},
+		{
+			name:   "dynamic element",
+			source: `<Element is={tag} class="title">Hello</Element>`,
+			want: want{
+				helpers: []string{RENDER_COMPONENT},
+				code:    `${$$renderComponent($$result,'Element',(tag),{"class":"title"},{"default": () => $$render` + "`" + `Hello` + "`" + `,})}`,
+			},
+		},
+		{
+			name:   "dynamic element with expression",
+			source: `<Element is={level > 1 ? 'h2' : Heading} {...props} />`,
+			want: want{
+				helpers: []string{RENDER_COMPONENT},
+				code:    `${$$renderComponent($$result,'Element',(level > 1 ? 'h2' : Heading),{...(props)})}`,
+			},
+		},
+		{
+			name: "imported Element component",
 			source: `---
-const image = './penguin.png';
-const canonicalURL = new URL('http://example.com');
+import Element from '../components/Element.astro';
 ---
-{image && (<meta property="og:image" content={new URL(image, canonicalURL)}>)}`,
+<Element is={tag} />`,
 			want: want{
-				frontmatter: []string{"", `const image = './penguin.png';
-const canonicalURL = new URL('http://example.com');`},
-				styles: []string{},
-				code:   "<html><head>${image && ($$render`<meta property=\"og:image\"${$$addAttribute(new URL(image, canonicalURL), \"content\")}>`)}</head><body></body></html>",
+				helpers:     []string{RENDER_COMPONENT},
+				frontmatter: []string{`import Element from '../components/Element.astro';`},
+				metadata:    metadata{modules: []string{`{ module: $$module1, specifier: '../components/Element.astro' }`}},
+				code:        `${$$renderComponent($$result,'Element',Element,{"is":(tag)})}`,
 			},
 		},
 		{
-			name: "Use of interfaces within frontmatter",
+			name:   "conditional dynamic component",
+			source: `<div>{Tag && <Tag />}</div>`,
+			want: want{
+				helpers: []string{RENDER_COMPONENT},
+				code:    `<html><head></head><body><div>${Tag && $$render` + "`" + `${$$renderComponent($$result,'Tag',Tag,{})}` + "`" + `}</div></body></html>`,
+			},
+		},
+		{
+			name: "hydrated component with spread",
 			source: `---
-interface MarkdownFrontmatter {
-	date: number;
-	image: string;
-	author: string;
-}
-let allPosts = Astro.fetchContent<MarkdownFrontmatter>('./post/*.md');
+import Component from '../components/Component.jsx';
 ---
-<div>testing</div>`,
+<Component client:visible {...props} />`,
 			want: want{
-				frontmatter: []string{"", `interface MarkdownFrontmatter {
-	date: number;
-	image: string;
-	author: string;
-}
-let allPosts = Astro.fetchContent<MarkdownFrontmatter>('./post/*.md');`},
-				styles: []string{},
-				code:   "<html><head></head><body><div>testing</div></body></html>",
+				helpers:     []string{RENDER_COMPONENT},
+				frontmatter: []string{`import Component from '../components/Component.jsx';`},
+				metadata:    metadata{islands: []string{`{ name: 'Component', directive: 'visible' }`}, hydratedComponents: []string{`Component`}, modules: []string{`{ module: $$module1, specifier: '../components/Component.jsx' }`}},
+				code:        `${$$renderComponent($$result,'Component',Component,{...(props),"client:visible":true,"client:component-path":($$metadata.getPath(Component)),"client:component-export":($$metadata.getExport(Component))})}`,
 			},
 		},
 		{
-			name: "Component names A-Z",
+			name: "props serialization by reference",
 			source: `---
-import AComponent from '../components/AComponent.jsx';
-import ZComponent from '../components/ZComponent.jsx';
+import One from '../components/One.jsx';
+import Two from '../components/Two.jsx';
 ---
-
-<body>
-  <AComponent />
-  <ZComponent />
-</body>`,
+<One client:load a={1} /><Two client:only="react" />`,
+			transformOptions: transform.TransformOptions{
+				PropsSerialization: "reference",
+			},
 			want: want{
-				frontmatter: []string{
-					`import AComponent from '../components/AComponent.jsx';
-import ZComponent from '../components/ZComponent.jsx';`},
+				helpers: []string{RENDER_COMPONENT},
+				frontmatter: []string{`import One from '../components/One.jsx';
+import Two from '../components/Two.jsx';`},
 				metadata: metadata{
-					modules: []string{
-						`{ module: $$module1, specifier: '../components/AComponent.jsx' }`,
-						`{ module: $$module2, specifier: '../components/ZComponent.jsx' }`,
-					},
+					islands:            []string{`{ name: 'One', directive: 'load' }`, `{ name: 'Two', directive: 'only' }`},
+					modules:            []string{`{ module: $$module1, specifier: '../components/One.jsx' }`},
+					hydratedComponents: []string{"One"},
 				},
-				code: `<html><head></head><body>
-  ${` + RENDER_COMPONENT + `($$result,'AComponent',AComponent,{})}
-  ${` + RENDER_COMPONENT + `($$result,'ZComponent',ZComponent,{})}
-</body></html>`,
+				code: `${$$renderComponent($$result,'One',One,{"client:load":true,"a":(1),"client:component-path":($$metadata.getPath(One)),"client:component-export":($$metadata.getExport(One)),"client:props-serialization":"reference","client:props-id":"O7NKCY5S-0"})}${$$renderComponent($$result,'Two',null,{"client:only":"react","client:props-serialization":"reference","client:props-id":"O7NKCY5S-1","client:component-path":($$metadata.resolvePath("../components/Two.jsx")),"client:component-export":"default"})}`,
 			},
 		},
 		{
-			name: "Parser can handle files > 4096 chars",
-			source: `<html><body>` + longRandomString + `<img
-  width="1600"
-  height="1131"
-  class="img"
-  src="https://images.unsplash.com/photo-1469854523086-cc02fe5d8800?w=1200&q=75"
-  srcSet="https://images.unsplash.com/photo-1469854523086-cc02fe5d8800?w=1200&q=75 800w,https://images.unsplash.com/photo-1469854523086-cc02fe5d8800?w=1200&q=75 1200w,https://images.unsplash.com/photo-1469854523086-cc02fe5d8800?w=1600&q=75 1600w,https://images.unsplash.com/photo-1469854523086-cc02fe5d8800?w=2400&q=75 2400w"
-  sizes="(max-width: 800px) 800px, (max-width: 1200px) 1200px, (max-width: 1600px) 1600px, (max-width: 2400px) 2400px, 1200px"
-></body></html>`,
+			name: "server:defer",
+			source: `---
+import Avatar from '../components/Avatar.astro';
+const user = await getUser();
+---
+<aside server:defer class="side"><Avatar {user} /></aside>`,
 			want: want{
-				code: `<html><head></head><body>` + longRandomString + `<img width="1600" height="1131" class="img" src="https://images.unsplash.com/photo-1469854523086-cc02fe5d8800?w=1200&q=75" srcSet="https://images.unsplash.com/photo-1469854523086-cc02fe5d8800?w=1200&q=75 800w,https://images.unsplash.com/photo-1469854523086-cc02fe5d8800?w=1200&q=75 1200w,https://images.unsplash.com/photo-1469854523086-cc02fe5d8800?w=1600&q=75 1600w,https://images.unsplash.com/photo-1469854523086-cc02fe5d8800?w=2400&q=75 2400w" sizes="(max-width: 800px) 800px, (max-width: 1200px) 1200px, (max-width: 1600px) 1600px, (max-width: 2400px) 2400px, 1200px"></body></html>`,
+				helpers:     []string{RENDER_COMPONENT},
+				frontmatter: []string{`import Avatar from '../components/Avatar.astro';`, `const user = await getUser();`},
+				metadata:    metadata{modules: []string{`{ module: $$module1, specifier: '../components/Avatar.astro' }`}},
+				code:        `<html><head></head><body>${$$renderComponent($$result,'$$Deferred0',$$Deferred0,{"server:defer":true,"user":(user)})}</body></html>`,
+				deferred: []string{`export const $$Deferred0 = $$createComponent(async ($$result, $$props, $$slots) => {
+const Astro = $$result.createAstro($$Astro, $$props, $$slots);
+const { user } = $$props;
+return $$render` + "`" + `<aside class="side">${$$renderComponent($$result,'Avatar',Avatar,{"user":(user)})}</aside>` + "`" + `;
+});`},
 			},
 		},
 		{
-			name:   "SVG styles",
-			source: `<svg><style>path { fill: red; }</style></svg>`,
+			name: "server:defer with destructured props",
+			source: `---
+import Avatar from '../components/Avatar.astro';
+const { user, size = 32 } = Astro.props;
+---
+<aside server:defer><Avatar {user} size={size} /></aside>`,
 			want: want{
-				code: `<html><head></head><body><svg><style>path { fill: red; }</style></svg></body></html>`,
+				helpers:     []string{RENDER_COMPONENT},
+				frontmatter: []string{`import Avatar from '../components/Avatar.astro';`, `const { user, size = 32 } = Astro.props;`},
+				metadata:    metadata{modules: []string{`{ module: $$module1, specifier: '../components/Avatar.astro' }`}},
+				code:        `<html><head></head><body>${$$renderComponent($$result,'$$Deferred0',$$Deferred0,{"server:defer":true,"user":(user),"size":(size)})}</body></html>`,
+				deferred: []string{`export const $$Deferred0 = $$createComponent(async ($$result, $$props, $$slots) => {
+const Astro = $$result.createAstro($$Astro, $$props, $$slots);
+const { user, size } = $$props;
+return $$render` + "`" + `<aside>${$$renderComponent($$result,'Avatar',Avatar,{"user":(user),"size":(size)})}</aside>` + "`" + `;
+});`},
 			},
 		},
 		{
-			name: "svg expressions",
+			name:   "transition directives",
+			source: `<header transition:persist="nav"></header><h1 transition:name="title" transition:animate={slide}>Hi</h1><Video transition:persist />`,
+			want: want{
+				helpers: []string{RENDER_COMPONENT, ADD_ATTRIBUTE, RENDER_TRANSITION, CREATE_TRANSITION_SCOPE},
+				metadata: metadata{transitions: []string{
+					`{ name: null, animate: null, persist: true }`,
+					`{ name: 'title', animate: null, persist: false }`,
+					`{ name: null, animate: null, persist: true }`,
+				}},
+				code: `<html><head></head><body><header data-astro-transition-persist="nav"></header><h1${$$addAttribute($$renderTransition($$result, "Q45PXZTM-1", (slide), "title"), "data-astro-transition-scope")}>Hi</h1>${$$renderComponent($$result,'Video',Video,{"data-astro-transition-persist":($$createTransitionScope($$result, "Q45PXZTM-2"))})}</body></html>`,
+			},
+		},
+		{
+			name: "translate text",
 			source: `---
-const title = 'icon';
+import { t } from '../i18n';
 ---
-<svg>{title ?? null}</svg>`,
+<h1>  Hello
+  world </h1><p translate="no">Astro</p>{cond && <span>Yes</span>}`,
+			transformOptions: transform.TransformOptions{
+				Translate: "t",
+			},
 			want: want{
-				frontmatter: []string{"", "const title = 'icon';"},
-				code:        `<html><head></head><body><svg>${title ?? null}</svg></body></html>`,
+				frontmatter: []string{`import { t } from '../i18n';`},
+				metadata:    metadata{modules: []string{`{ module: $$module1, specifier: '../i18n' }`}},
+				code:        `<html><head></head><body><h1>  ${t("Hello world")} </h1><p translate="no">Astro</p>${cond && $$render` + "`" + `<span>${t("Yes")}</span>` + "`" + `}</body></html>`,
 			},
 		},
 		{
-			name: "advanced svg expression",
+			name: "define",
 			source: `---
-const title = 'icon';
+const mode = import.meta.env.MODE;
 ---
-<svg>{title ? <title>{title}</title> : null}</svg>`,
+<div data-mode={import.meta.env.MODE}>{import.meta.env.SSR && <p>Server</p>}</div>`,
+			transformOptions: transform.TransformOptions{
+				Define: map[string]string{"import.meta.env.MODE": `"production"`, "import.meta.env.SSR": "true"},
+			},
 			want: want{
-				frontmatter: []string{"", "const title = 'icon';"},
-				code:        `<html><head></head><body><svg>${title ? $$render` + BACKTICK + `<title>${title}</title>` + BACKTICK + ` : null}</svg></body></html>`,
+				helpers:     []string{ADD_ATTRIBUTE},
+				frontmatter: []string{"", `const mode = "production";`},
+				code:        `<html><head></head><body><div${$$addAttribute("production", "data-mode")}><p>Server</p></div></body></html>`,
 			},
 		},
 		{
-			name:   "Empty script",
-			source: `<script hoist></script>`,
+			name: "dead branch elimination",
+			source: `---
+import Debug from '../components/Debug.astro';
+import Desktop from '../components/Desktop.astro';
+import Mobile from '../components/Mobile.astro';
+---
+<main>{import.meta.env.DEV && <Debug />}{import.meta.env.MOBILE ? <Mobile /> : <Desktop />}{0 && <p>Zero</p>}</main>`,
+			transformOptions: transform.TransformOptions{
+				Define: map[string]string{"import.meta.env.DEV": "false", "import.meta.env.MOBILE": `"yes"`},
+			},
 			want: want{
-				scripts: []string{`{props:{"hoist":true}}`},
-				code:    `<html><head></head><body></body></html>`,
+				helpers:     []string{RENDER_COMPONENT},
+				frontmatter: []string{`import Mobile from '../components/Mobile.astro';`},
+				metadata:    metadata{modules: []string{`{ module: $$module1, specifier: '../components/Mobile.astro' }`}},
+				code:        `<html><head></head><body><main>${$$renderComponent($$result,'Mobile',Mobile,{})}${0 && $$render` + "`" + `<p>Zero</p>` + "`" + `}</main></body></html>`,
 			},
 		},
 		{
-			name:   "Empty style",
-			source: `<style define:vars={{ color: "Gainsboro" }}></style>`,
+			name:   "jsx comments",
+			source: "<div>{/* a comment */}<p>x</p>{ /* multi\nline */ }{a /* inline */}{// line\n}</div>",
 			want: want{
-				styles: []string{`{props:{"define:vars":({ color: "Gainsboro" }),"data-astro-id":"7HAAVZPE"}}`},
-				code:   `<html class="astro-7HAAVZPE"><head></head><body></body></html>`,
+				lean: true,
+				code: `<html><head></head><body><div><p>x</p>${a /* inline */}</div></body></html>`,
 			},
 		},
 		{
-			name: "No extra script tag",
-			source: `<!-- Global Metadata -->
-<meta charset="utf-8">
-<meta name="viewport" content="width=device-width">
-
-<link rel="icon" type="image/svg+xml" href="/favicon.svg" />
-<link rel="alternate icon" type="image/x-icon" href="/favicon.ico" />
-
-<link rel="sitemap" href="/sitemap.xml"/>
-
-<!-- Global CSS -->
-<link rel="stylesheet" href="/theme.css" />
-<link rel="stylesheet" href="/code.css" />
-<link rel="stylesheet" href="/index.css" />
-
-<!-- Preload Fonts -->
-<link rel="preconnect" href="https://fonts.googleapis.com">
-<link rel="preconnect" href="https://fonts.gstatic.com" crossorigin>
-<link href="https://fonts.googleapis.com/css2?family=IBM+Plex+Mono:ital@0;1&display=swap" rel="stylesheet">
-
-<!-- Scrollable a11y code helper -->
-<script type="module" src="/make-scrollable-code-focusable.js" />
-
-<!-- This is intentionally inlined to avoid FOUC -->
-<script>
-  const root = document.documentElement;
-  const theme = localStorage.getItem('theme');
-  if (theme === 'dark' || (!theme) && window.matchMedia('(prefers-color-scheme: dark)').matches) {
-    root.classList.add('theme-dark');
-  } else {
-    root.classList.remove('theme-dark');
-  }
-</script>
-
-<!-- Global site tag (gtag.js) - Google Analytics -->
-<!-- <script async src="https://www.googletagmanager.com/gtag/js?id=G-TEL60V1WM9"></script>
-<script>
-  window.dataLayer = window.dataLayer || [];
-  function gtag(){dataLayer.push(arguments);}
-  gtag('js', new Date());
-  gtag('config', 'G-TEL60V1WM9');
-</script> -->`,
+			name:   "jsx comments preserved",
+			source: `<div>{/* a comment */}<p>x</p></div>`,
+			transformOptions: transform.TransformOptions{
+				PreserveJSXComments: true,
+			},
 			want: want{
-				code: `<!-- Global Metadata --><html><head><meta charset="utf-8">
-<meta name="viewport" content="width=device-width">
-
-<link rel="icon" type="image/svg+xml" href="/favicon.svg">
-<link rel="alternate icon" type="image/x-icon" href="/favicon.ico">
-
+				lean: true,
+				code: `<html><head></head><body><div><!-- a comment --><p>x</p></div></body></html>`,
+			},
+		},
+		{
+			name:   "jsx comments preserved with dashes",
+			source: `<div>{/* a --> b */}{/*-*/}</div>`,
+			transformOptions: transform.TransformOptions{
+				PreserveJSXComments: true,
+			},
+			want: want{
+				lean: true,
+				code: `<html><head></head><body><div><!-- a - -> b --><!--- --></div></body></html>`,
+			},
+		},
+		{
+			name:   "is:ignore",
+			source: `<div is:ignore><div @click="open = !open" :class="{ a: b }">{{ msg }}</div><Counter client:load /></div><p>{a}</p>`,
+			want: want{
+				lean: true,
+				code: `<html><head></head><body><div><div @click="open = !open" :class="{ a: b }">{{ msg }}</div><Counter client:load /></div><p>${a}</p></body></html>`,
+			},
+		},
+		{
+			name:   "alpine and htmx attributes",
+			source: `<div x-data="{ open: false }" @click="open = !open" :class="{ a: open }" x-on:keydown.escape.window="open = false" hx-post="/save" hx-on::after-request="done()"></div>`,
+			want: want{
+				lean: true,
+				code: `<html><head></head><body><div x-data="{ open: false }" @click="open = !open" :class="{ a: open }" x-on:keydown.escape.window="open = false" hx-post="/save" hx-on::after-request="done()"></div></body></html>`,
+			},
+		},
+		{
+			name:   "alpine attributes on a component",
+			source: `<Dropdown @click.outside="open = false" :open={open} />`,
+			want: want{
+				helpers: []string{RENDER_COMPONENT},
+				code:    `${$$renderComponent($$result,'Dropdown',Dropdown,{"@click.outside":"open = false",":open":(open)})}`,
+			},
+		},
+		{
+			name:   "attribute names are escaped",
+			source: "<div x-bind:[`key`]=\"a\" a\\b={b}></div>",
+			want: want{
+				helpers: []string{ADD_ATTRIBUTE},
+				lean:    true,
+				code:    `<html><head></head><body><div x-bind:[\` + "`" + `key\` + "`" + `]="a"${$$addAttribute(b, "a\\b")}></div></body></html>`,
+			},
+		},
+		{
+			name:   "processing instructions and CDATA",
+			source: `<html><body><?php echo "a > b"; ?><div><![CDATA[<p>a</p>]]></div></body></html>`,
+			want: want{
+				lean: true,
+				code: `<html><head></head><body><!--?php echo "a --> b"; ?><div><!--[CDATA[<p-->a<p></p>]]></div></body></html>`,
+			},
+		},
+		{
+			name:   "processing instructions and CDATA in xml",
+			source: `<?xml-stylesheet href="feed.xsl"?><description><![CDATA[<p>{a}</p>]]></description>`,
+			transformOptions: transform.TransformOptions{
+				ContentType: "xml",
+			},
+			want: want{
+				lean: true,
+				code: `<?xml-stylesheet href="feed.xsl"?><description><![CDATA[<p>{a}</p>]]></description>`,
+			},
+		},
+		{
+			name: "xml",
+			source: `---
+const posts = [];
+---
+<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"><channel><title>{title}</title><link>https://example.com</link>{posts.map(post => <item><title>{post.title}</title><link>{post.url}</link></item>)}</channel></rss>`,
+			transformOptions: transform.TransformOptions{
+				ContentType: "xml",
+			},
+			want: want{
+				frontmatter: []string{"", "const posts = [];"},
+				code:        `<?xml version="1.0" encoding="UTF-8"?><rss version="2.0"><channel><title>${title}</title><link>https://example.com</link>${posts.map(post => $$render` + "`" + `<item><title>${post.title}</title><link>${post.url}</link></item>` + "`" + `)}</channel></rss>`,
+			},
+		},
+		{
+			name:   "attribute case",
+			source: `<div onClick="go()" DATA-Id="a" {...spread} {someValue}></div><Button onClick={go} /><svg viewBox="0 0 1 1"><linearGradient gradientUnits="userSpaceOnUse"/></svg>`,
+			want: want{
+				helpers: []string{RENDER_COMPONENT, ADD_ATTRIBUTE, SPREAD_ATTRIBUTES},
+				code:    `<html><head></head><body><div onclick="go()" data-id="a"${$$spreadAttributes(spread, "spread")}${$$addAttribute(someValue, "someValue")}></div>${$$renderComponent($$result,'Button',Button,{"onClick":(go)})}<svg viewBox="0 0 1 1"><linearGradient gradientUnits="userSpaceOnUse"></linearGradient></svg></body></html>`,
+			},
+		},
+		{
+			name:   "attribute case preserved",
+			source: `<div onClick="go()"></div>`,
+			transformOptions: transform.TransformOptions{
+				PreserveAttributeCase: true,
+			},
+			want: want{
+				lean: true,
+				code: `<html><head></head><body><div onClick="go()"></div></body></html>`,
+			},
+		},
+		{
+			name:   "key directive",
+			source: `<ul>{items.map(item => <li key={item.id}>{item.name}</li>)}</ul>{items.map(key => <Card {key} />)}<div key="a"></div>`,
+			want: want{
+				helpers: []string{RENDER_COMPONENT, ADD_ATTRIBUTE},
+				code:    `<html><head></head><body><ul>${items.map(item => $$render` + "`" + `<li${$$addAttribute(item.id, "key")}>${item.name}</li>` + "`" + `)}</ul>${items.map(key => $$render` + "`" + `${$$renderComponent($$result,'Card',Card,{"key":(key),"astro:key":(key)})}` + "`" + `)}<div key="a"></div></body></html>`,
+			},
+		},
+		{
+			name:   "key directive with expression",
+			source: `{items.map(item => <Card key={item.id} title={item.title} />)}`,
+			want: want{
+				helpers: []string{RENDER_COMPONENT},
+				code:    `<html><head>${items.map(item => $$render` + "`" + `${$$renderComponent($$result,'Card',Card,{"key":(item.id),"title":(item.title),"astro:key":(item.id)})}` + "`" + `)}</head><body></body></html>`,
+			},
+		},
+		{
+			name:   "render telemetry",
+			source: `<main><Card title="a"><Fragment><Icon /></Fragment></Card><my-element /></main>`,
+			transformOptions: transform.TransformOptions{
+				RenderTelemetry: true,
+			},
+			want: want{
+				helpers: []string{FRAGMENT, RENDER_COMPONENT, MARK_RENDER_START, MARK_RENDER_END},
+				code:    `<html><head></head><body><main>${$$result.renderTelemetry && $$markRenderStart($$result,'Card')}${$$renderComponent($$result,'Card',Card,{"title":"a"},{"default": () => $$render` + "`" + `${$$renderComponent($$result,'Fragment',Fragment,{},{"default": () => $$render` + "`" + `${$$result.renderTelemetry && $$markRenderStart($$result,'Icon')}${$$renderComponent($$result,'Icon',Icon,{})}${$$result.renderTelemetry && $$markRenderEnd($$result,'Icon')}` + "`" + `,})}` + "`" + `,})}${$$result.renderTelemetry && $$markRenderEnd($$result,'Card')}${$$result.renderTelemetry && $$markRenderStart($$result,'my-element')}${$$renderComponent($$result,'my-element','my-element',{})}${$$result.renderTelemetry && $$markRenderEnd($$result,'my-element')}</main></body></html>`,
+			},
+		},
+		{
+			name:   "island markers",
+			source: `<main><Counter client:load /><Card /><Counter client:visible count={1} /></main>`,
+			transformOptions: transform.TransformOptions{
+				IslandMarkers: true,
+			},
+			want: want{
+				helpers: []string{RENDER_COMPONENT, OPEN_ISLAND, CLOSE_ISLAND},
+				metadata: metadata{
+					hydratedComponents: []string{"Counter", "Counter"},
+					islands:            []string{`{ name: 'Counter', directive: 'load' }`, `{ name: 'Counter', directive: 'visible' }`},
+				},
+				code: `<html><head></head><body><main><!--astro:island-start:${$$openIsland($$result,'astro-XXXX')}-->${$$renderComponent($$result,'Counter',Counter,{"client:load":true,"client:component-path":($$metadata.getPath(Counter)),"client:component-export":($$metadata.getExport(Counter))})}<!--astro:island-end:${$$closeIsland($$result)}-->${$$renderComponent($$result,'Card',Card,{})}<!--astro:island-start:${$$openIsland($$result,'astro-XXXX')}-->${$$renderComponent($$result,'Counter',Counter,{"client:visible":true,"count":(1),"client:component-path":($$metadata.getPath(Counter)),"client:component-export":($$metadata.getExport(Counter))})}<!--astro:island-end:${$$closeIsland($$result)}--></main></body></html>`,
+			},
+		},
+		{
+			name:   "island markers in a loop",
+			source: `<ul>{items.map((item) => <li><Counter client:load count={item} /></li>)}</ul>`,
+			transformOptions: transform.TransformOptions{
+				IslandMarkers: true,
+			},
+			want: want{
+				helpers: []string{RENDER_COMPONENT, OPEN_ISLAND, CLOSE_ISLAND},
+				metadata: metadata{
+					hydratedComponents: []string{"Counter"},
+					islands:            []string{`{ name: 'Counter', directive: 'load' }`},
+				},
+				code: `<html><head></head><body><ul>${items.map((item) => $$render` + "`" + `<li><!--astro:island-start:${$$openIsland($$result,'astro-XXXX')}-->${$$renderComponent($$result,'Counter',Counter,{"client:load":true,"count":(item),"client:component-path":($$metadata.getPath(Counter)),"client:component-export":($$metadata.getExport(Counter))})}<!--astro:island-end:${$$closeIsland($$result)}--></li>` + "`" + `)}</ul></body></html>`,
+			},
+		},
+		{
+			name:   "compiler version",
+			source: `<div></div>`,
+			transformOptions: transform.TransformOptions{
+				StampVersion: true,
+			},
+			want: want{
+				code: `<html><head></head><body><div></div></body></html>`,
+			},
+		},
+		{
+			name:   "lean output",
+			source: `<div><slot /></div>`,
+			want: want{
+				helpers: []string{RENDER_SLOT},
+				lean:    true,
+				code:    `<html><head></head><body><div>${$$renderSlot($$result,$$slots["default"])}</div></body></html>`,
+			},
+		},
+		{
+			name:   "full output",
+			source: `<div><slot /></div>`,
+			transformOptions: transform.TransformOptions{
+				FullOutput: true,
+			},
+			want: want{
+				helpers: []string{RENDER_SLOT},
+				code:    `<html><head></head><body><div>${$$renderSlot($$result,$$slots["default"])}</div></body></html>`,
+			},
+		},
+		{
+			name:   "mentions Astro",
+			source: `<div title={Astro.props.title}></div>`,
+			want: want{
+				helpers: []string{ADD_ATTRIBUTE},
+				code:    `<html><head></head><body><div${$$addAttribute(Astro.props.title, "title")}></div></body></html>`,
+			},
+		},
+		{
+			name:   "route metadata",
+			source: "---\nexport async function getStaticPaths() {\n  return [];\n}\nexport const prerender = true;\nconst { slug } = Astro.params;\n---\n<h1>{slug} {Astro.params.lang}</h1>",
+			transformOptions: transform.TransformOptions{
+				IsPage: true,
+				Route:  "/[lang]/blog/[slug]",
+			},
+			want: want{
+				frontmatter: []string{"export async function getStaticPaths() {\n  return [];\n}\nexport const prerender = true;", "const { slug } = Astro.params;"},
+				code:        "<html><head></head><body><h1>${slug} ${Astro.params.lang}</h1></body></html>",
+				metadata:    metadata{route: "{ pattern: '/[lang]/blog/[slug]', params: ['lang', 'slug'], readParams: ['slug', 'lang'], getStaticPaths: true, prerender: true }"},
+			},
+		},
+		{
+			name:   "route metadata without a pattern",
+			source: "<h1>{Astro.params.slug}</h1>",
+			transformOptions: transform.TransformOptions{
+				IsPage: true,
+			},
+			want: want{
+				code:     "<html><head></head><body><h1>${Astro.params.slug}</h1></body></html>",
+				metadata: metadata{route: "{ pattern: undefined, params: [], readParams: ['slug'], getStaticPaths: false, prerender: undefined }"},
+			},
+		},
+		{
+			name:   "trim whitespace",
+			source: "<div class=`  a  ` data-x={  x  }></div>\n<script hoist>\n  console.log(1);\n</script>",
+			want: want{
+				helpers:  []string{ADD_ATTRIBUTE},
+				code:     "<html><head></head><body><div${$$addAttribute(`  a  `, \"class\")}${$$addAttribute(x, \"data-x\")}></div>\n</body></html>",
+				metadata: metadata{hoisted: []string{`{ type: 'inline', value: "\n  console.log(1);\n" }`}},
+				scripts:  []string{"{props:{\"hoist\":true},children:`console.log(1);`}"},
+			},
+		},
+		{
+			name:   "trim whitespace none",
+			source: "<div data-x={  x  }></div>\n<script hoist>\n  console.log(1);\n</script>",
+			transformOptions: transform.TransformOptions{
+				TrimWhitespace: "none",
+			},
+			want: want{
+				helpers:  []string{ADD_ATTRIBUTE},
+				code:     "<html><head></head><body><div${$$addAttribute(  x  , \"data-x\")}></div>\n</body></html>",
+				metadata: metadata{hoisted: []string{`{ type: 'inline', value: "\n  console.log(1);\n" }`}},
+				scripts:  []string{"{props:{\"hoist\":true},children:`\n  console.log(1);\n`}"},
+			},
+		},
+		{
+			name:   "trim whitespace aggressive",
+			source: "<ul>\n\n  <li class=`  a  `>1</li>\n  <li>2</li>  <li>3</li>\n</ul>\n<pre>\n\n  <b>1</b>\n</pre>",
+			transformOptions: transform.TransformOptions{
+				TrimWhitespace: "aggressive",
+			},
+			want: want{
+				helpers: []string{ADD_ATTRIBUTE},
+				lean:    true,
+				code:    "<html><head></head><body><ul>\n<li${$$addAttribute(`a`, \"class\")}>1</li>\n<li>2</li> <li>3</li>\n</ul>\n<pre>\n\n  <b>1</b>\n</pre></body></html>",
+			},
+		},
+		{
+			name:   "hoisted script with escapes",
+			source: "<script hoist>console.log(\"a\\nb\", `${c}`)</script>",
+			want: want{
+				code:     "<html><head></head><body></body></html>",
+				metadata: metadata{hoisted: []string{`{ type: 'inline', value: "console.log(\"a\\nb\", ` + "`${c}`" + `)" }`}},
+				scripts:  []string{"{props:{\"hoist\":true},children:`console.log(\"a\\\\nb\", \\`\\${c}\\`)`}"},
+			},
+		},
+		{
+			name:   "hoisted script with closing tag",
+			source: `<script hoist><!--<script>x="</script>"--></script>`,
+			want: want{
+				code:     "<html><head></head><body></body></html>",
+				metadata: metadata{hoisted: []string{`{ type: 'inline', value: "<!--<script>x=\"<\/script>\"-->" }`}},
+				scripts:  []string{"{props:{\"hoist\":true},children:`<!--<script>x=\"<\\\\/script>\"-->`}"},
+			},
+		},
+		{
+			name:   "Component siblings are siblings",
+			source: `<BaseHead></BaseHead><link href="test">`,
+			want: want{
+				helpers: []string{RENDER_COMPONENT},
+				code:    `${$$renderComponent($$result,'BaseHead',BaseHead,{})}<link href="test">`,
+			},
+		},
+		{
+			name:   "Unclosed component is closed by its parent",
+			source: `<Layout><Card><p>a</p></Layout><footer />`,
+			want: want{
+				helpers: []string{RENDER_COMPONENT},
+				code:    `${$$renderComponent($$result,'Layout',Layout,{},{"default": () => $$render` + "`" + `${$$renderComponent($$result,'Card',Card,{},{"default": () => $$render` + "`" + `<p>a</p>` + "`" + `,})}` + "`" + `,})}<footer></footer>`,
+			},
+		},
+		{
+			name:   "Self-closing components siblings are siblings",
+			source: `<BaseHead /><link href="test">`,
+			want: want{
+				helpers: []string{RENDER_COMPONENT},
+				code:    `${$$renderComponent($$result,'BaseHead',BaseHead,{})}<link href="test">`,
+			},
+		},
+		{
+			name:   "Self-closing script in head works",
+			source: `<html><head><script /></head><html>`,
+			want: want{
+				lean: true,
+				code: `<html><head><script></script></head><body></body></html>`,
+			},
+		},
+		{
+			name:   "Self-closing components in head can have siblings",
+			source: `<html><head><BaseHead /><link href="test"></head><html>`,
+			want: want{
+				helpers: []string{RENDER_COMPONENT},
+				code:    `<html><head>${$$renderComponent($$result,'BaseHead',BaseHead,{})}<link href="test"></head><body></body></html>`,
+			},
+		},
+		{
+			name: "Nested HTML in expressions, wrapped in parens",
+			source: `---
+const image = './penguin.png';
+const canonicalURL = new URL('http://example.com');
+---
+{image && (<meta property="og:image" content={new URL(image, canonicalURL)}>)}`,
+			want: want{
+				helpers: []string{ADD_ATTRIBUTE},
+				frontmatter: []string{"", `const image = './penguin.png';
+const canonicalURL = new URL('http://example.com');`},
+				styles: []string{},
+				code:   "<html><head>${image && ($$render`<meta property=\"og:image\"${$$addAttribute(new URL(image, canonicalURL), \"content\")}>`)}</head><body></body></html>",
+			},
+		},
+		{
+			name: "Use of interfaces within frontmatter",
+			source: `---
+interface MarkdownFrontmatter {
+	date: number;
+	image: string;
+	author: string;
+}
+let allPosts = Astro.fetchContent<MarkdownFrontmatter>('./post/*.md');
+---
+<div>testing</div>`,
+			want: want{
+				frontmatter: []string{"", `interface MarkdownFrontmatter {
+	date: number;
+	image: string;
+	author: string;
+}
+let allPosts = Astro.fetchContent<MarkdownFrontmatter>('./post/*.md');`},
+				styles: []string{},
+				code:   "<html><head></head><body><div>testing</div></body></html>",
+			},
+		},
+		{
+			name: "Component names A-Z",
+			source: `---
+import AComponent from '../components/AComponent.jsx';
+import ZComponent from '../components/ZComponent.jsx';
+---
+
+<body>
+  <AComponent />
+  <ZComponent />
+</body>`,
+			want: want{
+				helpers: []string{RENDER_COMPONENT},
+				frontmatter: []string{
+					`import AComponent from '../components/AComponent.jsx';
+import ZComponent from '../components/ZComponent.jsx';`},
+				metadata: metadata{
+					modules: []string{
+						`{ module: $$module1, specifier: '../components/AComponent.jsx' }`,
+						`{ module: $$module2, specifier: '../components/ZComponent.jsx' }`,
+					},
+				},
+				code: `<html><head></head><body>
+  ${` + RENDER_COMPONENT + `($$result,'AComponent',AComponent,{})}
+  ${` + RENDER_COMPONENT + `($$result,'ZComponent',ZComponent,{})}
+</body></html>`,
+			},
+		},
+		{
+			name: "Parser can handle files > 4096 chars",
+			source: `<html><body>` + longRandomString + `<img
+  width="1600"
+  height="1131"
+  class="img"
+  src="https://images.unsplash.com/photo-1469854523086-cc02fe5d8800?w=1200&q=75"
+  srcSet="https://images.unsplash.com/photo-1469854523086-cc02fe5d8800?w=1200&q=75 800w,https://images.unsplash.com/photo-1469854523086-cc02fe5d8800?w=1200&q=75 1200w,https://images.unsplash.com/photo-1469854523086-cc02fe5d8800?w=1600&q=75 1600w,https://images.unsplash.com/photo-1469854523086-cc02fe5d8800?w=2400&q=75 2400w"
+  sizes="(max-width: 800px) 800px, (max-width: 1200px) 1200px, (max-width: 1600px) 1600px, (max-width: 2400px) 2400px, 1200px"
+></body></html>`,
+			want: want{
+				lean: true,
+				code: `<html><head></head><body>` + longRandomString + `<img width="1600" height="1131" class="img" src="https://images.unsplash.com/photo-1469854523086-cc02fe5d8800?w=1200&q=75" srcset="https://images.unsplash.com/photo-1469854523086-cc02fe5d8800?w=1200&q=75 800w,https://images.unsplash.com/photo-1469854523086-cc02fe5d8800?w=1200&q=75 1200w,https://images.unsplash.com/photo-1469854523086-cc02fe5d8800?w=1600&q=75 1600w,https://images.unsplash.com/photo-1469854523086-cc02fe5d8800?w=2400&q=75 2400w" sizes="(max-width: 800px) 800px, (max-width: 1200px) 1200px, (max-width: 1600px) 1600px, (max-width: 2400px) 2400px, 1200px"></body></html>`,
+			},
+		},
+		{
+			name:   "SVG styles",
+			source: `<svg><style>path { fill: red; }</style></svg>`,
+			want: want{
+				lean: true,
+				code: `<html><head></head><body><svg><style>path { fill: red; }</style></svg></body></html>`,
+			},
+		},
+		{
+			name: "svg expressions",
+			source: `---
+const title = 'icon';
+---
+<svg>{title ?? null}</svg>`,
+			want: want{
+				frontmatter: []string{"", "const title = 'icon';"},
+				code:        `<html><head></head><body><svg>${title ?? null}</svg></body></html>`,
+			},
+		},
+		{
+			name: "advanced svg expression",
+			source: `---
+const title = 'icon';
+---
+<svg>{title ? <title>{title}</title> : null}</svg>`,
+			want: want{
+				frontmatter: []string{"", "const title = 'icon';"},
+				code:        `<html><head></head><body><svg>${title ? $$render` + BACKTICK + `<title>${title}</title>` + BACKTICK + ` : null}</svg></body></html>`,
+			},
+		},
+		{
+			name:   "Empty script",
+			source: `<script hoist></script>`,
+			want: want{
+				scripts: []string{`{props:{"hoist":true}}`},
+				code:    `<html><head></head><body></body></html>`,
+			},
+		},
+		{
+			name:   "style define:vars",
+			source: `<style define:vars={{ color: "red" }}>div{color:var(--color)}</style><div>Hello</div>`,
+			want: want{
+				helpers:     []string{ADD_ATTRIBUTE, DEFINE_STYLE_VARS},
+				lean:        true,
+				styles:      []string{"{props:{\"data-astro-id\":\"F2GWRZFX\"},children:`div.astro-F2GWRZFX{color:var(--color);}`}"},
+				definedVars: `{ color: "red" }`,
+				code:        `<html class="astro-F2GWRZFX"><head></head><body><div class="astro-F2GWRZFX"${$$addAttribute($$definedVars, "style")}>Hello</div></body></html>`,
+			},
+		},
+		{
+			name:   "style define:vars merges static style",
+			source: `<style define:vars={{ color: "red" }}>div{color:var(--color)}</style><div style="margin: 0;">Hello</div><p style>World</p>`,
+			want: want{
+				helpers:     []string{ADD_ATTRIBUTE, DEFINE_STYLE_VARS},
+				lean:        true,
+				styles:      []string{"{props:{\"data-astro-id\":\"3PW2IDOW\"},children:`div.astro-3PW2IDOW{color:var(--color);}`}"},
+				definedVars: `{ color: "red" }`,
+				code:        "<html class=\"astro-3PW2IDOW\"><head></head><body><div${$$addAttribute(`margin: 0; ${$$definedVars}`, \"style\")} class=\"astro-3PW2IDOW\">Hello</div><p${$$addAttribute($$definedVars, \"style\")} class=\"astro-3PW2IDOW\">World</p></body></html>",
+			},
+		},
+		{
+			name:   "style define:vars merges dynamic style",
+			source: "---\nconst style = { margin: 0 };\n---\n<style define:vars={{ color: \"red\" }}>div{color:var(--color)}</style><div style={style}><span>a</span></div><section {style} />",
+			want: want{
+				helpers:     []string{ADD_ATTRIBUTE, DEFINE_STYLE_VARS},
+				frontmatter: []string{"", "const style = { margin: 0 };"},
+				styles:      []string{"{props:{\"data-astro-id\":\"6ZIJHSXT\"},children:`div.astro-6ZIJHSXT{color:var(--color);}`}"},
+				definedVars: `{ color: "red" }`,
+				code:        `<html class="astro-6ZIJHSXT"><head></head><body><div${$$addAttribute([style, $$definedVars], "style")} class="astro-6ZIJHSXT"><span class="astro-6ZIJHSXT">a</span></div><section${$$addAttribute([style, $$definedVars], "style")} class="astro-6ZIJHSXT"></section></body></html>`,
+			},
+		},
+		{
+			name:   "style define:vars merges spread style",
+			source: "---\nconst props = { style: \"margin: 0\" };\n---\n<style define:vars={{ color: \"red\" }}>div{color:var(--color)}</style><div {...props}>Hello</div>",
+			want: want{
+				helpers:     []string{SPREAD_ATTRIBUTES, MERGE_ATTR, DEFINE_STYLE_VARS},
+				frontmatter: []string{"", "const props = { style: \"margin: 0\" };"},
+				styles:      []string{"{props:{\"data-astro-id\":\"Y5XLTFV2\"},children:`div.astro-Y5XLTFV2{color:var(--color);}`}"},
+				definedVars: `{ color: "red" }`,
+				code:        `<html class="astro-Y5XLTFV2"><head></head><body><div${$$spreadAttributes($$mergeAttr(props, "style", $$definedVars), "$$mergeAttr(props, \"style\", $$definedVars)")} class="astro-Y5XLTFV2">Hello</div></body></html>`,
+			},
+		},
+		{
+			name:   "critical style",
+			source: `<style is:critical>h1{color:red}</style><style>p{color:blue}</style><h1>Hello</h1>`,
+			want: want{
+				lean: true,
+				styles: []string{
+					"{props:{\"data-astro-id\":\"DK4O6673\"},children:`h1.astro-DK4O6673{color:red;}`,critical:true}",
+					"{props:{\"data-astro-id\":\"DK4O6673\"},children:`p.astro-DK4O6673{color:blue;}`}",
+				},
+				code: `<html class="astro-DK4O6673"><head></head><body><h1 class="astro-DK4O6673">Hello</h1></body></html>`,
+			},
+		},
+		{
+			name:   "svg style define:vars",
+			source: `<svg><style define:vars={{ fill: "red" }}>rect{fill:var(--fill)}</style></svg>`,
+			want: want{
+				helpers: []string{DEFINE_STYLE_VARS},
+				lean:    true,
+				code:    fmt.Sprintf(`<html><head></head><body><svg><style>:root{${%s({ fill: "red" })}}rect{fill:var(--fill)}</style></svg></body></html>`, DEFINE_STYLE_VARS),
+			},
+		},
+		{
+			name:   "Empty style",
+			source: `<style define:vars={{ color: "Gainsboro" }}></style>`,
+			want: want{
+				lean:   true,
+				styles: []string{`{props:{"define:vars":({ color: "Gainsboro" }),"data-astro-id":"7HAAVZPE"}}`},
+				code:   `<html class="astro-7HAAVZPE"><head></head><body></body></html>`,
+			},
+		},
+		{
+			name: "No extra script tag",
+			source: `<!-- Global Metadata -->
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width">
+
+<link rel="icon" type="image/svg+xml" href="/favicon.svg" />
+<link rel="alternate icon" type="image/x-icon" href="/favicon.ico" />
+
+<link rel="sitemap" href="/sitemap.xml"/>
+
+<!-- Global CSS -->
+<link rel="stylesheet" href="/theme.css" />
+<link rel="stylesheet" href="/code.css" />
+<link rel="stylesheet" href="/index.css" />
+
+<!-- Preload Fonts -->
+<link rel="preconnect" href="https://fonts.googleapis.com">
+<link rel="preconnect" href="https://fonts.gstatic.com" crossorigin>
+<link href="https://fonts.googleapis.com/css2?family=IBM+Plex+Mono:ital@0;1&display=swap" rel="stylesheet">
+
+<!-- Scrollable a11y code helper -->
+<script type="module" src="/make-scrollable-code-focusable.js" />
+
+<!-- This is intentionally inlined to avoid FOUC -->
+<script>
+  const root = document.documentElement;
+  const theme = localStorage.getItem('theme');
+  if (theme === 'dark' || (!theme) && window.matchMedia('(prefers-color-scheme: dark)').matches) {
+    root.classList.add('theme-dark');
+  } else {
+    root.classList.remove('theme-dark');
+  }
+</script>
+
+<!-- Global site tag (gtag.js) - Google Analytics -->
+<!-- <script async src="https://www.googletagmanager.com/gtag/js?id=G-TEL60V1WM9"></script>
+<script>
+  window.dataLayer = window.dataLayer || [];
+  function gtag(){dataLayer.push(arguments);}
+  gtag('js', new Date());
+  gtag('config', 'G-TEL60V1WM9');
+</script> -->`,
+			want: want{
+				lean: true,
+				code: `<!-- Global Metadata --><html><head><meta charset="utf-8">
+<meta name="viewport" content="width=device-width">
+
+<link rel="icon" type="image/svg+xml" href="/favicon.svg">
+<link rel="alternate icon" type="image/x-icon" href="/favicon.ico">
+
 <link rel="sitemap" href="/sitemap.xml">
 
 <!-- Global CSS -->
@@ -989,6 +1838,7 @@ import { Container, Col, Row } from 'react-bootstrap';
 </Container>
 `,
 			want: want{
+				helpers:     []string{RENDER_COMPONENT},
 				frontmatter: []string{`import { Container, Col, Row } from 'react-bootstrap';`},
 				metadata:    metadata{modules: []string{`{ module: $$module1, specifier: 'react-bootstrap' }`}},
 				code:        "${$$renderComponent($$result,'Container',Container,{},{\"default\": () => $$render`${$$renderComponent($$result,'Row',Row,{},{\"default\": () => $$render`${$$renderComponent($$result,'Col',Col,{},{\"default\": () => $$render`<h1>Hi!</h1>`,})}`,})}`,})}",
@@ -1003,10 +1853,11 @@ import { Container, Col, Row } from 'react-bootstrap';
 </head>
 <div />`,
 			want: want{
+				lean: true,
 				styles: []string{
-					"{props:{\"data-astro-id\":\"EX5CHM4O\"},children:`div.astro-EX5CHM4O{color:blue;}`}",
-					"{props:{\"data-astro-id\":\"EX5CHM4O\"},children:`div.astro-EX5CHM4O{color:green;}`}",
 					"{props:{\"global\":true},children:`div { color: red }`}",
+					"{props:{\"data-astro-id\":\"EX5CHM4O\"},children:`div.astro-EX5CHM4O{color:green;}`}",
+					"{props:{\"data-astro-id\":\"EX5CHM4O\"},children:`div.astro-EX5CHM4O{color:blue;}`}",
 				},
 				code: "<html class=\"astro-EX5CHM4O\"><head>\n\n\n\n\n\n\n</head>\n<body><div class=\"astro-EX5CHM4O\"></div></body></html>",
 			},
@@ -1015,41 +1866,50 @@ import { Container, Col, Row } from 'react-bootstrap';
 			name:   "Fragment",
 			source: `<body><Fragment><div>Default</div><div>Named</div></Fragment></body>`,
 			want: want{
-				code: `<html><head></head><body>${$$renderComponent($$result,'Fragment',Fragment,{},{"default": () => $$render` + BACKTICK + `<div>Default</div><div>Named</div>` + BACKTICK + `,})}</body></html>`,
+				helpers: []string{FRAGMENT, RENDER_COMPONENT},
+				lean:    true,
+				code:    `<html><head></head><body>${$$renderComponent($$result,'Fragment',Fragment,{},{"default": () => $$render` + BACKTICK + `<div>Default</div><div>Named</div>` + BACKTICK + `,})}</body></html>`,
 			},
 		},
 		{
 			name:   "Fragment shorthand",
 			source: `<body><><div>Default</div><div>Named</div></></body>`,
 			want: want{
-				code: `<html><head></head><body>${$$renderComponent($$result,'Fragment',Fragment,{},{"default": () => $$render` + BACKTICK + `<div>Default</div><div>Named</div>` + BACKTICK + `,})}</body></html>`,
+				helpers: []string{FRAGMENT, RENDER_COMPONENT},
+				lean:    true,
+				code:    `<html><head></head><body>${$$renderComponent($$result,'Fragment',Fragment,{},{"default": () => $$render` + BACKTICK + `<div>Default</div><div>Named</div>` + BACKTICK + `,})}</body></html>`,
 			},
 		},
 		{
 			name:   "Fragment slotted",
 			source: `<body><Component><><div>Default</div><div>Named</div></></Component></body>`,
 			want: want{
-				code: `<html><head></head><body>${$$renderComponent($$result,'Component',Component,{},{"default": () => $$render` + BACKTICK + `${$$renderComponent($$result,'Fragment',Fragment,{},{"default": () => $$render` + BACKTICK + `<div>Default</div><div>Named</div>` + BACKTICK + `,})}` + BACKTICK + `,})}</body></html>`,
+				helpers: []string{FRAGMENT, RENDER_COMPONENT},
+				code:    `<html><head></head><body>${$$renderComponent($$result,'Component',Component,{},{"default": () => $$render` + BACKTICK + `${$$renderComponent($$result,'Fragment',Fragment,{},{"default": () => $$render` + BACKTICK + `<div>Default</div><div>Named</div>` + BACKTICK + `,})}` + BACKTICK + `,})}</body></html>`,
 			},
 		},
 		{
 			name:   "Fragment slotted with name",
 			source: `<body><Component><Fragment slot=named><div>Default</div><div>Named</div></Fragment></Component></body>`,
 			want: want{
-				code: `<html><head></head><body>${$$renderComponent($$result,'Component',Component,{},{"named": () => $$render` + BACKTICK + `${$$renderComponent($$result,'Fragment',Fragment,{"slot":"named"},{"default": () => $$render` + BACKTICK + `<div>Default</div><div>Named</div>` + BACKTICK + `,})}` + BACKTICK + `,})}</body></html>`,
+				helpers: []string{FRAGMENT, RENDER_COMPONENT},
+				code:    `<html><head></head><body>${$$renderComponent($$result,'Component',Component,{},{"named": () => $$render` + BACKTICK + `${$$renderComponent($$result,'Fragment',Fragment,{"slot":"named"},{"default": () => $$render` + BACKTICK + `<div>Default</div><div>Named</div>` + BACKTICK + `,})}` + BACKTICK + `,})}</body></html>`,
 			},
 		},
 		{
 			name:   "Preserve slots inside custom-element",
 			source: `<body><my-element><div slot=name>Name</div><div>Default</div></my-element></body>`,
 			want: want{
-				code: `<html><head></head><body>${$$renderComponent($$result,'my-element','my-element',{},{"default": () => $$render` + BACKTICK + `<div slot="name">Name</div><div>Default</div>` + BACKTICK + `,})}</body></html>`,
+				helpers: []string{RENDER_COMPONENT},
+				lean:    true,
+				code:    `<html><head></head><body>${$$renderComponent($$result,'my-element','my-element',{},{"default": () => $$render` + BACKTICK + `<div slot="name">Name</div><div>Default</div>` + BACKTICK + `,})}</body></html>`,
 			},
 		},
 		{
 			name:   "Preserve namespaces",
 			source: `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink"><rect xlink:href="#id"></svg>`,
 			want: want{
+				lean: true,
 				code: `<html><head></head><body><svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink"><rect xlink:href="#id"></rect></svg></body></html>`,
 			},
 		},
@@ -1099,6 +1959,7 @@ const { product } = Astro.props;
 </body>
 </html>`, BACKTICK, BACKTICK),
 			want: want{
+				helpers: []string{RENDER_COMPONENT},
 				code: `<!DOCTYPE html><html lang="en">
 <head>
   <meta charset="UTF-8">
@@ -1139,6 +2000,7 @@ import ProductPageContent from '../../components/ProductPageContent.jsx';`,
 }`, BACKTICK, BACKTICK),
 				skipHoist: true,
 				metadata: metadata{
+					islands: []string{`{ name: 'ProductPageContent', directive: 'visible' }`},
 					modules: []string{`{ module: $$module1, specifier: '../../components/Header.jsx' }`,
 						`{ module: $$module2, specifier: '../../components/Footer.astro' }`,
 						`{ module: $$module3, specifier: '../../components/ProductPageContent.jsx' }`,
@@ -1206,27 +2068,171 @@ const items = ["Dog", "Cat", "Platipus"];
 			name:   "escaped entity",
 			source: `<img alt="A person saying &#x22;hello&#x22;">`,
 			want: want{
+				lean: true,
+				code: `<html><head></head><body><img alt="A person saying &#x22;hello&#x22;"></body></html>`,
+			},
+		},
+		{
+			name:   "escaped entity (normalize)",
+			source: `<img alt="A person saying &#x22;hello&#x22;">`,
+			transformOptions: transform.TransformOptions{
+				Entities: "normalize",
+			},
+			want: want{
+				lean: true,
 				code: `<html><head></head><body><img alt="A person saying &quot;hello&quot;"></body></html>`,
 			},
 		},
+		{
+			name:   "named entities",
+			source: `<p title="&copy; 2021 &amp; beyond">Hello&nbsp;world &copy; &#169; &lt;3</p>`,
+			want: want{
+				lean: true,
+				code: `<html><head></head><body><p title="&copy; 2021 &amp; beyond">Hello&nbsp;world &copy; &#169; &lt;3</p></body></html>`,
+			},
+		},
+		{
+			name:   "named entities (normalize)",
+			source: `<p title="&copy; 2021 &amp; beyond">Hello&nbsp;world &copy; &#169; &lt;3</p>`,
+			transformOptions: transform.TransformOptions{
+				Entities: "normalize",
+			},
+			want: want{
+				lean: true,
+				code: "<html><head></head><body><p title=\"© 2021 &amp; beyond\">Hello\u00a0world © © &lt;3</p></body></html>",
+			},
+		},
+		{
+			name: "entities in component props",
+			source: `---
+import Component from 'test';
+---
+<Component title="&copy; 2021">&copy;</Component>`,
+			want: want{
+				helpers:     []string{RENDER_COMPONENT},
+				frontmatter: []string{`import Component from 'test';`},
+				metadata:    metadata{modules: []string{`{ module: $$module1, specifier: 'test' }`}},
+				code:        `${$$renderComponent($$result,'Component',Component,{"title":"© 2021"},{"default": () => $$render` + BACKTICK + `&copy;` + BACKTICK + `,})}`,
+			},
+		},
 		{
 			name:   "textarea in form",
 			source: `<html><Component><form><textarea></textarea></form></Component></html>`,
 			want: want{
-				code: `<html>${$$renderComponent($$result,'Component',Component,{},{"default": () => $$render` + BACKTICK + `<form><textarea></textarea></form>` + BACKTICK + `,})}</html>`,
+				helpers: []string{RENDER_COMPONENT},
+				code:    `<html>${$$renderComponent($$result,'Component',Component,{},{"default": () => $$render` + BACKTICK + `<form><textarea></textarea></form>` + BACKTICK + `,})}</html>`,
+			},
+		},
+		{
+			name:   "pre leading newline",
+			source: "<pre>\nfoo</pre>",
+			want: want{
+				lean: true,
+				code: "<html><head></head><body><pre>\nfoo</pre></body></html>",
+			},
+		},
+		{
+			name:   "pre double leading newline",
+			source: "<pre>\n\nfoo</pre>",
+			want: want{
+				lean: true,
+				code: "<html><head></head><body><pre>\n\nfoo</pre></body></html>",
+			},
+		},
+		{
+			name:   "pre whitespace",
+			source: "<div><pre>  a  \n   b{x}  c\n\t</pre></div>",
+			want: want{
+				lean: true,
+				code: "<html><head></head><body><div><pre>  a  \n   b${x}  c\n\t</pre></div></body></html>",
+			},
+		},
+		{
+			name:   "pre code",
+			source: "<pre><code>\n  const a = 0;\n\n  a++;\n</code></pre>",
+			want: want{
+				lean: true,
+				code: "<html><head></head><body><pre><code>\n  const a = 0;\n\n  a++;\n</code></pre></body></html>",
+			},
+		},
+		{
+			name:   "pre inside of component",
+			source: "<Component><pre>\n  x\n</pre></Component>",
+			want: want{
+				helpers: []string{RENDER_COMPONENT},
+				code:    `${$$renderComponent($$result,'Component',Component,{},{"default": () => $$render` + BACKTICK + "<pre>\n  x\n</pre>" + BACKTICK + `,})}`,
+			},
+		},
+		{
+			name:   "textarea leading newline",
+			source: "<textarea>\n  x\n</textarea>",
+			want: want{
+				lean: true,
+				code: "<html><head></head><body><textarea>\n  x\n</textarea></body></html>",
+			},
+		},
+		{
+			name:   "emoji text",
+			source: "<p>😀 👍🏽 👨‍👩‍👧</p>",
+			want: want{
+				lean: true,
+				code: "<html><head></head><body><p>😀 👍🏽 👨‍👩‍👧</p></body></html>",
+			},
+		},
+		{
+			name:   "astral plane characters next to expressions",
+			source: "<p>𝒜{a}🎉{b}</p>",
+			want: want{
+				lean: true,
+				code: "<html><head></head><body><p>𝒜${a}🎉${b}</p></body></html>",
+			},
+		},
+		{
+			name:   "emoji next to backticks",
+			source: "<p>🎉`${x}`🎉</p>",
+			want: want{
+				lean: true,
+				code: "<html><head></head><body><p>🎉\\`$${x}\\`🎉</p></body></html>",
+			},
+		},
+		{
+			name:   "emoji in attributes",
+			source: "<div title=\"🎉 `party` ${x}\" data-emoji=😀></div>",
+			want: want{
+				lean: true,
+				code: "<html><head></head><body><div title=\"🎉 \\`party\\` \\${x}\" data-emoji=\"😀\"></div></body></html>",
+			},
+		},
+		{
+			name:   "emoji in component props and slots",
+			source: "<Component title=\"🚀\" label={\"👋\"}><span slot=\"🦄\">🦄</span></Component>",
+			want: want{
+				helpers: []string{RENDER_COMPONENT},
+				code:    `${$$renderComponent($$result,'Component',Component,{"title":"🚀","label":("👋")},{"🦄": () => $$render` + BACKTICK + `<span>🦄</span>` + BACKTICK + `,})}`,
+			},
+		},
+		{
+			name:   "emoji in hoisted script",
+			source: "<script hoist>console.log(`🎉 ${a} 😀`);</script>",
+			want: want{
+				scripts:  []string{"{props:{\"hoist\":true},children:`console.log(\\`🎉 \\${a} 😀\\`);`}"},
+				metadata: metadata{hoisted: []string{`{ type: 'inline', value: "console.log(` + "`🎉 ${a} 😀`" + `);" }`}},
+				code:     "<html><head></head><body></body></html>",
 			},
 		},
 		{
 			name:   "slot inside of Base",
 			source: `<Base title="Home"><div>Hello</div></Base>`,
 			want: want{
-				code: `${$$renderComponent($$result,'Base',Base,{"title":"Home"},{"default": () => $$render` + BACKTICK + `<div>Hello</div>` + BACKTICK + `,})}`,
+				helpers: []string{RENDER_COMPONENT},
+				code:    `${$$renderComponent($$result,'Base',Base,{"title":"Home"},{"default": () => $$render` + BACKTICK + `<div>Hello</div>` + BACKTICK + `,})}`,
 			},
 		},
 		{
 			name:   "user-defined `implicit` is printed",
 			source: `<html implicit></html>`,
 			want: want{
+				lean: true,
 				code: `<html implicit><head></head><body></body></html>`,
 			},
 		},
@@ -1240,6 +2246,7 @@ const items = ["Dog", "Cat", "Platipus"];
 <div class="container">My Text</div>`,
 
 			want: want{
+				lean:   true,
 				styles: []string{fmt.Sprintf(`{props:{"data-astro-id":"RN5ULUD7"},children:%s/* comment */.container.astro-RN5ULUD7{padding:2rem;}%s}`, BACKTICK, BACKTICK)},
 				code: `<html class="astro-RN5ULUD7"><head>
 
@@ -1261,7 +2268,7 @@ const items = ["Dog", "Cat", "Platipus"];
 			// transform output from source
 			code := test_utils.Dedent(tt.source)
 
-			doc, err := tycho.Parse(strings.NewReader(code))
+			doc, err := tycho.ParseWithOptions(strings.NewReader(code), tycho.ParseOptionXML(tt.transformOptions.ContentType == "xml"))
 
 			if err != nil {
 				t.Error(err)
@@ -1269,15 +2276,19 @@ const items = ["Dog", "Cat", "Platipus"];
 
 			hash := tycho.HashFromSource(code)
 			transform.ExtractStyles(doc)
-			transform.Transform(doc, transform.TransformOptions{Scope: hash}) // note: we want to test Transform in context here, but more advanced cases could be tested separately
-			result := PrintToJS(code, doc, transform.TransformOptions{
-				Scope:       "astro-XXXX",
-				Site:        "https://astro.build",
-				InternalURL: "http://localhost:3000/",
-			})
+			opts := tt.transformOptions
+			opts.Scope = hash
+			transform.Transform(doc, opts, handler.NewHandler(code, "<stdin>")) // note: we want to test Transform in context here, but more advanced cases could be tested separately
+			opts.Scope = "astro-XXXX"
+			opts.Site = "https://astro.build"
+			opts.InternalURL = "http://localhost:3000/"
+			result := PrintToJS(code, doc, opts)
 			output := string(result.Output)
 
 			toMatch := INTERNAL_IMPORTS
+			if tt.want.sideEffects != "" {
+				toMatch = test_utils.Dedent(tt.want.sideEffects) + "\n" + toMatch
+			}
 			if len(tt.want.frontmatter) > 0 {
 				toMatch += test_utils.Dedent(tt.want.frontmatter[0])
 			}
@@ -1318,6 +2329,10 @@ const items = ["Dog", "Cat", "Platipus"];
 				}
 			}
 			metadata += "]"
+			// metadata.islands
+			metadata += ", islands: [" + strings.Join(tt.want.metadata.islands, ", ") + "]"
+			// metadata.transitions
+			metadata += ", transitions: [" + strings.Join(tt.want.metadata.transitions, ", ") + "]"
 			// metadata.hoisted
 			metadata += ", hoisted: ["
 			if len(tt.want.metadata.hoisted) > 0 {
@@ -1328,14 +2343,25 @@ const items = ["Dog", "Cat", "Platipus"];
 					metadata += h
 				}
 			}
-			metadata += "] }"
+			metadata += "]"
+			if tt.want.metadata.route != "" {
+				metadata += ", route: " + tt.want.metadata.route
+			}
+			if opts.StampVersion {
+				metadata += fmt.Sprintf(", compilerVersion: '%s'", tycho.Version)
+			}
+			metadata += " }"
 
-			toMatch += "\n\n" + fmt.Sprintf("export const %s = %s(import.meta.url, %s);\n\n", METADATA, CREATE_METADATA, metadata)
-			toMatch += test_utils.Dedent(CREATE_ASTRO_CALL) + "\n\n"
-			if tt.want.skipHoist != true && len(tt.want.getStaticPaths) > 0 {
-				toMatch += strings.TrimSpace(test_utils.Dedent(tt.want.getStaticPaths)) + "\n\n"
+			if tt.want.lean {
+				toMatch += "\n" + test_utils.Dedent(LEAN_PRELUDE) + "\n"
+			} else {
+				toMatch += "\n\n" + fmt.Sprintf("export const %s = %s(import.meta.url, %s);\n\n", METADATA, CREATE_METADATA, metadata)
+				toMatch += test_utils.Dedent(CREATE_ASTRO_CALL) + "\n\n"
+				if tt.want.skipHoist != true && len(tt.want.getStaticPaths) > 0 {
+					toMatch += strings.TrimSpace(test_utils.Dedent(tt.want.getStaticPaths)) + "\n\n"
+				}
+				toMatch += test_utils.Dedent(PRELUDE) + "\n"
 			}
-			toMatch += test_utils.Dedent(PRELUDE) + "\n"
 			if len(tt.want.frontmatter) > 1 {
 				toMatch += test_utils.Dedent(tt.want.frontmatter[1])
 			}
@@ -1347,6 +2373,9 @@ const items = ["Dog", "Cat", "Platipus"];
 				}
 				toMatch += STYLE_SUFFIX
 			}
+			if tt.want.definedVars != "" {
+				toMatch += fmt.Sprintf("const $$definedVars = %s([%s]);\n", DEFINE_STYLE_VARS, tt.want.definedVars)
+			}
 			if len(tt.want.scripts) > 0 {
 				toMatch = toMatch + SCRIPT_PRELUDE
 				for _, script := range tt.want.scripts {
@@ -1361,6 +2390,10 @@ const items = ["Dog", "Cat", "Platipus"];
 				toMatch = strings.TrimRight(toMatch, ".")
 			}
 			toMatch += SUFFIX
+			for _, deferred := range tt.want.deferred {
+				toMatch += "\n\n//@ts-ignore\n" + deferred
+			}
+			toMatch = strings.Replace(toMatch, INTERNAL_IMPORTS, expectedInternalImports(tt.want), 1)
 
 			// compare to expected string, show diff if mismatch
 			if diff := test_utils.ANSIDiff(test_utils.Dedent(toMatch), test_utils.Dedent(output)); diff != "" {
@@ -1369,3 +2402,437 @@ const items = ["Dog", "Cat", "Platipus"];
 		})
 	}
 }
+
+func TestStats(t *testing.T) {
+	source := `---
+import Card from '../components/Card.astro';
+import Counter from '../components/Counter.jsx';
+---
+<html><head><style>h1 { color: red; }</style></head><body><h1>{title}</h1><Card><my-element /></Card><Counter client:visible /><script hoist>console.log(1)</script></body></html>`
+	doc, err := tycho.Parse(strings.NewReader(source))
+	if err != nil {
+		t.Error(err)
+	}
+	transform.ExtractStyles(doc)
+	transform.Transform(doc, transform.TransformOptions{}, handler.NewHandler(source, "<stdin>"))
+	result := PrintToJS(source, doc, transform.TransformOptions{})
+	got := result.Stats
+	want := Stats{
+		Elements:    4,
+		Components:  3,
+		Expressions: 1,
+		Islands:     1,
+		Styles:      1,
+		Scripts:     1,
+		OutputSize:  len(result.Output),
+	}
+	got.Print = 0
+	if got != want {
+		t.Error(fmt.Sprintf("\nFAIL: stats\n  want: %+v\n  got:  %+v", want, got))
+	}
+}
+
+func TestRawContent(t *testing.T) {
+	source := `<style>a { color: red; }</style><script>let a = 1;</script>`
+	doc, err := tycho.Parse(strings.NewReader(source))
+	if err != nil {
+		t.Error(err)
+	}
+	transform.ExtractStyles(doc)
+	// Preprocessors may produce content which the tokenizer would never allow
+	doc.Styles[0].FirstChild.Data = `a::after { content: "</style>"; }`
+	transform.Transform(doc, transform.TransformOptions{}, handler.NewHandler(source, "<stdin>"))
+	var findScript func(n *tycho.Node) *tycho.Node
+	findScript = func(n *tycho.Node) *tycho.Node {
+		if n.Data == "script" {
+			return n
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if found := findScript(c); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	findScript(doc).FirstChild.Data = "let a = `</SCRIPT>`;"
+	output := string(PrintToJS(source, doc, transform.TransformOptions{}).Output)
+	for _, want := range []string{`content:"<\\/style>"`, "let a = \\`<\\\\/SCRIPT>\\`;"} {
+		if !strings.Contains(output, want) {
+			t.Error(fmt.Sprintf("\nFAIL: raw content\n  want to contain: %s\n  got: %s", want, output))
+		}
+	}
+}
+
+func TestTags(t *testing.T) {
+	source := `<style>a {}</style><script hoist>one()</script><style>b {}</style><script hoist>two()</script>`
+	doc, err := tycho.Parse(strings.NewReader(source))
+	if err != nil {
+		t.Error(err)
+	}
+	transform.ExtractStyles(doc)
+	transform.Transform(doc, transform.TransformOptions{}, handler.NewHandler(source, "<stdin>"))
+	result := PrintToJS(source, doc, transform.TransformOptions{})
+	wantStyles := []Tag{
+		{Index: 0, Loc: loc.Loc{Start: 0}, Hash: tycho.HashFromSource(`<style>a {}</style>`)},
+		{Index: 1, Loc: loc.Loc{Start: 47}, Hash: tycho.HashFromSource(`<style>b {}</style>`)},
+	}
+	wantScripts := []Tag{
+		{Index: 0, Loc: loc.Loc{Start: 19}, Hash: tycho.HashFromSource(`<script hoist>one()</script>`)},
+		{Index: 1, Loc: loc.Loc{Start: 66}, Hash: tycho.HashFromSource(`<script hoist>two()</script>`)},
+	}
+	if diff := test_utils.ANSIDiff(wantStyles, result.Styles); diff != "" {
+		t.Error(fmt.Sprintf("styles mismatch (-want +got):\n%s", diff))
+	}
+	if diff := test_utils.ANSIDiff(wantScripts, result.Scripts); diff != "" {
+		t.Error(fmt.Sprintf("scripts mismatch (-want +got):\n%s", diff))
+	}
+	output := string(result.Output)
+	if strings.Index(output, "one()") > strings.Index(output, "two()") {
+		t.Error("scripts are not in document order")
+	}
+}
+
+func TestCriticalTags(t *testing.T) {
+	source := `<style>a {}</style><style is:critical>b {}</style><div is:critical></div>`
+	doc, err := tycho.Parse(strings.NewReader(source))
+	if err != nil {
+		t.Error(err)
+	}
+	transform.ExtractStyles(doc)
+	transform.Transform(doc, transform.TransformOptions{}, handler.NewHandler(source, "<stdin>"))
+	result := PrintToJS(source, doc, transform.TransformOptions{})
+	wantStyles := []Tag{
+		{Index: 0, Loc: loc.Loc{Start: 0}, Hash: tycho.HashFromSource(`<style>a {}</style>`)},
+		{Index: 1, Loc: loc.Loc{Start: 19}, Hash: tycho.HashFromSource(`<style is:critical>b {}</style>`), Critical: true},
+	}
+	if diff := test_utils.ANSIDiff(wantStyles, result.Styles); diff != "" {
+		t.Error(fmt.Sprintf("styles mismatch (-want +got):\n%s", diff))
+	}
+}
+
+func TestInlineSources(t *testing.T) {
+	source := `<style>a { color: red; }</style><button onclick="go(&quot;x&quot;)" onmouseover={hover}>Go</button><script hoist>
+  one()
+</script><script is:inline define:vars={{ a }}>two(a)</script><script is:inline>three()</script><script hoist src="/four.js"></script>`
+	doc, err := tycho.Parse(strings.NewReader(source))
+	if err != nil {
+		t.Error(err)
+	}
+	transform.ExtractStyles(doc)
+	transform.Transform(doc, transform.TransformOptions{}, handler.NewHandler(source, "<stdin>"))
+	result := PrintToJS(source, doc, transform.TransformOptions{})
+	want := []InlineSource{
+		// Styles are hashed as the page renders them, once scoped
+		{Kind: "style", Loc: loc.Loc{Start: 0}, Hash: cspHash("a.astro-{color:red;}")},
+		{Kind: "event-handler", Loc: loc.Loc{Start: strings.Index(source, "onclick")}, Attribute: "onclick", Hash: cspHash(`go("x")`)},
+		{Kind: "event-handler", Loc: loc.Loc{Start: strings.Index(source, "onmouseover")}, Attribute: "onmouseover"},
+		{Kind: "script", Loc: loc.Loc{Start: strings.Index(source, "<script hoist>")}, Hash: cspHash("one()")},
+		{Kind: "script", Loc: loc.Loc{Start: strings.Index(source, "<script is:inline define:vars")}},
+		{Kind: "script", Loc: loc.Loc{Start: strings.Index(source, "<script is:inline>")}, Hash: cspHash("three()")},
+	}
+	if diff := test_utils.ANSIDiff(want, result.InlineSources); diff != "" {
+		t.Error(fmt.Sprintf("inline sources mismatch (-want +got):\n%s", diff))
+	}
+	// The example of the CSP specification
+	if got, want := cspHash(`alert('Hello, world.');`), "sha256-qznLcsROx4GACP2dm0UCKCzCG+HiZ1guq6ZZDob/Tng="; got != want {
+		t.Errorf("\nFAIL: hash\n  want: %s\n  got:  %s", want, got)
+	}
+}
+
+func TestHashes(t *testing.T) {
+	compile := func(source string) PrintResult {
+		doc, err := tycho.Parse(strings.NewReader(source))
+		if err != nil {
+			t.Error(err)
+		}
+		transform.ExtractStyles(doc)
+		opts := transform.TransformOptions{Scope: tycho.HashFromSource(source)}
+		transform.Transform(doc, opts, handler.NewHandler(source, "<stdin>"))
+		return PrintToJS(source, doc, opts)
+	}
+	base := compile("---\nconst a = 1;\n---\n<h1>{a}</h1><style>h1 { color: red; }</style><script hoist>one()</script>")
+	tests := []struct {
+		name        string
+		source      string
+		frontmatter bool
+		template    bool
+		style       bool
+		script      bool
+	}{
+		{
+			name:   "style",
+			source: "---\nconst a = 1;\n---\n<h1>{a}</h1><style>h1 { color: blue; }</style><script hoist>one()</script>",
+			style:  true,
+		},
+		{
+			name:   "script",
+			source: "---\nconst a = 1;\n---\n<h1>{a}</h1><style>h1 { color: red; }</style><script hoist>two()</script>",
+			script: true,
+		},
+		{
+			name:     "template",
+			source:   "---\nconst a = 1;\n---\n<h2>{a}</h2><style>h1 { color: red; }</style><script hoist>one()</script>",
+			template: true,
+		},
+		{
+			name:        "frontmatter",
+			source:      "---\nconst a = 2;\n---\n<h1>{a}</h1><style>h1 { color: red; }</style><script hoist>one()</script>",
+			frontmatter: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := compile(tt.source)
+			changed := map[string][2]bool{
+				"frontmatter": {result.Hashes.Frontmatter != base.Hashes.Frontmatter, tt.frontmatter},
+				"template":    {result.Hashes.Template != base.Hashes.Template, tt.template},
+				"style":       {result.Styles[0].Hash != base.Styles[0].Hash, tt.style},
+				"script":      {result.Scripts[0].Hash != base.Scripts[0].Hash, tt.script},
+			}
+			for part, c := range changed {
+				if c[0] != c[1] {
+					t.Error(fmt.Sprintf("\nFAIL: %s\n  %s hash changed: %t, want %t", tt.name, part, c[0], c[1]))
+				}
+			}
+		})
+	}
+}
+
+func TestPrerender(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		define map[string]string
+		want   string
+	}{
+		{
+			name:   "none",
+			source: "<h1>Hello</h1>",
+			want:   "<nil>",
+		},
+		{
+			name:   "true",
+			source: "---\nexport const prerender = true;\n---\n<h1>Hello</h1>",
+			want:   "true",
+		},
+		{
+			name:   "false",
+			source: "---\nimport Layout from '../layouts/Layout.astro';\nexport const prerender = false\n---\n<Layout />",
+			want:   "false",
+		},
+		{
+			name:   "expression",
+			source: "---\nexport const prerender = import.meta.env.PRERENDER;\n---\n<h1>Hello</h1>",
+			want:   "<nil>",
+		},
+		{
+			name:   "defined",
+			source: "---\nexport const prerender = import.meta.env.PRERENDER;\n---\n<h1>Hello</h1>",
+			define: map[string]string{"import.meta.env.PRERENDER": "true"},
+			want:   "true",
+		},
+		{
+			name:   "not exported",
+			source: "---\nconst prerender = true;\n---\n<h1>{prerender}</h1>",
+			want:   "<nil>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := tycho.Parse(strings.NewReader(tt.source))
+			if err != nil {
+				t.Error(err)
+			}
+			opts := transform.TransformOptions{Define: tt.define}
+			transform.Transform(doc, opts, handler.NewHandler(tt.source, "<stdin>"))
+			result := PrintToJS(tt.source, doc, opts)
+			got := "<nil>"
+			if result.Prerender != nil {
+				got = fmt.Sprint(*result.Prerender)
+			}
+			if got != tt.want {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %v\n  got:  %v", tt.name, tt.want, got))
+			}
+		})
+	}
+}
+
+func TestPrunedImportsSourcemap(t *testing.T) {
+	source := "---\nimport './global.css';\nconst title = 'Hi';\n---\n<h1>{title}</h1>"
+	doc, err := tycho.Parse(strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := PrintToJS(source, doc, transform.TransformOptions{InternalURL: "http://localhost:3000/"})
+	output := string(result.Output)
+	if strings.Contains(output, SPREAD_ATTRIBUTES) {
+		t.Fatalf("unused helpers were imported:\n%s", output)
+	}
+
+	// Find where `{title}` is printed, then the mapping which covers it
+	original := strings.Index(source, "{title}") + 1
+	got := originalPosition(result, strings.Index(output, "${title}")+2)
+	if want := lineAndColumn(source, original); got != want {
+		t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %v\n  got:  %v", "title mapping", want, got))
+	}
+}
+
+func lineAndColumn(text string, offset int) string {
+	return fmt.Sprintf("%d:%d", strings.Count(text[:offset], "\n"), offset-strings.LastIndex(text[:offset], "\n")-1)
+}
+
+// originalPosition returns the line and column of the source which the
+// mapping covering offset in the output points to
+func originalPosition(result PrintResult, offset int) string {
+	output := string(result.Output)
+	wantLine, wantCol := strings.Count(output[:offset], "\n"), offset-strings.LastIndex(output[:offset], "\n")-1
+	mappings := result.SourceMapChunk.Buffer
+	line, col, srcLine, srcCol := 0, 0, 0, 0
+	position := "unmapped"
+	for i := 0; i < len(mappings); {
+		switch mappings[i] {
+		case ';':
+			line, col = line+1, 0
+			i++
+			continue
+		case ',':
+			i++
+			continue
+		}
+		var v int
+		v, i = sourcemap.DecodeVLQ(mappings, i)
+		col += v
+		_, i = sourcemap.DecodeVLQ(mappings, i)
+		v, i = sourcemap.DecodeVLQ(mappings, i)
+		srcLine += v
+		v, i = sourcemap.DecodeVLQ(mappings, i)
+		srcCol += v
+		if line == wantLine && col <= wantCol {
+			position = fmt.Sprintf("%d:%d", srcLine, srcCol)
+		}
+	}
+	return position
+}
+
+func TestCompactOutput(t *testing.T) {
+	source := "---\nimport Card from './Card.astro';\n\nconst text = `a\n\nb`;\n\n---\n<Card text={text} />"
+	doc, err := tycho.Parse(strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := PrintToJS(source, doc, transform.TransformOptions{CompactOutput: true})
+	output := string(result.Output)
+	withoutLiteral := strings.Replace(output, "`a\n\nb`", "", 1)
+	if strings.Contains(output, "//@ts-ignore") || strings.Contains(withoutLiteral, "\n\n") || !strings.Contains(output, "`a\n\nb`") {
+		t.Errorf("the output isn't compact:\n%s", output)
+	}
+	got := originalPosition(result, strings.Index(output, "(text)"))
+	if want := lineAndColumn(source, strings.Index(source, "{text}")+1); got != want {
+		t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %v\n  got:  %v", "text mapping", want, got))
+	}
+}
+
+func TestModuleFormat(t *testing.T) {
+	source := `---
+import Card from '../components/Card.astro';
+export async function getStaticPaths() {
+  return [];
+}
+const { title } = Astro.props;
+---
+<Card title={title} />`
+	print := func(format string) string {
+		doc, err := tycho.Parse(strings.NewReader(source))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(PrintToJS(source, doc, transform.TransformOptions{ModuleFormat: format}).Output)
+	}
+	esm, cjs := print("esm"), print("cjs")
+
+	for _, line := range strings.Split(cjs, "\n") {
+		if strings.HasPrefix(line, "import ") || strings.HasPrefix(line, "export ") || strings.Contains(line, "import.meta") {
+			t.Errorf("ES module syntax left in the CommonJS output: %s", line)
+		}
+	}
+	for _, want := range []string{
+		`const { render: $$render, createAstro: $$createAstro, createComponent: $$createComponent, renderComponent: $$renderComponent, createMetadata: $$createMetadata } = require("astro/internal");`,
+		"const { default: Card } = require('../components/Card.astro');",
+		"const $$metadata = $$createMetadata(require('url').pathToFileURL(__filename).href, ",
+		"exports.default = $$Component;",
+		"exports.getStaticPaths = getStaticPaths;",
+		"exports.$$metadata = $$metadata;",
+		"Object.defineProperty(exports, '__esModule', { value: true });",
+	} {
+		if !strings.Contains(cjs, want) {
+			t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %v\n  got:  %v", "cjs output", want, cjs))
+		}
+	}
+	// Statements keep their lines, so the source map of the module still applies
+	if lines := strings.Count(esm, "\n"); strings.Split(cjs, "\n")[lines-1] != "exports.default = $$Component;" {
+		t.Errorf("the CommonJS output moved lines:\n%s", cjs)
+	}
+
+	iife := print("iife")
+	if !strings.HasPrefix(iife, "var AstroComponent = (function ($$runtime) { var exports = {}; ") || !strings.HasSuffix(iife, "\nreturn exports;\n})(globalThis.astroInternal);\n") {
+		t.Errorf("the IIFE output isn't wrapped:\n%s", iife)
+	}
+	if strings.Contains(iife, "__filename") {
+		t.Errorf("the IIFE output depends on Node:\n%s", iife)
+	}
+	if !strings.Contains(iife, `if (specifier === "astro/internal") return $$runtime;`) {
+		t.Errorf("the IIFE output doesn't resolve the runtime:\n%s", iife)
+	}
+}
+
+func TestMetadataFormat(t *testing.T) {
+	source := "---\nimport Counter from './Counter.jsx';\n---\n<Counter client:load />"
+	doc, err := tycho.Parse(strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := transform.TransformOptions{MetadataFormat: "multi-line", StampVersion: true}
+	transform.Transform(doc, opts, handler.NewHandler(source, "<stdin>"))
+	output := string(PrintToJS(source, doc, opts).Output)
+	want := fmt.Sprintf(`export const $$metadata = $$createMetadata(import.meta.url, {
+  modules: [
+    { module: $$module1, specifier: './Counter.jsx' },
+  ],
+  hydratedComponents: [
+    Counter,
+  ],
+  islands: [
+    { name: 'Counter', directive: 'load' },
+  ],
+  transitions: [],
+  hoisted: [],
+  compilerVersion: '%s',
+});
+`, tycho.Version)
+	if !strings.Contains(output, want) {
+		t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %v\n  got:  %v", "multi-line metadata", want, output))
+	}
+	// The module imports don't depend on the format
+	if imports := "\nimport * as $$module1 from './Counter.jsx';\n"; !strings.Contains(output, imports) {
+		t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %v\n  got:  %v", "module imports", imports, output))
+	}
+}
+
+func TestBannerAndFooter(t *testing.T) {
+	source := "---\nconst title = 'Hi';\n---\n<h1>{title}</h1>"
+	doc, err := tycho.Parse(strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	banner := "/* MIT License */\nimport 'polyfill';"
+	footer := "if (import.meta.hot) import.meta.hot.accept();"
+	result := PrintToJS(source, doc, transform.TransformOptions{Banner: banner, Footer: footer})
+	output := string(result.Output)
+	if !strings.HasPrefix(output, banner+"\nimport {\n") || !strings.HasSuffix(output, "\nexport default $$Component;\n"+footer+"\n") {
+		t.Errorf("the banner or footer is missing:\n%s", output)
+	}
+	got := originalPosition(result, strings.Index(output, "${title}")+2)
+	if want := lineAndColumn(source, strings.Index(source, "{title}")+1); got != want {
+		t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %v\n  got:  %v", "title mapping", want, got))
+	}
+}
@@ -0,0 +1,51 @@
+package printer
+
+import (
+	"time"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/transform"
+)
+
+// Stats describes what a compiled document contains, for build dashboards
+// and performance budgets
+type Stats struct {
+	Elements    int
+	Components  int
+	Expressions int
+	Islands     int
+	Styles      int
+	Scripts     int
+	OutputSize  int
+	// How long each phase of the compile took. The printer only measures
+	// Print, the earlier phases are filled in by the caller.
+	Parse     time.Duration
+	Transform time.Duration
+	Print     time.Duration
+}
+
+func collectStats(doc *astro.Node) Stats {
+	stats := Stats{
+		Islands: len(transform.Islands(doc)),
+		Styles:  len(doc.Styles),
+		Scripts: len(doc.Scripts),
+	}
+	var count func(n *astro.Node)
+	count = func(n *astro.Node) {
+		if n.Type == astro.ElementNode && !transform.IsImplictNode(n) {
+			switch {
+			case n.Expression:
+				stats.Expressions++
+			case n.Component || n.CustomElement:
+				stats.Components++
+			case !n.Fragment:
+				stats.Elements++
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			count(c)
+		}
+	}
+	count(doc)
+	return stats
+}
@@ -0,0 +1,110 @@
+package printer
+
+import (
+	"sort"
+	"strings"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/loc"
+	"github.com/snowpackjs/astro/internal/transform"
+)
+
+// Tag is a <style> or hoisted <script> of a document. Styles and scripts are
+// always printed in document order, so Index is both the position of the tag
+// in the document and in the STYLES or SCRIPTS the component registers (and
+// the hoisted scripts of its metadata). Dev servers can use it to update a
+// single tag when it changes.
+type Tag struct {
+	Index int
+	Loc   loc.Loc
+	// A hash of the tag as authored
+	Hash string
+	// Set on styles marked with is:critical
+	Critical bool
+}
+
+// Hashes of the parts of a document as authored, so a dev server can tell
+// which kind of update an edit needs: a CSS-only update when only the Hash
+// of a style changed, a script update when only a script changed, and a full
+// reload when the frontmatter changed. The template excludes the frontmatter,
+// styles and hoisted scripts.
+type Hashes struct {
+	Frontmatter string
+	Template    string
+}
+
+type sourceRange struct {
+	start int
+	end   int
+}
+
+func collectTags(source string, nodes []*astro.Node, tag string) ([]Tag, []sourceRange) {
+	tags := make([]Tag, 0, len(nodes))
+	ranges := make([]sourceRange, 0, len(nodes))
+	for i, n := range nodes {
+		t := Tag{Index: i, Critical: tag == "style" && transform.IsCritical(n)}
+		if len(n.Loc) > 0 {
+			t.Loc = n.Loc[0]
+			r := elementRange(source, n.Loc[0].Start, tag)
+			t.Hash = astro.HashFromSource(source[r.start:r.end])
+			ranges = append(ranges, r)
+		}
+		tags = append(tags, t)
+	}
+	return tags, ranges
+}
+
+// elementRange finds the end of a raw text element which starts at start.
+// Its content can't contain a closing tag, so the first one ends it.
+func elementRange(source string, start int, tag string) sourceRange {
+	if start < 0 || start > len(source) {
+		return sourceRange{len(source), len(source)}
+	}
+	rest := source[start:]
+	end := strings.Index(strings.ToLower(rest), "</"+tag)
+	if end == -1 {
+		return sourceRange{start, len(source)}
+	}
+	if gt := strings.IndexByte(rest[end:], '>'); gt != -1 {
+		return sourceRange{start, start + end + gt + 1}
+	}
+	return sourceRange{start, len(source)}
+}
+
+func frontmatterRange(source string, doc *astro.Node) (sourceRange, bool) {
+	for c := doc.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != astro.FrontmatterNode || len(c.Loc) < 2 {
+			continue
+		}
+		start, end := c.Loc[0].Start, c.Loc[1].Start+len("---")
+		if start < 0 || end > len(source) || start > end {
+			return sourceRange{}, false
+		}
+		return sourceRange{start, end}, true
+	}
+	return sourceRange{}, false
+}
+
+func collectHashes(source string, doc *astro.Node, ranges []sourceRange) Hashes {
+	hashes := Hashes{}
+	if fm, ok := frontmatterRange(source, doc); ok {
+		hashes.Frontmatter = astro.HashFromSource(source[fm.start:fm.end])
+		ranges = append(ranges, fm)
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].start < ranges[j].start
+	})
+	var template strings.Builder
+	prev := 0
+	for _, r := range ranges {
+		if r.start > prev {
+			template.WriteString(source[prev:r.start])
+		}
+		if r.end > prev {
+			prev = r.end
+		}
+	}
+	template.WriteString(source[prev:])
+	hashes.Template = astro.HashFromSource(template.String())
+	return hashes
+}
@@ -1,37 +1,83 @@
 package printer
 
 import (
-	"regexp"
+	"fmt"
 	"strings"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/escape"
+	"golang.org/x/net/html/atom"
 )
 
-func escapeText(src string) string {
-	return escapeBackticks(
-		escapeInterpolation(
-			escapeExistingEscapes(src),
-		),
-	)
+// Attribute names are almost unrestricted, so shapes like Alpine's
+// `@click.prevent` or Vue's `:class` are passed through as authored. They
+// only need to be escaped for the JavaScript string they're printed into.
+func quoteAttributeKey(key string) string {
+	return `"` + escape.DoubleQuoted(key) + `"`
+}
+
+// Decode all entities in text, then re-encode only the characters that must be escaped
+func normalizeTextEntities(str string) string {
+	return escape.HTMLText(astro.UnescapeString(str))
+}
+
+// Decode all entities in an attribute value, then re-encode only the characters that must be escaped
+func normalizeAttributeEntities(str string) string {
+	return escape.HTMLAttr(astro.UnescapeAttributeString(str))
 }
 
-func escapeExistingEscapes(src string) string {
-	return strings.Replace(src, "\\", "\\\\", -1)
+// rawContent keeps the content of a <script> or <style> element from ending
+// it early once rendered. Preprocessors and transforms may produce content
+// that the tokenizer would never have allowed.
+func rawContent(n *astro.Node, content string) string {
+	switch n.DataAtom {
+	case atom.Script:
+		return escape.ScriptContent(content)
+	case atom.Style:
+		return escape.StyleContent(content)
+	}
+	return content
 }
 
-func escapeInterpolation(src string) string {
-	interpolation := regexp.MustCompile(`\${`)
-	return interpolation.ReplaceAllString(src, "\\${")
+// Attributes whose value is a comma-separated list of candidates
+var candidateListAttributes = map[string]bool{
+	"srcset":      true,
+	"sizes":       true,
+	"imagesrcset": true,
+	"imagesizes":  true,
 }
 
-// Escape backtick characters for Text nodes
-func escapeBackticks(src string) string {
-	backticks := regexp.MustCompile("`")
-	return backticks.ReplaceAllString(src, "\\`")
+// Print a slice of strings as a JavaScript array of single-quoted strings
+func printStringArray(items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = fmt.Sprintf("'%s'", escape.SingleQuoted(item))
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
 }
 
-func escapeSingleQuote(str string) string {
-	return strings.Replace(str, "'", "\\'", -1)
+// collapseWhitespace shortens whitespace between elements to a single
+// character, which renders the same outside of preformatted content
+func collapseWhitespace(str string) string {
+	if strings.Contains(str, "\n") {
+		return "\n"
+	}
+	return " "
 }
 
-func encodeDoubleQuote(str string) string {
-	return strings.Replace(str, `"`, "&quot;", -1)
+// preservesWhitespace returns true if n is inside of content where whitespace
+// is significant, like a <pre> element, Markdown or an expression
+func preservesWhitespace(n *astro.Node) bool {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.Type != astro.ElementNode {
+			continue
+		}
+		if p.Expression || p.Data == "pre" || p.Data == "textarea" || p.Data == "listing" || (p.Component && p.Data == "Markdown") {
+			return true
+		}
+		if astro.GetAttribute(p, "data-astro-raw") != nil || astro.GetAttribute(p, "is:ignore") != nil {
+			return true
+		}
+	}
+	return false
 }
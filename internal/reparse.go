@@ -0,0 +1,249 @@
+package astro
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/snowpackjs/astro/internal/loc"
+	a "golang.org/x/net/html/atom"
+)
+
+// Edit replaces the text from Start to End of a source with Text
+type Edit struct {
+	Start int
+	End   int
+	Text  string
+}
+
+// Apply returns source with the edit made
+func (e Edit) Apply(source string) string {
+	return source[:e.Start] + e.Text + source[e.End:]
+}
+
+// Elements which parsing may close, move or reinterpret because of what
+// comes after them, so their content can't be parsed on its own. The content
+// of an element inside of one of these can't be either, since a new child
+// like a <div> could close a <p> around it.
+var reparseUnsafe = map[a.Atom]bool{
+	a.Head: true, a.Frameset: true, a.Template: true,
+	a.Table: true, a.Caption: true, a.Colgroup: true, a.Tbody: true, a.Thead: true, a.Tfoot: true, a.Tr: true, a.Td: true, a.Th: true,
+	a.Select: true, a.Option: true, a.Optgroup: true, a.Form: true, a.Button: true,
+	a.P: true, a.Li: true, a.Dd: true, a.Dt: true, a.Rb: true, a.Rp: true, a.Rt: true, a.Rtc: true,
+	a.A: true, a.Nobr: true, a.H1: true, a.H2: true, a.H3: true, a.H4: true, a.H5: true, a.H6: true,
+}
+
+// Reparse returns the document of the source that edit makes of source, given
+// doc, the document parsed from source with the same options. When the edit
+// is inside of the content of an element which can be parsed on its own, only
+// that content is parsed again and doc is updated and returned, which is much
+// faster for large documents. Otherwise the whole new source is parsed and
+// doc is left as it was.
+//
+// A handler passed in opts only gets the warnings of what was parsed again.
+// The nodes which the edit replaces keep using memory until doc is released.
+func Reparse(doc *Node, source string, edit Edit, opts ...ParseOption) (*Node, error) {
+	if edit.Start < 0 || edit.End < edit.Start || edit.End > len(source) {
+		return nil, fmt.Errorf("edit %d-%d is outside of the source", edit.Start, edit.End)
+	}
+	newSource := edit.Apply(source)
+	n, start, end := reparseRegion(doc, source, edit)
+	if n == nil {
+		return ParseWithOptions(strings.NewReader(newSource), opts...)
+	}
+	// The region ends with the end tag of n
+	buf := []byte(newSource)
+	end += len(edit.Text) - (edit.End - edit.Start)
+	if !balanced(buf[:end], start, n.Data) {
+		return ParseWithOptions(strings.NewReader(newSource), opts...)
+	}
+
+	p := &parser{
+		// The end tag of n is ignored, since n isn't open
+		tokenizer:        tokenizerAt(buf[:end], start, n.Data),
+		doc:              &Node{Type: DocumentNode},
+		arena:            &nodeArena{},
+		scripting:        true,
+		fragment:         true,
+		context:          n,
+		frontmatterState: FrontmatterClosed,
+	}
+	for _, f := range opts {
+		f(p)
+	}
+	if p.tokenizer.xml {
+		return ParseWithOptions(strings.NewReader(newSource), opts...)
+	}
+	children, err := p.parseFragment()
+	if err != nil {
+		return nil, err
+	}
+
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		n.RemoveChild(c)
+		c = next
+	}
+	shiftLocs(n, edit.End, len(edit.Text)-(edit.End-edit.Start))
+	for _, c := range children {
+		n.AppendChild(c)
+	}
+	doc.arena.chunks = append(doc.arena.chunks, p.arena.chunks...)
+	return doc, nil
+}
+
+// reparseRegion returns the innermost element whose content contains edit and
+// can be parsed on its own, with the start of its content and the end of its
+// end tag in source
+func reparseRegion(doc *Node, source string, edit Edit) (region *Node, start int, end int) {
+	if doc.arena == nil {
+		return nil, 0, 0
+	}
+	// The elements which the edit is inside of, outermost first
+	candidates := make([]*Node, 0)
+	for n := doc; n != nil; {
+		var next *Node
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != ElementNode || len(c.Loc) == 0 || c.Loc[0].Start > edit.Start {
+				continue
+			}
+			// Implicit elements and expressions may contain it too
+			if len(c.Loc) > 1 && c.Loc[1].Start < edit.End && !c.Expression {
+				continue
+			}
+			next = c
+		}
+		if next == nil {
+			break
+		}
+		// Nothing inside of an unsafe element can be parsed on its own
+		if reparseUnsafe[next.DataAtom] || next.Namespace != "" || hasRawAttr(next) {
+			break
+		}
+		if len(next.Loc) > 1 && !next.Expression && !next.Fragment && next.DataAtom != a.Html && next.DataAtom != a.Body && next.Loc[0].Start < edit.Start {
+			candidates = append(candidates, next)
+		}
+		n = next
+	}
+
+	buf := []byte(source)
+	for i := len(candidates) - 1; i >= 0; i-- {
+		c := candidates[i]
+		s := tokenEnd(buf, c.Loc[0].Start, StartTagToken, c.Data)
+		e := tokenEnd(buf, c.Loc[1].Start, EndTagToken, c.Data)
+		// The old content must be balanced too, since elements it left open
+		// may have been closed or reconstructed outside of it
+		if s != -1 && s <= edit.Start && edit.End <= c.Loc[1].Start && e != -1 && balanced(buf[:e], s, c.Data) {
+			return c, s, e
+		}
+	}
+	return nil, 0, 0
+}
+
+// tokenEnd returns the end of the token at buf[start:] if it is a tag of type
+// tt named name, or else -1
+func tokenEnd(buf []byte, start int, tt TokenType, name string) int {
+	z := tokenizerAt(buf, start, "")
+	if z.Next() != tt {
+		return -1
+	}
+	if tag, _ := z.TagName(); !strings.EqualFold(string(tag), name) {
+		return -1
+	}
+	return z.raw.End
+}
+
+// balanced reports whether buf[start:], the content of tag and its end tag,
+// closes every element and expression that it opens and nothing else before
+// the end tag
+func balanced(buf []byte, start int, tag string) bool {
+	z := tokenizerAt(buf, start, tag)
+	open := make([]string, 0)
+	depth := 0
+	for {
+		switch z.Next() {
+		case ErrorToken, FrontmatterFenceToken:
+			return false
+		case StartTagToken:
+			name, _ := z.TagName()
+			open = append(open, string(name))
+		case EndTagToken:
+			name, _ := z.TagName()
+			if len(open) == 0 {
+				return depth == 0 && strings.EqualFold(string(name), tag) && z.raw.End == len(buf)
+			}
+			if open[len(open)-1] != string(name) {
+				return false
+			}
+			open = open[:len(open)-1]
+		case StartExpressionToken:
+			depth++
+		case EndExpressionToken:
+			depth--
+			if depth < 0 {
+				return false
+			}
+		case TextToken:
+			// A stray } may close an expression around the content
+			if depth == 0 && bytes.IndexByte(z.Raw(), '}') != -1 {
+				return false
+			}
+		}
+	}
+}
+
+// tokenizerAt returns a tokenizer of buf which starts at start, so the
+// locations of its tokens are in buf, in the content of contextTag
+func tokenizerAt(buf []byte, start int, contextTag string) *Tokenizer {
+	z := NewTokenizerFragment(bytes.NewReader(nil), contextTag)
+	z.buf = buf
+	z.raw = loc.Span{Start: start, End: start}
+	z.fm = FrontmatterClosed
+	return z
+}
+
+// shiftLocs moves every location from offset on by delta, which are all
+// after n or in its end tag, or in those of its ancestors
+func shiftLocs(n *Node, offset int, delta int) {
+	if delta == 0 {
+		return
+	}
+	// Cloned nodes share their locations
+	shifted := make(map[*loc.Loc]bool)
+	shift := func(l *loc.Loc) {
+		if l.Start >= offset && !shifted[l] {
+			l.Start += delta
+			shifted[l] = true
+		}
+	}
+	var walk func(n *Node)
+	walk = func(n *Node) {
+		for i := range n.Loc {
+			shift(&n.Loc[i])
+		}
+		for i := range n.Attr {
+			shift(&n.Attr[i].KeyLoc)
+			shift(&n.Attr[i].ValLoc)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	for ; n != nil; n = n.Parent {
+		for i := range n.Loc {
+			shift(&n.Loc[i])
+		}
+		for s := n.NextSibling; s != nil; s = s.NextSibling {
+			walk(s)
+		}
+	}
+}
+
+func hasRawAttr(n *Node) bool {
+	for _, attr := range n.Attr {
+		if attr.Key == "is:raw" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package astro
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestReparse(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		edit   Edit
+		// Whether only the content of an element is parsed again
+		incremental bool
+	}{
+		{
+			name:        "text",
+			source:      "---\nconst a = 1;\n---\n<main><div>Hello</div><span class=\"b\">{a}</span></main>",
+			edit:        Edit{Start: 32, End: 37, Text: "Goodbye"},
+			incremental: true,
+		},
+		{
+			name:        "new element",
+			source:      "<main><section><h2>Title</h2></section><footer /></main>",
+			edit:        Edit{Start: 29, End: 29, Text: "<ul><li>a</li></ul>"},
+			incremental: true,
+		},
+		{
+			name:        "expression",
+			source:      "<ul>{items.map(item => <li>{item}</li>)}</ul>\n<p id=\"after\">end</p>",
+			edit:        Edit{Start: 28, End: 32, Text: "item.name"},
+			incremental: true,
+		},
+		{
+			name:        "deletion",
+			source:      "<div><Card title=\"a\" /><Card title=\"b\" /></div><div>after</div>",
+			edit:        Edit{Start: 23, End: 41, Text: ""},
+			incremental: true,
+		},
+		{
+			name:   "unclosed element",
+			source: "<main><div>a</div></main><footer>b</footer>",
+			edit:   Edit{Start: 11, End: 11, Text: "<span>"},
+		},
+		{
+			name:   "stray end tag",
+			source: "<main><div>a</div></main><footer>b</footer>",
+			edit:   Edit{Start: 11, End: 11, Text: "</div>"},
+		},
+		{
+			name:   "inside a paragraph",
+			source: "<p><span>a</span></p>",
+			edit:   Edit{Start: 9, End: 10, Text: "<div>b</div>"},
+		},
+		{
+			name:   "tag",
+			source: "<main><div>a</div></main>",
+			edit:   Edit{Start: 7, End: 10, Text: "span"},
+		},
+		{
+			name:   "frontmatter",
+			source: "---\nconst a = 1;\n---\n<div>{a}</div>",
+			edit:   Edit{Start: 10, End: 11, Text: "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Reparse(doc, tt.source, tt.edit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := Parse(strings.NewReader(tt.edit.Apply(tt.source)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (got == doc) != tt.incremental {
+				t.Errorf("expected incremental to be %v", tt.incremental)
+			}
+			if g, w := dumpNode(got), dumpNode(want); g != w {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %s\n  got:  %s", tt.name, w, g))
+			}
+		})
+	}
+}
+
+// dumpNode returns the tree of n with its locations
+func dumpNode(n *Node) string {
+	var b strings.Builder
+	var dump func(n *Node, depth int)
+	dump = func(n *Node, depth int) {
+		fmt.Fprintf(&b, "\n%s%d %q %v", strings.Repeat("  ", depth), n.Type, n.Data, n.Loc)
+		for _, attr := range n.Attr {
+			fmt.Fprintf(&b, " %s=%q@%d,%d", attr.Key, attr.Val, attr.KeyLoc.Start, attr.ValLoc.Start)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			dump(c, depth+1)
+		}
+	}
+	dump(n, 0)
+	return b.String()
+}
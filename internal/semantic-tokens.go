@@ -0,0 +1,151 @@
+package astro
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SemanticTokenTypes is the legend of SemanticTokens: the type of a token is
+// its index in this list. Directive namespaces, like `client` in
+// `client:load`, use the standard "namespace" type.
+var SemanticTokenTypes = []string{"tag", "component", "attribute", "namespace", "punctuation", "frontmatter"}
+
+const (
+	semanticTag = iota
+	semanticComponent
+	semanticAttribute
+	semanticNamespace
+	semanticPunctuation
+	semanticFrontmatter
+)
+
+type semanticToken struct {
+	start, length, kind int
+}
+
+// SemanticTokens tokenizes source and returns its tag names, attribute
+// names, directive namespaces, expression braces and frontmatter fences in
+// the relative encoding of the Language Server Protocol: five integers per
+// token for the line delta, the start delta, the length, the type from
+// SemanticTokenTypes and the modifiers, which are always 0. Columns and
+// lengths are in UTF-16 code units.
+func SemanticTokens(source string) []int {
+	tokens := make([]semanticToken, 0)
+	add := func(start int, length int, kind int) {
+		if start >= 0 && length > 0 && start+length <= len(source) {
+			tokens = append(tokens, semanticToken{start, length, kind})
+		}
+	}
+	// Only add a brace which is actually at start
+	brace := func(start int, c byte) {
+		if start >= 0 && start < len(source) && source[start] == c {
+			add(start, 1, semanticPunctuation)
+		}
+	}
+
+	z := NewTokenizer(strings.NewReader(source))
+	fences := 0
+	for {
+		tt := z.Next()
+		if tt == ErrorToken {
+			break
+		}
+		t := z.Token()
+		switch tt {
+		case StartTagToken, SelfClosingTagToken:
+			add(t.Loc.Start+1, len(t.Data), tagKind(t.Data))
+			for _, attr := range t.Attr {
+				switch attr.Type {
+				case ShorthandAttribute:
+					brace(attr.KeyLoc.Start-1, '{')
+					brace(attr.KeyLoc.Start+len(attr.Key), '}')
+				case SpreadAttribute:
+					brace(attr.KeyLoc.Start-len("{..."), '{')
+					brace(attr.KeyLoc.Start+len(attr.Key), '}')
+				default:
+					if i := strings.IndexByte(attr.Key, ':'); i > 0 {
+						add(attr.KeyLoc.Start, i, semanticNamespace)
+						add(attr.KeyLoc.Start+i+1, len(attr.Key)-i-1, semanticAttribute)
+					} else {
+						add(attr.KeyLoc.Start, len(attr.Key), semanticAttribute)
+					}
+					if attr.Type == ExpressionAttribute {
+						brace(attr.ValLoc.Start-1, '{')
+						brace(attr.ValLoc.Start+len(attr.Val), '}')
+					}
+				}
+			}
+		case EndTagToken:
+			add(t.Loc.Start+2, len(t.Data), tagKind(t.Data))
+		case StartExpressionToken:
+			brace(t.Loc.Start, '{')
+		case EndExpressionToken:
+			brace(t.Loc.Start, '}')
+		case FrontmatterFenceToken:
+			// The closing fence is located at its end
+			start := t.Loc.Start
+			if fences > 0 {
+				start -= len("---")
+			}
+			if start >= 0 && strings.HasPrefix(source[start:], "---") {
+				add(start, len("---"), semanticFrontmatter)
+			}
+			fences++
+		}
+	}
+	sort.SliceStable(tokens, func(i, j int) bool { return tokens[i].start < tokens[j].start })
+	return encodeSemanticTokens(source, tokens)
+}
+
+func tagKind(name string) int {
+	if r, _ := utf8.DecodeRuneInString(name); unicode.IsUpper(r) || strings.Contains(name, ".") {
+		return semanticComponent
+	}
+	return semanticTag
+}
+
+func encodeSemanticTokens(source string, tokens []semanticToken) []int {
+	data := make([]int, 0, len(tokens)*5)
+	line, column, offset, end := 0, 0, 0, 0
+	prevLine, prevColumn := 0, 0
+	for _, t := range tokens {
+		// Tokens which overlap the previous one can't be encoded
+		if t.start < end {
+			continue
+		}
+		for i, r := range source[offset:t.start] {
+			switch {
+			case r == '\n' || (r == '\r' && (offset+i+1 >= len(source) || source[offset+i+1] != '\n')):
+				line++
+				column = 0
+			case r == '\r':
+			case r > 0xFFFF:
+				column += 2
+			default:
+				column++
+			}
+		}
+		offset, end = t.start, t.start+t.length
+		deltaColumn := column
+		if line == prevLine {
+			deltaColumn -= prevColumn
+		}
+		data = append(data, line-prevLine, deltaColumn, utf16Len(source[t.start:end]), t.kind, 0)
+		prevLine, prevColumn = line, column
+	}
+	return data
+}
+
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		if r > 0xFFFF {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,70 @@
+package astro
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestSemanticTokens(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "element",
+			source: `<div class="a" data-id={id}>{text}</div>`,
+			want:   []string{"div tag", "class attribute", "data-id attribute", "{ punctuation", "} punctuation", "{ punctuation", "} punctuation", "div tag"},
+		},
+		{
+			name: "component with directives",
+			source: `---
+import Card from './Card.astro';
+---
+<Card.Item client:load {title} {...props} />`,
+			want: []string{
+				"--- frontmatter", "--- frontmatter",
+				"Card.Item component", "client namespace", "load attribute",
+				"{ punctuation", "} punctuation", "{ punctuation", "} punctuation",
+			},
+		},
+		{
+			name:   "nested expressions",
+			source: "<ul>\n  {items.map(item => <li>{item}</li>)}\n</ul>",
+			want:   []string{"ul tag", "{ punctuation", "li tag", "{ punctuation", "} punctuation", "li tag", "} punctuation", "ul tag"},
+		},
+		{
+			name:   "utf-16 columns",
+			source: "<p>😀</p><br />",
+			want:   []string{"p tag", "p tag", "br tag"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := decodeSemanticTokens(tt.source, SemanticTokens(tt.source))
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %q\n  got:  %q", tt.name, tt.want, got))
+			}
+		})
+	}
+}
+
+// decodeSemanticTokens returns the text and type of every token of data
+func decodeSemanticTokens(source string, data []int) []string {
+	lines := strings.Split(source, "\n")
+	tokens := make([]string, 0)
+	line, column := 0, 0
+	for i := 0; i+4 < len(data); i += 5 {
+		if data[i] > 0 {
+			column = 0
+		}
+		line += data[i]
+		column += data[i+1]
+		units := utf16.Encode([]rune(lines[line]))
+		text := string(utf16.Decode(units[column : column+data[i+2]]))
+		tokens = append(tokens, fmt.Sprintf("%s %s", text, SemanticTokenTypes[data[i+3]]))
+	}
+	return tokens
+}
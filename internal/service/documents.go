@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/compiler"
+	"github.com/snowpackjs/astro/internal/handler"
+)
+
+// DocumentResult is the ParseResult of a document the service keeps, so later
+// edits only parse again what they change
+type DocumentResult struct {
+	Document int `json:"document"`
+	ParseResult
+}
+
+type EditDocumentParams struct {
+	Document int `json:"document"`
+	// Made in order, each one to the source the one before it left
+	Edits []TextEdit `json:"edits"`
+}
+
+// TextEdit replaces the text from Start to End of a source with Text
+type TextEdit struct {
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Text  string `json:"text"`
+}
+
+type CloseDocumentParams struct {
+	Document int `json:"document"`
+}
+
+type document struct {
+	mu      sync.Mutex
+	doc     *astro.Node
+	source  string
+	options Options
+}
+
+// Documents kept between requests until they're closed, by handle
+var documents = struct {
+	sync.Mutex
+	next int
+	byID map[int]*document
+}{byID: make(map[int]*document)}
+
+// OpenDocument parses an Astro component like Parse does, and keeps it until
+// CloseDocument so that EditDocument can parse it again after an edit
+func OpenDocument(ctx context.Context, params ParseParams) (DocumentResult, error) {
+	doc, h, err := parse(ctx, params)
+	if err != nil {
+		return DocumentResult{}, err
+	}
+	documents.Lock()
+	documents.next++
+	id := documents.next
+	documents.byID[id] = &document{doc: doc, source: params.Source, options: params.Options}
+	documents.Unlock()
+	return DocumentResult{Document: id, ParseResult: parseResult(doc, h)}, nil
+}
+
+// EditDocument makes edits to a document opened by OpenDocument. Only the
+// element each edit is inside of is parsed again when it can be parsed on its
+// own, and the diagnostics are only those of what was parsed again. A document
+// which an edit fails to parse is closed.
+func EditDocument(ctx context.Context, params EditDocumentParams) (DocumentResult, error) {
+	documents.Lock()
+	d, ok := documents.byID[params.Document]
+	documents.Unlock()
+	if !ok {
+		return DocumentResult{}, &Error{Message: fmt.Sprintf("document %d isn't open", params.Document)}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.doc == nil {
+		return DocumentResult{}, &Error{Message: fmt.Sprintf("document %d isn't open", params.Document)}
+	}
+
+	opts := d.options.TransformOptions()
+	source := d.source
+	for _, e := range params.Edits {
+		if err := ctx.Err(); err != nil {
+			return DocumentResult{}, err
+		}
+		if e.Start < 0 || e.End < e.Start || e.End > len(source) {
+			return DocumentResult{}, &Error{Message: fmt.Sprintf("edit %d-%d is outside of the document", e.Start, e.End)}
+		}
+		source = astro.Edit{Start: e.Start, End: e.End, Text: e.Text}.Apply(source)
+	}
+	h := handler.NewHandler(source, opts.Filename)
+	doc := d.doc
+	var err error
+	if opts.As == "fragment" || opts.DataFrontmatter {
+		// A fragment has no document of its own to reparse a part of, and the
+		// data of a content file isn't parsed, so an edit can't be made to it
+		var next *astro.Node
+		next, h, err = parse(ctx, ParseParams{Source: source, Options: d.options})
+		if err == nil {
+			doc.Release()
+			doc = next
+		}
+	} else {
+		current := d.source
+		for _, e := range params.Edits {
+			var next *astro.Node
+			next, err = compiler.Reparse(ctx, doc, current, astro.Edit{Start: e.Start, End: e.End, Text: e.Text}, opts, h)
+			if err != nil {
+				err = &Error{Message: err.Error(), Diagnostics: h.Diagnostics()}
+				break
+			}
+			if next != doc {
+				doc.Release()
+				doc = next
+			}
+			current = astro.Edit{Start: e.Start, End: e.End, Text: e.Text}.Apply(current)
+		}
+	}
+	if err != nil {
+		documents.Lock()
+		delete(documents.byID, params.Document)
+		documents.Unlock()
+		doc.Release()
+		d.doc = nil
+		return DocumentResult{}, err
+	}
+	d.doc, d.source = doc, source
+	return DocumentResult{Document: params.Document, ParseResult: parseResult(doc, h)}, nil
+}
+
+// CloseDocument releases a document opened by OpenDocument
+func CloseDocument(params CloseDocumentParams) error {
+	if !closeDocument(params.Document) {
+		return &Error{Message: fmt.Sprintf("document %d isn't open", params.Document)}
+	}
+	return nil
+}
+
+func closeDocument(id int) bool {
+	documents.Lock()
+	d, ok := documents.byID[id]
+	delete(documents.byID, id)
+	documents.Unlock()
+	if !ok {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.doc != nil {
+		d.doc.Release()
+		d.doc = nil
+	}
+	return true
+}
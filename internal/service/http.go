@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"runtime"
+	"sync"
+
+	astro "github.com/snowpackjs/astro/internal"
+)
+
+// NewHTTPHandler serves the compiler over HTTP:
+//
+//	POST /compile {source, options}
+//	     -> {code, map, diagnostics, version}
+//	POST /compileProject {files, options, analyze}
+//	     -> {files, manifest, analysis, version}
+//	POST /parse {source, options}
+//	     -> {ast, references, definitions, diagnostics}
+//	POST /outline {source, options} -> {symbols, diagnostics}
+//	POST /semanticTokens {source} -> {legend, data}
+//	POST /completion {source, offset}
+//	     -> {kind, prefix, start, tag, attribute, attributes, parents, scope}
+//	POST /diff {source, options, summary, before}
+//	     -> {summary, changes, diagnostics}
+//	GET  /version -> {version}
+//
+// At most concurrency requests compile at once, or one per CPU when it is not
+// positive. Request bodies larger than MaxRequestSize are rejected.
+//
+// Successful responses carry an ETag derived from the compiler version and the
+// request, and are kept by the handler, up to MaxCacheSize bytes of them, so
+// every machine sending the same request gets the kept result without another
+// compile. The X-Cache header of a response is "hit" when it was kept.
+func NewHTTPHandler(concurrency int) http.Handler {
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+	s := &httpServer{slots: make(chan struct{}, concurrency), results: make(map[string][]byte)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/compile", s.post(func(body []byte) ([]byte, run, error) {
+		var params CompileParams
+		if err := json.Unmarshal(body, &params); err != nil {
+			return nil, nil, err
+		}
+		key, _ := json.Marshal(params)
+		return key, func(ctx context.Context) (interface{}, error) {
+			return Compile(ctx, params)
+		}, nil
+	}))
+	mux.HandleFunc("/compileProject", s.post(func(body []byte) ([]byte, run, error) {
+		var params CompileProjectParams
+		if err := json.Unmarshal(body, &params); err != nil {
+			return nil, nil, err
+		}
+		key, _ := json.Marshal(params)
+		return key, func(ctx context.Context) (interface{}, error) {
+			return CompileProject(ctx, params)
+		}, nil
+	}))
+	mux.HandleFunc("/parse", s.post(func(body []byte) ([]byte, run, error) {
+		var params ParseParams
+		if err := json.Unmarshal(body, &params); err != nil {
+			return nil, nil, err
+		}
+		key, _ := json.Marshal(params)
+		return key, func(ctx context.Context) (interface{}, error) {
+			return Parse(ctx, params)
+		}, nil
+	}))
+	mux.HandleFunc("/outline", s.post(func(body []byte) ([]byte, run, error) {
+		var params ParseParams
+		if err := json.Unmarshal(body, &params); err != nil {
+			return nil, nil, err
+		}
+		key, _ := json.Marshal(params)
+		return key, func(ctx context.Context) (interface{}, error) {
+			return Outline(ctx, params)
+		}, nil
+	}))
+	mux.HandleFunc("/semanticTokens", s.post(func(body []byte) ([]byte, run, error) {
+		var params ParseParams
+		if err := json.Unmarshal(body, &params); err != nil {
+			return nil, nil, err
+		}
+		key, _ := json.Marshal(params)
+		return key, func(ctx context.Context) (interface{}, error) {
+			return SemanticTokens(ctx, params)
+		}, nil
+	}))
+	mux.HandleFunc("/completion", s.post(func(body []byte) ([]byte, run, error) {
+		var params CompletionParams
+		if err := json.Unmarshal(body, &params); err != nil {
+			return nil, nil, err
+		}
+		key, _ := json.Marshal(params)
+		return key, func(ctx context.Context) (interface{}, error) {
+			return Completion(ctx, params)
+		}, nil
+	}))
+	mux.HandleFunc("/diff", s.post(func(body []byte) ([]byte, run, error) {
+		var params DiffParams
+		if err := json.Unmarshal(body, &params); err != nil {
+			return nil, nil, err
+		}
+		key, _ := json.Marshal(params)
+		return key, func(ctx context.Context) (interface{}, error) {
+			return Diff(ctx, params)
+		}, nil
+	}))
+	mux.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeJSON(w, http.StatusMethodNotAllowed, Error{Message: "method not allowed"})
+			return
+		}
+		writeJSON(w, http.StatusOK, VersionResult{Version: astro.Version})
+	})
+	return mux
+}
+
+// MaxRequestSize is the largest request body the HTTP handler reads, in bytes
+const MaxRequestSize = 32 << 20
+
+// MaxCacheSize is how many bytes of results the HTTP handler keeps
+const MaxCacheSize = 64 << 20
+
+type httpServer struct {
+	slots chan struct{}
+
+	mu sync.Mutex
+	// The encoded results of successful requests, by ETag
+	results map[string][]byte
+	size    int
+}
+
+type run func(ctx context.Context) (interface{}, error)
+
+// decode parses a request body, returning the canonical form of the request
+// used to compute its ETag and the function which answers it
+type decode func(body []byte) (key []byte, fn run, err error)
+
+func (s *httpServer) post(decode decode) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			writeJSON(w, http.StatusMethodNotAllowed, Error{Message: "method not allowed"})
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxRequestSize))
+		if err != nil {
+			status := http.StatusBadRequest
+			if len(body) == MaxRequestSize {
+				status = http.StatusRequestEntityTooLarge
+			}
+			writeJSON(w, status, Error{Message: err.Error()})
+			return
+		}
+		key, fn, err := decode(body)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, Error{Message: err.Error()})
+			return
+		}
+		// Only successful responses are cached, so a matching ETag means the
+		// client already has the result
+		etag := etag(r.URL.Path, key)
+		if r.Header.Get("If-None-Match") == etag {
+			w.Header().Set("ETag", etag)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if body, ok := s.cached(etag); ok {
+			writeResult(w, etag, "hit", body)
+			return
+		}
+
+		select {
+		case s.slots <- struct{}{}:
+			defer func() { <-s.slots }()
+		case <-r.Context().Done():
+			return
+		}
+
+		result, err := fn(r.Context())
+		var serviceError *Error
+		switch {
+		case err == nil:
+			body, err := json.Marshal(result)
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, Error{Message: err.Error()})
+				return
+			}
+			body = append(body, '\n')
+			s.store(etag, body)
+			writeResult(w, etag, "miss", body)
+		case errors.As(err, &serviceError):
+			writeJSON(w, http.StatusUnprocessableEntity, serviceError)
+		case r.Context().Err() != nil:
+		default:
+			writeJSON(w, http.StatusInternalServerError, Error{Message: err.Error()})
+		}
+	}
+}
+
+// cached returns the result kept for etag, if there is one
+func (s *httpServer) cached(etag string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body, ok := s.results[etag]
+	return body, ok
+}
+
+// store keeps the result body for etag. Results are dropped until it fits in
+// MaxCacheSize, and one which never would isn't kept.
+func (s *httpServer) store(etag string, body []byte) {
+	if len(body) > MaxCacheSize {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.results[etag]; ok {
+		return
+	}
+	for k, b := range s.results {
+		if s.size+len(body) <= MaxCacheSize {
+			break
+		}
+		delete(s.results, k)
+		s.size -= len(b)
+	}
+	s.results[etag] = body
+	s.size += len(body)
+}
+
+func etag(path string, key []byte) string {
+	h := sha256.New()
+	h.Write([]byte(astro.Version))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(key)
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// writeResult writes the encoded result of a successful request, which never
+// changes for the same ETag
+func writeResult(w http.ResponseWriter, etag string, cache string, body []byte) {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("X-Cache", cache)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	astro "github.com/snowpackjs/astro/internal"
+)
+
+func TestHTTPHandler(t *testing.T) {
+	server := httptest.NewServer(NewHTTPHandler(2))
+	defer server.Close()
+
+	post := func(path string, body string, etag string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, server.URL+path, strings.NewReader(body))
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	res := post("/compile", `{"source":"<h1>{title}</h1>"}`, "")
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+	var compiled CompileResult
+	if err := json.NewDecoder(res.Body).Decode(&compiled); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(compiled.Code, "<h1>${title}</h1>") {
+		t.Errorf("unexpected code:\n%s", compiled.Code)
+	}
+	etag := res.Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("expected an ETag")
+	}
+	if cache := res.Header.Get("X-Cache"); cache != "miss" {
+		t.Errorf("expected a first compile to miss the cache, got %q", cache)
+	}
+
+	// Another machine sending the same request gets the kept result
+	res = post("/compile", `{"source":"<h1>{title}</h1>"}`, "")
+	var cached CompileResult
+	json.NewDecoder(res.Body).Decode(&cached)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || res.Header.Get("X-Cache") != "hit" || res.Header.Get("ETag") != etag {
+		t.Errorf("expected a cache hit, got status %d, X-Cache %q", res.StatusCode, res.Header.Get("X-Cache"))
+	}
+	if cached.Code != compiled.Code {
+		t.Errorf("expected the kept code, got:\n%s", cached.Code)
+	}
+
+	// Equivalent requests share an ETag, regardless of formatting
+	res = post("/compile", `{ "options": {}, "source": "<h1>{title}</h1>" }`, etag)
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotModified {
+		t.Errorf("expected status 304, got %d", res.StatusCode)
+	}
+
+	res = post("/compile", `{"source":"<h1>{title}</h1>","options":{"stampVersion":true}}`, etag)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || res.Header.Get("ETag") == etag {
+		t.Errorf("expected a new result for different options, got status %d", res.StatusCode)
+	}
+
+	res = post("/compile", `{"source":"<h1>","options":{"as":"page"}}`, "")
+	var failure Error
+	json.NewDecoder(res.Body).Decode(&failure)
+	res.Body.Close()
+	if res.StatusCode != http.StatusUnprocessableEntity || failure.Message == "" {
+		t.Errorf("expected status 422 with a message, got %d %+v", res.StatusCode, failure)
+	}
+	if res.Header.Get("ETag") != "" {
+		t.Error("expected failures not to be cacheable")
+	}
+
+	res = post("/parse", `{"source":"<p>hi</p>","options":{"as":"fragment"}}`, "")
+	var parsed ParseResult
+	json.NewDecoder(res.Body).Decode(&parsed)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || len(parsed.AST.Children) != 1 || parsed.AST.Children[0].Name != "p" {
+		t.Errorf("unexpected parse result %d %+v", res.StatusCode, parsed.AST)
+	}
+
+	res = post("/compile", `{"source":`, "")
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 for invalid JSON, got %d", res.StatusCode)
+	}
+
+	res = post("/compile", `{"source":"`+strings.Repeat("a", MaxRequestSize)+`"}`, "")
+	res.Body.Close()
+	if res.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status 413 for a body over the limit, got %d", res.StatusCode)
+	}
+
+	res, err := http.Get(server.URL + "/compile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405, got %d", res.StatusCode)
+	}
+
+	res, err = http.Get(server.URL + "/version")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var version VersionResult
+	json.NewDecoder(res.Body).Decode(&version)
+	res.Body.Close()
+	if version.Version != astro.Version {
+		t.Errorf("expected version %q, got %q", astro.Version, version.Version)
+	}
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"time"
+
+	"github.com/snowpackjs/astro/internal/transform"
+)
+
+// Options are the JSON form of transform.TransformOptions, with the same
+// names as the options of the JavaScript API
+type Options struct {
+	As                    string            `json:"as"`
+	Sourcefile            string            `json:"sourcefile"`
+	InternalURL           string            `json:"internalURL"`
+	Site                  string            `json:"site"`
+	Sourcemap             string            `json:"sourcemap"`
+	DedentRaw             bool              `json:"dedentRaw"`
+	Entities              string            `json:"entities"`
+	CustomElements        map[string]string `json:"customElements"`
+	ImageHints            bool              `json:"imageHints"`
+	A11y                  bool              `json:"a11y"`
+	Strict                bool              `json:"strict"`
+	PropsSerialization    string            `json:"propsSerialization"`
+	Translate             string            `json:"translate"`
+	Define                map[string]string `json:"define"`
+	PreserveJSXComments   bool              `json:"preserveJSXComments"`
+	ContentType           string            `json:"contentType"`
+	PreserveAttributeCase bool              `json:"preserveAttributeCase"`
+	RenderTelemetry       bool              `json:"renderTelemetry"`
+	IslandMarkers         bool              `json:"islandMarkers"`
+	StampVersion          bool              `json:"stampVersion"`
+	IsPage                bool              `json:"isPage"`
+	Route                 string            `json:"route"`
+	DataFrontmatter       bool              `json:"dataFrontmatter"`
+	FullOutput            bool              `json:"fullOutput"`
+	CompactOutput         bool              `json:"compactOutput"`
+	ModuleFormat          string            `json:"moduleFormat"`
+	MetadataFormat        string            `json:"metadataFormat"`
+	GlobalName            string            `json:"globalName"`
+	RuntimeGlobal         string            `json:"runtimeGlobal"`
+	Banner                string            `json:"banner"`
+	Footer                string            `json:"footer"`
+	ScopeRootElements     bool              `json:"scopeRootElements"`
+	ScopedStyleStrategy   string            `json:"scopedStyleStrategy"`
+	UnusedSelectors       string            `json:"unusedSelectors"`
+	TrimWhitespace        string            `json:"trimWhitespace"`
+	CSPNonceVar           string            `json:"cspNonceVar"`
+	IslandBudget          int               `json:"islandBudget"`
+	InlineScriptBudget    int               `json:"inlineScriptBudget"`
+	MaxInputSize          int               `json:"maxInputSize"`
+	MaxNodes              int               `json:"maxNodes"`
+	MaxExpressionDepth    int               `json:"maxExpressionDepth"`
+	// In milliseconds
+	Timeout int `json:"timeout"`
+	// Only compile returns a trace, a project has none
+	Trace bool `json:"trace"`
+}
+
+func (o Options) TransformOptions() transform.TransformOptions {
+	opts := transform.TransformOptions{
+		As:                    o.As,
+		Filename:              o.Sourcefile,
+		InternalURL:           o.InternalURL,
+		Site:                  o.Site,
+		SourceMap:             o.Sourcemap,
+		DedentRaw:             o.DedentRaw,
+		Entities:              o.Entities,
+		CustomElements:        o.CustomElements,
+		ImageHints:            o.ImageHints,
+		A11y:                  o.A11y,
+		Strict:                o.Strict,
+		PropsSerialization:    o.PropsSerialization,
+		Translate:             o.Translate,
+		Define:                o.Define,
+		PreserveJSXComments:   o.PreserveJSXComments,
+		ContentType:           o.ContentType,
+		PreserveAttributeCase: o.PreserveAttributeCase,
+		RenderTelemetry:       o.RenderTelemetry,
+		IslandMarkers:         o.IslandMarkers,
+		StampVersion:          o.StampVersion,
+		IsPage:                o.IsPage,
+		Route:                 o.Route,
+		DataFrontmatter:       o.DataFrontmatter,
+		FullOutput:            o.FullOutput,
+		CompactOutput:         o.CompactOutput,
+		ModuleFormat:          o.ModuleFormat,
+		MetadataFormat:        o.MetadataFormat,
+		GlobalName:            o.GlobalName,
+		RuntimeGlobal:         o.RuntimeGlobal,
+		Banner:                o.Banner,
+		Footer:                o.Footer,
+		ScopeRootElements:     o.ScopeRootElements,
+		ScopedStyleStrategy:   o.ScopedStyleStrategy,
+		UnusedSelectors:       o.UnusedSelectors,
+		TrimWhitespace:        o.TrimWhitespace,
+		CSPNonceVar:           o.CSPNonceVar,
+		IslandBudget:          o.IslandBudget,
+		InlineScriptBudget:    o.InlineScriptBudget,
+		MaxInputSize:          o.MaxInputSize,
+		MaxNodes:              o.MaxNodes,
+		MaxExpressionDepth:    o.MaxExpressionDepth,
+		Timeout:               time.Duration(o.Timeout) * time.Millisecond,
+	}
+	opts.Normalize()
+	return opts
+}
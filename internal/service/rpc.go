@@ -0,0 +1,296 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+
+	astro "github.com/snowpackjs/astro/internal"
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeCompileError   = -32000
+	codeCancelled      = -32800
+)
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *responseError  `json:"error,omitempty"`
+}
+
+type responseError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+type cancelParams struct {
+	ID json.RawMessage `json:"id"`
+}
+
+type VersionResult struct {
+	Version string `json:"version"`
+}
+
+// Serve answers JSON-RPC 2.0 requests read from r, one message per line, until
+// r is exhausted, a "shutdown" request arrives or ctx is done. Requests run
+// concurrently, so responses may be written out of order. A request still
+// running can be stopped with a "$/cancelRequest" notification.
+func Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s := &server{w: w, running: make(map[string]context.CancelFunc)}
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<30)
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return nil
+		}
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var req request
+		if err := json.Unmarshal(line, &req); err != nil {
+			s.reply(nil, nil, &responseError{Code: codeParseError, Message: err.Error()})
+			continue
+		}
+		if req.JSONRPC != "2.0" || req.Method == "" {
+			s.reply(req.ID, nil, &responseError{Code: codeInvalidRequest, Message: "invalid request"})
+			continue
+		}
+		switch req.Method {
+		case "shutdown":
+			s.reply(req.ID, struct{}{}, nil)
+			return nil
+		case "$/cancelRequest":
+			var params cancelParams
+			if err := json.Unmarshal(req.Params, &params); err == nil {
+				s.cancel(params.ID)
+			}
+			continue
+		}
+
+		reqCtx, reqCancel := context.WithCancel(ctx)
+		s.start(req.ID, reqCancel)
+		wg.Add(1)
+		go func(req request) {
+			defer wg.Done()
+			defer s.finish(req.ID)
+			result, err := handle(reqCtx, req)
+			if req.ID == nil {
+				return
+			}
+			s.reply(req.ID, result, err)
+		}(req)
+	}
+	return scanner.Err()
+}
+
+type server struct {
+	mu      sync.Mutex
+	w       io.Writer
+	running map[string]context.CancelFunc
+}
+
+func (s *server) reply(id json.RawMessage, result interface{}, err *responseError) {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	res := response{JSONRPC: "2.0", ID: id, Result: result, Error: err}
+	if err == nil && result == nil {
+		res.Result = struct{}{}
+	}
+	b, _ := json.Marshal(res)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.w.Write(append(b, '\n'))
+}
+
+func (s *server) start(id json.RawMessage, cancel context.CancelFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running[string(id)] = cancel
+}
+
+func (s *server) finish(id json.RawMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cancel, ok := s.running[string(id)]; ok {
+		cancel()
+		delete(s.running, string(id))
+	}
+}
+
+func (s *server) cancel(id json.RawMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cancel, ok := s.running[string(id)]; ok {
+		cancel()
+	}
+}
+
+func handle(ctx context.Context, req request) (interface{}, *responseError) {
+	switch req.Method {
+	case "compile":
+		var params CompileParams
+		if err := unmarshalParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		result, err := Compile(ctx, params)
+		if err != nil {
+			return nil, toResponseError(ctx, err)
+		}
+		return result, nil
+	case "compileProject":
+		var params CompileProjectParams
+		if err := unmarshalParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		result, err := CompileProject(ctx, params)
+		if err != nil {
+			return nil, toResponseError(ctx, err)
+		}
+		return result, nil
+	case "parse":
+		var params ParseParams
+		if err := unmarshalParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		result, err := Parse(ctx, params)
+		if err != nil {
+			return nil, toResponseError(ctx, err)
+		}
+		return result, nil
+	case "openDocument":
+		var params ParseParams
+		if err := unmarshalParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		result, err := OpenDocument(ctx, params)
+		if err != nil {
+			return nil, toResponseError(ctx, err)
+		}
+		return result, nil
+	case "editDocument":
+		var params EditDocumentParams
+		if err := unmarshalParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		result, err := EditDocument(ctx, params)
+		if err != nil {
+			return nil, toResponseError(ctx, err)
+		}
+		return result, nil
+	case "closeDocument":
+		var params CloseDocumentParams
+		if err := unmarshalParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		if err := CloseDocument(params); err != nil {
+			return nil, toResponseError(ctx, err)
+		}
+		return struct{}{}, nil
+	case "outline":
+		var params ParseParams
+		if err := unmarshalParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		result, err := Outline(ctx, params)
+		if err != nil {
+			return nil, toResponseError(ctx, err)
+		}
+		return result, nil
+	case "semanticTokens":
+		var params ParseParams
+		if err := unmarshalParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		result, err := SemanticTokens(ctx, params)
+		if err != nil {
+			return nil, toResponseError(ctx, err)
+		}
+		return result, nil
+	case "completion":
+		var params CompletionParams
+		if err := unmarshalParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		result, err := Completion(ctx, params)
+		if err != nil {
+			return nil, toResponseError(ctx, err)
+		}
+		return result, nil
+	case "diff":
+		var params DiffParams
+		if err := unmarshalParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		result, err := Diff(ctx, params)
+		if err != nil {
+			return nil, toResponseError(ctx, err)
+		}
+		return result, nil
+	case "version":
+		return VersionResult{Version: astro.Version}, nil
+	}
+	return nil, &responseError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+}
+
+func unmarshalParams(raw json.RawMessage, v interface{}) *responseError {
+	if raw == nil {
+		return &responseError{Code: codeInvalidParams, Message: "missing params"}
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func toResponseError(ctx context.Context, err error) *responseError {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return &responseError{Code: codeCancelled, Message: "request cancelled"}
+	}
+	var serviceError *Error
+	if errors.As(err, &serviceError) {
+		return &responseError{Code: codeCompileError, Message: serviceError.Message, Data: serviceError.Diagnostics}
+	}
+	return &responseError{Code: codeCompileError, Message: err.Error()}
+}
+
+// Call answers a single request outside of a stream, encoding either
+// {"result": ...} or {"error": {code, message, data}} with the codes of Serve
+func Call(ctx context.Context, method string, params []byte) []byte {
+	var raw json.RawMessage
+	if len(params) > 0 {
+		raw = params
+	}
+	result, err := handle(ctx, request{JSONRPC: "2.0", Method: method, Params: raw})
+	b, _ := json.Marshal(struct {
+		Result interface{}    `json:"result,omitempty"`
+		Error  *responseError `json:"error,omitempty"`
+	}{result, err})
+	return b
+}
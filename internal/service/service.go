@@ -0,0 +1,593 @@
+// Package service implements the requests of the long-running compiler
+// process, independent of the protocol they arrive over
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"sync"
+	"time"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/compiler"
+	"github.com/snowpackjs/astro/internal/handler"
+	"github.com/snowpackjs/astro/internal/loc"
+	"github.com/snowpackjs/astro/internal/printer"
+	"github.com/snowpackjs/astro/internal/transform"
+)
+
+type CompileParams struct {
+	Source  string  `json:"source"`
+	Options Options `json:"options"`
+}
+
+type CompileResult struct {
+	Code        string                  `json:"code"`
+	Map         string                  `json:"map,omitempty"`
+	Diagnostics []loc.DiagnosticMessage `json:"diagnostics"`
+	// Resources a page rendering the component needs, for Link headers and
+	// early hints
+	Preloads []Preload `json:"preloads"`
+	// null unless the frontmatter exports prerender as true or false
+	Prerender       *bool            `json:"prerender"`
+	DataFrontmatter *DataFrontmatter `json:"dataFrontmatter,omitempty"`
+	Trace           []TraceEvent     `json:"trace,omitempty"`
+	Version         string           `json:"version"`
+}
+
+// Preload is a transform.Preload
+type Preload struct {
+	URL   string `json:"url"`
+	Kind  string `json:"kind"`
+	Rel   string `json:"rel"`
+	As    string `json:"as"`
+	Start int    `json:"start"`
+}
+
+// TraceEvent is a transform.TraceEvent, with its duration in milliseconds
+type TraceEvent struct {
+	Phase    string  `json:"phase"`
+	Pass     string  `json:"pass"`
+	Decision string  `json:"decision"`
+	Duration float64 `json:"duration"`
+	Nodes    int     `json:"nodes"`
+}
+
+// DataFrontmatter is the YAML or TOML data a content file starts with, when
+// the dataFrontmatter option is set
+type DataFrontmatter struct {
+	Lang    string `json:"lang"`
+	Content string `json:"content"`
+	Start   int    `json:"start"`
+}
+
+// Error is returned when a request fails, with the diagnostics that explain why
+type Error struct {
+	Message     string                  `json:"message"`
+	Diagnostics []loc.DiagnosticMessage `json:"diagnostics"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Compile compiles an Astro component to a JavaScript module
+func Compile(ctx context.Context, params CompileParams) (CompileResult, error) {
+	opts := params.Options.TransformOptions()
+	if params.Options.Trace {
+		opts.Trace = transform.NewTrace(nil)
+	}
+	h := handler.NewHandler(params.Source, opts.Filename)
+	result, err := compiler.Compile(ctx, params.Source, opts, h)
+	if err != nil {
+		return CompileResult{}, &Error{Message: err.Error(), Diagnostics: h.Diagnostics()}
+	}
+
+	code, sourcemap := output(params.Source, opts, result)
+	compiled := CompileResult{
+		Code:        code,
+		Map:         sourcemap,
+		Diagnostics: h.Diagnostics(),
+		Preloads:    make([]Preload, 0, len(result.Preloads)),
+		Prerender:   result.Prerender,
+		Version:     astro.Version,
+	}
+	for _, p := range result.Preloads {
+		compiled.Preloads = append(compiled.Preloads, Preload{URL: p.URL, Kind: p.Kind, Rel: p.Rel, As: p.As, Start: p.Loc.Start})
+	}
+	if data := result.DataFrontmatter; data != nil {
+		compiled.DataFrontmatter = &DataFrontmatter{Lang: data.Lang, Content: data.Content, Start: data.Loc.Start}
+	}
+	if opts.Trace != nil {
+		for _, e := range opts.Trace.Events {
+			compiled.Trace = append(compiled.Trace, TraceEvent{
+				Phase:    e.Phase,
+				Pass:     e.Pass,
+				Decision: e.Decision,
+				Duration: float64(e.Duration) / float64(time.Millisecond),
+				Nodes:    e.Nodes,
+			})
+		}
+	}
+	return compiled, nil
+}
+
+// output returns the code of result and its source map, as the SourceMap
+// option asks for them
+func output(source string, opts transform.TransformOptions, result printer.PrintResult) (string, string) {
+	sourcemap := ""
+	if opts.SourceMap != "" {
+		sourcemap = sourceMapString(source, opts.Filename, result)
+	}
+	code := string(result.Output)
+	switch opts.SourceMap {
+	case "inline", "both":
+		code += "\n//# sourceMappingURL=data:application/json;charset=utf-8;base64," + base64.StdEncoding.EncodeToString([]byte(sourcemap))
+	}
+	if opts.SourceMap == "inline" {
+		sourcemap = ""
+	}
+	return code, sourcemap
+}
+
+type CompileProjectParams struct {
+	Files   []ProjectFile `json:"files"`
+	Options Options       `json:"options"`
+	// Also report unused components, undeclared props and unrendered slots
+	Analyze bool `json:"analyze"`
+}
+
+type ProjectFile struct {
+	Path   string `json:"path"`
+	Source string `json:"source"`
+}
+
+type CompileProjectResult struct {
+	Files    []ProjectFileResult `json:"files"`
+	Manifest ProjectManifest     `json:"manifest"`
+	Analysis []ProjectFinding    `json:"analysis,omitempty"`
+	Version  string              `json:"version"`
+}
+
+type ProjectFinding struct {
+	Path       string                `json:"path"`
+	Diagnostic loc.DiagnosticMessage `json:"diagnostic"`
+}
+
+// ProjectFileResult is a compiled file of a project, with Error set instead
+// of Code if it failed to compile
+type ProjectFileResult struct {
+	Path        string                  `json:"path"`
+	Code        string                  `json:"code,omitempty"`
+	Map         string                  `json:"map,omitempty"`
+	Error       string                  `json:"error,omitempty"`
+	Diagnostics []loc.DiagnosticMessage `json:"diagnostics"`
+	Prerender   *bool                   `json:"prerender,omitempty"`
+}
+
+type ProjectManifest struct {
+	Components []ProjectComponent `json:"components"`
+}
+
+type ProjectComponent struct {
+	Path       string            `json:"path"`
+	Scope      string            `json:"scope"`
+	Imports    []ComponentImport `json:"imports"`
+	Dependents []string          `json:"dependents"`
+}
+
+type ComponentImport struct {
+	Name      string `json:"name,omitempty"`
+	Specifier string `json:"specifier"`
+	// Empty if the project has no file the import resolves to
+	Path string `json:"path,omitempty"`
+}
+
+// CompileProject compiles a set of components together, resolving their
+// imports of each other into a manifest of the project. Files compiled by an
+// earlier request with the same options are reused while their source is the
+// same.
+func CompileProject(ctx context.Context, params CompileProjectParams) (CompileProjectResult, error) {
+	opts := params.Options.TransformOptions()
+	if err := opts.Validate(); err != nil {
+		return CompileProjectResult{}, &Error{Message: err.Error()}
+	}
+	files := make([]compiler.File, 0, len(params.Files))
+	for _, f := range params.Files {
+		files = append(files, compiler.File{Path: f.Path, Source: f.Source})
+	}
+	p := project(params.Options)
+	compile := p.Compile
+	if params.Analyze {
+		compile = p.Analyze
+	}
+	project, err := compile(ctx, files)
+	if err != nil {
+		if ctx.Err() != nil {
+			return CompileProjectResult{}, err
+		}
+		return CompileProjectResult{}, &Error{Message: err.Error()}
+	}
+	result := CompileProjectResult{
+		Files:    make([]ProjectFileResult, 0, len(project.Files)),
+		Manifest: ProjectManifest{Components: make([]ProjectComponent, 0, len(project.Manifest.Components))},
+		Version:  astro.Version,
+	}
+	// Files are in the order they were given
+	for i, f := range project.Files {
+		file := ProjectFileResult{Path: f.Path, Diagnostics: f.Diagnostics}
+		if f.Err != nil {
+			file.Error = f.Err.Error()
+		} else {
+			fileOpts := opts
+			fileOpts.Filename = f.Path
+			file.Code, file.Map = output(params.Files[i].Source, fileOpts, f.Result)
+			file.Prerender = f.Result.Prerender
+		}
+		result.Files = append(result.Files, file)
+	}
+	for _, c := range project.Manifest.Components {
+		component := ProjectComponent{Path: c.Path, Scope: c.Scope, Imports: make([]ComponentImport, 0, len(c.Imports)), Dependents: c.Dependents}
+		for _, i := range c.Imports {
+			component.Imports = append(component.Imports, ComponentImport{Name: i.Name, Specifier: i.Specifier, Path: i.Path})
+		}
+		result.Manifest.Components = append(result.Manifest.Components, component)
+	}
+	if params.Analyze {
+		result.Analysis = make([]ProjectFinding, 0, len(project.Analysis))
+		for _, f := range project.Analysis {
+			result.Analysis = append(result.Analysis, ProjectFinding{Path: f.Path, Diagnostic: f.Diagnostic})
+		}
+	}
+	return result, nil
+}
+
+// MaxProjects is how many projects the service keeps between requests
+const MaxProjects = 16
+
+// Projects are kept between requests by their options, so a file whose source
+// hasn't changed since the last compile with the same options isn't compiled
+// again
+var projects = struct {
+	sync.Mutex
+	byOptions map[string]*compiler.Project
+}{byOptions: make(map[string]*compiler.Project)}
+
+// project returns the project which compiles files with opts, creating it if
+// there is none. When MaxProjects are kept, one of them is dropped first.
+func project(opts Options) *compiler.Project {
+	key, _ := json.Marshal(opts)
+	projects.Lock()
+	defer projects.Unlock()
+	if p, ok := projects.byOptions[string(key)]; ok {
+		return p
+	}
+	if len(projects.byOptions) >= MaxProjects {
+		for k := range projects.byOptions {
+			delete(projects.byOptions, k)
+			break
+		}
+	}
+	p := compiler.NewProject(opts.TransformOptions())
+	projects.byOptions[string(key)] = p
+	return p
+}
+
+func sourceMapString(source string, filename string, result printer.PrintResult) string {
+	sourcemap, _ := json.Marshal(struct {
+		Version        int      `json:"version"`
+		Sources        []string `json:"sources"`
+		SourcesContent []string `json:"sourcesContent"`
+		Mappings       string   `json:"mappings"`
+		Names          []string `json:"names"`
+	}{
+		Version:        3,
+		Sources:        []string{filename},
+		SourcesContent: []string{source},
+		Mappings:       string(result.SourceMapChunk.Buffer),
+		Names:          []string{},
+	})
+	return string(sourcemap)
+}
+
+type ParseParams struct {
+	Source  string  `json:"source"`
+	Options Options `json:"options"`
+}
+
+type ParseResult struct {
+	AST *Node `json:"ast"`
+	// The identifiers the template refers to
+	References []Reference `json:"references"`
+	// The imports of the component tags of the template
+	Definitions []Definition            `json:"definitions"`
+	Diagnostics []loc.DiagnosticMessage `json:"diagnostics"`
+}
+
+type Reference struct {
+	Name  string `json:"name"`
+	Kind  string `json:"kind"`
+	Start int    `json:"start"`
+}
+
+type Definition struct {
+	Name        string `json:"name"`
+	Start       int    `json:"start"`
+	ImportStart int    `json:"importStart"`
+	ImportEnd   int    `json:"importEnd"`
+	Specifier   string `json:"specifier"`
+	ExportName  string `json:"exportName"`
+}
+
+// Node is the JSON form of a node of the syntax tree
+type Node struct {
+	Type       string      `json:"type"`
+	Name       string      `json:"name,omitempty"`
+	Value      string      `json:"value,omitempty"`
+	Attributes []Attribute `json:"attributes,omitempty"`
+	Children   []*Node     `json:"children,omitempty"`
+	Start      int         `json:"start"`
+}
+
+type Attribute struct {
+	Kind  string `json:"kind"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	Start int    `json:"start"`
+}
+
+// Parse parses an Astro component without transforming it
+func Parse(ctx context.Context, params ParseParams) (ParseResult, error) {
+	doc, h, err := parse(ctx, params)
+	if err != nil {
+		return ParseResult{}, err
+	}
+	defer doc.Release()
+	return parseResult(doc, h), nil
+}
+
+// parseResult returns the ParseResult of doc, whose warnings h has
+func parseResult(doc *astro.Node, h *handler.Handler) ParseResult {
+	references := make([]Reference, 0)
+	for _, r := range transform.References(doc) {
+		references = append(references, Reference{Name: r.Name, Kind: r.Kind, Start: r.Loc.Start})
+	}
+	definitions := make([]Definition, 0)
+	for _, d := range transform.ComponentDefinitions(doc) {
+		definitions = append(definitions, Definition{
+			Name:        d.Name,
+			Start:       d.Loc.Start,
+			ImportStart: d.Import.Loc.Start,
+			ImportEnd:   d.Import.End(),
+			Specifier:   d.Specifier,
+			ExportName:  d.ExportName,
+		})
+	}
+	return ParseResult{AST: makeNode(doc), References: references, Definitions: definitions, Diagnostics: h.Diagnostics()}
+}
+
+type OutlineResult struct {
+	Symbols     []Symbol                `json:"symbols"`
+	Diagnostics []loc.DiagnosticMessage `json:"diagnostics"`
+}
+
+type Symbol struct {
+	Name     string   `json:"name"`
+	Kind     string   `json:"kind"`
+	Start    int      `json:"start"`
+	End      int      `json:"end"`
+	Children []Symbol `json:"children"`
+}
+
+// Outline returns the document symbols of an Astro component for editor
+// outlines and folding ranges, which only takes parsing it
+func Outline(ctx context.Context, params ParseParams) (OutlineResult, error) {
+	doc, h, err := parse(ctx, params)
+	if err != nil {
+		return OutlineResult{}, err
+	}
+	defer doc.Release()
+	return OutlineResult{Symbols: makeSymbols(transform.Outline(params.Source, doc)), Diagnostics: h.Diagnostics()}, nil
+}
+
+func makeSymbols(symbols []transform.Symbol) []Symbol {
+	result := make([]Symbol, 0, len(symbols))
+	for _, s := range symbols {
+		result = append(result, Symbol{Name: s.Name, Kind: s.Kind, Start: s.Start, End: s.End, Children: makeSymbols(s.Children)})
+	}
+	return result
+}
+
+type SemanticTokensResult struct {
+	Legend SemanticTokensLegend `json:"legend"`
+	Data   []int                `json:"data"`
+}
+
+type SemanticTokensLegend struct {
+	TokenTypes     []string `json:"tokenTypes"`
+	TokenModifiers []string `json:"tokenModifiers"`
+}
+
+// SemanticTokens returns the semantic tokens of an Astro component in the
+// encoding of the Language Server Protocol, which only takes tokenizing it
+func SemanticTokens(ctx context.Context, params ParseParams) (SemanticTokensResult, error) {
+	if err := ctx.Err(); err != nil {
+		return SemanticTokensResult{}, err
+	}
+	return SemanticTokensResult{
+		Legend: SemanticTokensLegend{TokenTypes: astro.SemanticTokenTypes, TokenModifiers: []string{}},
+		Data:   astro.SemanticTokens(params.Source),
+	}, nil
+}
+
+type CompletionParams struct {
+	Source string `json:"source"`
+	Offset int    `json:"offset"`
+}
+
+type CompletionResult struct {
+	Kind       string   `json:"kind"`
+	Prefix     string   `json:"prefix"`
+	Start      int      `json:"start"`
+	Tag        string   `json:"tag"`
+	Attribute  string   `json:"attribute"`
+	Attributes []string `json:"attributes"`
+	Parents    []string `json:"parents"`
+	Scope      []string `json:"scope"`
+}
+
+// Completion returns what can be typed at an offset of an Astro component,
+// which only takes tokenizing the source before it
+func Completion(ctx context.Context, params CompletionParams) (CompletionResult, error) {
+	if err := ctx.Err(); err != nil {
+		return CompletionResult{}, err
+	}
+	c := astro.Completion(params.Source, params.Offset)
+	return CompletionResult{
+		Kind:       c.Kind,
+		Prefix:     c.Prefix,
+		Start:      c.Start,
+		Tag:        c.Tag,
+		Attribute:  c.Attribute,
+		Attributes: c.Attributes,
+		Parents:    c.Parents,
+		Scope:      c.Scope,
+	}, nil
+}
+
+type DiffParams struct {
+	Source  string  `json:"source"`
+	Options Options `json:"options"`
+	// What to compare the compile with Options to: the summary of an earlier
+	// compile, like one recorded before a compiler upgrade, or else a compile
+	// with other options. With neither, there are no changes.
+	Summary *Summary `json:"summary,omitempty"`
+	Before  *Options `json:"before,omitempty"`
+}
+
+// Summary is the JSON form of compiler.Summary, which can be recorded and
+// passed back as the summary of a later diff
+type Summary struct {
+	Version string   `json:"version"`
+	Imports []string `json:"imports"`
+	Islands []string `json:"islands"`
+	Styles  []string `json:"styles"`
+	Scripts []string `json:"scripts"`
+	Output  string   `json:"output"`
+}
+
+type DiffResult struct {
+	Summary     Summary                 `json:"summary"`
+	Changes     []Change                `json:"changes"`
+	Diagnostics []loc.DiagnosticMessage `json:"diagnostics"`
+}
+
+type Change struct {
+	Kind   string `json:"kind"`
+	Change string `json:"change"`
+	Value  string `json:"value,omitempty"`
+}
+
+// Diff compiles an Astro component and reports how its module differs from
+// an earlier compile of it, at the level of imports, islands, styles and
+// scripts, to audit the impact of a compiler upgrade or of new options
+func Diff(ctx context.Context, params DiffParams) (DiffResult, error) {
+	summarize := func(options Options) (compiler.Summary, *handler.Handler, error) {
+		opts := options.TransformOptions()
+		h := handler.NewHandler(params.Source, opts.Filename)
+		summary, err := compiler.Summarize(ctx, params.Source, opts, h)
+		if err != nil {
+			return compiler.Summary{}, h, &Error{Message: err.Error(), Diagnostics: h.Diagnostics()}
+		}
+		return summary, h, nil
+	}
+	after, h, err := summarize(params.Options)
+	if err != nil {
+		return DiffResult{}, err
+	}
+	before := after
+	switch {
+	case params.Summary != nil:
+		before = compiler.Summary(*params.Summary)
+	case params.Before != nil:
+		if before, _, err = summarize(*params.Before); err != nil {
+			return DiffResult{}, err
+		}
+	}
+	result := DiffResult{Summary: Summary(after), Changes: make([]Change, 0), Diagnostics: h.Diagnostics()}
+	for _, c := range compiler.Diff(before, after) {
+		result.Changes = append(result.Changes, Change(c))
+	}
+	return result, nil
+}
+
+func parse(ctx context.Context, params ParseParams) (*astro.Node, *handler.Handler, error) {
+	opts := params.Options.TransformOptions()
+	h := handler.NewHandler(params.Source, opts.Filename)
+	doc, err := compiler.Parse(ctx, params.Source, opts, h)
+	if err != nil {
+		return nil, nil, &Error{Message: err.Error(), Diagnostics: h.Diagnostics()}
+	}
+	return doc, h, nil
+}
+
+var nodeTypes = map[astro.NodeType]string{
+	astro.TextNode:                  "text",
+	astro.DocumentNode:              "root",
+	astro.ElementNode:               "element",
+	astro.CommentNode:               "comment",
+	astro.DoctypeNode:               "doctype",
+	astro.FrontmatterNode:           "frontmatter",
+	astro.ExpressionNode:            "expression",
+	astro.ProcessingInstructionNode: "processing-instruction",
+	astro.CDATANode:                 "cdata",
+}
+
+var attributeKinds = map[astro.AttributeType]string{
+	astro.QuotedAttribute:          "quoted",
+	astro.EmptyAttribute:           "empty",
+	astro.ExpressionAttribute:      "expression",
+	astro.SpreadAttribute:          "spread",
+	astro.ShorthandAttribute:       "shorthand",
+	astro.TemplateLiteralAttribute: "template-literal",
+}
+
+func makeNode(n *astro.Node) *Node {
+	node := &Node{Type: nodeTypes[n.Type]}
+	if len(n.Loc) > 0 {
+		node.Start = n.Loc[0].Start
+	}
+	switch {
+	case n.Type != astro.ElementNode:
+		node.Value = n.Data
+	case n.Expression:
+		node.Type = "expression"
+	case n.Fragment:
+		node.Type = "fragment"
+		node.Name = n.Data
+	case n.Component:
+		node.Type = "component"
+		node.Name = n.Data
+	case n.CustomElement:
+		node.Type = "custom-element"
+		node.Name = n.Data
+	default:
+		node.Name = n.Data
+	}
+	for _, attr := range n.Attr {
+		if attr.Key == astro.ImplicitNodeMarker {
+			continue
+		}
+		node.Attributes = append(node.Attributes, Attribute{
+			Kind:  attributeKinds[attr.Type],
+			Name:  attr.Key,
+			Value: attr.Val,
+			Start: attr.KeyLoc.Start,
+		})
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		node.Children = append(node.Children, makeNode(c))
+	}
+	return node
+}
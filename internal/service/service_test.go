@@ -0,0 +1,385 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/loc"
+)
+
+func TestServe(t *testing.T) {
+	input := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"version"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"compile","params":{"source":"<h1>{title}</h1>","options":{"sourcemap":"external"}}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"compile","params":{"source":"<h1>","options":{"as":"page"}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"parse","params":{"source":"<h1 class=\"title\">{title}</h1>","options":{"as":"fragment"}}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"unknown","params":{}}`,
+		`{"jsonrpc":"2.0","method":"compile","params":{"source":"<h1></h1>"}}`,
+		`not json`,
+		`{"jsonrpc":"2.0","id":6,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","id":7,"method":"version"}`,
+	}, "\n")
+
+	var output strings.Builder
+	if err := Serve(context.Background(), strings.NewReader(input), &output); err != nil {
+		t.Fatal(err)
+	}
+
+	responses := make(map[string]response)
+	scanner := bufio.NewScanner(strings.NewReader(output.String()))
+	for scanner.Scan() {
+		var res struct {
+			response
+			Result json.RawMessage `json:"result"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &res); err != nil {
+			t.Fatalf("invalid response %q: %v", scanner.Text(), err)
+		}
+		res.response.Result = res.Result
+		responses[string(res.ID)] = res.response
+	}
+
+	if len(responses) != 7 {
+		t.Fatalf("expected 7 responses, got %d:\n%s", len(responses), output.String())
+	}
+	if _, ok := responses["7"]; ok {
+		t.Error("expected no response after shutdown")
+	}
+
+	var version VersionResult
+	json.Unmarshal(responses["1"].Result.(json.RawMessage), &version)
+	if version.Version != astro.Version {
+		t.Errorf("expected version %q, got %q", astro.Version, version.Version)
+	}
+
+	var compiled CompileResult
+	json.Unmarshal(responses["2"].Result.(json.RawMessage), &compiled)
+	if !strings.Contains(compiled.Code, "<h1>${title}</h1>") {
+		t.Errorf("unexpected code:\n%s", compiled.Code)
+	}
+	if !strings.Contains(compiled.Map, `"mappings"`) {
+		t.Errorf("expected a source map, got %q", compiled.Map)
+	}
+
+	if res := responses["3"]; res.Error == nil || res.Error.Code != codeCompileError {
+		t.Errorf("expected a compile error for invalid options, got %+v", res)
+	}
+
+	var parsed ParseResult
+	json.Unmarshal(responses["4"].Result.(json.RawMessage), &parsed)
+	if len(parsed.AST.Children) != 1 {
+		t.Fatalf("expected one root child, got %+v", parsed.AST)
+	}
+	h1 := parsed.AST.Children[0]
+	if h1.Name != "h1" || len(h1.Attributes) != 1 || h1.Attributes[0].Value != "title" {
+		t.Errorf("unexpected element %+v", h1)
+	}
+	if len(h1.Children) != 1 || h1.Children[0].Type != "expression" {
+		t.Errorf("expected an expression child, got %+v", h1.Children)
+	}
+	if len(parsed.References) != 1 || parsed.References[0].Name != "title" || parsed.References[0].Start != 19 {
+		t.Errorf("expected a reference to title, got %+v", parsed.References)
+	}
+
+	if res := responses["5"]; res.Error == nil || res.Error.Code != codeMethodNotFound {
+		t.Errorf("expected method not found, got %+v", res)
+	}
+	if res := responses["null"]; res.Error == nil || res.Error.Code != codeParseError {
+		t.Errorf("expected a parse error, got %+v", res)
+	}
+}
+
+func TestServeStdout(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	// Anything else printed to stdout would break the protocol
+	input := `{"jsonrpc":"2.0","id":1,"method":"compile","params":{"source":"<script><!-- x --></script>"}}`
+	err = Serve(context.Background(), strings.NewReader(input), w)
+	w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, _ := io.ReadAll(r)
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected one line of output, got:\n%s", output)
+	}
+	var res response
+	if err := json.Unmarshal([]byte(lines[0]), &res); err != nil || res.Error != nil {
+		t.Errorf("unexpected response %s", lines[0])
+	}
+}
+
+func TestCompileCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := Compile(ctx, CompileParams{Source: "<h1></h1>"})
+	if err == nil {
+		t.Fatal("expected an error for a cancelled context")
+	}
+	if res := toResponseError(ctx, err); res.Code != codeCancelled {
+		t.Errorf("expected code %d, got %d", codeCancelled, res.Code)
+	}
+}
+
+func TestCompileTrace(t *testing.T) {
+	result, err := Compile(context.Background(), CompileParams{Source: "<h1></h1>", Options: Options{Trace: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Trace) == 0 || result.Trace[len(result.Trace)-1].Phase != "print" {
+		t.Errorf("expected a trace which ends with the print phase, got %+v", result.Trace)
+	}
+	if result, _ := Compile(context.Background(), CompileParams{Source: "<h1></h1>"}); result.Trace != nil {
+		t.Errorf("expected no trace unless it is asked for, got %+v", result.Trace)
+	}
+}
+
+func TestCompilePreloads(t *testing.T) {
+	source := "---\nimport './global.css';\nimport Counter from './Counter.jsx';\n---\n<Counter client:visible /><script hoist type=\"module\" src=\"/a.js\"></script>"
+	result, err := Compile(context.Background(), CompileParams{Source: source})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Preload{
+		{URL: "./global.css", Kind: "style", Rel: "preload", As: "style", Start: 4},
+		{URL: "./Counter.jsx", Kind: "component", Rel: "modulepreload", As: "script", Start: 68},
+		{URL: "/a.js", Kind: "script", Rel: "modulepreload", As: "script", Start: 94},
+	}
+	if fmt.Sprint(result.Preloads) != fmt.Sprint(want) {
+		t.Errorf("\nFAIL: preloads\n  want: %+v\n  got:  %+v", want, result.Preloads)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	source := "---\nimport Counter from './Counter.jsx';\n---\n{import.meta.env.DEV && <Counter client:load />}"
+	recorded, err := Diff(context.Background(), DiffParams{Source: source})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recorded.Changes) != 0 || fmt.Sprint(recorded.Summary.Islands) != "[Counter client:load]" {
+		t.Errorf("unexpected diff without a summary %+v", recorded)
+	}
+	options := Options{Define: map[string]string{"import.meta.env.DEV": "false"}}
+	for _, params := range []DiffParams{
+		{Source: source, Options: options, Summary: &recorded.Summary},
+		{Source: source, Options: options, Before: &Options{}},
+	} {
+		result, err := Diff(context.Background(), params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "[{import removed ./Counter.jsx} {island removed Counter client:load} {output changed }]"
+		if got := fmt.Sprint(result.Changes); got != want {
+			t.Errorf("\nFAIL: diff\n  want: %s\n  got:  %s", want, got)
+		}
+	}
+}
+
+func TestCall(t *testing.T) {
+	var res struct {
+		Result *CompileResult `json:"result"`
+		Error  *responseError `json:"error"`
+	}
+	json.Unmarshal(Call(context.Background(), "compile", []byte(`{"source":"<p>{a}</p>","options":{"as":"fragment"}}`)), &res)
+	if res.Error != nil || res.Result == nil || !strings.Contains(res.Result.Code, "<p>${a}</p>") {
+		t.Errorf("unexpected compile response %+v", res)
+	}
+
+	res.Result, res.Error = nil, nil
+	json.Unmarshal(Call(context.Background(), "compile", nil), &res)
+	if res.Error == nil || res.Error.Code != codeInvalidParams {
+		t.Errorf("expected invalid params, got %+v", res.Error)
+	}
+}
+
+func TestCompileProject(t *testing.T) {
+	var res struct {
+		Result *CompileProjectResult `json:"result"`
+		Error  *responseError        `json:"error"`
+	}
+	params := `{"files":[{"path":"src/pages/index.astro","source":"---\nimport Card from '../components/Card.astro';\n---\n<Card />"},{"path":"src/components/Card.astro","source":"<div>{"}]}`
+	json.Unmarshal(Call(context.Background(), "compileProject", []byte(params)), &res)
+	if res.Error != nil || res.Result == nil || len(res.Result.Files) != 2 {
+		t.Fatalf("unexpected compileProject response %+v", res)
+	}
+	if page := res.Result.Files[0]; page.Error != "" || !strings.Contains(page.Code, "$$renderComponent") {
+		t.Errorf("unexpected page result %+v", page)
+	}
+	manifest := res.Result.Manifest.Components
+	if len(manifest) != 2 || manifest[0].Imports[0].Path != "src/components/Card.astro" || len(manifest[1].Dependents) != 1 || manifest[1].Dependents[0] != "src/pages/index.astro" {
+		t.Errorf("unexpected manifest %+v", manifest)
+	}
+
+	res.Result, res.Error = nil, nil
+	json.Unmarshal(Call(context.Background(), "compileProject", []byte(`{"files":[{"path":"src/components/A.astro","source":"<p />"}],"analyze":true}`)), &res)
+	if res.Error != nil || res.Result == nil || len(res.Result.Analysis) != 1 || res.Result.Analysis[0].Diagnostic.Code != int(loc.WARNING_UNUSED_COMPONENT) {
+		t.Errorf("unexpected analysis %+v", res)
+	}
+
+	res.Result, res.Error = nil, nil
+	json.Unmarshal(Call(context.Background(), "compileProject", []byte(`{"files":[],"options":{"as":"page"}}`)), &res)
+	if res.Error == nil || res.Error.Code != codeCompileError {
+		t.Errorf("expected a compile error, got %+v", res.Error)
+	}
+}
+
+func TestProjectCache(t *testing.T) {
+	a := project(Options{As: "page"})
+	if project(Options{As: "page"}) != a {
+		t.Error("expected a project to be kept for the same options")
+	}
+	if project(Options{As: "component"}) == a {
+		t.Error("expected a new project for other options")
+	}
+	for i := 0; i < MaxProjects*2; i++ {
+		project(Options{Site: fmt.Sprintf("https://%d.example.com", i)})
+	}
+	if len(projects.byOptions) > MaxProjects {
+		t.Errorf("expected at most %d projects, got %d", MaxProjects, len(projects.byOptions))
+	}
+}
+
+func TestOutline(t *testing.T) {
+	var res struct {
+		Result *OutlineResult `json:"result"`
+		Error  *responseError `json:"error"`
+	}
+	params := `{"source":"---\nconst a = 1;\n---\n<main><p>{a}</p></main>\n<style>p {}</style>"}`
+	json.Unmarshal(Call(context.Background(), "outline", []byte(params)), &res)
+	if res.Error != nil || res.Result == nil {
+		t.Fatalf("unexpected outline response %+v", res)
+	}
+	got := make([]string, 0)
+	for _, s := range res.Result.Symbols {
+		got = append(got, s.Kind)
+		for _, c := range s.Children {
+			got = append(got, s.Kind+" > "+c.Name)
+		}
+	}
+	want := []string{"frontmatter", "element", "element > p", "style"}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("symbols = %v\nExpected = %v", got, want)
+	}
+	if main := res.Result.Symbols[1]; main.Start != 21 || main.End != 44 {
+		t.Errorf("unexpected range of <main> %d-%d", main.Start, main.End)
+	}
+}
+
+func TestDocument(t *testing.T) {
+	var res struct {
+		Result *DocumentResult `json:"result"`
+		Error  *responseError  `json:"error"`
+	}
+	source := "<main><div>{a}</div></main>"
+	json.Unmarshal(Call(context.Background(), "openDocument", []byte(`{"source":"<main><div>{a}</div></main>"}`)), &res)
+	if res.Error != nil || res.Result == nil || res.Result.Document == 0 || len(res.Result.References) != 1 {
+		t.Fatalf("unexpected openDocument response %+v", res)
+	}
+	document := res.Result.Document
+
+	res.Result, res.Error = nil, nil
+	start := strings.Index(source, "a}")
+	params := fmt.Sprintf(`{"document":%d,"edits":[{"start":%d,"end":%d,"text":"bc"},{"start":%d,"end":%d,"text":"<p>{d}</p>"}]}`, document, start, start+1, start+3, start+3)
+	json.Unmarshal(Call(context.Background(), "editDocument", []byte(params)), &res)
+	if res.Error != nil || res.Result == nil {
+		t.Fatalf("unexpected editDocument response %+v", res)
+	}
+	got := make([]string, 0)
+	for _, r := range res.Result.References {
+		got = append(got, fmt.Sprintf("%s@%d", r.Name, r.Start))
+	}
+	// <main><div>{bc}<p>{d}</p></div></main>
+	if want := []string{"bc@12", "d@19"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("references = %v\nExpected = %v", got, want)
+	}
+
+	var closed struct {
+		Error *responseError `json:"error"`
+	}
+	json.Unmarshal(Call(context.Background(), "closeDocument", []byte(fmt.Sprintf(`{"document":%d}`, document))), &closed)
+	if closed.Error != nil {
+		t.Errorf("unexpected closeDocument error %+v", closed.Error)
+	}
+	res.Result, res.Error = nil, nil
+	json.Unmarshal(Call(context.Background(), "editDocument", []byte(params)), &res)
+	if res.Error == nil || res.Error.Code != codeCompileError {
+		t.Errorf("expected a closed document to be an error, got %+v", res)
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	source := "<main><div>{a}</div><p>b</p></main>"
+	for _, method := range []string{"parse", "outline", "openDocument"} {
+		for _, options := range []string{`{"maxInputSize":10}`, `{"maxNodes":2}`} {
+			var res struct {
+				Error *responseError `json:"error"`
+			}
+			params := fmt.Sprintf(`{"source":%q,"options":%s}`, source, options)
+			json.Unmarshal(Call(context.Background(), method, []byte(params)), &res)
+			if res.Error == nil || res.Error.Code != codeCompileError {
+				t.Errorf("%s with %s: expected an error, got %+v", method, options, res)
+			}
+		}
+	}
+
+	var res struct {
+		Result *DocumentResult `json:"result"`
+		Error  *responseError  `json:"error"`
+	}
+	json.Unmarshal(Call(context.Background(), "openDocument", []byte(fmt.Sprintf(`{"source":%q,"options":{"maxInputSize":40}}`, source))), &res)
+	if res.Error != nil || res.Result == nil {
+		t.Fatalf("unexpected openDocument response %+v", res)
+	}
+	start := strings.Index(source, "b<")
+	params := fmt.Sprintf(`{"document":%d,"edits":[{"start":%d,"end":%d,"text":"a longer text"}]}`, res.Result.Document, start, start+1)
+	res.Result, res.Error = nil, nil
+	json.Unmarshal(Call(context.Background(), "editDocument", []byte(params)), &res)
+	if res.Error == nil || res.Error.Code != codeCompileError {
+		t.Errorf("expected an edit past maxInputSize to be an error, got %+v", res)
+	}
+}
+
+func TestSemanticTokens(t *testing.T) {
+	var res struct {
+		Result *SemanticTokensResult `json:"result"`
+		Error  *responseError        `json:"error"`
+	}
+	json.Unmarshal(Call(context.Background(), "semanticTokens", []byte(`{"source":"<p>\n  <Card client:load /></p>"}`)), &res)
+	if res.Error != nil || res.Result == nil {
+		t.Fatalf("unexpected semanticTokens response %+v", res)
+	}
+	want := []int{0, 1, 1, 0, 0, 1, 3, 4, 1, 0, 0, 5, 6, 3, 0, 0, 7, 4, 2, 0, 0, 9, 1, 0, 0}
+	if fmt.Sprint(res.Result.Data) != fmt.Sprint(want) || len(res.Result.Legend.TokenTypes) != 6 {
+		t.Errorf("data = %v\nExpected = %v", res.Result.Data, want)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	var res struct {
+		Result *CompletionResult `json:"result"`
+		Error  *responseError    `json:"error"`
+	}
+	params := `{"source":"---\nconst post = {};\n---\n<a href={post.}>","offset":39}`
+	json.Unmarshal(Call(context.Background(), "completion", []byte(params)), &res)
+	if res.Error != nil || res.Result == nil {
+		t.Fatalf("unexpected completion response %+v", res)
+	}
+	got := *res.Result
+	if got.Kind != "expression" || got.Prefix != "post." || got.Start != 34 || got.Attribute != "href" || fmt.Sprint(got.Scope) != "[post]" {
+		t.Errorf("unexpected completion context %+v", got)
+	}
+}
@@ -0,0 +1,56 @@
+package sourcemap
+
+import (
+	"testing"
+
+	"github.com/snowpackjs/astro/internal/loc"
+)
+
+func TestAstralPlaneColumns(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		loc      int
+		output   string
+		expected string
+	}{
+		{
+			name:     "ascii",
+			source:   "ab<b>x</b>",
+			loc:      2,
+			output:   "ab",
+			expected: "EAAE",
+		},
+		{
+			name:     "emoji counts as two columns",
+			source:   "😀<b>x</b>",
+			loc:      4,
+			output:   "😀",
+			expected: "EAAE",
+		},
+		{
+			name:     "bmp character counts as one column",
+			source:   "é<b>x</b>",
+			loc:      2,
+			output:   "é",
+			expected: "CAAC",
+		},
+		{
+			name:     "emoji on second line",
+			source:   "a\n👍🏽<b>x</b>",
+			loc:      10,
+			output:   "a\n👍🏽",
+			expected: ";IACI",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := MakeChunkBuilder(nil, GenerateLineOffsetTables(tt.source, 2))
+			builder.AddSourceMapping(loc.Loc{Start: tt.loc}, []byte(tt.output))
+			got := string(builder.GenerateChunk([]byte(tt.output)).Buffer)
+			if got != tt.expected {
+				t.Errorf("Mappings = %q\nExpected = %q", got, tt.expected)
+			}
+		})
+	}
+}
@@ -11,8 +11,8 @@ import (
 	"io"
 	"strconv"
 	"strings"
-	"unicode"
 
+	"github.com/snowpackjs/astro/internal/js_scanner"
 	"github.com/snowpackjs/astro/internal/loc"
 	"golang.org/x/net/html/atom"
 )
@@ -223,6 +223,8 @@ func (t Token) String() string {
 type Tokenizer struct {
 	// r is the source of the HTML text.
 	r io.Reader
+	// interned holds the tag names and attribute keys seen so far, see intern.
+	interned map[string]string
 	// tt is the TokenType of the current token.
 	tt            TokenType
 	prevTokenType TokenType
@@ -246,11 +248,10 @@ type Tokenizer struct {
 	// pendingAttr is the attribute key and value currently being tokenized.
 	// When complete, pendingAttr is pushed onto attr. nAttrReturned is
 	// incremented on each call to TagAttr.
-	pendingAttr         [2]loc.Span
-	pendingAttrType     AttributeType
-	attr                [][2]loc.Span
-	attrTypes           []AttributeType
-	attrExpressionStack int
+	pendingAttr     [2]loc.Span
+	pendingAttrType AttributeType
+	attr            [][2]loc.Span
+	attrTypes       []AttributeType
 
 	nAttrReturned int
 	dashCount     int
@@ -263,6 +264,10 @@ type Tokenizer struct {
 	// token: one that treats "<p>" as text instead of an element.
 	// rawTag's contents are lower-cased.
 	rawTag string
+	// rawNested is whether rawTag was set by an attribute like `is:ignore`
+	// rather than by the element itself, in which case the raw text may
+	// contain nested elements with the same name.
+	rawNested bool
 	// stringStartChar is the character that opened the last string: ', ", or `
 	// stringStartChar byte
 	// stringIsOpen will be true while in the context of a string
@@ -274,11 +279,18 @@ type Tokenizer struct {
 	convertNUL bool
 	// allowCDATA is whether CDATA sections are allowed in the current context.
 	allowCDATA bool
+	// commentType is the type of node that the current CommentToken becomes,
+	// since processing instructions and CDATA sections are also comments.
+	commentType NodeType
+	// xml is whether the input is an XML document, which has no void
+	// elements and no raw text elements other than <script> and <style>.
+	xml bool
 }
 
 // AllowCDATA sets whether or not the tokenizer recognizes <![CDATA[foo]]> as
 // the text "foo". The default value is false, which means to recognize it as
-// a bogus comment "<!-- [CDATA[foo]] -->" instead.
+// a bogus comment "<!-- [CDATA[foo]] -->" instead, or in XML documents as a
+// comment with the data "foo" which becomes a CDATANode.
 //
 // Strictly speaking, an HTML5 compliant tokenizer should allow CDATA if and
 // only if tokenizing foreign content, such as MathML and SVG. However,
@@ -363,10 +375,13 @@ func (z *Tokenizer) skipWhiteSpace() {
 	for {
 		c := z.readByte()
 		if z.err != nil {
-			fmt.Printf("Unexpected character in skipWhiteSpace: \"%v\"\n", string(c))
 			return
 		}
-		if !unicode.IsSpace(rune(c)) {
+		// Compare bytes rather than runes, otherwise the continuation bytes of
+		// multi-byte characters (like emoji) could be mistaken for whitespace
+		switch c {
+		case ' ', '\n', '\r', '\t', '\f':
+		default:
 			z.raw.End--
 			return
 		}
@@ -388,11 +403,11 @@ func (z *Tokenizer) readRawOrRCDATA() {
 		z.rawTag = ""
 		return
 	}
+	depth := 0
 loop:
 	for {
 		c := z.readByte()
 		if z.err != nil {
-			fmt.Printf("Unexpected character in loop: \"%v\"\n", string(c))
 			break loop
 		}
 		if c != '<' {
@@ -404,9 +419,21 @@ loop:
 		}
 		if c != '/' {
 			z.raw.End--
+			if z.rawNested && z.hasRawStartTag() {
+				depth++
+			}
 			continue loop
 		}
-		if z.readRawEndTag() || z.err != nil {
+		if z.readRawEndTag() {
+			if depth == 0 {
+				break loop
+			}
+			// Skip past the end tag of a nested element
+			depth--
+			z.raw.End += 3 + len(z.rawTag)
+			continue loop
+		}
+		if z.err != nil {
 			break loop
 		}
 	}
@@ -414,6 +441,27 @@ loop:
 	// A textarea's or title's RCDATA can contain escaped entities.
 	z.textIsRaw = z.rawTag != "textarea" && z.rawTag != "title"
 	z.rawTag = ""
+	z.rawNested = false
+}
+
+// hasRawStartTag returns true if the input continues with a tag like "<foo",
+// where "foo" is z.rawTag. The opening "<" has already been consumed, and the
+// input position is left unchanged.
+func (z *Tokenizer) hasRawStartTag() bool {
+	buf := z.buf[z.raw.End:]
+	if len(buf) <= len(z.rawTag) {
+		return false
+	}
+	for i := 0; i < len(z.rawTag); i++ {
+		if c := buf[i]; c != z.rawTag[i] && c != z.rawTag[i]-('a'-'A') {
+			return false
+		}
+	}
+	switch buf[len(z.rawTag)] {
+	case ' ', '\n', '\r', '\t', '\f', '/', '>':
+		return true
+	}
+	return false
 }
 
 // readRawEndTag attempts to read a tag like "</foo>", where "foo" is z.rawTag.
@@ -433,7 +481,6 @@ func (z *Tokenizer) readRawEndTag() bool {
 	}
 	c := z.readByte()
 	if z.err != nil {
-		fmt.Printf("Unexpected character in readRawEndTag: %v\n", string(c))
 		return false
 	}
 	switch c {
@@ -457,7 +504,6 @@ func (z *Tokenizer) readScript() {
 scriptData:
 	c = z.readByte()
 	if z.err != nil {
-		fmt.Printf("Unexpected character in scriptData: %v\n", string(c))
 		return
 	}
 	if c == '<' {
@@ -468,7 +514,6 @@ scriptData:
 scriptDataLessThanSign:
 	c = z.readByte()
 	if z.err != nil {
-		fmt.Printf("Unexpected character in scriptDataLessThanSign: %v\n", string(c))
 		return
 	}
 	switch c {
@@ -482,7 +527,6 @@ scriptDataLessThanSign:
 
 scriptDataEndTagOpen:
 	if z.err != nil {
-		fmt.Printf("Unexpected character in scriptDataEndTagOpen: %v\n", string(c))
 		return
 	}
 	if z.readRawEndTag() {
@@ -493,7 +537,6 @@ scriptDataEndTagOpen:
 scriptDataEscapeStart:
 	c = z.readByte()
 	if z.err != nil {
-		fmt.Printf("Unexpected character in scriptDataEscapeStart: %v\n", string(c))
 		return
 	}
 	if c == '-' {
@@ -505,7 +548,6 @@ scriptDataEscapeStart:
 scriptDataEscapeStartDash:
 	c = z.readByte()
 	if z.err != nil {
-		fmt.Printf("Unexpected character in scriptDataEscapeStartDash: %v\n", string(c))
 		return
 	}
 	if c == '-' {
@@ -517,7 +559,6 @@ scriptDataEscapeStartDash:
 scriptDataEscaped:
 	c = z.readByte()
 	if z.err != nil {
-		fmt.Printf("Unexpected character in scriptDataEscaped: %v\n", string(c))
 		return
 	}
 	switch c {
@@ -529,7 +570,6 @@ scriptDataEscaped:
 	goto scriptDataEscaped
 
 scriptDataEscapedDash:
-	fmt.Printf("Unexpected character in scriptDataEscapedDash: %v\n", string(c))
 	c = z.readByte()
 	if z.err != nil {
 		return
@@ -545,7 +585,6 @@ scriptDataEscapedDash:
 scriptDataEscapedDashDash:
 	c = z.readByte()
 	if z.err != nil {
-		fmt.Printf("Unexpected character in scriptDataEscapedDashDash: %v\n", string(c))
 		return
 	}
 	switch c {
@@ -561,7 +600,6 @@ scriptDataEscapedDashDash:
 scriptDataEscapedLessThanSign:
 	c = z.readByte()
 	if z.err != nil {
-		fmt.Printf("Unexpected character in scriptDataEscapedLessThanSign: %v\n", string(c))
 		return
 	}
 	if c == '/' {
@@ -575,7 +613,6 @@ scriptDataEscapedLessThanSign:
 
 scriptDataEscapedEndTagOpen:
 	if z.err != nil {
-		fmt.Printf("Unexpected character in scriptDataEscapedEndTagOpen: %v\n", string(c))
 		return
 	}
 	if z.readRawEndTag() || z.err != nil {
@@ -588,7 +625,6 @@ scriptDataDoubleEscapeStart:
 	for i := 0; i < len("script"); i++ {
 		c = z.readByte()
 		if z.err != nil {
-			fmt.Printf("Unexpected character in scriptDataDoubleEscapeStart: %v\n", string(c))
 			return
 		}
 		if c != "script"[i] && c != "SCRIPT"[i] {
@@ -610,7 +646,6 @@ scriptDataDoubleEscapeStart:
 scriptDataDoubleEscaped:
 	c = z.readByte()
 	if z.err != nil {
-		fmt.Printf("Unexpected character in scriptDataDoubleEscaped: %v\n", string(c))
 		return
 	}
 	switch c {
@@ -624,7 +659,6 @@ scriptDataDoubleEscaped:
 scriptDataDoubleEscapedDash:
 	c = z.readByte()
 	if z.err != nil {
-		fmt.Printf("Unexpected character in scriptDataDoubleEscapedDash: %v\n", string(c))
 		return
 	}
 	switch c {
@@ -638,7 +672,6 @@ scriptDataDoubleEscapedDash:
 scriptDataDoubleEscapedDashDash:
 	c = z.readByte()
 	if z.err != nil {
-		fmt.Printf("Unexpected character in scriptDataDoubleEscapedDashDash: %v\n", string(c))
 		return
 	}
 	switch c {
@@ -654,7 +687,6 @@ scriptDataDoubleEscapedDashDash:
 scriptDataDoubleEscapedLessThanSign:
 	c = z.readByte()
 	if z.err != nil {
-		fmt.Printf("Unexpected character in scriptDataDoubleEscapedLessThanSign: %v\n", string(c))
 		return
 	}
 	if c == '/' {
@@ -669,7 +701,6 @@ scriptDataDoubleEscapeEnd:
 		goto scriptDataEscaped
 	}
 	if z.err != nil {
-		fmt.Printf("Unexpected character in scriptDataDoubleEscapeEnd: %v\n", string(c))
 		return
 	}
 	goto scriptDataDoubleEscaped
@@ -737,78 +768,26 @@ func (z *Tokenizer) readUntilCloseAngle() {
 	}
 }
 
-// readString reads until a JavaScript string is closed.
+// readString reads until the JavaScript string or template literal starting
+// with the quote just read is closed.
 func (z *Tokenizer) readString(c byte) {
-	switch c {
-	// single quote (ends on newline)
-	case '\'':
-		z.readUntilChar([]byte{'\'', '\r', '\n'})
-	// double quote (ends on newline)
-	case '"':
-		z.readUntilChar([]byte{'"', '\r', '\n'})
-	// template literal
-	case '`':
-		// Note that we DO NOT have to handle `${}` here because our expression
-		// behavior already handles `{}`. Technically incorrect, but it works.
-		z.readUntilChar([]byte{'`'})
-	}
-}
-
-// generic utilty to look ahead until the first char is encountered from given splice
-func (z *Tokenizer) readUntilChar(chars []byte) {
-find_next:
-	for {
-		c := z.readByte()
-		// fail on error
-		if z.err != nil {
-			z.data.End = z.raw.End - 1
-			return
-		}
-		// handle escape char \
-		if c == '\\' {
-			z.raw.End++
-			c = z.buf[z.data.Start : z.data.Start+1][0]
-			// if this is a match but it’s escaped, skip and move to the next char
-			for _, v := range chars {
-				if c == v {
-					z.raw.End++
-					continue find_next
-				}
-			}
-		}
-		// match found!
-		for _, v := range chars {
-			if c == v {
-				z.data.End = z.raw.End
-				return
-			}
-		}
-	}
+	_, z.raw.End = js_scanner.Skip(z.buf, z.raw.End-1)
+	z.data.End = z.raw.End
 }
 
-// read RegExp expressions and comments (starting from '/' byte)
+// readCommentOrRegExp reads until the JavaScript comment or regular expression
+// starting with the '/' just read is closed.
 func (z *Tokenizer) readCommentOrRegExp() {
-	c := z.readByte() // find next character after '/' to know how to handle it
-	switch c {
-	// single-line commment (ends on newline)
-	case '/':
-		z.readUntilChar([]byte{'\r', '\n'})
-	// multi-line comment
-	case '*':
-		// look for "*/"
-		for {
-			z.readUntilChar([]byte{'*'})
-			c = z.readByte()
-			if c == '/' {
-				z.data.End = z.raw.End
-				return
-			}
+	kind, end := js_scanner.Skip(z.buf, z.raw.End-1)
+	// A line comment keeps its line break, which ends it in the output too
+	if kind == js_scanner.LineCommentSpan && end < len(z.buf) {
+		if z.buf[end] == '\r' && end+1 < len(z.buf) && z.buf[end+1] == '\n' {
+			end++
 		}
-	// RegExp
-	default:
-		z.raw.End--
-		z.readUntilChar([]byte{'/', '\r', '\n'})
+		end++
 	}
+	z.raw.End = end
+	z.data.End = z.raw.End
 }
 
 // readMarkupDeclaration reads the next token starting with "<!". It might be
@@ -832,15 +811,39 @@ func (z *Tokenizer) readMarkupDeclaration() TokenType {
 	if z.readDoctype() {
 		return DoctypeToken
 	}
-	if z.allowCDATA && z.readCDATA() {
-		z.convertNUL = true
-		return TextToken
+	if (z.allowCDATA || z.xml) && z.readCDATA() {
+		if z.allowCDATA {
+			z.convertNUL = true
+			return TextToken
+		}
+		z.commentType = CDATANode
+		return CommentToken
 	}
 	// It's a bogus comment.
 	z.readUntilCloseAngle()
 	return CommentToken
 }
 
+// readProcessingInstruction reads a "<?target data?>" processing instruction
+// of an XML document. HTML treats it as a bogus comment which ends at the
+// first ">", but XML keeps the whole instruction so it can be printed as
+// authored. The opening "<?" has already been consumed.
+func (z *Tokenizer) readProcessingInstruction() {
+	z.data.Start = z.raw.End
+	z.commentType = ProcessingInstructionNode
+	for {
+		c := z.readByte()
+		if z.err != nil {
+			z.data.End = z.raw.End
+			return
+		}
+		if c == '>' && z.buf[z.raw.End-2] == '?' && z.raw.End-2 >= z.data.Start {
+			z.data.End = z.raw.End - len("?>")
+			return
+		}
+	}
+}
+
 // readDoctype attempts to read a doctype declaration and returns true if
 // successful. The opening "<!" has already been consumed.
 func (z *Tokenizer) readDoctype() bool {
@@ -930,7 +933,10 @@ loop:
 
 		x := z.attr[i]
 		key := z.buf[x[0].Start:x[0].End]
-		for i := 0; i < len(key) && i < len(s); i++ {
+		if len(key) != len(s) {
+			continue loop
+		}
+		for i := 0; i < len(key); i++ {
 			c := key[i]
 			if c != s[i] {
 				continue loop
@@ -947,6 +953,10 @@ func (z *Tokenizer) readStartTag() TokenType {
 	z.readTag(true)
 	// Several tags flag the tokenizer's next token as raw.
 	c, raw := z.buf[z.data.Start], false
+	if z.xml {
+		c = 0
+		raw = z.startTagIn("script", "style")
+	}
 	switch c {
 	case 'i':
 		raw = z.startTagIn("iframe")
@@ -961,8 +971,9 @@ func (z *Tokenizer) readStartTag() TokenType {
 	case 'x':
 		raw = z.startTagIn("xmp")
 	}
-	if !raw {
-		raw = z.hasTag("data-astro-raw")
+	if !raw && (z.hasTag("data-astro-raw") || z.hasTag("is:ignore")) {
+		raw = true
+		z.rawNested = true
 	}
 	if raw {
 		z.rawTag = string(z.buf[z.data.Start:z.data.End])
@@ -970,7 +981,7 @@ func (z *Tokenizer) readStartTag() TokenType {
 
 	// HTML void tags list: https://www.w3.org/TR/2011/WD-html-markup-20110113/syntax.html#syntax-elements
 	// Note: self-closing tags in SVG and MathML work differently; handled below
-	if z.startTagIn("area", "base", "br", "col", "command", "embed", "hr", "img", "input", "keygen", "link", "meta", "param", "source", "track", "wbr") {
+	if !z.xml && z.startTagIn("area", "base", "br", "col", "command", "embed", "hr", "img", "input", "keygen", "link", "meta", "param", "source", "track", "wbr") {
 		return SelfClosingTagToken
 	}
 	// Look for a self-closing token that’s not in the list above (e.g. "<svg><path/></svg>")
@@ -1026,7 +1037,6 @@ func (z *Tokenizer) readTag(saveAttr bool) {
 	z.pendingAttrType = QuotedAttribute
 	z.attr = z.attr[:0]
 	z.attrTypes = z.attrTypes[:0]
-	z.attrExpressionStack = 0
 	z.nAttrReturned = 0
 	// Read the tag name and attribute key/value pairs.
 	z.readTagName()
@@ -1090,7 +1100,6 @@ func (z *Tokenizer) readTagAttrKey() {
 		case '{':
 			z.pendingAttr[0].Start = z.raw.End
 			z.pendingAttrType = ShorthandAttribute
-			z.attrExpressionStack = 1
 			z.readTagAttrExpression()
 			pendingAttr := z.buf[z.pendingAttr[0].Start:]
 			if len(pendingAttr) > 3 {
@@ -1184,7 +1193,6 @@ func (z *Tokenizer) readTagAttrVal() {
 	case '{':
 		z.pendingAttr[1].Start = z.raw.End
 		z.pendingAttrType = ExpressionAttribute
-		z.attrExpressionStack = 1
 		z.readTagAttrExpression()
 		z.pendingAttr[1].End = z.raw.End - 1
 		return
@@ -1212,39 +1220,34 @@ func (z *Tokenizer) readTagAttrVal() {
 	}
 }
 
+// readTagAttrExpression reads until the '}' closing the attribute expression
+// whose '{' was just read.
 func (z *Tokenizer) readTagAttrExpression() {
 	if z.err != nil {
 		return
 	}
-	for {
-		c := z.readByte()
-		if z.err != nil {
-			return
-		}
-		switch c {
-		// Handle comments, strings within attrs
-		case '/', '"', '\'', '`':
-			end := z.data.End
-			if c == '/' {
-				next := z.readByte()
-				if next == '/' {
-					panic("Block comments (//) are not allowed inside of expressions")
+	depth := 1
+	for i := z.raw.End; i < len(z.buf); {
+		kind, end := js_scanner.Skip(z.buf, i)
+		switch kind {
+		case js_scanner.LineCommentSpan:
+			panic("Block comments (//) are not allowed inside of expressions")
+		case js_scanner.CodeSpan:
+			switch z.buf[i] {
+			case '{':
+				depth++
+			case '}':
+				depth--
+				if depth == 0 {
+					z.raw.End = end
+					return
 				}
-				z.readCommentOrRegExp()
-			} else {
-				z.readString(c)
-			}
-			z.raw.End = z.data.End
-			z.data.End = end
-		case '{':
-			z.attrExpressionStack++
-		case '}':
-			z.attrExpressionStack--
-			if z.attrExpressionStack == 0 {
-				return
 			}
 		}
+		i = end
 	}
+	z.raw.End = len(z.buf)
+	z.err = io.EOF
 }
 
 func (z *Tokenizer) Loc() loc.Loc {
@@ -1272,6 +1275,7 @@ func (z *Tokenizer) Next() TokenType {
 	z.data.Start = z.raw.End
 	z.data.End = z.raw.End
 	z.prevTokenType = z.tt
+	z.commentType = CommentNode
 
 	// This handles expressions nested inside of Frontmatter elements
 	// but preserves `{}` as text outside of elements
@@ -1454,6 +1458,11 @@ loop:
 				z.tt = z.readMarkupDeclaration()
 				return z.tt
 			}
+			if c == '?' && z.xml {
+				z.readProcessingInstruction()
+				z.tt = CommentToken
+				return z.tt
+			}
 			z.raw.End--
 			z.readUntilCloseAngle()
 			z.tt = CommentToken
@@ -1481,6 +1490,19 @@ frontmatter_loop:
 			break frontmatter_loop
 		}
 
+		// Only a fence at the start of the file opens frontmatter, so a `---`
+		// after some text, like a Markdown rule or table, is left as text
+		if z.fm == FrontmatterInitial {
+			switch c {
+			case '-', '<', ' ', '\n', '\r', '\t', '\f':
+			default:
+				z.fm = FrontmatterClosed
+				z.dashCount = 0
+				z.raw.End--
+				goto loop
+			}
+		}
+
 		// handle frontmatter fence
 		if c == '-' {
 			z.dashCount++ // increase dashCount with each consecutive "-"
@@ -1491,6 +1513,9 @@ frontmatter_loop:
 			case FrontmatterInitial:
 				z.fm = FrontmatterOpen
 				z.dashCount = 0
+				// The fence starts after any leading whitespace
+				z.raw.Start = z.raw.End - len("---")
+				z.data.Start = z.raw.Start
 				z.data.End = z.raw.End
 				z.tt = FrontmatterFenceToken
 				z.openBraceIsExpressionStart = false
@@ -1765,8 +1790,9 @@ func (z *Tokenizer) TagName() (name []byte, hasAttr bool) {
 	return nil, false
 }
 
-// TagAttr returns the lower-cased key and unescaped value of the next unparsed
-// attribute for the current tag token and whether there are more attributes.
+// TagAttr returns the lower-cased key and value of the next unparsed attribute
+// for the current tag token and whether there are more attributes. Like Text,
+// the value is not unescaped so entities are preserved as written.
 // The contents of the returned slices may change on the next call to Next.
 func (z *Tokenizer) TagAttr() (key []byte, keyLoc loc.Loc, val []byte, valLoc loc.Loc, attrType AttributeType, moreAttr bool) {
 	if z.nAttrReturned < len(z.attr) {
@@ -1779,7 +1805,7 @@ func (z *Tokenizer) TagAttr() (key []byte, keyLoc loc.Loc, val []byte, valLoc lo
 			val = z.buf[x[1].Start:x[1].End]
 			keyLoc := loc.Loc{Start: x[0].Start}
 			valLoc := loc.Loc{Start: x[1].Start}
-			return key, keyLoc, unescape(convertNewlines(val), true), valLoc, attrType, z.nAttrReturned < len(z.attr)
+			return key, keyLoc, convertNewlines(val), valLoc, attrType, z.nAttrReturned < len(z.attr)
 		}
 	}
 	return nil, loc.Loc{Start: 0}, nil, loc.Loc{Start: 0}, QuotedAttribute, false
@@ -1805,14 +1831,15 @@ func (z *Tokenizer) Token() Token {
 			var attrType AttributeType
 			var attrTokenizer *Tokenizer = nil
 			key, keyLoc, val, valLoc, attrType, moreAttr = z.TagAttr()
-			t.Attr = append(t.Attr, Attribute{"", atom.String(key), keyLoc, string(val), valLoc, attrTokenizer, attrType})
+			t.Attr = append(t.Attr, Attribute{"", z.intern(key), keyLoc, string(val), valLoc, attrTokenizer, attrType})
 		}
-		if isFragment(string(name)) || isComponent(string(name)) {
-			t.DataAtom, t.Data = 0, string(name)
+		data := z.intern(name)
+		if isFragment(data) || isComponent(data) {
+			t.DataAtom, t.Data = 0, data
 		} else if a := atom.Lookup(name); a != 0 {
-			t.DataAtom, t.Data = a, a.String()
+			t.DataAtom, t.Data = a, data
 		} else {
-			t.DataAtom, t.Data = 0, string(name)
+			t.DataAtom, t.Data = 0, data
 		}
 	}
 	return t
@@ -1834,6 +1861,11 @@ func NewTokenizer(r io.Reader) *Tokenizer {
 // The input is assumed to be UTF-8 encoded.
 func NewTokenizerFragment(r io.Reader, contextTag string) *Tokenizer {
 	buf := new(bytes.Buffer)
+	// Readers which know their length, like strings.Reader and bytes.Reader, are
+	// read in a single allocation instead of growing the buffer repeatedly
+	if l, ok := r.(interface{ Len() int }); ok {
+		buf.Grow(l.Len() + bytes.MinRead)
+	}
 	buf.ReadFrom(r)
 	z := &Tokenizer{
 		r:                          r,
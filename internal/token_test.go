@@ -5,6 +5,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"unsafe"
 
 	"github.com/snowpackjs/astro/internal/test_utils"
 )
@@ -162,6 +163,26 @@ func TestBasic(t *testing.T) {
 			"<span data-astro-raw>function foo() { }</span>",
 			[]TokenType{StartTagToken, TextToken, EndTagToken},
 		},
+		{
+			"is:ignore allows children to be parsed as Text",
+			`<div is:ignore><div @click="open = true">{{ msg }}</div></div><p>{a}</p>`,
+			[]TokenType{StartTagToken, TextToken, EndTagToken, StartTagToken, StartExpressionToken, TextToken, EndExpressionToken, EndTagToken},
+		},
+		{
+			"processing instruction",
+			`<?xml version="1.0"?><rss></rss>`,
+			[]TokenType{CommentToken, StartTagToken, EndTagToken},
+		},
+		{
+			"CDATA section is a bogus comment in HTML",
+			"<description><![CDATA[<p>a > b</p>]]></description>",
+			[]TokenType{StartTagToken, CommentToken, TextToken, EndTagToken, TextToken, EndTagToken},
+		},
+		{
+			"unterminated processing instruction",
+			`<?php echo 1 <p>a</p>`,
+			[]TokenType{CommentToken, TextToken, EndTagToken},
+		},
 		{
 			"Doesn't throw on other data attributes",
 			"<span data-foo></span>",
@@ -270,6 +291,23 @@ func TestFrontmatter(t *testing.T) {
 			`,
 			[]TokenType{SelfClosingTagToken, TextToken},
 		},
+		{
+			"treated as text after text",
+			`
+			# Hello
+			---
+			const a = 0;
+			---
+			`,
+			[]TokenType{TextToken},
+		},
+		{
+			"markdown table",
+			`| a | b |
+			|---|---|
+			<div />`,
+			[]TokenType{TextToken, SelfClosingTagToken},
+		},
 		{
 			"treated as text after closed",
 			`
@@ -477,7 +515,7 @@ func TestExpressions(t *testing.T) {
 		{
 			"expression with nested strings",
 			"{`${`${`${foo}`}`}`}",
-			[]TokenType{StartExpressionToken, TextToken, TextToken, TextToken, TextToken, TextToken, EndExpressionToken},
+			[]TokenType{StartExpressionToken, TextToken, EndExpressionToken},
 		},
 		{
 			"element with multiple expressions",
@@ -566,6 +604,16 @@ func TestAttributes(t *testing.T) {
 			`<div a={value} />`,
 			[]AttributeType{ExpressionAttribute},
 		},
+		{
+			"expressions with braces in literals",
+			"<div a={\"}\"} b={`${'}'}`} c={/* } */ d} e={'\\'}'} />",
+			[]AttributeType{ExpressionAttribute, ExpressionAttribute, ExpressionAttribute, ExpressionAttribute},
+		},
+		{
+			"expressions with division",
+			"<div a={b / 2} c={(d) / e} />",
+			[]AttributeType{ExpressionAttribute, ExpressionAttribute},
+		},
 		{
 			"shorthand",
 			`<div {value} />`,
@@ -673,3 +721,84 @@ func runAttributeTypeTest(t *testing.T, suite []AttributeTest) {
 		})
 	}
 }
+
+type LocTest struct {
+	name     string
+	input    string
+	expected []int
+}
+
+func TestLoc(t *testing.T) {
+	Locs := []LocTest{
+		{
+			"ascii",
+			`<div>a</div>`,
+			[]int{0, 5, 6},
+		},
+		{
+			"emoji text",
+			`<div>😀</div>`,
+			[]int{0, 5, 9},
+		},
+		{
+			"emoji before element",
+			`😀<span>👍🏽</span>`,
+			[]int{0, 4, 10, 18},
+		},
+		{
+			"astral plane attribute",
+			`<div title="𝒜"><p>x</p></div>`,
+			[]int{0, 18, 21, 22, 26},
+		},
+		{
+			"emoji expression",
+			`<div>{"🎉"}<b/></div>`,
+			[]int{0, 5, 6, 12, 13, 17},
+		},
+	}
+
+	for _, tt := range Locs {
+		t.Run(tt.name, func(t *testing.T) {
+			locs := make([]int, 0)
+			tokenizer := NewTokenizer(strings.NewReader(tt.input))
+			for {
+				if tokenizer.Next() == ErrorToken {
+					break
+				}
+				locs = append(locs, tokenizer.Token().Loc.Start)
+			}
+			if !reflect.DeepEqual(locs, tt.expected) {
+				t.Errorf("Locs = %v\nExpected = %v", locs, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIntern(t *testing.T) {
+	z := NewTokenizer(strings.NewReader(`<Counter client:load data-x="1" /><Counter client:load data-x="2" /><div class="a"></div>`))
+	var tokens []Token
+	for tt := z.Next(); tt != ErrorToken; tt = z.Next() {
+		if tt == SelfClosingTagToken || tt == StartTagToken {
+			tokens = append(tokens, z.Token())
+		}
+	}
+	if len(tokens) != 3 {
+		t.Fatalf("expected 3 tags, got %d", len(tokens))
+	}
+
+	pointer := func(s string) uintptr {
+		return (*reflect.StringHeader)(unsafe.Pointer(&s)).Data
+	}
+	first, second := tokens[0], tokens[1]
+	if first.Data != "Counter" || pointer(first.Data) != pointer(second.Data) {
+		t.Errorf("expected repeated tag names to share a string, got %q and %q", first.Data, second.Data)
+	}
+	for i := range first.Attr {
+		if pointer(first.Attr[i].Key) != pointer(second.Attr[i].Key) {
+			t.Errorf("expected repeated attribute keys to share a string, got %q and %q", first.Attr[i].Key, second.Attr[i].Key)
+		}
+	}
+	if tokens[2].Data != "div" || tokens[2].Attr[0].Key != "class" {
+		t.Errorf("unexpected token %+v", tokens[2])
+	}
+}
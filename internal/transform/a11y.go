@@ -0,0 +1,233 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/handler"
+	"github.com/snowpackjs/astro/internal/loc"
+)
+
+// https://www.w3.org/TR/wai-aria-1.2/#state_prop_def
+var ariaAttributes = map[string]bool{
+	"aria-activedescendant": true, "aria-atomic": true, "aria-autocomplete": true, "aria-busy": true,
+	"aria-checked": true, "aria-colcount": true, "aria-colindex": true, "aria-colspan": true,
+	"aria-controls": true, "aria-current": true, "aria-describedby": true, "aria-description": true,
+	"aria-details": true, "aria-disabled": true, "aria-dropeffect": true, "aria-errormessage": true,
+	"aria-expanded": true, "aria-flowto": true, "aria-grabbed": true, "aria-haspopup": true,
+	"aria-hidden": true, "aria-invalid": true, "aria-keyshortcuts": true, "aria-label": true,
+	"aria-labelledby": true, "aria-level": true, "aria-live": true, "aria-modal": true,
+	"aria-multiline": true, "aria-multiselectable": true, "aria-orientation": true, "aria-owns": true,
+	"aria-placeholder": true, "aria-posinset": true, "aria-pressed": true, "aria-readonly": true,
+	"aria-relevant": true, "aria-required": true, "aria-roledescription": true, "aria-rowcount": true,
+	"aria-rowindex": true, "aria-rowspan": true, "aria-selected": true, "aria-setsize": true,
+	"aria-sort": true, "aria-valuemax": true, "aria-valuemin": true, "aria-valuenow": true,
+	"aria-valuetext": true,
+}
+
+// https://www.w3.org/TR/wai-aria-1.2/#role_definitions, mapped to whether the role is interactive
+var ariaRoles = map[string]bool{
+	"alert": false, "alertdialog": false, "application": false, "article": false, "banner": false,
+	"blockquote": false, "button": true, "caption": false, "cell": false, "checkbox": true,
+	"code": false, "columnheader": false, "combobox": true, "complementary": false, "contentinfo": false,
+	"definition": false, "deletion": false, "dialog": false, "directory": false, "document": false,
+	"emphasis": false, "feed": false, "figure": false, "form": false, "generic": false,
+	"grid": true, "gridcell": true, "group": false, "heading": false, "img": false,
+	"insertion": false, "link": true, "list": false, "listbox": true, "listitem": false,
+	"log": false, "main": false, "marquee": false, "math": false, "menu": true,
+	"menubar": true, "menuitem": true, "menuitemcheckbox": true, "menuitemradio": true, "meter": false,
+	"navigation": false, "none": false, "note": false, "option": true, "paragraph": false,
+	"presentation": false, "progressbar": false, "radio": true, "radiogroup": true, "region": false,
+	"row": false, "rowgroup": false, "rowheader": false, "scrollbar": true, "search": false,
+	"searchbox": true, "separator": false, "slider": true, "spinbutton": true, "status": false,
+	"strong": false, "subscript": false, "superscript": false, "switch": true, "tab": true,
+	"table": false, "tablist": true, "tabpanel": false, "term": false, "textbox": true,
+	"time": false, "timer": false, "toolbar": false, "tooltip": false, "tree": true,
+	"treegrid": true, "treeitem": true,
+}
+
+// Elements which can be interacted with without any extra ARIA
+var interactiveElements = map[string]bool{
+	"a": true, "button": true, "details": true, "embed": true, "iframe": true, "input": true,
+	"label": true, "object": true, "option": true, "select": true, "summary": true, "textarea": true,
+}
+
+// Elements which may be associated with a <label>
+var labelableElements = map[string]bool{
+	"button": true, "input": true, "meter": true, "output": true, "progress": true, "select": true, "textarea": true,
+}
+
+// A11y warns about common accessibility mistakes, like images without alt
+// text or unknown ARIA attributes
+func A11y(n *astro.Node, h *handler.Handler) {
+	if n.Type != astro.ElementNode || n.Component || n.CustomElement || n.Fragment || len(n.Loc) == 0 {
+		return
+	}
+	tag := loc.Range{Loc: n.Loc[0], Len: len(n.Data) + 1}
+
+	// Attributes can always be checked, even if a spread may add more
+	for _, attr := range n.Attr {
+		if strings.HasPrefix(attr.Key, "aria-") && !ariaAttributes[attr.Key] {
+			warning := &loc.ErrorWithRange{
+				Code:  loc.WARNING_A11Y_UNKNOWN_ARIA_ATTRIBUTE,
+				Text:  fmt.Sprintf("Unknown ARIA attribute %s", attr.Key),
+				Range: loc.Range{Loc: attr.KeyLoc, Len: len(attr.Key)},
+			}
+			if suggestion := closestMatch(attr.Key, ariaAttributes); suggestion != "" {
+				warning.Hint = fmt.Sprintf("Did you mean %s?", suggestion)
+			}
+			h.AppendWarning(warning)
+		}
+		if attr.Key == "role" && attr.Type == astro.QuotedAttribute {
+			for _, role := range strings.Fields(attr.Val) {
+				if _, ok := ariaRoles[role]; !ok {
+					h.AppendWarning(&loc.ErrorWithRange{
+						Code:  loc.WARNING_A11Y_UNKNOWN_ROLE,
+						Text:  fmt.Sprintf("Unknown ARIA role %q", role),
+						Range: loc.Range{Loc: attr.ValLoc, Len: len(attr.Val)},
+					})
+				}
+			}
+		}
+	}
+
+	// A spread may provide any attribute, so the remaining checks can't be sure
+	for _, attr := range n.Attr {
+		if attr.Type == astro.SpreadAttribute {
+			return
+		}
+	}
+
+	if needsAlt(n) && !HasAttr(n, "alt") && !HasAttr(n, "aria-label") && !HasAttr(n, "aria-labelledby") {
+		h.AppendWarning(&loc.ErrorWithRange{
+			Code:  loc.WARNING_IMAGE_MISSING_ALT,
+			Text:  fmt.Sprintf("<%s> is missing an alt attribute", n.Data),
+			Hint:  `Describe the image with alt="...", or use alt="" if it is decorative`,
+			Range: tag,
+		})
+	}
+
+	if hasClickHandler(n) && !isInteractive(n) {
+		h.AppendWarning(&loc.ErrorWithRange{
+			Code:  loc.WARNING_A11Y_CLICK_NON_INTERACTIVE,
+			Text:  fmt.Sprintf("<%s> has a click handler but is not interactive", n.Data),
+			Hint:  `Use a <button>, or add an interactive role like role="button" and a keyboard handler`,
+			Range: tag,
+		})
+	}
+
+	if n.Data == "label" && !HasAttr(n, "for") && !hasLabelableDescendant(n) {
+		h.AppendWarning(&loc.ErrorWithRange{
+			Code:  loc.WARNING_A11Y_LABEL_WITHOUT_CONTROL,
+			Text:  "<label> is not associated with a control",
+			Hint:  `Add a for="..." attribute, or nest the control inside of the <label>`,
+			Range: tag,
+		})
+	}
+}
+
+func needsAlt(n *astro.Node) bool {
+	switch n.Data {
+	case "img":
+		return true
+	case "area":
+		return HasAttr(n, "href")
+	case "input":
+		typ := astro.GetAttribute(n, "type")
+		return typ != nil && typ.Type == astro.QuotedAttribute && strings.ToLower(typ.Val) == "image"
+	}
+	return false
+}
+
+func hasClickHandler(n *astro.Node) bool {
+	for _, attr := range n.Attr {
+		if strings.ToLower(attr.Key) == "onclick" {
+			return true
+		}
+	}
+	return false
+}
+
+func isInteractive(n *astro.Node) bool {
+	if interactiveElements[n.Data] {
+		return true
+	}
+	if (n.Data == "audio" || n.Data == "video") && HasAttr(n, "controls") {
+		return true
+	}
+	role := astro.GetAttribute(n, "role")
+	if role == nil {
+		return false
+	}
+	// Dynamic roles can't be checked
+	if role.Type != astro.QuotedAttribute {
+		return true
+	}
+	for _, r := range strings.Fields(role.Val) {
+		if ariaRoles[r] {
+			return true
+		}
+	}
+	return false
+}
+
+// Components, slots and expressions might render a control, so give them the benefit of the doubt
+func hasLabelableDescendant(n *astro.Node) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != astro.ElementNode {
+			continue
+		}
+		if labelableElements[c.Data] || c.Component || c.CustomElement || c.Expression || c.Data == "slot" {
+			return true
+		}
+		if hasLabelableDescendant(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// closestMatch returns the candidate closest to str, if it's close enough to be a likely typo
+func closestMatch(str string, candidates map[string]bool) string {
+	best := ""
+	bestDistance := 3
+	for candidate := range candidates {
+		d := levenshtein(str, candidate)
+		if d < bestDistance || (d == bestDistance && best != "" && candidate < best) {
+			best = candidate
+			bestDistance = d
+		}
+	}
+	return best
+}
+
+func levenshtein(a string, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
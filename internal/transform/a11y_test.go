@@ -0,0 +1,101 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/handler"
+	"golang.org/x/net/html/atom"
+)
+
+func TestA11y(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "valid",
+			source: `<img src="a.png" alt=""><button onclick="go()">Go</button><div role="button" onclick="go()" aria-pressed="false">Go</div>`,
+			want:   []string{},
+		},
+		{
+			name:   "missing alt",
+			source: `<img src="a.png"><input type="image" src="a.png"><map><area href="/"></map>`,
+			want: []string{
+				"2001 <img> is missing an alt attribute",
+				"2001 <input> is missing an alt attribute",
+				"2001 <area> is missing an alt attribute",
+			},
+		},
+		{
+			name:   "aria-label instead of alt",
+			source: `<img src="a.png" aria-label="A">`,
+			want:   []string{},
+		},
+		{
+			name:   "click on non-interactive element",
+			source: `<div onclick="go()">Go</div><span onClick={go}>Go</span><li role="listitem" onclick="go()"></li>`,
+			want: []string{
+				"2103 <div> has a click handler but is not interactive",
+				"2103 <span> has a click handler but is not interactive",
+				"2103 <li> has a click handler but is not interactive",
+			},
+		},
+		{
+			name:   "label",
+			source: `<label>Name</label><label for="name">Name</label><label>Name <input></label><label>Name <Input /></label><label>Name {input}</label>`,
+			want:   []string{"2104 <label> is not associated with a control"},
+		},
+		{
+			name:   "unknown aria attribute",
+			source: `<div aria-lable="Name" aria-foo="bar"></div>`,
+			want: []string{
+				"2101 Unknown ARIA attribute aria-lable (Did you mean aria-label?)",
+				"2101 Unknown ARIA attribute aria-foo",
+			},
+		},
+		{
+			name:   "unknown role",
+			source: `<div role="buton"></div><div role="img presentation"></div>`,
+			want:   []string{`2102 Unknown ARIA role "buton"`},
+		},
+		{
+			name:   "spread",
+			source: `<img {...props}><div {...props} onclick="go()"></div><div {...props} aria-foo></div>`,
+			want:   []string{"2101 Unknown ARIA attribute aria-foo"},
+		},
+		{
+			name:   "components",
+			source: `<Image src="a.png" /><Button onclick="go()" />`,
+			want:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := astro.ParseFragment(strings.NewReader(tt.source), &astro.Node{Type: astro.ElementNode, DataAtom: atom.Body, Data: atom.Body.String()})
+			if err != nil {
+				t.Error(err)
+			}
+			h := handler.NewHandler(tt.source, "TestA11y.astro")
+			for _, n := range nodes {
+				walk(n, func(n *astro.Node) {
+					A11y(n, h)
+				})
+			}
+			got := make([]string, 0)
+			for _, w := range h.Warnings() {
+				text := fmt.Sprintf("%d %s", w.Code, w.Text)
+				if w.Code == 2101 && w.Hint != "" {
+					text += fmt.Sprintf(" (%s)", w.Hint)
+				}
+				got = append(got, text)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %q\n  got:  %q", tt.name, tt.want, got))
+			}
+		})
+	}
+}
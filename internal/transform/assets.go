@@ -0,0 +1,172 @@
+package transform
+
+import (
+	"strings"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/loc"
+)
+
+// Asset is a static URL referenced by an element in the template
+type Asset struct {
+	URL       string
+	Element   string
+	Attribute string
+	Loc       loc.Loc
+}
+
+// Attributes that reference assets, with the elements they apply to.
+// An empty list means the attribute is an asset on any element.
+var assetAttributes = map[string][]string{
+	"src":    {},
+	"srcset": {"img", "source"},
+	"poster": {"video"},
+	"href":   {"link", "image", "use"},
+}
+
+func isAssetAttribute(n *astro.Node, attr astro.Attribute) bool {
+	if n.Type != astro.ElementNode || n.Component || n.CustomElement || n.Fragment {
+		return false
+	}
+	if attr.Type != astro.QuotedAttribute || (attr.Namespace != "" && attr.Namespace != "xlink") {
+		return false
+	}
+	elements, ok := assetAttributes[attr.Key]
+	if !ok {
+		return false
+	}
+	if len(elements) == 0 {
+		return true
+	}
+	for _, el := range elements {
+		if n.Data == el {
+			return true
+		}
+	}
+	return false
+}
+
+// Only URLs which resolve to a local file can be fingerprinted
+func isLocalURL(url string) bool {
+	if url == "" || strings.HasPrefix(url, "#") || strings.HasPrefix(url, "//") {
+		return false
+	}
+	// Anything with a scheme (http:, data:, mailto:...) is external
+	if i := strings.IndexAny(url, ":/?#"); i > 0 && url[i] == ':' {
+		return false
+	}
+	return true
+}
+
+type srcsetCandidate struct {
+	start int
+	end   int
+}
+
+// splitSrcset returns the position of every URL in a srcset attribute value,
+// following the HTML spec's rules for parsing image candidate strings
+func splitSrcset(value string) []srcsetCandidate {
+	candidates := make([]srcsetCandidate, 0)
+	i := 0
+	for i < len(value) {
+		// Skip whitespace and commas before the URL
+		for i < len(value) && (isSpace(value[i]) || value[i] == ',') {
+			i++
+		}
+		if i >= len(value) {
+			break
+		}
+		start := i
+		for i < len(value) && !isSpace(value[i]) {
+			i++
+		}
+		end := i
+		// A URL which ends with a comma has no descriptors
+		if value[end-1] == ',' {
+			for end > start && value[end-1] == ',' {
+				end--
+			}
+		} else {
+			// Skip descriptors, which may contain commas inside of parens
+			parens := 0
+			for i < len(value) && !(value[i] == ',' && parens == 0) {
+				if value[i] == '(' {
+					parens++
+				} else if value[i] == ')' && parens > 0 {
+					parens--
+				}
+				i++
+			}
+		}
+		if end > start {
+			candidates = append(candidates, srcsetCandidate{start, end})
+		}
+	}
+	return candidates
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// CollectAssets walks doc and returns every static, local asset URL in the
+// order that it appears in the template
+func CollectAssets(doc *astro.Node) []Asset {
+	assets := make([]Asset, 0)
+	walk(doc, func(n *astro.Node) {
+		for _, attr := range n.Attr {
+			if !isAssetAttribute(n, attr) {
+				continue
+			}
+			if attr.Key == "srcset" {
+				for _, c := range splitSrcset(attr.Val) {
+					url := astro.UnescapeAttributeString(attr.Val[c.start:c.end])
+					if isLocalURL(url) {
+						assets = append(assets, Asset{URL: url, Element: n.Data, Attribute: attr.Key, Loc: loc.Loc{Start: attr.ValLoc.Start + c.start}})
+					}
+				}
+				continue
+			}
+			url := astro.UnescapeAttributeString(strings.TrimSpace(attr.Val))
+			if isLocalURL(url) {
+				assets = append(assets, Asset{URL: url, Element: n.Data, Attribute: attr.Key, Loc: attr.ValLoc})
+			}
+		}
+	})
+	return assets
+}
+
+// RewriteAsset returns attr with every local asset URL replaced by the result
+// of rewrite. Attributes which don't reference assets are returned unchanged.
+func RewriteAsset(n *astro.Node, attr astro.Attribute, rewrite func(url string) string) astro.Attribute {
+	if rewrite == nil || !isAssetAttribute(n, attr) {
+		return attr
+	}
+	replace := func(raw string) string {
+		url := astro.UnescapeAttributeString(raw)
+		if !isLocalURL(url) {
+			return raw
+		}
+		if next := rewrite(url); next != url {
+			return next
+		}
+		return raw
+	}
+	if attr.Key == "srcset" {
+		var b strings.Builder
+		prev := 0
+		for _, c := range splitSrcset(attr.Val) {
+			b.WriteString(attr.Val[prev:c.start])
+			b.WriteString(replace(attr.Val[c.start:c.end]))
+			prev = c.end
+		}
+		b.WriteString(attr.Val[prev:])
+		attr.Val = b.String()
+		return attr
+	}
+	url := strings.TrimSpace(attr.Val)
+	if next := replace(url); next != url {
+		attr.Val = next
+	}
+	return attr
+}
@@ -0,0 +1,74 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"golang.org/x/net/html/atom"
+)
+
+func TestCollectAssets(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "src",
+			source: `<img src="/logo.png"><script src="./main.js"></script>`,
+			want:   []string{"img[src]=/logo.png", "script[src]=./main.js"},
+		},
+		{
+			name:   "srcset",
+			source: `<img srcset="a.png 1x, b.png 2x,c.png"><picture><source srcset="d.webp 100w"></picture>`,
+			want:   []string{"img[srcset]=a.png", "img[srcset]=b.png", "img[srcset]=c.png", "source[srcset]=d.webp"},
+		},
+		{
+			// Per the HTML spec, a comma is only a separator when followed by whitespace or descriptors
+			name:   "srcset without whitespace",
+			source: `<img srcset="a.png,b.png, c.png">`,
+			want:   []string{"img[srcset]=a.png,b.png", "img[srcset]=c.png"},
+		},
+		{
+			name:   "poster and href",
+			source: `<video poster="poster.jpg"></video><link rel="stylesheet" href="/global.css"><a href="/about">About</a>`,
+			want:   []string{"video[poster]=poster.jpg", "link[href]=/global.css"},
+		},
+		{
+			name:   "entities",
+			source: `<img src="/img?w=1&amp;h=2">`,
+			want:   []string{"img[src]=/img?w=1&h=2"},
+		},
+		{
+			name:   "external",
+			source: `<img src="https://astro.build/logo.png"><img src="//cdn.com/a.png"><img src="data:image/png;base64,AAAA"><use href="#icon"/>`,
+			want:   []string{},
+		},
+		{
+			name:   "dynamic",
+			source: "<img src={logo}><img src=`${base}/logo.png`><Image src=\"/logo.png\" />",
+			want:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := astro.ParseFragment(strings.NewReader(tt.source), &astro.Node{Type: astro.ElementNode, DataAtom: atom.Body, Data: atom.Body.String()})
+			if err != nil {
+				t.Error(err)
+			}
+			doc := &astro.Node{Type: astro.DocumentNode}
+			for _, n := range nodes {
+				doc.AppendChild(n)
+			}
+			got := make([]string, 0)
+			for _, asset := range CollectAssets(doc) {
+				got = append(got, fmt.Sprintf("%s[%s]=%s", asset.Element, asset.Attribute, asset.URL))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %v\n  got:  %v", tt.name, tt.want, got))
+			}
+		})
+	}
+}
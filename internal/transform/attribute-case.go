@@ -0,0 +1,24 @@
+package transform
+
+import (
+	"strings"
+
+	astro "github.com/snowpackjs/astro/internal"
+)
+
+// LowercaseAttributes lowercases the attribute names of plain HTML elements,
+// which are case-insensitive. Components, custom elements and SVG or MathML
+// content keep their names as authored, since props like `onClick` and
+// attributes like `viewBox` are case-sensitive.
+func LowercaseAttributes(n *astro.Node) {
+	if n.Type != astro.ElementNode || n.Component || n.CustomElement || n.Fragment || n.Expression || n.Namespace != "" {
+		return
+	}
+	for i, attr := range n.Attr {
+		// The key of a shorthand or spread attribute is JavaScript
+		switch attr.Type {
+		case astro.QuotedAttribute, astro.EmptyAttribute, astro.ExpressionAttribute, astro.TemplateLiteralAttribute:
+			n.Attr[i].Key = strings.ToLower(attr.Key)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package transform
+
+import (
+	"fmt"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/handler"
+	"github.com/snowpackjs/astro/internal/loc"
+	a "golang.org/x/net/html/atom"
+)
+
+// Budgets warns when the component hydrates more islands than
+// opts.IslandBudget, or has an inline script larger than
+// opts.InlineScriptBudget, so teams can enforce performance budgets at
+// compile time. Hoisted scripts are bundled, so they don't count. This must
+// run after hoisted scripts are removed from the document.
+func Budgets(doc *astro.Node, opts TransformOptions, h *handler.Handler) {
+	if opts.IslandBudget > 0 {
+		if islands := Islands(doc); len(islands) > opts.IslandBudget {
+			// Point at the first island over the budget
+			island := islands[opts.IslandBudget]
+			h.AppendWarning(&loc.ErrorWithRange{
+				Code:  loc.WARNING_ISLAND_BUDGET,
+				Text:  fmt.Sprintf("The component hydrates %d islands, more than the budget of %d", len(islands), opts.IslandBudget),
+				Hint:  "Render components which don't need to be interactive without a client: directive",
+				Range: loc.Range{Loc: island.Loc, Len: len(island.Name) + 1},
+			})
+		}
+	}
+
+	if opts.InlineScriptBudget > 0 {
+		walk(doc, func(n *astro.Node) {
+			if n.Type != astro.ElementNode || n.DataAtom != a.Script || n.Component || len(n.Loc) == 0 {
+				return
+			}
+			size := 0
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				size += len(c.Data)
+			}
+			if size > opts.InlineScriptBudget {
+				h.AppendWarning(&loc.ErrorWithRange{
+					Code:  loc.WARNING_INLINE_SCRIPT_BUDGET,
+					Text:  fmt.Sprintf("This inline script is %d bytes, more than the budget of %d", size, opts.InlineScriptBudget),
+					Hint:  "Move the code into a hoisted script, which is bundled and cached, or load it with src",
+					Range: loc.Range{Loc: n.Loc[0], Len: len(n.Data) + 1},
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/handler"
+	"github.com/snowpackjs/astro/internal/loc"
+)
+
+func TestBudgets(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		opts   TransformOptions
+		want   []string
+	}{
+		{
+			name:   "no budgets",
+			source: `<A client:load /><B client:idle /><script>console.log(1)</script>`,
+			want:   []string{},
+		},
+		{
+			name:   "within budgets",
+			source: `<A client:load /><B client:idle /><script>console.log(1)</script>`,
+			opts:   TransformOptions{IslandBudget: 2, InlineScriptBudget: 14},
+			want:   []string{},
+		},
+		{
+			name:   "too many islands",
+			source: `<A client:load /><Static /><B client:idle /><C client:only="react" />`,
+			opts:   TransformOptions{IslandBudget: 1},
+			want:   []string{"2019 <B The component hydrates 3 islands, more than the budget of 1"},
+		},
+		{
+			name:   "large inline script",
+			source: `<script>console.log(1)</script><script is:inline>1</script>`,
+			opts:   TransformOptions{InlineScriptBudget: 10},
+			want:   []string{"2020 <script This inline script is 14 bytes, more than the budget of 10"},
+		},
+		{
+			name:   "hoisted script",
+			source: `<script hoist>console.log(1)</script>`,
+			opts:   TransformOptions{InlineScriptBudget: 10},
+			want:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := astro.Parse(strings.NewReader(tt.source))
+			if err != nil {
+				t.Error(err)
+			}
+			h := handler.NewHandler(tt.source, "TestBudgets.astro")
+			Transform(doc, tt.opts, h)
+			got := make([]string, 0)
+			for _, w := range h.Warnings() {
+				if w.Code != int(loc.WARNING_ISLAND_BUDGET) && w.Code != int(loc.WARNING_INLINE_SCRIPT_BUDGET) {
+					continue
+				}
+				text := tt.source[w.Location.Column-1 : w.Location.Column-1+w.Location.Length]
+				got = append(got, fmt.Sprintf("%d %s %s", w.Code, text, w.Text))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %q\n  got:  %q", tt.name, tt.want, got))
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package transform
+
+import (
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/js_scanner"
+)
+
+// CustomElementLink connects a hoisted script to the custom elements it defines
+type CustomElementLink struct {
+	// Tag names registered by the script with `customElements.define`
+	Defines []string
+	// The subset of Defines that is used in the document
+	Used []string
+}
+
+// LinkCustomElements finds every hoisted inline script that defines a custom
+// element and links it to the usages of that element in doc, so a definition
+// only needs to be shipped when its tag actually appears.
+func LinkCustomElements(doc *astro.Node) map[*astro.Node]CustomElementLink {
+	links := make(map[*astro.Node]CustomElementLink)
+	if len(doc.Scripts) == 0 {
+		return links
+	}
+
+	used := make(map[string]bool)
+	walk(doc, func(n *astro.Node) {
+		if n.Type == astro.ElementNode && n.CustomElement {
+			used[n.Data] = true
+		}
+	})
+
+	for _, script := range doc.Scripts {
+		if HasAttr(script, "src") || script.FirstChild == nil {
+			continue
+		}
+		defines := js_scanner.CustomElementDefinitions([]byte(script.FirstChild.Data))
+		if len(defines) == 0 {
+			continue
+		}
+		link := CustomElementLink{Defines: defines, Used: make([]string, 0)}
+		for _, tag := range defines {
+			if used[tag] {
+				link.Used = append(link.Used, tag)
+			}
+		}
+		links[script] = link
+	}
+	return links
+}
@@ -0,0 +1,82 @@
+package transform
+
+import (
+	"strings"
+
+	"github.com/snowpackjs/astro/internal/loc"
+)
+
+// DataFrontmatter is a block of YAML or TOML data at the start of a content
+// file, like the frontmatter of a Markdown file
+type DataFrontmatter struct {
+	// "yaml" for a block fenced by `---`, or "toml" for one fenced by `+++`
+	Lang string
+	// The data between the fences, as authored
+	Content string
+	// The position of Content in the source
+	Loc loc.Loc
+}
+
+var dataFences = map[string]string{
+	"---": "yaml",
+	"+++": "toml",
+}
+
+// ExtractDataFrontmatter returns the block of data which source starts with,
+// if any, and source with that block blanked out, so it can be parsed as a
+// component. Everything but line breaks is replaced by spaces, so positions in
+// the rest of source don't change and a component script can follow the data.
+func ExtractDataFrontmatter(source string) (string, *DataFrontmatter) {
+	start := 0
+	// A byte order mark is allowed before the opening fence
+	if strings.HasPrefix(source, "\ufeff") {
+		start = len("\ufeff")
+	}
+	line, next := fenceLine(source, start)
+	lang, ok := dataFences[line]
+	if !ok {
+		return source, nil
+	}
+
+	for i := next; i < len(source); {
+		l, n := fenceLine(source, i)
+		if l == line {
+			data := &DataFrontmatter{
+				Lang:    lang,
+				Content: source[next:i],
+				Loc:     loc.Loc{Start: next},
+			}
+			return blank(source, start, n), data
+		}
+		if n == i {
+			break
+		}
+		i = n
+	}
+	return source, nil
+}
+
+// fenceLine returns the line of source at start without trailing whitespace,
+// and the position of the line after it
+func fenceLine(source string, start int) (string, int) {
+	end := strings.IndexByte(source[start:], '\n')
+	if end == -1 {
+		return strings.TrimRight(source[start:], " \t\r"), len(source)
+	}
+	return strings.TrimRight(source[start:start+end], " \t\r"), start + end + 1
+}
+
+func blank(source string, start int, end int) string {
+	var b strings.Builder
+	b.Grow(len(source))
+	b.WriteString(source[:start])
+	for i := start; i < end; i++ {
+		if source[i] == '\n' || source[i] == '\r' {
+			b.WriteByte(source[i])
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+	b.WriteString(source[end:])
+	return b.String()
+}
@@ -0,0 +1,78 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestExtractDataFrontmatter(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "yaml",
+			source: "---\ntitle: Hello\ntags: [a, b]\n---\n# Hello",
+			want:   "yaml \"title: Hello\\ntags: [a, b]\\n\"@4",
+		},
+		{
+			name:   "toml",
+			source: "+++\ntitle = \"Hello\"\n+++\n<h1>Hello</h1>",
+			want:   "toml \"title = \\\"Hello\\\"\\n\"@4",
+		},
+		{
+			name:   "followed by a script",
+			source: "---\r\ntitle: Hello\r\n---\r\n---\nconst a = 1;\n---\n<h1>{a}</h1>",
+			want:   "yaml \"title: Hello\\r\\n\"@5",
+		},
+		{
+			name:   "empty",
+			source: "---\n---\n<h1>Hello</h1>",
+			want:   "yaml \"\"@4",
+		},
+		{
+			name:   "byte order mark",
+			source: "\ufeff+++\na = 1\n+++",
+			want:   "toml \"a = 1\\n\"@7",
+		},
+		{
+			name:   "unclosed",
+			source: "---\ntitle: Hello\n<h1>Hello</h1>",
+			want:   "<nil>",
+		},
+		{
+			name:   "not at the start",
+			source: "\n---\ntitle: Hello\n---\n",
+			want:   "<nil>",
+		},
+		{
+			name:   "mismatched fences",
+			source: "---\ntitle: Hello\n+++\n",
+			want:   "<nil>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, data := ExtractDataFrontmatter(tt.source)
+			got := "<nil>"
+			if data != nil {
+				got = fmt.Sprintf("%s %q@%d", data.Lang, data.Content, data.Loc.Start)
+				if tt.source[data.Loc.Start:data.Loc.Start+len(data.Content)] != data.Content {
+					t.Errorf("%s: content is not at its location", tt.name)
+				}
+			}
+			if got != tt.want {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %v\n  got:  %v", tt.name, tt.want, got))
+			}
+			// Positions after the data don't change
+			if len(source) != len(tt.source) || strings.Count(source, "\n") != strings.Count(tt.source, "\n") {
+				t.Errorf("%s: blanked source doesn't line up with the source: %q", tt.name, source)
+			}
+			if data != nil && strings.TrimLeft(source, " \r\n\ufeff") != strings.TrimLeft(tt.source[data.Loc.Start+len(data.Content)+3:], " \r\n") {
+				t.Errorf("%s: unexpected blanked source %q", tt.name, source)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package transform
+
+import (
+	"regexp"
+	"strings"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/js_scanner"
+)
+
+var andCondition = regexp.MustCompile(`^\s*(.+?)\s*&&\s*$`)
+var ternaryCondition = regexp.MustCompile(`^\s*(.+?)\s*\?\s*$`)
+
+// constantCondition evaluates a literal condition, like `true` or `!"production"`.
+// empty is true when a falsy value would render nothing at all. Numbers aren't
+// evaluated, since `{0 && <A />}` still renders the 0.
+func constantCondition(condition string) (truthy bool, empty bool, ok bool) {
+	negated := false
+	for strings.HasPrefix(condition, "!") {
+		negated = !negated
+		condition = strings.TrimSpace(condition[1:])
+	}
+	switch {
+	case condition == "true":
+		truthy, empty = true, true
+	case condition == "false" || condition == "null" || condition == "undefined":
+		truthy, empty = false, true
+	case len(condition) >= 2 && (condition[0] == '"' || condition[0] == '\'') && condition[len(condition)-1] == condition[0]:
+		if strings.ContainsAny(condition[1:len(condition)-1], "\"'\\") {
+			return false, false, false
+		}
+		truthy, empty = len(condition) > 2, true
+	default:
+		return false, false, false
+	}
+	if negated {
+		// Negation always results in a boolean
+		return !truthy, true, true
+	}
+	return truthy, empty, true
+}
+
+// significantChildren returns the children of an expression, ignoring
+// whitespace after the last element
+func significantChildren(n *astro.Node) []*astro.Node {
+	children := make([]*astro.Node, 0)
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		children = append(children, c)
+	}
+	if len(children) > 0 {
+		last := children[len(children)-1]
+		if last.Type == astro.TextNode && strings.TrimSpace(last.Data) == "" {
+			children = children[:len(children)-1]
+		}
+	}
+	return children
+}
+
+// foldExpression returns what a constant conditional expression renders, or
+// false if it can't be known at compile time
+func foldExpression(n *astro.Node) (result *astro.Node, ok bool) {
+	children := significantChildren(n)
+	if len(children) == 2 && children[0].Type == astro.TextNode && children[1].Type == astro.ElementNode {
+		if m := andCondition.FindStringSubmatch(children[0].Data); m != nil {
+			truthy, empty, ok := constantCondition(m[1])
+			if !ok || (!truthy && !empty) {
+				return nil, false
+			}
+			if truthy {
+				return children[1], true
+			}
+			return nil, true
+		}
+	}
+	if len(children) == 4 && children[0].Type == astro.TextNode && children[1].Type == astro.ElementNode && children[2].Type == astro.TextNode && children[3].Type == astro.ElementNode {
+		m := ternaryCondition.FindStringSubmatch(children[0].Data)
+		if m == nil || strings.TrimSpace(children[2].Data) != ":" {
+			return nil, false
+		}
+		truthy, _, ok := constantCondition(m[1])
+		if !ok {
+			return nil, false
+		}
+		if truthy {
+			return children[1], true
+		}
+		return children[3], true
+	}
+	return nil, false
+}
+
+// EliminateDeadBranches folds conditional expressions whose condition is a
+// constant, like `{false && <Debug />}` after `define` substitution. Imports
+// which were only used by the removed markup are removed as well.
+func EliminateDeadBranches(doc *astro.Node) {
+	expressions := make([]*astro.Node, 0)
+	walk(doc, func(n *astro.Node) {
+		if n.Type == astro.ElementNode && n.Expression {
+			expressions = append(expressions, n)
+		}
+	})
+
+	removed := make(map[string]bool)
+	for _, n := range expressions {
+		// Expressions inside of a branch that was already removed can be skipped
+		if n.Parent == nil || !isAttached(doc, n) {
+			continue
+		}
+		result, ok := foldExpression(n)
+		if !ok {
+			continue
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c != result {
+				for _, name := range TemplateReferences(c) {
+					removed[name] = true
+				}
+			}
+		}
+		if result != nil {
+			n.RemoveChild(result)
+			n.Parent.InsertBefore(result, n)
+		}
+		n.Parent.RemoveChild(n)
+	}
+
+	if len(removed) > 0 {
+		removeDeadImports(doc, removed)
+	}
+}
+
+func isAttached(doc *astro.Node, n *astro.Node) bool {
+	for p := n; p != nil; p = p.Parent {
+		if p == doc {
+			return true
+		}
+	}
+	return false
+}
+
+// removeDeadImports removes import statements from the frontmatter whose
+// bindings were only referenced by removed markup
+func removeDeadImports(doc *astro.Node, removed map[string]bool) {
+	var text *astro.Node
+	for c := doc.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == astro.FrontmatterNode && c.FirstChild != nil {
+			text = c.FirstChild
+			break
+		}
+	}
+	if text == nil {
+		return
+	}
+
+	source := []byte(text.Data)
+	statements := make([]js_scanner.ImportStatement, 0)
+	pos, statement := js_scanner.NextImportStatement(source, 0)
+	for pos != -1 {
+		statements = append(statements, statement)
+		pos, statement = js_scanner.NextImportStatement(source, pos)
+	}
+
+	rest := []byte(text.Data)
+	for _, statement := range statements {
+		for i := statement.Span.Start; i < statement.Span.End; i++ {
+			rest[i] = ' '
+		}
+	}
+	used := make(map[string]bool)
+	for _, name := range js_scanner.Identifiers(rest) {
+		used[name] = true
+	}
+	for _, name := range TemplateReferences(doc) {
+		used[name] = true
+	}
+
+	// Remove from the end, so the earlier spans stay valid
+	for i := len(statements) - 1; i >= 0; i-- {
+		statement := statements[i]
+		if len(statement.Imports) == 0 {
+			continue
+		}
+		dead := true
+		for _, imported := range statement.Imports {
+			if used[imported.LocalName] || !removed[imported.LocalName] {
+				dead = false
+			}
+		}
+		if dead {
+			text.Data = text.Data[:statement.Span.Start] + strings.TrimLeft(text.Data[statement.Span.End:], " \t\r\n")
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package transform
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestConstantCondition(t *testing.T) {
+	tests := []struct {
+		condition string
+		want      string
+	}{
+		{"true", "truthy"},
+		{"false", "falsy"},
+		{"undefined", "falsy"},
+		{`"production"`, "truthy"},
+		{`''`, "falsy"},
+		{"!true", "falsy"},
+		{`!!"yes"`, "truthy"},
+		{"0", "unknown"},
+		{"mode", "unknown"},
+		{`"a" + b`, "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.condition, func(t *testing.T) {
+			got := "unknown"
+			if truthy, _, ok := constantCondition(tt.condition); ok && truthy {
+				got = "truthy"
+			} else if ok {
+				got = "falsy"
+			}
+			if got != tt.want {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %s\n  got:  %s", tt.condition, tt.want, got))
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package transform
+
+import (
+	"strconv"
+	"strings"
+
+	astro "github.com/snowpackjs/astro/internal"
+)
+
+// Attribute used to pass the amount of removed indentation to the runtime
+const DedentAttribute = "data-astro-dedent"
+
+// isRawContent returns true for nodes whose children are preserved as authored,
+// like `<Markdown>` or any element marked with `data-astro-raw`
+func isRawContent(n *astro.Node) bool {
+	if n.Type != astro.ElementNode {
+		return false
+	}
+	if n.Component && n.Data == "Markdown" {
+		return true
+	}
+	return HasAttr(n, "data-astro-raw")
+}
+
+// DedentRaw removes the common leading indentation from the raw content of n,
+// so nested Markdown inside of indented layouts isn't treated as a code block.
+// The amount of indentation that was removed is returned. Raw content nested
+// inside of other raw content is dedented along with its outermost ancestor.
+func DedentRaw(n *astro.Node) int {
+	if !isRawContent(n) || hasRawAncestor(n) {
+		return 0
+	}
+	amount := minIndent(n)
+	if amount <= 0 {
+		return 0
+	}
+	walk(n, func(c *astro.Node) {
+		if isRawText(c) {
+			c.Data = dedentLines(c.Data, amount)
+		}
+	})
+
+	if n.Component {
+		n.Attr = append(n.Attr, astro.Attribute{
+			Key:  DedentAttribute,
+			Val:  strconv.Itoa(amount),
+			Type: astro.ExpressionAttribute,
+		})
+	} else {
+		n.Attr = append(n.Attr, astro.Attribute{
+			Key:  DedentAttribute,
+			Val:  strconv.Itoa(amount),
+			Type: astro.QuotedAttribute,
+		})
+	}
+	return amount
+}
+
+func hasRawAncestor(n *astro.Node) bool {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if isRawContent(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// minIndent finds the smallest indentation of any non-blank line that starts
+// inside of a descendant TextNode. Returns -1 if no such line exists.
+func minIndent(n *astro.Node) int {
+	smallest := -1
+	walk(n, func(c *astro.Node) {
+		if !isRawText(c) {
+			return
+		}
+		lines := strings.Split(c.Data, "\n")
+		// The first line continues the preceding content, so it isn't indented
+		for _, line := range lines[1:] {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			indent := len(line) - len(strings.TrimLeft(line, " \t"))
+			if smallest == -1 || indent < smallest {
+				smallest = indent
+			}
+		}
+	})
+	return smallest
+}
+
+// Text inside of expressions is JavaScript and should be left alone
+func isRawText(n *astro.Node) bool {
+	return n.Type == astro.TextNode && !(n.Parent != nil && n.Parent.Expression)
+}
+
+func dedentLines(text string, amount int) string {
+	lines := strings.Split(text, "\n")
+	for i := 1; i < len(lines); i++ {
+		line := lines[i]
+		j := 0
+		for j < amount && j < len(line) && (line[j] == ' ' || line[j] == '\t') {
+			j++
+		}
+		lines[i] = line[j:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,71 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"golang.org/x/net/html/atom"
+)
+
+func TestDedentRaw(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "markdown",
+			source: "<Markdown>\n    # Hello\n\n    - a\n      - b\n  </Markdown>",
+			want:   "<Markdown data-astro-dedent={4}>\n# Hello\n\n- a\n  - b\n</Markdown>",
+		},
+		{
+			name:   "raw element",
+			source: "<div data-astro-raw>\n\t\tone\n\t\t\ttwo\n\t</div>",
+			want:   "<div data-astro-raw data-astro-dedent=\"2\">\none\n\ttwo\n</div>",
+		},
+		{
+			name:   "inline first line",
+			source: "<Markdown># Title\n  text\n  more</Markdown>",
+			want:   "<Markdown data-astro-dedent={2}># Title\ntext\nmore</Markdown>",
+		},
+		{
+			name:   "nested element",
+			source: "<Markdown>\n    Hello <span>\n      world</span>\n  </Markdown>",
+			want:   "<Markdown data-astro-dedent={4}>\nHello <span>\n  world</span>\n</Markdown>",
+		},
+		{
+			name:   "nested raw element",
+			source: "<Markdown>\n    # Title\n    <div data-astro-raw>\n      text\n    </div>\n  </Markdown>",
+			want:   "<Markdown data-astro-dedent={4}>\n# Title\n<div data-astro-raw>\n  text\n</div>\n</Markdown>",
+		},
+		{
+			name:   "not indented",
+			source: "<Markdown>\n# Hello\n</Markdown>",
+			want:   "<Markdown>\n# Hello\n</Markdown>",
+		},
+		{
+			name:   "not raw",
+			source: "<div>\n    text\n  </div>",
+			want:   "<div>\n    text\n  </div>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := astro.ParseFragment(strings.NewReader(tt.source), &astro.Node{Type: astro.ElementNode, DataAtom: atom.Body, Data: atom.Body.String()})
+			if err != nil {
+				t.Error(err)
+			}
+			walk(nodes[0], func(n *astro.Node) {
+				DedentRaw(n)
+			})
+			var b strings.Builder
+			astro.PrintToSource(&b, nodes[0])
+			got := b.String()
+			if tt.want != got {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %s\n  got:  %s", tt.name, tt.want, got))
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package transform
+
+import (
+	"strings"
+
+	astro "github.com/snowpackjs/astro/internal"
+)
+
+// The variable the printer declares with the custom properties of every
+// define:vars, once per component
+const DefinedVars = "$$definedVars"
+
+// DefineStyleVars moves the define:vars of hoisted styles onto the style
+// attribute of the root elements of the template, where the custom properties
+// are inherited by everything the component renders. If the template has no
+// root element to set them on, the styles keep their define:vars.
+func DefineStyleVars(doc *astro.Node) {
+	values := make([]string, 0)
+	for _, style := range doc.Styles {
+		for _, attr := range style.Attr {
+			if attr.Key == "define:vars" && attr.Type == astro.ExpressionAttribute {
+				values = append(values, strings.TrimSpace(attr.Val))
+			}
+		}
+	}
+	if len(values) == 0 {
+		return
+	}
+
+	found := false
+	walk(doc, func(n *astro.Node) {
+		if !isRootElement(n) || n.Component || n.CustomElement || IsDeferred(n) || NeverScopedElements[n.Data] {
+			return
+		}
+		mergeDefinedVars(n)
+		found = true
+	})
+	if !found {
+		return
+	}
+	doc.DefinedVars = values
+	for _, style := range doc.Styles {
+		attrs := make([]astro.Attribute, 0, len(style.Attr))
+		for _, attr := range style.Attr {
+			if attr.Key != "define:vars" || attr.Type != astro.ExpressionAttribute {
+				attrs = append(attrs, attr)
+			}
+		}
+		style.Attr = attrs
+	}
+}
+
+var templateLiteralEscaper = strings.NewReplacer("\\", "\\\\", "`", "\\`", "${", "\\${")
+
+// mergeDefinedVars adds the custom properties to the style attribute of n.
+// A static style is merged with them at compile time, so the element never
+// has two style attributes. Anything else is rendered with them as a
+// `[style, vars]` pair, which the runtime joins.
+func mergeDefinedVars(n *astro.Node) {
+	for i, attr := range n.Attr {
+		if attr.Key != "style" || attr.Namespace != "" {
+			continue
+		}
+		switch attr.Type {
+		case astro.EmptyAttribute:
+			attr.Type = astro.ExpressionAttribute
+			attr.Val = DefinedVars
+		case astro.QuotedAttribute, astro.TemplateLiteralAttribute:
+			value := strings.TrimRight(strings.TrimSpace(attr.Val), ";")
+			if attr.Type == astro.QuotedAttribute {
+				value = templateLiteralEscaper.Replace(value)
+			}
+			attr.Type = astro.TemplateLiteralAttribute
+			if value == "" {
+				attr.Val = "${" + DefinedVars + "}"
+			} else {
+				attr.Val = value + "; ${" + DefinedVars + "}"
+			}
+		case astro.ShorthandAttribute:
+			attr.Type = astro.ExpressionAttribute
+			attr.Val = "[" + attr.Key + ", " + DefinedVars + "]"
+		case astro.ExpressionAttribute:
+			attr.Val = "[" + attr.Val + ", " + DefinedVars + "]"
+		default:
+			continue
+		}
+		n.Attr[i] = attr
+		return
+	}
+	// A spread may set the style too, so the custom properties are merged into
+	// whatever style the last spread has
+	if mergeIntoSpread(n, "style", DefinedVars) {
+		return
+	}
+	n.Attr = append(n.Attr, astro.Attribute{
+		Key:  "style",
+		Type: astro.ExpressionAttribute,
+		Val:  DefinedVars,
+	})
+}
@@ -0,0 +1,35 @@
+package transform
+
+import (
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/js_scanner"
+)
+
+// Define replaces the keys of defines, like `import.meta.env.MODE`, with their
+// values everywhere JavaScript appears: the frontmatter, expressions and
+// expression attributes
+func Define(doc *astro.Node, defines map[string]string) {
+	if len(defines) == 0 {
+		return
+	}
+	replace := func(source string) string {
+		return string(js_scanner.ReplaceDefines([]byte(source), defines))
+	}
+	walk(doc, func(n *astro.Node) {
+		if n.Type == astro.TextNode && n.Parent != nil && (n.Parent.Expression || n.Parent.Type == astro.FrontmatterNode) {
+			n.Data = replace(n.Data)
+			return
+		}
+		for i, attr := range n.Attr {
+			switch attr.Type {
+			case astro.ExpressionAttribute:
+				n.Attr[i].Val = replace(attr.Val)
+			case astro.TemplateLiteralAttribute:
+				value := replace("`" + attr.Val + "`")
+				n.Attr[i].Val = value[1 : len(value)-1]
+			case astro.SpreadAttribute, astro.ShorthandAttribute:
+				n.Attr[i].Key = replace(attr.Key)
+			}
+		}
+	})
+}
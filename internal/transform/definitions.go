@@ -0,0 +1,77 @@
+package transform
+
+import (
+	"strings"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/js_scanner"
+	"github.com/snowpackjs/astro/internal/loc"
+)
+
+// ComponentDefinition links a component tag in the template to the
+// frontmatter import which defines it, so editors can jump from `<Card>` to
+// the import or to the file it imports
+type ComponentDefinition struct {
+	// The name of the tag, like `Card` or `Card.Header`
+	Name string
+	// The location of the tag name
+	Loc loc.Loc
+	// The location of the import statement
+	Import     loc.Range
+	Specifier  string
+	ExportName string
+}
+
+// ComponentDefinitions returns a definition for every component tag of the
+// template whose name is imported in the frontmatter, in the order that it
+// appears. Components which are declared any other way are left out.
+func ComponentDefinitions(doc *astro.Node) []ComponentDefinition {
+	imports := frontmatterImports(doc)
+	definitions := make([]ComponentDefinition, 0)
+	walk(doc, func(n *astro.Node) {
+		if n.Type != astro.ElementNode || !n.Component || len(n.Loc) == 0 || DynamicTag(n) != nil {
+			return
+		}
+		d, ok := imports[strings.Split(n.Data, ".")[0]]
+		if !ok {
+			return
+		}
+		definitions = append(definitions, ComponentDefinition{
+			Name:       n.Data,
+			Loc:        loc.Loc{Start: n.Loc[0].Start + 1},
+			Import:     d.r,
+			Specifier:  d.statement.Specifier,
+			ExportName: d.imported.ExportName,
+		})
+	})
+	return definitions
+}
+
+type frontmatterImport struct {
+	statement js_scanner.ImportStatement
+	imported  js_scanner.Import
+	r         loc.Range
+}
+
+// frontmatterImports returns the import of every name the frontmatter
+// imports, with the range of its import statement
+func frontmatterImports(doc *astro.Node) map[string]frontmatterImport {
+	imports := make(map[string]frontmatterImport)
+	for c := doc.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != astro.FrontmatterNode || c.FirstChild == nil || len(c.FirstChild.Loc) == 0 {
+			continue
+		}
+		text := c.FirstChild
+		source := []byte(text.Data)
+		pos, statement := js_scanner.NextImportStatement(source, 0)
+		for pos != -1 {
+			span := strings.TrimSpace(text.Data[statement.Span.Start:statement.Span.End])
+			r := loc.Range{Loc: loc.Loc{Start: text.Loc[0].Start + statement.Span.Start}, Len: len(span)}
+			for _, imported := range statement.Imports {
+				imports[imported.LocalName] = frontmatterImport{statement, imported, r}
+			}
+			pos, statement = js_scanner.NextImportStatement(source, pos)
+		}
+	}
+	return imports
+}
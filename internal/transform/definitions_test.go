@@ -0,0 +1,68 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	astro "github.com/snowpackjs/astro/internal"
+)
+
+func TestComponentDefinitions(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name: "default import",
+			source: `---
+import Card from '../components/Card.astro';
+---
+<Card><Card /></Card>`,
+			want: []string{
+				"Card -> import Card from '../components/Card.astro'; (../components/Card.astro default)",
+				"Card -> import Card from '../components/Card.astro'; (../components/Card.astro default)",
+			},
+		},
+		{
+			name: "named and namespace imports",
+			source: `---
+import { Counter as Count } from '@components/counter';
+import * as UI from './ui';
+---
+<Count client:load /><UI.Button />`,
+			want: []string{
+				"Count -> import { Counter as Count } from '@components/counter'; (@components/counter Counter)",
+				"UI.Button -> import * as UI from './ui'; (./ui *)",
+			},
+		},
+		{
+			name: "undefined and declared components",
+			source: `---
+const Heading = 'h1';
+---
+<Heading /><Missing /><Element is={Heading} />`,
+			want: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := astro.Parse(strings.NewReader(tt.source))
+			if err != nil {
+				t.Error(err)
+			}
+			got := make([]string, 0)
+			for _, d := range ComponentDefinitions(doc) {
+				if !strings.HasPrefix(tt.source[d.Loc.Start:], d.Name) {
+					t.Errorf("%s is not at %d", d.Name, d.Loc.Start)
+				}
+				statement := tt.source[d.Import.Loc.Start:d.Import.End()]
+				got = append(got, fmt.Sprintf("%s -> %s (%s %s)", d.Name, statement, d.Specifier, d.ExportName))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %q\n  got:  %q", tt.name, tt.want, got))
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/handler"
+	"github.com/snowpackjs/astro/internal/loc"
+)
+
+// Every directive the compiler understands, by namespace. Attributes in one of
+// these namespaces which aren't listed are most likely typos.
+var directives = map[string][]string{
+	"client":     {"load", "idle", "visible", "media", "only"},
+	"define":     {"vars"},
+	"server":     {"defer"},
+	"transition": {"name", "animate", "persist"},
+	"set":        {},
+	"is":         {"ignore", "critical"},
+}
+
+// IgnoreDirective marks an element whose children are left as authored, for
+// markup that belongs to another templating engine like Vue or Alpine
+const IgnoreDirective = "is:ignore"
+
+// CriticalDirective marks a style which build tools should inline into the
+// head of the page, while the rest of the styles can be loaded later
+const CriticalDirective = "is:critical"
+
+// IsCritical reports whether n is an element with the `is:critical` directive
+func IsCritical(n *astro.Node) bool {
+	return n.Type == astro.ElementNode && HasAttr(n, CriticalDirective)
+}
+
+func splitDirective(key string) (namespace string, name string, ok bool) {
+	i := strings.IndexByte(key, ':')
+	if i == -1 {
+		return "", "", false
+	}
+	namespace = key[:i]
+	if _, ok := directives[namespace]; !ok {
+		return "", "", false
+	}
+	return namespace, key[i+1:], true
+}
+
+func IsKnownDirective(key string) bool {
+	namespace, name, ok := splitDirective(key)
+	if !ok {
+		return false
+	}
+	for _, directive := range directives[namespace] {
+		if name == directive {
+			return true
+		}
+	}
+	return false
+}
+
+// UnknownDirectives warns about attributes in a directive namespace, like
+// `client:lod`, that the compiler doesn't recognize
+func UnknownDirectives(n *astro.Node, h *handler.Handler) {
+	if n.Type != astro.ElementNode {
+		return
+	}
+	for _, attr := range n.Attr {
+		if attr.Type == astro.SpreadAttribute {
+			continue
+		}
+		namespace, _, ok := splitDirective(attr.Key)
+		if !ok || IsKnownDirective(attr.Key) {
+			continue
+		}
+		warning := &loc.ErrorWithRange{
+			Code:  loc.WARNING_UNKNOWN_DIRECTIVE,
+			Text:  fmt.Sprintf("Unknown directive %s", attr.Key),
+			Range: loc.Range{Loc: attr.KeyLoc, Len: len(attr.Key)},
+		}
+		candidates := make(map[string]bool)
+		for _, directive := range directives[namespace] {
+			candidates[namespace+":"+directive] = true
+		}
+		if suggestion := closestMatch(attr.Key, candidates); suggestion != "" {
+			warning.Hint = fmt.Sprintf("Did you mean %s?", suggestion)
+			warning.Fixes = []loc.Fix{{
+				Title: fmt.Sprintf("Change to %s", suggestion),
+				Range: warning.Range,
+				Text:  suggestion,
+			}}
+		}
+		h.AppendWarning(warning)
+	}
+}
@@ -0,0 +1,75 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/handler"
+	"golang.org/x/net/html/atom"
+)
+
+func TestUnknownDirectives(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "known",
+			source: `<Counter client:load /><Counter client:media="(max-width: 50em)" /><style define:vars={{ color }}></style>`,
+			want:   []string{},
+		},
+		{
+			name:   "typo",
+			source: `<Counter client:lod />`,
+			want:   []string{"client:lod Unknown directive client:lod (Did you mean client:load?)"},
+		},
+		{
+			name:   "typo on element",
+			source: `<style define:var={{ color }}></style>`,
+			want:   []string{"define:var Unknown directive define:var (Did you mean define:vars?)"},
+		},
+		{
+			name:   "no suggestion",
+			source: `<Counter client:whenever />`,
+			want:   []string{"client:whenever Unknown directive client:whenever"},
+		},
+		{
+			name:   "reserved namespaces",
+			source: `<div set:html={html} is:foo is:ignore></div>`,
+			want:   []string{"set:html Unknown directive set:html", "is:foo Unknown directive is:foo"},
+		},
+		{
+			name:   "other namespaces",
+			source: `<svg xmlns:xlink="http://www.w3.org/1999/xlink"><use xlink:href="#a" /></svg><div x-on:click="go"></div>`,
+			want:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := astro.ParseFragment(strings.NewReader(tt.source), &astro.Node{Type: astro.ElementNode, DataAtom: atom.Body, Data: atom.Body.String()})
+			if err != nil {
+				t.Error(err)
+			}
+			h := handler.NewHandler(tt.source, "TestUnknownDirectives.astro")
+			for _, n := range nodes {
+				walk(n, func(n *astro.Node) {
+					UnknownDirectives(n, h)
+				})
+			}
+			got := make([]string, 0)
+			for _, w := range h.Warnings() {
+				text := fmt.Sprintf("%s %s", tt.source[w.Location.Column-1:w.Location.Column-1+w.Location.Length], w.Text)
+				if w.Hint != "" {
+					text += fmt.Sprintf(" (%s)", w.Hint)
+				}
+				got = append(got, text)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %q\n  got:  %q", tt.name, tt.want, got))
+			}
+		})
+	}
+}
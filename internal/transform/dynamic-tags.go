@@ -0,0 +1,30 @@
+package transform
+
+import (
+	astro "github.com/snowpackjs/astro/internal"
+)
+
+// DynamicTagName is a built-in component which renders whatever component or
+// element its `is` expression evaluates to, like `<Element is={tag}>`
+const DynamicTagName = "Element"
+
+// DynamicTag returns the `is` attribute which selects the tag of a dynamic
+// element, or nil if n isn't a dynamic element. A component the frontmatter
+// imports or declares as `Element` is rendered like any other component.
+func DynamicTag(n *astro.Node) *astro.Attribute {
+	if n.Type != astro.ElementNode || n.Data != DynamicTagName {
+		return nil
+	}
+	attr := astro.GetAttribute(n, "is")
+	if attr == nil || attr.Type != astro.ExpressionAttribute {
+		return nil
+	}
+	doc := n
+	for doc.Parent != nil {
+		doc = doc.Parent
+	}
+	if FrontmatterDeclarations(doc)[DynamicTagName] {
+		return nil
+	}
+	return attr
+}
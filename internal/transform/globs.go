@@ -0,0 +1,33 @@
+package transform
+
+import (
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/js_scanner"
+	"github.com/snowpackjs/astro/internal/loc"
+)
+
+// Glob is a pattern passed to `Astro.glob()` in the frontmatter
+type Glob struct {
+	Pattern string
+	Loc     loc.Loc
+}
+
+// CollectGlobs returns the pattern of every `Astro.glob()` call in the
+// frontmatter, in order, so a bundler can turn them into glob imports and
+// watch the files they match. Patterns which aren't string literals are left
+// out.
+func CollectGlobs(doc *astro.Node) []Glob {
+	globs := make([]Glob, 0)
+	text := frontmatterText(doc)
+	if text == nil {
+		return globs
+	}
+	for _, call := range js_scanner.AstroGlobs([]byte(text.Data)) {
+		g := Glob{Pattern: call.Pattern}
+		if len(text.Loc) > 0 {
+			g.Loc = loc.Loc{Start: text.Loc[0].Start + call.Start}
+		}
+		globs = append(globs, g)
+	}
+	return globs
+}
@@ -0,0 +1,53 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	astro "github.com/snowpackjs/astro/internal"
+)
+
+func TestCollectGlobs(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "globs",
+			source: "---\nconst posts = await Astro.glob('../posts/*.md');\nconst pages = await Astro.glob(`./**/*.mdx`);\n---\n<div />",
+			want:   []string{"../posts/*.md@36", "./**/*.mdx@85"},
+		},
+		{
+			name:   "dynamic",
+			source: "---\nconst dir = 'posts';\nconst a = await Astro.glob(`../${dir}/*.md`);\nconst b = await Astro.glob(pattern);\n---\n<div />",
+			want:   []string{},
+		},
+		{
+			name:   "not astro",
+			source: "---\n// Astro.glob('./a/*.md')\nconst a = props.Astro.glob('./b/*.md');\nconst b = \"Astro.glob('./c/*.md')\";\n---\n<div />",
+			want:   []string{},
+		},
+		{
+			name:   "no frontmatter",
+			source: "<div>{Astro.glob('./a/*.md')}</div>",
+			want:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := astro.Parse(strings.NewReader(tt.source))
+			if err != nil {
+				t.Error(err)
+			}
+			got := make([]string, 0)
+			for _, g := range CollectGlobs(doc) {
+				got = append(got, fmt.Sprintf("%s@%d", g.Pattern, g.Loc.Start))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %v\n  got:  %v", tt.name, tt.want, got))
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/escape"
+	"github.com/snowpackjs/astro/internal/loc"
+	a "golang.org/x/net/html/atom"
+)
+
+// Message is a piece of template text which can be translated
+type Message struct {
+	// The text with its whitespace collapsed, used to look up translations
+	ID string
+	// The closest element around the text, which gives translators context
+	Element string
+	Loc     loc.Loc
+}
+
+// isTranslatable returns true for text nodes which are rendered as text, outside
+// of any element whose content is code or has to be preserved as authored
+func isTranslatable(n *astro.Node) bool {
+	if n.Type != astro.TextNode || strings.TrimSpace(n.Data) == "" || !isRawText(n) {
+		return false
+	}
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.Type == astro.FrontmatterNode || isRawContent(p) {
+			return false
+		}
+		switch p.DataAtom {
+		case a.Script, a.Style, a.Pre, a.Textarea:
+			return false
+		}
+		if translate := astro.GetAttribute(p, "translate"); translate != nil && translate.Val == "no" {
+			return false
+		}
+	}
+	return true
+}
+
+func closestElement(n *astro.Node) string {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.Type == astro.ElementNode && !p.Expression {
+			return p.Data
+		}
+	}
+	return ""
+}
+
+func messageID(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// ExtractMessages returns every piece of translatable text in the template, in
+// document order
+func ExtractMessages(doc *astro.Node) []Message {
+	messages := make([]Message, 0)
+	walk(doc, func(n *astro.Node) {
+		if !isTranslatable(n) {
+			return
+		}
+		m := Message{ID: messageID(n.Data), Element: closestElement(n)}
+		if len(n.Loc) > 0 {
+			leading := len(n.Data) - len(strings.TrimLeft(n.Data, " \t\r\n\f"))
+			m.Loc = loc.Loc{Start: n.Loc[0].Start + leading}
+		}
+		messages = append(messages, m)
+	})
+	return messages
+}
+
+// TranslateText replaces every piece of translatable text with a call to the
+// helper function, like `{t("Hello world")}`. Whitespace around the text is kept
+// as it is, so the layout doesn't change.
+func TranslateText(doc *astro.Node, helper string) {
+	texts := make([]*astro.Node, 0)
+	walk(doc, func(n *astro.Node) {
+		if isTranslatable(n) {
+			texts = append(texts, n)
+		}
+	})
+	for _, n := range texts {
+		trimmed := strings.TrimLeft(n.Data, " \t\r\n\f")
+		leading := n.Data[:len(n.Data)-len(trimmed)]
+		trimmed = strings.TrimRight(trimmed, " \t\r\n\f")
+		trailing := n.Data[len(leading)+len(trimmed):]
+
+		start := 0
+		if len(n.Loc) > 0 {
+			start = n.Loc[0].Start
+		}
+		if leading != "" {
+			n.Parent.InsertBefore(&astro.Node{Type: astro.TextNode, Data: leading, Loc: []loc.Loc{{Start: start}}}, n)
+		}
+		expr := &astro.Node{
+			Type:       astro.ElementNode,
+			DataAtom:   a.Template,
+			Data:       "astro:expression",
+			Attr:       make([]astro.Attribute, 0),
+			Expression: true,
+			Loc:        []loc.Loc{{Start: start + len(leading)}},
+		}
+		expr.AppendChild(&astro.Node{
+			Type: astro.TextNode,
+			Data: fmt.Sprintf("%s(%s)", helper, escape.JSONString(messageID(trimmed))),
+			Loc:  []loc.Loc{{Start: start + len(leading)}},
+		})
+		n.Parent.InsertBefore(expr, n)
+		if trailing != "" {
+			n.Parent.InsertBefore(&astro.Node{Type: astro.TextNode, Data: trailing, Loc: []loc.Loc{{Start: start + len(leading) + len(trimmed)}}}, n)
+		}
+		n.Parent.RemoveChild(n)
+	}
+}
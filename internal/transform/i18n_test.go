@@ -0,0 +1,53 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"golang.org/x/net/html/atom"
+)
+
+func TestExtractMessages(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "text",
+			source: "<h1>  Hello\n  world </h1><p>Hi <b>there</b></p>",
+			want:   []string{"Hello world@h1:6", "Hi@p:28", "there@b:34"},
+		},
+		{
+			name:   "inside expressions",
+			source: `{cond && <span>Yes</span>}<Card>Slot</Card>`,
+			want:   []string{"Yes@span:15", "Slot@Card:32"},
+		},
+		{
+			name:   "skipped",
+			source: `<pre> code </pre><p translate="no">Astro</p><div data-astro-raw># Title</div><script>a</script>`,
+			want:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := astro.ParseFragment(strings.NewReader(tt.source), &astro.Node{Type: astro.ElementNode, DataAtom: atom.Body, Data: atom.Body.String()})
+			if err != nil {
+				t.Error(err)
+			}
+			doc := &astro.Node{Type: astro.DocumentNode}
+			for _, n := range nodes {
+				doc.AppendChild(n)
+			}
+			got := make([]string, 0)
+			for _, m := range ExtractMessages(doc) {
+				got = append(got, fmt.Sprintf("%s@%s:%d", m.ID, m.Element, m.Loc.Start))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %q\n  got:  %q", tt.name, tt.want, got))
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/handler"
+	"github.com/snowpackjs/astro/internal/loc"
+)
+
+// ImageHints warns about `<img>` elements that are likely to hurt accessibility
+// or performance: missing alt text, missing dimensions, or a src which points
+// to a path on disk rather than a URL.
+func ImageHints(n *astro.Node, h *handler.Handler) {
+	if n.Type != astro.ElementNode || n.Component || n.Data != "img" || len(n.Loc) == 0 {
+		return
+	}
+	// A spread may provide any attribute, so there's nothing we can know for sure
+	for _, attr := range n.Attr {
+		if attr.Type == astro.SpreadAttribute {
+			return
+		}
+	}
+	tag := loc.Range{Loc: n.Loc[0], Len: len(n.Data) + 1}
+
+	if !HasAttr(n, "alt") {
+		h.AppendWarning(&loc.ErrorWithRange{
+			Code:  loc.WARNING_IMAGE_MISSING_ALT,
+			Text:  "<img> is missing an alt attribute",
+			Hint:  `Describe the image with alt="...", or use alt="" if it is decorative`,
+			Range: tag,
+		})
+	}
+
+	var missing []string
+	for _, key := range []string{"width", "height"} {
+		if !HasAttr(n, key) {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		h.AppendWarning(&loc.ErrorWithRange{
+			Code:  loc.WARNING_IMAGE_MISSING_DIMENSIONS,
+			Text:  fmt.Sprintf("<img> is missing %s", strings.Join(missing, " and ")),
+			Hint:  "Images without explicit dimensions cause layout shift while loading",
+			Range: tag,
+		})
+	}
+
+	if src := astro.GetAttribute(n, "src"); src != nil && src.Type == astro.QuotedAttribute && isLocalPath(src.Val) {
+		h.AppendWarning(&loc.ErrorWithRange{
+			Code:  loc.WARNING_IMAGE_LOCAL_PATH,
+			Text:  fmt.Sprintf("<img> src %q points to a file path, not a URL", src.Val),
+			Hint:  "Reference files in public/ from the site root, like src=\"/image.png\", or import the image in the frontmatter",
+			Range: loc.Range{Loc: src.ValLoc, Len: len(src.Val)},
+		})
+	}
+}
+
+// Paths which only make sense on the machine that built the site
+func isLocalPath(src string) bool {
+	if strings.HasPrefix(src, "file:") || strings.HasPrefix(src, "/public/") || strings.HasPrefix(src, "/src/") {
+		return true
+	}
+	// Windows drive letters, like C:\ or C:/
+	return len(src) > 2 && src[1] == ':' && (src[2] == '\\' || src[2] == '/') &&
+		(src[0] >= 'a' && src[0] <= 'z' || src[0] >= 'A' && src[0] <= 'Z')
+}
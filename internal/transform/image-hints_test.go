@@ -0,0 +1,87 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/handler"
+	"golang.org/x/net/html/atom"
+)
+
+func TestImageHints(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "complete",
+			source: `<img src="/a.png" alt="A" width="10" height="10">`,
+			want:   []string{},
+		},
+		{
+			name:   "decorative",
+			source: `<img src="/a.png" alt="" width={w} height={h}>`,
+			want:   []string{},
+		},
+		{
+			name:   "missing alt",
+			source: `<img src="/a.png" width="10" height="10">`,
+			want:   []string{"2001 <img <img> is missing an alt attribute"},
+		},
+		{
+			name:   "missing dimensions",
+			source: `<img src="/a.png" alt="A" width="10">`,
+			want:   []string{"2002 <img <img> is missing height"},
+		},
+		{
+			name:   "missing everything",
+			source: `<div><img></div>`,
+			want:   []string{"2001 <img <img> is missing an alt attribute", "2002 <img <img> is missing width and height"},
+		},
+		{
+			name:   "public path",
+			source: `<img src="/public/a.png" alt="A" width="10" height="10">`,
+			want:   []string{"2003 /public/a.png <img> src \"/public/a.png\" points to a file path, not a URL"},
+		},
+		{
+			name:   "windows path",
+			source: `<img src="C:\images\a.png" alt="A" width="10" height="10">`,
+			want:   []string{"2003 C:\\images\\a.png <img> src \"C:\\\\images\\\\a.png\" points to a file path, not a URL"},
+		},
+		{
+			name:   "spread",
+			source: `<img {...props}>`,
+			want:   []string{},
+		},
+		{
+			name:   "component",
+			source: `<Image src="/public/a.png" />`,
+			want:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := astro.ParseFragment(strings.NewReader(tt.source), &astro.Node{Type: astro.ElementNode, DataAtom: atom.Body, Data: atom.Body.String()})
+			if err != nil {
+				t.Error(err)
+			}
+			h := handler.NewHandler(tt.source, "TestImageHints.astro")
+			for _, n := range nodes {
+				walk(n, func(n *astro.Node) {
+					ImageHints(n, h)
+				})
+			}
+			got := make([]string, 0)
+			for _, w := range h.Warnings() {
+				text := tt.source[w.Location.Column-1 : w.Location.Column-1+w.Location.Length]
+				got = append(got, fmt.Sprintf("%d %s %s", w.Code, text, w.Text))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %q\n  got:  %q", tt.name, tt.want, got))
+			}
+		})
+	}
+}
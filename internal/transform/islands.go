@@ -0,0 +1,55 @@
+package transform
+
+import (
+	"strings"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/loc"
+)
+
+// Island is a component which is hydrated on the client
+type Island struct {
+	Name string
+	// The client directive without its namespace, like "idle"
+	Directive string
+	Loc       loc.Loc
+}
+
+// Islands returns every hydrated component in document order, so runtimes can
+// decide which ones to hydrate first
+func Islands(doc *astro.Node) []Island {
+	islands := make([]Island, 0)
+	for _, n := range IslandNodes(doc) {
+		island := Island{Name: n.Data, Directive: strings.TrimPrefix(hydrationDirective(n), "client:")}
+		if len(n.Loc) > 0 {
+			island.Loc = n.Loc[0]
+		}
+		islands = append(islands, island)
+	}
+	return islands
+}
+
+// IslandNodes returns the element of every hydrated component, in the same
+// order as Islands
+func IslandNodes(doc *astro.Node) []*astro.Node {
+	nodes := make([]*astro.Node, 0)
+	walk(doc, func(n *astro.Node) {
+		if n.Type != astro.ElementNode || !(n.Component || n.CustomElement) || DynamicTag(n) != nil {
+			return
+		}
+		if hydrationDirective(n) != "" {
+			nodes = append(nodes, n)
+		}
+	})
+	return nodes
+}
+
+// hydrationDirective returns the first known client directive of n
+func hydrationDirective(n *astro.Node) string {
+	for _, attr := range n.Attr {
+		if strings.HasPrefix(attr.Key, "client:") && IsKnownDirective(attr.Key) {
+			return attr.Key
+		}
+	}
+	return ""
+}
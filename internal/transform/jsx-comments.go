@@ -0,0 +1,78 @@
+package transform
+
+import (
+	"io"
+	"strings"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/escape"
+	"github.com/tdewolff/parse/v2"
+	"github.com/tdewolff/parse/v2/js"
+)
+
+// expressionComments returns the text of every comment in source, or false if
+// source contains anything besides comments and whitespace
+func expressionComments(source string) ([]string, bool) {
+	comments := make([]string, 0)
+	l := js.NewLexer(parse.NewInputString(source))
+	for {
+		token, value := l.Next()
+		switch token {
+		case js.ErrorToken:
+			// EOF or other error
+			return comments, l.Err() == io.EOF
+		case js.WhitespaceToken, js.LineTerminatorToken:
+			continue
+		case js.CommentToken, js.CommentLineTerminatorToken:
+			text := string(value)
+			if strings.HasPrefix(text, "//") {
+				text = text[2:]
+			} else {
+				text = strings.TrimSuffix(strings.TrimPrefix(text, "/*"), "*/")
+			}
+			comments = append(comments, text)
+		default:
+			return nil, false
+		}
+	}
+}
+
+// JSXComments removes expressions which only contain comments, like
+// `{/* TODO */}`, since an empty expression isn't valid JavaScript. With
+// preserve, they're turned into HTML comments instead.
+func JSXComments(doc *astro.Node, preserve bool) {
+	expressions := make([]*astro.Node, 0)
+	walk(doc, func(n *astro.Node) {
+		if n.Type == astro.ElementNode && n.Expression && n.Parent != nil {
+			expressions = append(expressions, n)
+		}
+	})
+	for _, n := range expressions {
+		comments, ok := onlyComments(n)
+		if !ok {
+			continue
+		}
+		if preserve {
+			for _, comment := range comments {
+				n.Parent.InsertBefore(&astro.Node{
+					Type: astro.CommentNode,
+					Data: escape.HTMLComment(comment),
+					Loc:  n.Loc,
+				}, n)
+			}
+		}
+		n.Parent.RemoveChild(n)
+	}
+}
+
+func onlyComments(n *astro.Node) ([]string, bool) {
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != astro.TextNode {
+			return nil, false
+		}
+		b.WriteString(c.Data)
+	}
+	comments, ok := expressionComments(b.String())
+	return comments, ok && len(comments) > 0
+}
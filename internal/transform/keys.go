@@ -0,0 +1,46 @@
+package transform
+
+import (
+	astro "github.com/snowpackjs/astro/internal"
+)
+
+// Compiler-private prop used to pass the key of a list item to the runtime
+const KeyProp = "astro:key"
+
+// AddKey passes the `key` of a component rendered inside of an expression,
+// like `{items.map(item => <Card key={item.id} />)}`, to $$renderComponent as
+// well, so diffing runtimes and island reuse can tell the items of a list
+// apart. The authored `key` is kept, so it's still in `Astro.props`, and keys
+// of plain elements are rendered as authored.
+func AddKey(n *astro.Node) {
+	if n.Type != astro.ElementNode || !(n.Component || n.CustomElement) || n.Fragment || !insideExpression(n) {
+		return
+	}
+	for _, attr := range n.Attr {
+		if attr.Key != "key" {
+			continue
+		}
+		key := attr
+		key.Key = KeyProp
+		switch attr.Type {
+		case astro.ShorthandAttribute:
+			key.Type = astro.ExpressionAttribute
+			key.Val = attr.Key
+			key.ValLoc = attr.KeyLoc
+		case astro.QuotedAttribute, astro.ExpressionAttribute, astro.TemplateLiteralAttribute:
+		default:
+			continue
+		}
+		n.Attr = append(n.Attr, key)
+		return
+	}
+}
+
+func insideExpression(n *astro.Node) bool {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.Expression {
+			return true
+		}
+	}
+	return false
+}
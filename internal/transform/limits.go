@@ -0,0 +1,64 @@
+package transform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/handler"
+	"github.com/snowpackjs/astro/internal/loc"
+)
+
+// CheckInputSize reports an error and returns false if source is larger than
+// opts.MaxInputSize. It should be called before source is parsed.
+func CheckInputSize(source string, opts TransformOptions, h *handler.Handler) bool {
+	if opts.MaxInputSize == 0 || len(source) <= opts.MaxInputSize {
+		return true
+	}
+	h.AppendError(&loc.ErrorWithRange{
+		Code:  loc.ERROR_INPUT_TOO_LARGE,
+		Text:  fmt.Sprintf("Input is %d bytes, which is more than the limit of %d bytes", len(source), opts.MaxInputSize),
+		Range: loc.Range{Loc: loc.Loc{Start: 0}},
+	})
+	return false
+}
+
+// ParseLimits returns the parse options which enforce opts.MaxNodes and
+// opts.MaxExpressionDepth as nodes are created, and abandon the parse once
+// ctx is done
+func ParseLimits(ctx context.Context, opts TransformOptions) []astro.ParseOption {
+	return []astro.ParseOption{
+		astro.ParseOptionContext(ctx),
+		astro.ParseOptionLimits(opts.MaxNodes, opts.MaxExpressionDepth),
+	}
+}
+
+// ParseError reports err, returned by a parse with the options of
+// ParseLimits, as an error diagnostic if a limit ended the parse, and returns
+// the error the compile should fail with
+func ParseError(ctx context.Context, err error, opts TransformOptions, h *handler.Handler) error {
+	var limit *loc.ErrorWithRange
+	if errors.As(err, &limit) {
+		h.AppendError(limit)
+		return h.Error()
+	}
+	if ctx.Err() != nil {
+		return CheckContext(ctx, opts, h)
+	}
+	return err
+}
+
+// CheckContext returns ctx.Err(), and reports an error if the compile ran out
+// of the time given by opts.Timeout. It should be called between phases.
+func CheckContext(ctx context.Context, opts TransformOptions, h *handler.Handler) error {
+	err := ctx.Err()
+	if err != nil && opts.Timeout > 0 && errors.Is(err, context.DeadlineExceeded) {
+		h.AppendError(&loc.ErrorWithRange{
+			Code:  loc.ERROR_COMPILE_TIMEOUT,
+			Text:  fmt.Sprintf("Compile took longer than the limit of %s", opts.Timeout),
+			Range: loc.Range{Loc: loc.Loc{Start: 0}},
+		})
+	}
+	return err
+}
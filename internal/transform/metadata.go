@@ -0,0 +1,95 @@
+package transform
+
+import (
+	"strings"
+
+	astro "github.com/snowpackjs/astro/internal"
+)
+
+// NodeMetadata is what the compiler did with an element of the template, for
+// language servers to show in hovers
+type NodeMetadata struct {
+	// The directives of the element as authored, like `client:visible`
+	Directives []string
+	// The client directive of a hydrated island without its namespace, like
+	// "visible", or empty if the element isn't hydrated
+	Hydration string
+	// The class or attribute which scoped styles match the element by
+	Scope string
+	// The named slot the element is passed to
+	Slot string
+}
+
+// Attributes which the compiler adds in the namespace of a directive
+var generatedDirectives = map[string]bool{
+	"client:component-path":      true,
+	"client:component-export":    true,
+	"client:props-serialization": true,
+	"client:props-id":            true,
+}
+
+// Metadata returns the metadata of every element which has any, keyed by the
+// position of its start tag. It must run after Transform, which decides how
+// elements are hydrated and scoped.
+func Metadata(doc *astro.Node, opts TransformOptions) map[int]NodeMetadata {
+	hydrated := make(map[*astro.Node]bool)
+	for _, n := range doc.HydratedComponents {
+		hydrated[n] = true
+	}
+	for _, n := range doc.ClientOnlyComponents {
+		hydrated[n] = true
+	}
+
+	metadata := make(map[int]NodeMetadata)
+	add := func(n *astro.Node) {
+		if n.Type != astro.ElementNode || len(n.Loc) == 0 || IsImplictNode(n) {
+			return
+		}
+		m := NodeMetadata{Directives: make([]string, 0)}
+		for _, attr := range n.Attr {
+			if _, _, ok := splitDirective(attr.Key); ok && !generatedDirectives[attr.Key] {
+				m.Directives = append(m.Directives, attr.Key)
+				if hydrated[n] && m.Hydration == "" && strings.HasPrefix(attr.Key, "client:") && IsKnownDirective(attr.Key) {
+					m.Hydration = strings.TrimPrefix(attr.Key, "client:")
+				}
+			}
+			if attr.Key == "slot" && attr.Type == astro.QuotedAttribute {
+				m.Slot = attr.Val
+			}
+		}
+		m.Scope = appliedScope(n, opts)
+		if len(m.Directives) > 0 || m.Scope != "" || m.Slot != "" {
+			metadata[n.Loc[0].Start] = m
+		}
+	}
+	walk(doc, add)
+	// Styles and hoisted scripts are no longer part of the tree
+	for _, n := range doc.Styles {
+		add(n)
+	}
+	for _, n := range doc.Scripts {
+		add(n)
+	}
+	return metadata
+}
+
+// appliedScope returns the scoped class or attribute that Transform added to
+// n, if any
+func appliedScope(n *astro.Node, opts TransformOptions) string {
+	if opts.Scope == "" {
+		return ""
+	}
+	if opts.ScopedStyleStrategy == "attribute" {
+		if key := scopeAttribute(opts); HasAttr(n, key) {
+			return key
+		}
+		return ""
+	}
+	class := "astro-" + opts.Scope
+	for _, attr := range n.Attr {
+		if strings.Contains(attr.Val, class) || (attr.Type == astro.SpreadAttribute && strings.Contains(attr.Key, class)) {
+			return class
+		}
+	}
+	return ""
+}
@@ -0,0 +1,100 @@
+package transform
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/handler"
+)
+
+func TestMetadata(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		opts   TransformOptions
+		want   []string
+	}{
+		{
+			name:   "islands",
+			source: `<Counter client:visible /><Chart client:only="react" /><Static />`,
+			want: []string{
+				"<Counter directives=[client:visible] hydration=visible",
+				"<Chart directives=[client:only] hydration=only",
+			},
+		},
+		{
+			name:   "unknown directives don't hydrate",
+			source: `<Counter client:lod />`,
+			want:   []string{"<Counter directives=[client:lod]"},
+		},
+		{
+			name:   "slots",
+			source: `<Card><h2 slot="title">Hi</h2><p slot={name}>x</p></Card>`,
+			want:   []string{`<h2 slot=title`},
+		},
+		{
+			name:   "scoped classes",
+			source: `<div class="a"><span>x</span></div><style>div { color: red; }</style>`,
+			opts:   TransformOptions{Scope: "XXXX"},
+			want: []string{
+				"<div scope=astro-XXXX",
+				"<span scope=astro-XXXX",
+			},
+		},
+		{
+			name:   "scoped attributes",
+			source: `<p>x</p><style is:global>p { color: red; }</style><style>p { margin: 0; }</style>`,
+			opts:   TransformOptions{Scope: "XXXX", ScopedStyleStrategy: "attribute"},
+			want: []string{
+				"<p scope=data-astro-cid-XXXX",
+				"<style directives=[is:global]",
+			},
+		},
+		{
+			name:   "hoisted scripts",
+			source: `<script define:vars={{ a }}>console.log(a)</script><script hoist>go()</script>`,
+			want:   []string{"<script directives=[define:vars]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := handler.NewHandler(tt.source, "TestMetadata.astro")
+			doc, err := astro.ParseWithOptions(strings.NewReader(tt.source), astro.ParseOptionWithHandler(h))
+			if err != nil {
+				t.Error(err)
+			}
+			ExtractStyles(doc)
+			Transform(doc, tt.opts, h)
+			starts := make([]int, 0)
+			metadata := Metadata(doc, tt.opts)
+			for start := range metadata {
+				starts = append(starts, start)
+			}
+			sort.Ints(starts)
+			got := make([]string, 0)
+			for _, start := range starts {
+				m := metadata[start]
+				text := strings.FieldsFunc(tt.source[start:], func(r rune) bool { return r == ' ' || r == '>' })[0]
+				if len(m.Directives) > 0 {
+					text += fmt.Sprintf(" directives=%v", m.Directives)
+				}
+				if m.Hydration != "" {
+					text += " hydration=" + m.Hydration
+				}
+				if m.Scope != "" {
+					text += " scope=" + m.Scope
+				}
+				if m.Slot != "" {
+					text += " slot=" + m.Slot
+				}
+				got = append(got, text)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %q\n  got:  %q", tt.name, tt.want, got))
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Normalize fills in the default for every option which wasn't set. Every
+// entry point should call it, so the compiler only ever sees complete options.
+func (opts *TransformOptions) Normalize() {
+	if opts.As == "" {
+		opts.As = "document"
+	}
+	if opts.Filename == "" {
+		opts.Filename = "<stdin>"
+	}
+	if opts.InternalURL == "" {
+		opts.InternalURL = "astro/internal"
+	}
+	if opts.Site == "" {
+		opts.Site = "https://astro.build"
+	}
+	if opts.Entities == "" {
+		opts.Entities = "preserve"
+	}
+	if opts.ContentType == "" {
+		opts.ContentType = "html"
+	}
+	if opts.PropsSerialization == "" {
+		opts.PropsSerialization = "attribute"
+	}
+	if opts.TrimWhitespace == "" {
+		opts.TrimWhitespace = "smart"
+	}
+	if opts.ScopedStyleStrategy == "" {
+		opts.ScopedStyleStrategy = "class"
+	}
+	if opts.UnusedSelectors == "" {
+		opts.UnusedSelectors = "keep"
+	}
+	if opts.ModuleFormat == "" {
+		opts.ModuleFormat = "esm"
+	}
+	if opts.MetadataFormat == "" {
+		opts.MetadataFormat = "single-line"
+	}
+	if opts.GlobalName == "" {
+		opts.GlobalName = "AstroComponent"
+	}
+	if opts.RuntimeGlobal == "" {
+		opts.RuntimeGlobal = "astroInternal"
+	}
+}
+
+// Validate returns an error describing the first option which has an unknown
+// value or contradicts another option, or nil if opts can be compiled
+func (opts TransformOptions) Validate() error {
+	choices := []struct {
+		name    string
+		value   string
+		allowed []string
+	}{
+		{"as", opts.As, []string{"document", "fragment"}},
+		{"sourcemap", opts.SourceMap, []string{"", "external", "inline", "both"}},
+		{"entities", opts.Entities, []string{"", "preserve", "normalize"}},
+		{"contentType", opts.ContentType, []string{"", "html", "xml"}},
+		{"propsSerialization", opts.PropsSerialization, []string{"", "attribute", "script", "reference"}},
+		{"trimWhitespace", opts.TrimWhitespace, []string{"", "none", "smart", "aggressive"}},
+		{"scopedStyleStrategy", opts.ScopedStyleStrategy, []string{"", "class", "attribute", "where"}},
+		{"unusedSelectors", opts.UnusedSelectors, []string{"", "keep", "warn", "remove"}},
+		{"moduleFormat", opts.ModuleFormat, []string{"", "esm", "cjs", "iife"}},
+		{"metadataFormat", opts.MetadataFormat, []string{"", "single-line", "multi-line"}},
+	}
+	for _, c := range choices {
+		if c.name == "as" && c.value == "" {
+			continue
+		}
+		if !isOneOf(c.value, c.allowed) {
+			return fmt.Errorf("invalid %s option %q, expected one of %s", c.name, c.value, quotedList(c.allowed))
+		}
+	}
+	if opts.MaxInputSize < 0 || opts.MaxNodes < 0 || opts.MaxExpressionDepth < 0 || opts.Timeout < 0 {
+		return fmt.Errorf("limits can't be negative")
+	}
+	if opts.IslandBudget < 0 || opts.InlineScriptBudget < 0 {
+		return fmt.Errorf("budgets can't be negative")
+	}
+	if opts.As == "fragment" && opts.ContentType == "xml" {
+		return fmt.Errorf(`the contentType option "xml" can't be used with as "fragment"`)
+	}
+	if opts.GlobalName != "" && !isIdentifier(opts.GlobalName) {
+		return fmt.Errorf("invalid globalName option %q, expected an identifier", opts.GlobalName)
+	}
+	if opts.RuntimeGlobal != "" && !isFunctionName(opts.RuntimeGlobal) {
+		return fmt.Errorf("invalid runtimeGlobal option %q, expected the name of a global", opts.RuntimeGlobal)
+	}
+	if opts.Translate != "" && !isFunctionName(opts.Translate) {
+		return fmt.Errorf("invalid translate option %q, expected the name of a function", opts.Translate)
+	}
+	if opts.CSPNonceVar != "" && !isFunctionName(opts.CSPNonceVar) {
+		return fmt.Errorf("invalid cspNonceVar option %q, expected the name of a variable", opts.CSPNonceVar)
+	}
+	return nil
+}
+
+func isOneOf(value string, allowed []string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
+
+func quotedList(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != "" {
+			quoted = append(quoted, fmt.Sprintf("%q", v))
+		}
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// Functions may be referenced through an object, like `i18n.t`
+func isFunctionName(name string) bool {
+	for _, part := range strings.Split(name, ".") {
+		if !isIdentifier(part) {
+			return false
+		}
+	}
+	return true
+}
+
+func isIdentifier(name string) bool {
+	for i, c := range name {
+		if c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c > 0x7f {
+			continue
+		}
+		if i > 0 && c >= '0' && c <= '9' {
+			continue
+		}
+		return false
+	}
+	return name != ""
+}
@@ -0,0 +1,110 @@
+package transform
+
+import (
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	opts := TransformOptions{Site: "https://example.com"}
+	opts.Normalize()
+	want := TransformOptions{
+		As:                  "document",
+		Filename:            "<stdin>",
+		InternalURL:         "astro/internal",
+		Site:                "https://example.com",
+		Entities:            "preserve",
+		ContentType:         "html",
+		PropsSerialization:  "attribute",
+		TrimWhitespace:      "smart",
+		ScopedStyleStrategy: "class",
+		UnusedSelectors:     "keep",
+	}
+	if opts.UnusedSelectors != want.UnusedSelectors || opts.ScopedStyleStrategy != want.ScopedStyleStrategy || opts.TrimWhitespace != want.TrimWhitespace || opts.As != want.As || opts.Filename != want.Filename || opts.InternalURL != want.InternalURL || opts.Site != want.Site || opts.Entities != want.Entities || opts.ContentType != want.ContentType || opts.PropsSerialization != want.PropsSerialization {
+		t.Errorf("\nFAIL: normalize\n  want: %+v\n  got:  %+v", want, opts)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		opts TransformOptions
+		want string
+	}{
+		{
+			name: "defaults",
+			opts: TransformOptions{},
+			want: "",
+		},
+		{
+			name: "valid",
+			opts: TransformOptions{As: "fragment", SourceMap: "both", Entities: "normalize", PropsSerialization: "reference", Translate: "i18n.t", CSPNonceVar: "Astro.locals.nonce"},
+			want: "",
+		},
+		{
+			name: "unknown as",
+			opts: TransformOptions{As: "page"},
+			want: `invalid as option "page", expected one of "document", "fragment"`,
+		},
+		{
+			name: "unknown content type",
+			opts: TransformOptions{ContentType: "json"},
+			want: `invalid contentType option "json", expected one of "html", "xml"`,
+		},
+		{
+			name: "unknown props serialization",
+			opts: TransformOptions{PropsSerialization: "json"},
+			want: `invalid propsSerialization option "json", expected one of "attribute", "script", "reference"`,
+		},
+		{
+			name: "unknown scoped style strategy",
+			opts: TransformOptions{ScopedStyleStrategy: "id"},
+			want: `invalid scopedStyleStrategy option "id", expected one of "class", "attribute", "where"`,
+		},
+		{
+			name: "unknown unused selectors",
+			opts: TransformOptions{UnusedSelectors: "error"},
+			want: `invalid unusedSelectors option "error", expected one of "keep", "warn", "remove"`,
+		},
+		{
+			name: "unknown module format",
+			opts: TransformOptions{ModuleFormat: "umd"},
+			want: `invalid moduleFormat option "umd", expected one of "esm", "cjs", "iife"`,
+		},
+		{
+			name: "unknown metadata format",
+			opts: TransformOptions{MetadataFormat: "pretty"},
+			want: `invalid metadataFormat option "pretty", expected one of "single-line", "multi-line"`,
+		},
+		{
+			name: "global name expression",
+			opts: TransformOptions{ModuleFormat: "iife", GlobalName: "window.Card"},
+			want: `invalid globalName option "window.Card", expected an identifier`,
+		},
+		{
+			name: "xml fragment",
+			opts: TransformOptions{As: "fragment", ContentType: "xml"},
+			want: `the contentType option "xml" can't be used with as "fragment"`,
+		},
+		{
+			name: "translate expression",
+			opts: TransformOptions{Translate: "t()"},
+			want: `invalid translate option "t()", expected the name of a function`,
+		},
+		{
+			name: "cspNonceVar expression",
+			opts: TransformOptions{CSPNonceVar: "locals['nonce']"},
+			want: `invalid cspNonceVar option "locals['nonce']", expected the name of a variable`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if err := tt.opts.Validate(); err != nil {
+				got = err.Error()
+			}
+			if got != tt.want {
+				t.Errorf("\nFAIL: %s\n  want: %s\n  got:  %s", tt.name, tt.want, got)
+			}
+		})
+	}
+}
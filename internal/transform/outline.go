@@ -0,0 +1,114 @@
+package transform
+
+import (
+	"strings"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/js_scanner"
+	a "golang.org/x/net/html/atom"
+)
+
+// Symbol is an entry of the outline of a document, for editor outlines and
+// folding ranges
+type Symbol struct {
+	Name string
+	// "frontmatter", "element", "component", "style" or "script"
+	Kind string
+	// The range of the source from the start tag to the end tag
+	Start    int
+	End      int
+	Children []Symbol
+}
+
+// Outline returns the frontmatter and the elements of a parsed document as
+// nested symbols, without transforming it. Implicit elements, fragments and
+// expressions aren't symbols, so the elements inside of them are children of
+// the closest element around them.
+func Outline(source string, doc *astro.Node) []Symbol {
+	symbols := make([]Symbol, 0)
+	for c := doc.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == astro.FrontmatterNode && len(c.Loc) > 1 {
+			symbols = append(symbols, Symbol{Name: "---", Kind: "frontmatter", Start: c.Loc[0].Start, End: c.Loc[1].Start, Children: make([]Symbol, 0)})
+		}
+	}
+	return append(symbols, outlineChildren(source, doc)...)
+}
+
+func outlineChildren(source string, n *astro.Node) []Symbol {
+	symbols := make([]Symbol, 0)
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != astro.ElementNode {
+			continue
+		}
+		if isTransparentElement(c) || len(c.Loc) == 0 {
+			symbols = append(symbols, outlineChildren(source, c)...)
+			continue
+		}
+		symbols = append(symbols, outlineSymbol(source, c))
+	}
+	return symbols
+}
+
+func outlineSymbol(source string, n *astro.Node) Symbol {
+	s := Symbol{Name: n.Data, Kind: "element", Start: n.Loc[0].Start, Children: outlineChildren(source, n)}
+	switch {
+	case n.Component:
+		s.Kind = "component"
+	case n.DataAtom == a.Style:
+		s.Kind = "style"
+	case n.DataAtom == a.Script:
+		s.Kind = "script"
+	}
+	// The location of an end tag which closed elements implicitly may be
+	// recorded on the innermost of them
+	if len(n.Loc) > 1 && isEndTag(source, n.Loc[1].Start, n.Data) {
+		s.End = tagEnd(source, n.Loc[1].Start)
+		return s
+	}
+	s.End = tagEnd(source, s.Start)
+	if len(s.Children) > 0 && s.Children[len(s.Children)-1].End > s.End {
+		s.End = s.Children[len(s.Children)-1].End
+	}
+	// An end tag which wasn't recorded, like that of <html>, follows the
+	// last child
+	if end := s.End + len(source[s.End:]) - len(strings.TrimLeft(source[s.End:], " \t\r\n")); isEndTag(source, end, n.Data) {
+		s.End = tagEnd(source, end)
+	}
+	return s
+}
+
+func isEndTag(source string, start int, name string) bool {
+	tag := "</" + name
+	if start < 0 || start+len(tag) > len(source) || !strings.EqualFold(source[start:start+len(tag)], tag) {
+		return false
+	}
+	rest := source[start+len(tag):]
+	return rest == "" || rest[0] == '>' || rest[0] == ' ' || rest[0] == '\t' || rest[0] == '\r' || rest[0] == '\n'
+}
+
+// tagEnd returns the position after the `>` of the tag which starts at start,
+// skipping over quoted values and expressions
+func tagEnd(source string, start int) int {
+	if start < 0 || start >= len(source) {
+		return len(source)
+	}
+	for i := start + 1; i < len(source); i++ {
+		switch c := source[i]; c {
+		case '>':
+			return i + 1
+		case '"', '\'', '`':
+			end := strings.IndexByte(source[i+1:], c)
+			if end == -1 {
+				return len(source)
+			}
+			i += end + 1
+		case '{':
+			end := js_scanner.MatchingBrace([]byte(source), i)
+			if end == -1 {
+				return len(source)
+			}
+			i = end
+		}
+	}
+	return len(source)
+}
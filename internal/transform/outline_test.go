@@ -0,0 +1,61 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	astro "github.com/snowpackjs/astro/internal"
+)
+
+func TestOutline(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name: "component",
+			source: `---
+import Card from './Card.astro';
+---
+<Card title="a > b"><p>One</p><img src={src} alt=">" /></Card>
+<style>p { color: red; }</style>`,
+			want: `[frontmatter "---\nimport Card from './Card.astro';\n---" component "<Card title=\"a > b\"><p>One</p><img src={src} alt=\">\" /></Card>" [element "<p>One</p>" element "<img src={src} alt=\">\" />"] style "<style>p { color: red; }</style>"]`,
+		},
+		{
+			name:   "document",
+			source: `<html><head><title>Hi</title></head><body><main>{items.map(i => <li>{i}</li>)}</main><script>go()</script></body></html>`,
+			want:   `[element "<html><head><title>Hi</title></head><body><main>{items.map(i => <li>{i}</li>)}</main><script>go()</script></body></html>" [element "<head><title>Hi</title></head>" [element "<title>Hi</title>"] element "<body><main>{items.map(i => <li>{i}</li>)}</main><script>go()</script></body>" [element "<main>{items.map(i => <li>{i}</li>)}</main>" [element "<li>{i}</li>"] script "<script>go()</script>"]]]`,
+		},
+		{
+			name:   "fragments and unclosed elements",
+			source: "<Fragment><div>a</Fragment>\n<section>",
+			want:   `[element "<div>a</Fragment>\n<section>" [element "<section>"]]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := astro.Parse(strings.NewReader(tt.source))
+			if err != nil {
+				t.Error(err)
+			}
+			got := printSymbols(tt.source, Outline(tt.source, doc))
+			if got != tt.want {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %s\n  got:  %s", tt.name, tt.want, got))
+			}
+		})
+	}
+}
+
+func printSymbols(source string, symbols []Symbol) string {
+	parts := make([]string, 0, len(symbols))
+	for _, s := range symbols {
+		part := fmt.Sprintf("%s %q", s.Kind, source[s.Start:s.End])
+		if len(s.Children) > 0 {
+			part += " " + printSymbols(source, s.Children)
+		}
+		parts = append(parts, part)
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
@@ -0,0 +1,95 @@
+package transform
+
+import (
+	"sort"
+	"strings"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/loc"
+	a "golang.org/x/net/html/atom"
+)
+
+// Preload is a resource which a page rendering the component is known to
+// need, so a server can announce it with a `Link` header or an early hint
+// before the page is rendered
+type Preload struct {
+	// The import specifier, which is relative to the component, or the URL
+	// of a script, as authored
+	URL string
+	// "component" for the module of a hydrated component, "script" for a
+	// remote script and "style" for a stylesheet imported by the frontmatter
+	Kind string
+	// The rel and as of the link: "modulepreload" for components and module
+	// scripts, or "preload"
+	Rel string
+	As  string
+	Loc loc.Loc
+}
+
+// Preloads returns the modules of hydrated components, remote scripts with a
+// static src and the stylesheets the frontmatter imports, in document order.
+// A resource is only listed the first time it's needed.
+func Preloads(doc *astro.Node, opts TransformOptions) []Preload {
+	preloads := make([]Preload, 0)
+	for _, i := range CollectStyleImports(doc) {
+		preloads = append(preloads, Preload{URL: i.Specifier, Kind: "style", Rel: "preload", As: "style", Loc: i.Loc})
+	}
+	for _, script := range doc.Scripts {
+		if preload, ok := scriptPreload(script); ok {
+			preloads = append(preloads, preload)
+		}
+	}
+	walk(doc, func(n *astro.Node) {
+		if n.Type != astro.ElementNode || n.DataAtom != a.Script {
+			return
+		}
+		if preload, ok := scriptPreload(n); ok {
+			preloads = append(preloads, preload)
+		}
+	})
+	imports := frontmatterImports(doc)
+	for _, n := range IslandNodes(doc) {
+		specifier, ok := opts.CustomElements[n.Data]
+		if !n.CustomElement || !ok {
+			i, imported := imports[strings.Split(n.Data, ".")[0]]
+			if !imported {
+				continue
+			}
+			specifier = i.statement.Specifier
+		}
+		preload := Preload{URL: specifier, Kind: "component", Rel: "modulepreload", As: "script"}
+		if len(n.Loc) > 0 {
+			preload.Loc = n.Loc[0]
+		}
+		preloads = append(preloads, preload)
+	}
+
+	sort.SliceStable(preloads, func(i, j int) bool {
+		return preloads[i].Loc.Start < preloads[j].Loc.Start
+	})
+	seen := make(map[string]bool)
+	unique := make([]Preload, 0, len(preloads))
+	for _, p := range preloads {
+		if seen[p.URL] {
+			continue
+		}
+		seen[p.URL] = true
+		unique = append(unique, p)
+	}
+	return unique
+}
+
+func scriptPreload(n *astro.Node) (Preload, bool) {
+	src := astro.GetAttribute(n, "src")
+	if src == nil || src.Type != astro.QuotedAttribute || strings.TrimSpace(src.Val) == "" {
+		return Preload{}, false
+	}
+	preload := Preload{URL: astro.UnescapeAttributeString(src.Val), Kind: "script", Rel: "preload", As: "script"}
+	if t := astro.GetAttribute(n, "type"); t != nil && t.Type == astro.QuotedAttribute && t.Val == "module" {
+		preload.Rel = "modulepreload"
+	}
+	if len(n.Loc) > 0 {
+		preload.Loc = n.Loc[0]
+	}
+	return preload, true
+}
@@ -0,0 +1,62 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/handler"
+)
+
+func TestPreloads(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		opts   TransformOptions
+		want   []string
+	}{
+		{
+			name:   "components",
+			source: "---\nimport Counter from '../components/Counter.jsx';\nimport { Card } from '../components/index.js';\nimport * as ui from '../ui.js';\n---\n<Counter client:load /><Card /><ui.Button client:visible /><Counter client:idle />",
+			want:   []string{"component modulepreload ../components/Counter.jsx", "component modulepreload ../ui.js"},
+		},
+		{
+			name:   "custom elements",
+			source: "<my-element client:load /><other-element client:load />",
+			opts:   TransformOptions{CustomElements: map[string]string{"my-element": "../elements/my-element.js"}},
+			want:   []string{"component modulepreload ../elements/my-element.js"},
+		},
+		{
+			name:   "scripts",
+			source: "<script hoist type=\"module\" src=\"/hoisted.js\"></script><script src=\"https://cdn.example.com/a.js?v=1&amp;b\"></script><script src={dynamic}></script><script>inline()</script>",
+			want:   []string{"script modulepreload /hoisted.js", "script preload https://cdn.example.com/a.js?v=1&b"},
+		},
+		{
+			name:   "styles",
+			source: "---\nimport './global.css';\nimport Counter from './Counter.jsx';\n---\n<Counter client:only />",
+			want:   []string{"style preload ./global.css", "component modulepreload ./Counter.jsx"},
+		},
+		{
+			name:   "not hydrated",
+			source: "---\nimport Card from './Card.astro';\n---\n<Card />",
+			want:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := astro.Parse(strings.NewReader(tt.source))
+			if err != nil {
+				t.Error(err)
+			}
+			Transform(doc, tt.opts, handler.NewHandler(tt.source, "<stdin>"))
+			got := make([]string, 0)
+			for _, p := range Preloads(doc, tt.opts) {
+				got = append(got, fmt.Sprintf("%s %s %s", p.Kind, p.Rel, p.URL))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %v\n  got:  %v", tt.name, tt.want, got))
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package transform
+
+import (
+	"strings"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/js_scanner"
+	"github.com/snowpackjs/astro/internal/loc"
+)
+
+// Reference is an identifier which the template refers to, for language
+// tooling to rename, find references to and find unused frontmatter bindings
+type Reference struct {
+	Name string
+	Loc  loc.Loc
+	// Where the identifier is: "expression", "attribute" or "component"
+	Kind string
+}
+
+// References returns every identifier the template refers to, with its
+// location, in the order that it appears. Names bound by the template itself,
+// like the parameters of the arrow function of a loop, aren't references. Like
+// TemplateReferences, this must run before the compiler adds any attributes
+// of its own.
+func References(doc *astro.Node) []Reference {
+	references := make([]Reference, 0)
+	var bound map[string]bool
+	add := func(kind string, source string, start int) {
+		for _, r := range js_scanner.IdentifierReferences([]byte(source)) {
+			if !bound[r.Name] {
+				references = append(references, Reference{Name: r.Name, Loc: loc.Loc{Start: start + r.Start}, Kind: kind})
+			}
+		}
+	}
+	walk(doc, func(n *astro.Node) {
+		bound = expressionBindings(n)
+		switch n.Type {
+		case astro.TextNode:
+			if n.Parent != nil && n.Parent.Expression && len(n.Loc) > 0 {
+				add("expression", n.Data, n.Loc[0].Start)
+			}
+		case astro.ElementNode:
+			if n.Component && len(n.Loc) > 0 && DynamicTag(n) == nil {
+				name := strings.Split(n.Data, ".")[0]
+				references = append(references, Reference{Name: name, Loc: loc.Loc{Start: n.Loc[0].Start + 1}, Kind: "component"})
+			}
+			for _, attr := range n.Attr {
+				switch attr.Type {
+				case astro.ExpressionAttribute:
+					add("attribute", attr.Val, attr.ValLoc.Start)
+				case astro.TemplateLiteralAttribute:
+					// Only the substitutions are code
+					add("attribute", "`"+attr.Val+"`", attr.ValLoc.Start-1)
+				case astro.SpreadAttribute, astro.ShorthandAttribute:
+					add("attribute", attr.Key, attr.KeyLoc.Start)
+				}
+			}
+		}
+	})
+	return references
+}
+
+// expressionBindings returns the names which the expressions around n bind,
+// like `item` for the markup of `{items.map(item => <li>{item}</li>)}`
+func expressionBindings(n *astro.Node) map[string]bool {
+	bound := make(map[string]bool)
+	for p := n.Parent; p != nil; p = p.Parent {
+		if !p.Expression {
+			continue
+		}
+		for c := p.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == astro.TextNode {
+				for _, name := range js_scanner.LocalBindings([]byte(c.Data)) {
+					bound[name] = true
+				}
+			}
+		}
+	}
+	return bound
+}
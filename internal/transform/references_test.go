@@ -0,0 +1,69 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	astro "github.com/snowpackjs/astro/internal"
+)
+
+func TestReferences(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "expressions",
+			source: `<p>{title} {site.name} {items.map(item => <li>{item}</li>)}</p>`,
+			want:   []string{"expression title", "expression site", "expression items"},
+		},
+		{
+			name:   "loop bindings",
+			source: `<ul>{items.map(({ href }, i) => <li data-i={i}><a href={href}>{label}</a></li>)}</ul>`,
+			want:   []string{"expression items", "expression label"},
+		},
+		{
+			name:   "attributes",
+			source: "<a href={url} class=`link ${kind}` {title} {...props} data-a=\"b\">x</a>",
+			want:   []string{"attribute url", "attribute kind", "attribute title", "attribute props"},
+		},
+		{
+			name:   "components",
+			source: `<Card.Item><Element is={tag} /></Card.Item>`,
+			want:   []string{"component Card", "attribute tag"},
+		},
+		{
+			name:   "object literals and conditionals",
+			source: `<p>{open ? { label: text } : fallback}</p>`,
+			want:   []string{"expression open", "expression text", "expression fallback"},
+		},
+		{
+			name: "frontmatter is left out",
+			source: `---
+const { title } = Astro.props;
+---
+<h1>{title}</h1>`,
+			want: []string{"expression title"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := astro.Parse(strings.NewReader(tt.source))
+			if err != nil {
+				t.Error(err)
+			}
+			got := make([]string, 0)
+			for _, r := range References(doc) {
+				if !strings.HasPrefix(tt.source[r.Loc.Start:], r.Name) {
+					t.Errorf("%s is not at %d", r.Name, r.Loc.Start)
+				}
+				got = append(got, fmt.Sprintf("%s %s", r.Kind, r.Name))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %q\n  got:  %q", tt.name, tt.want, got))
+			}
+		})
+	}
+}
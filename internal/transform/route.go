@@ -0,0 +1,157 @@
+package transform
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/handler"
+	"github.com/snowpackjs/astro/internal/js_scanner"
+	"github.com/snowpackjs/astro/internal/loc"
+)
+
+// Route is what the build needs to know about a page to validate its route
+// without running it
+type Route struct {
+	// The route pattern of the page, like "/blog/[slug]", if it's known
+	Pattern string
+	// The params of Pattern, like "slug" for `[slug]` or "path" for `[...path]`
+	Params []string
+	// The params which the page reads from `Astro.params`, in the order that
+	// they're first read
+	ReadParams     []string
+	GetStaticPaths bool
+	// The value of `export const prerender`, or nil if it isn't exported as a
+	// boolean literal
+	Prerender *bool
+}
+
+var routeParam = regexp.MustCompile(`\[(?:\.\.\.)?([^\[\]]+)\]`)
+
+// RouteParams returns the params of a route pattern, like "slug" for
+// "/blog/[slug]"
+func RouteParams(pattern string) []string {
+	params := make([]string, 0)
+	for _, match := range routeParam.FindAllStringSubmatch(pattern, -1) {
+		params = append(params, match[1])
+	}
+	return params
+}
+
+// RouteInfo returns the route metadata of a page
+func RouteInfo(doc *astro.Node, opts TransformOptions) Route {
+	route := Route{
+		Pattern:    opts.Route,
+		Params:     RouteParams(opts.Route),
+		ReadParams: make([]string, 0),
+	}
+	seen := make(map[string]bool)
+	for _, ref := range paramReferences(doc) {
+		if !seen[ref.Name] {
+			seen[ref.Name] = true
+			route.ReadParams = append(route.ReadParams, ref.Name)
+		}
+	}
+	if text := frontmatterText(doc); text != nil {
+		route.GetStaticPaths = js_scanner.ExportsGetStaticPaths([]byte(text.Data))
+	}
+	route.Prerender = Prerender(doc)
+	return route
+}
+
+// Prerender returns the value of `export const prerender` in the frontmatter,
+// or nil if it isn't exported as a boolean literal, so a build can decide
+// whether to prerender a page without running it
+func Prerender(doc *astro.Node) *bool {
+	text := frontmatterText(doc)
+	if text == nil {
+		return nil
+	}
+	if prerender, _, ok := js_scanner.Prerender([]byte(text.Data)); ok {
+		return &prerender
+	}
+	return nil
+}
+
+func frontmatterText(doc *astro.Node) *astro.Node {
+	for c := doc.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == astro.FrontmatterNode && c.FirstChild != nil {
+			return c.FirstChild
+		}
+	}
+	return nil
+}
+
+type paramReference struct {
+	Name string
+	Loc  loc.Loc
+}
+
+// paramReferences returns every param read from `Astro.params` in the
+// frontmatter or in template expressions, in document order
+func paramReferences(doc *astro.Node) []paramReference {
+	refs := make([]paramReference, 0)
+	walk(doc, func(n *astro.Node) {
+		if n.Type != astro.TextNode || n.Parent == nil {
+			return
+		}
+		if n.Parent.Type != astro.FrontmatterNode && !n.Parent.Expression {
+			return
+		}
+		for _, p := range js_scanner.AstroParams([]byte(n.Data)) {
+			ref := paramReference{Name: p.Name}
+			if len(n.Loc) > 0 {
+				ref.Loc = loc.Loc{Start: n.Loc[0].Start + p.Start}
+			}
+			refs = append(refs, ref)
+		}
+	})
+	return refs
+}
+
+// warnRouteParams warns about params which a page reads but its route never
+// sets, and about dynamic routes which are prerendered without
+// `getStaticPaths`. This must run before defines are substituted, so
+// locations in the frontmatter are still those of the source.
+func warnRouteParams(doc *astro.Node, opts TransformOptions, h *handler.Handler) {
+	if !opts.IsPage || opts.Route == "" {
+		return
+	}
+	params := RouteParams(opts.Route)
+	declared := make(map[string]bool)
+	for _, p := range params {
+		declared[p] = true
+	}
+
+	for _, ref := range paramReferences(doc) {
+		if declared[ref.Name] {
+			continue
+		}
+		hint := fmt.Sprintf("%s has no params", opts.Route)
+		if len(params) > 0 {
+			hint = fmt.Sprintf("The params of %s are %s", opts.Route, strings.Join(params, ", "))
+		}
+		h.AppendWarning(&loc.ErrorWithRange{
+			Code:  loc.WARNING_UNKNOWN_ROUTE_PARAM,
+			Text:  fmt.Sprintf("Astro.params.%s is never set by the route %s", ref.Name, opts.Route),
+			Hint:  hint,
+			Range: loc.Range{Loc: ref.Loc, Len: len(ref.Name)},
+		})
+	}
+
+	text := frontmatterText(doc)
+	if len(params) == 0 || text == nil || len(text.Loc) == 0 {
+		return
+	}
+	source := []byte(text.Data)
+	prerender, start, ok := js_scanner.Prerender(source)
+	if ok && prerender && !js_scanner.ExportsGetStaticPaths(source) {
+		h.AppendWarning(&loc.ErrorWithRange{
+			Code:  loc.WARNING_MISSING_STATIC_PATHS,
+			Text:  fmt.Sprintf("The dynamic route %s is prerendered but doesn't export getStaticPaths()", opts.Route),
+			Hint:  "Export getStaticPaths() to list the pages to build, or set prerender to false to render them on demand",
+			Range: loc.Range{Loc: loc.Loc{Start: text.Loc[0].Start + start}, Len: len("prerender")},
+		})
+	}
+}
@@ -2,25 +2,27 @@ package transform
 
 import (
 	"bytes"
+	"fmt"
 	"strings"
 
-	// "strings"
-
 	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/handler"
+	"github.com/snowpackjs/astro/internal/loc"
 	"github.com/tdewolff/parse/css"
 	a "golang.org/x/net/html/atom"
 )
 
-// Take a slice of DOM nodes, and scope CSS within every <style> tag
-func ScopeStyle(styles []*astro.Node, opts TransformOptions) bool {
+// Take a slice of DOM nodes, and scope CSS within every <style> tag. With
+// usage, selectors which can't match anything it has are reported or removed,
+// as the UnusedSelectors option asks.
+func ScopeStyle(styles []*astro.Node, opts TransformOptions, h *handler.Handler, usage *SelectorUsage) bool {
 	didScope := false
-outer:
 	for _, n := range styles {
 		if n.DataAtom != a.Style {
 			continue
 		}
 		if hasTruthyAttr(n, "global") {
-			continue outer
+			continue
 		}
 		didScope = true
 		n.Attr = append(n.Attr, astro.Attribute{
@@ -30,203 +32,594 @@ outer:
 		if n.FirstChild == nil {
 			continue
 		}
-		p := css.NewParser(bytes.NewBufferString(n.FirstChild.Data), false)
-		out := ""
+		// Other languages have at-rules and selectors of their own, which are
+		// only compiled to CSS later
+		lang := GetQuotedAttr(n, "lang")
+		isCSS := lang == "" || lang == "css"
+		s := newStyleScoper(n.FirstChild.Data, opts)
+		if isCSS && opts.UnusedSelectors != "keep" {
+			s.usage = usage
+		}
+		for !s.done() {
+			s.ruleList(false, true)
+			// Keep going after a stray `}`
+			if !s.done() {
+				s.emit()
+			}
+		}
+		n.FirstChild.Data = strings.TrimSpace(s.out.String())
 
-		isKeyframes := false    // if we’re inside @keyframes, there’s nothing to scope
-		keyframeCurlyCount := 0 // keep track of open "{"s inside @keyframes
+		if !isCSS {
+			continue
+		}
+		for _, t := range s.unknown {
+			r := loc.Range{Len: len(t.data)}
+			if len(n.FirstChild.Loc) > 0 {
+				r.Loc = loc.Loc{Start: n.FirstChild.Loc[0].Start + t.offset}
+			}
+			h.AppendWarning(&loc.ErrorWithRange{
+				Code:  loc.WARNING_UNKNOWN_AT_RULE,
+				Text:  fmt.Sprintf("Unknown at-rule %s is left unscoped", t.data),
+				Hint:  "Everything inside of it is kept as authored, so its selectors won't be scoped",
+				Range: r,
+			})
+		}
+		if opts.UnusedSelectors != "warn" {
+			continue
+		}
+		for _, t := range s.unused {
+			r := loc.Range{Len: len(t.data)}
+			if len(n.FirstChild.Loc) > 0 {
+				r.Loc = loc.Loc{Start: n.FirstChild.Loc[0].Start + t.offset}
+			}
+			h.AppendWarning(&loc.ErrorWithRange{
+				Code:  loc.WARNING_UNUSED_SELECTOR,
+				Text:  fmt.Sprintf("Unused CSS selector %q", t.data),
+				Hint:  "No element of this component can match it. Wrap it in :global() if it's meant for elements rendered elsewhere",
+				Range: r,
+			})
+		}
+	}
+	return didScope
+}
 
-	walk:
-		for {
-			gt, _, data := p.Next()
+// How the block of an at-rule is scoped
+type atRuleKind int
 
-			switch gt {
-			case css.ErrorGrammar:
-				if len(string(data)) > 0 {
-					out += string(data) // this will happen for invalid or unexpected CSS. Try and retain output as much as possible without throwing
-				} else {
-					break walk // an unrecoverable error occurred, or the end has been reached
-				}
-			case css.CommentGrammar:
-				out += string(data)
-			case css.EndAtRuleGrammar,
-				css.EndRulesetGrammar:
-				out += "}"
-			case
-				css.BeginAtRuleGrammar,
-				css.BeginRulesetGrammar,
-				css.DeclarationGrammar,
-				css.QualifiedRuleGrammar:
-
-				// prelude
-				switch gt {
-				case css.AtRuleGrammar,
-					css.BeginAtRuleGrammar:
-					out += string(data)
-					if string(data) == "@keyframes" {
-						isKeyframes = true
-						keyframeCurlyCount = 0
-					}
-				case css.DeclarationGrammar:
-					out += string(data) + ":"
-				default:
-				}
+const (
+	// Rules, or declarations and rules inside of a style rule, like @media
+	atRuleGroup atRuleKind = iota
+	// Rules which aren't scoped, like the keyframes of @keyframes
+	atRuleKeyframes
+	// Declarations, like those of @font-face
+	atRuleDeclarations
+)
+
+var atRules = map[string]atRuleKind{
+	"media":               atRuleGroup,
+	"supports":            atRuleGroup,
+	"container":           atRuleGroup,
+	"layer":               atRuleGroup,
+	"scope":               atRuleGroup,
+	"document":            atRuleGroup,
+	"starting-style":      atRuleGroup,
+	"keyframes":           atRuleKeyframes,
+	"font-face":           atRuleDeclarations,
+	"font-feature-values": atRuleDeclarations,
+	"font-palette-values": atRuleDeclarations,
+	"page":                atRuleDeclarations,
+	"property":            atRuleDeclarations,
+	"counter-style":       atRuleDeclarations,
+	"view-transition":     atRuleDeclarations,
+	"position-try":        atRuleDeclarations,
+	"viewport":            atRuleDeclarations,
+	"color-profile":       atRuleDeclarations,
+	"import":              atRuleDeclarations,
+	"charset":             atRuleDeclarations,
+	"namespace":           atRuleDeclarations,
+}
+
+type cssToken struct {
+	tt     css.TokenType
+	data   string
+	offset int
+}
+
+// styleScoper walks a stylesheet as a tree of blocks, so it can tell
+// selectors from everything else however rules are nested. Whitespace is
+// removed wherever it isn't needed, and at-rules it doesn't know are printed
+// as authored.
+type styleScoper struct {
+	tokens  []cssToken
+	i       int
+	out     strings.Builder
+	opts    TransformOptions
+	unknown []cssToken
+	// Selectors are only checked against usage if it's set
+	usage  *SelectorUsage
+	unused []cssToken
+}
+
+func newStyleScoper(source string, opts TransformOptions) *styleScoper {
+	s := &styleScoper{opts: opts}
+	l := css.NewLexer(bytes.NewBufferString(source))
+	offset := 0
+	for {
+		tt, data := l.Next()
+		if tt == css.ErrorToken {
+			break
+		}
+		s.tokens = append(s.tokens, cssToken{tt, string(data), offset})
+		offset += len(data)
+	}
+	s.out.Grow(len(source))
+	return s
+}
+
+func (s *styleScoper) done() bool {
+	return s.i >= len(s.tokens)
+}
+
+func (s *styleScoper) peek() cssToken {
+	return s.tokens[s.i]
+}
+
+func (s *styleScoper) write(tokens []cssToken) {
+	for _, t := range tokens {
+		s.out.WriteString(t.data)
+	}
+}
+
+func (s *styleScoper) emit() {
+	s.out.WriteString(s.tokens[s.i].data)
+	s.i++
+}
+
+// ruleList handles rules up to the end of the current block. Inside of a
+// style rule, nested rules are told from declarations by whether a `{` comes
+// before the next `;`. Selectors are only scoped when scope is set.
+func (s *styleScoper) ruleList(nested bool, scope bool) {
+	for !s.done() {
+		t := s.peek()
+		switch t.tt {
+		case css.RightBraceToken:
+			return
+		case css.CommentToken:
+			// Only comments between top-level rules are kept
+			if !nested && scope {
+				s.emit()
+			} else {
+				s.i++
+			}
+		case css.WhitespaceToken, css.SemicolonToken, css.CDOToken, css.CDCToken:
+			s.i++
+		case css.AtKeywordToken:
+			s.atRule(nested, scope)
+		default:
+			if nested && (t.tt == css.CustomPropertyNameToken || s.endOfPrelude() != css.LeftBraceToken) {
+				s.declaration()
+			} else {
+				s.styleRule(scope)
+			}
+		}
+	}
+}
+
+// endOfPrelude returns the token which ends the rule or declaration starting
+// at the current token
+func (s *styleScoper) endOfPrelude() css.TokenType {
+	depth := 0
+	for j := s.i; j < len(s.tokens); j++ {
+		switch tt := s.tokens[j].tt; tt {
+		case css.LeftParenthesisToken, css.FunctionToken, css.LeftBracketToken:
+			depth++
+		case css.RightParenthesisToken, css.RightBracketToken:
+			depth--
+		case css.LeftBraceToken, css.SemicolonToken, css.RightBraceToken:
+			if depth <= 0 {
+				return tt
+			}
+		}
+	}
+	return css.ErrorToken
+}
+
+// prelude returns the tokens up to the `{`, `;` or `}` which ends it, which
+// isn't consumed
+func (s *styleScoper) prelude() []cssToken {
+	start := s.i
+	depth := 0
+	for ; !s.done(); s.i++ {
+		switch s.peek().tt {
+		case css.LeftParenthesisToken, css.FunctionToken, css.LeftBracketToken:
+			depth++
+		case css.RightParenthesisToken, css.RightBracketToken:
+			depth--
+		case css.LeftBraceToken, css.SemicolonToken, css.RightBraceToken:
+			if depth <= 0 {
+				return s.tokens[start:s.i]
+			}
+		}
+	}
+	return s.tokens[start:s.i]
+}
+
+// declaration prints a declaration as `name:value;`
+func (s *styleScoper) declaration() {
+	name := s.peek()
+	start := s.i
+	depth := 0
+loop:
+	for ; !s.done(); s.i++ {
+		switch s.peek().tt {
+		case css.LeftParenthesisToken, css.FunctionToken, css.LeftBracketToken, css.LeftBraceToken:
+			depth++
+		case css.RightParenthesisToken, css.RightBracketToken:
+			depth--
+		case css.RightBraceToken:
+			if depth <= 0 {
+				break loop
+			}
+			depth--
+		case css.SemicolonToken:
+			if depth <= 0 {
+				break loop
+			}
+		}
+	}
+	tokens := s.tokens[start:s.i]
+	if !s.done() && s.peek().tt == css.SemicolonToken {
+		s.i++
+	}
+
+	colon := 1
+	for colon < len(tokens) && tokens[colon].tt == css.WhitespaceToken {
+		colon++
+	}
+	if colon >= len(tokens) || tokens[colon].tt != css.ColonToken {
+		// Not a declaration, keep it as is
+		s.write(compact(tokens, nil))
+		s.out.WriteString(";")
+		return
+	}
+	if name.tt == css.CustomPropertyNameToken {
+		// The value of a custom property can be anything, so it's kept as is
+		s.out.WriteString(name.data + ":")
+		s.write(tokens[colon+1:])
+	} else {
+		s.out.WriteString(strings.ToLower(name.data) + ":")
+		s.write(compact(tokens[colon+1:], declarationSeparators))
+	}
+	s.out.WriteString(";")
+}
+
+func (s *styleScoper) styleRule(scope bool) {
+	selector := compact(s.prelude(), selectorSeparators)
+	if scope && s.usage != nil {
+		selector = s.pruneSelector(selector)
+		if len(selector) == 0 {
+			s.skipBlock()
+			return
+		}
+	}
+	if scope {
+		s.out.WriteString(scopeSelector(selector, s.opts))
+	} else {
+		s.write(selector)
+	}
+	s.block(func() { s.ruleList(true, scope) })
+}
+
+func (s *styleScoper) atRule(nested bool, scope bool) {
+	keyword := s.peek()
+	s.i++
+	name := strings.ToLower(keyword.data[1:])
+	// Skip vendor prefixes, like @-webkit-keyframes
+	if strings.HasPrefix(name, "-") {
+		if i := strings.IndexByte(name[1:], '-'); i != -1 {
+			name = name[i+2:]
+		}
+	}
+	kind, known := atRules[name]
+	if !known {
+		// Unknown at-rules are kept exactly as authored
+		if scope {
+			s.unknown = append(s.unknown, keyword)
+		}
+		s.out.WriteString(keyword.data)
+		s.write(s.prelude())
+		s.block(s.verbatim)
+		return
+	}
+
+	s.out.WriteString(strings.ToLower(keyword.data))
+	if prelude := compact(s.prelude(), atRuleSeparators); len(prelude) > 0 {
+		s.out.WriteString(" ")
+		s.write(prelude)
+	}
+	switch kind {
+	case atRuleGroup:
+		s.block(func() { s.ruleList(nested, scope) })
+	case atRuleKeyframes:
+		s.block(func() { s.ruleList(false, false) })
+	case atRuleDeclarations:
+		s.block(func() { s.ruleList(true, false) })
+	}
+}
+
+// block prints the `{ ... }` block or `;` which ends a rule, with the contents
+// of a block handled by inner
+func (s *styleScoper) block(inner func()) {
+	if s.done() {
+		return
+	}
+	switch s.peek().tt {
+	case css.SemicolonToken:
+		s.emit()
+	case css.LeftBraceToken:
+		s.emit()
+		inner()
+		if !s.done() {
+			s.emit()
+		}
+	}
+}
 
-				// main selector
-				parenCount := 0          // keeps track of open parens. scoping can’t happen inside parens (:not(), :where(), etc.)
-				isBracket := false       // keeps track of attr brackets (can’t nest like parens, so it’s simply ”open”/“close”)
-				isGlobal := false        // keeps track of :global() function (no scope, and omit from output)
-				isElement := true        // keeps track of base element selectors (e.g. body, h1). Elements must be assumed ("true") until ".", "#", etc. are encountered
-				isGlobalElement := false // keeps track of <body>, <html>, and other protected elements (isElement will always be true as well)
-				isPseudoState := false   // keeps track of pseudo state/element context (i.e. ensures :hover or ::before don’t get scoped). This is "false" until ":" is encountered
-				nextValues := p.Values()
-				for n, val := range nextValues {
-					strVal := string(val.Data)
-
-					// if inside @keyframes, don’t transform what’s there
-					if isKeyframes {
-						out += strVal
-						continue
-					}
-
-					switch strVal {
-					case ".",
-						"#":
-						isPseudoState = false
-						isElement = false
-						out += strVal
-					case ":":
-						isPseudoState = true
-						// look ahead to see if this is the start of ":global(".
-						// If so, omit from output and start global state
-						if len(nextValues) > n+1 && string(nextValues[n+1].Data) == "global(" {
-							isGlobal = true
-						} else {
-							// if not the start of ":global(", then include in output
-							out += strVal
-						}
-					case "global(":
-						parenCount++
-						// omit from output
-					case "(":
-						parenCount++
-						out += strVal
-						isElement = true
-						isPseudoState = false
-					case ")":
-						parenCount--
-						if !isGlobal || parenCount != 0 {
-							out += strVal // output only if this doesn’t close ":global("
-						}
-					case "[":
-						isBracket = true
-						isElement = false
-						isPseudoState = false
-						// if there is no selector before an attribute selector, then assume "*"
-						if n == 0 {
-							out += scopeRule("", opts)
-						}
-						out += strVal
-					case "]":
-						isBracket = false
-						out += strVal
-					case "{":
-						keyframeCurlyCount++
-						isElement = true
-						isPseudoState = false
-						out += strVal
-					case "}":
-						keyframeCurlyCount--
-						if keyframeCurlyCount == 0 {
-							isKeyframes = false
-						}
-						out += strVal
-					case "*":
-						if parenCount == 0 && !isGlobal {
-							out += scopeRule("", opts) // turns "*" into ".astro-XXXXXX" rather than "*.astro-XXXXXX"
-						} else {
-							out += strVal
-						}
-					default:
-						// handle IDs with parens attached
-						if strings.Contains(strVal, "(") {
-							parenCount++ // if new paren opened, count it
-							isElement = true
-							isPseudoState = false
-						}
-
-						// if this is an element, check if it’s <body>, etc.
-						if isElement && globalElement(strVal) {
-							isGlobalElement = true
-						}
-
-						// whitespace tokens are used to reset chained classes and functions
-						if val.TokenType == css.WhitespaceToken {
-							// important: global elements like <body> may have classes that should not be scoped
-							if isElement && isGlobalElement {
-								isGlobalElement = false
-							}
-
-							// important: :global() might be chained (:global().some-class)
-							// so keep it active until whitespace is reached after final paren
-							if isGlobal && parenCount == 0 {
-								isGlobal = false
-							}
-						}
-
-						// scope class
-						isCssSelector := (gt == css.BeginRulesetGrammar || gt == css.QualifiedRuleGrammar) && (val.TokenType == css.IdentToken || val.TokenType == css.HashToken)
-						if isCssSelector && // don’t scope @media and other non-class specifiers
-							!isPseudoState && // don’t scope pseudostates
-							!isGlobal && // don’t scope in :global() scope
-							!isGlobalElement &&
-							!isBracket && // don’t scope within element brackets
-							parenCount == 0 { // don’t scope within parens like :not()
-							out += scopeRule(strVal, opts)
-						} else {
-							// otherwise, append output
-							out += strVal
-						}
-
-						// reset state
-						isElement = true
-						isPseudoState = false
-					}
+// pruneSelector records every selector of the list which can't match, and
+// leaves them out if they should be removed
+func (s *styleScoper) pruneSelector(selector []cssToken) []cssToken {
+	result := make([]cssToken, 0, len(selector))
+	start := 0
+	depth := 0
+	for i := 0; i <= len(selector); i++ {
+		if i < len(selector) {
+			switch selector[i].tt {
+			case css.FunctionToken, css.LeftParenthesisToken:
+				depth++
+			case css.RightParenthesisToken:
+				depth--
+			}
+			if depth > 0 || selector[i].tt != css.CommaToken {
+				continue
+			}
+		}
+		part := selector[start:i]
+		start = i + 1
+		if len(part) == 0 {
+			continue
+		}
+		if !s.usage.CanMatch(part) {
+			var text strings.Builder
+			for _, t := range part {
+				text.WriteString(t.data)
+			}
+			s.unused = append(s.unused, cssToken{data: text.String(), offset: part[0].offset})
+			if s.opts.UnusedSelectors == "remove" {
+				continue
+			}
+		}
+		if len(result) > 0 {
+			result = append(result, cssToken{css.CommaToken, ",", part[0].offset})
+		}
+		result = append(result, part...)
+	}
+	return result
+}
+
+// skipBlock leaves out the rest of a rule, including its block
+func (s *styleScoper) skipBlock() {
+	depth := 0
+	for ; !s.done(); s.i++ {
+		switch s.peek().tt {
+		case css.SemicolonToken:
+			if depth == 0 {
+				s.i++
+				return
+			}
+		case css.LeftBraceToken:
+			depth++
+		case css.RightBraceToken:
+			if depth == 0 {
+				return
+			}
+			depth--
+			if depth == 0 {
+				s.i++
+				return
+			}
+		}
+	}
+}
+
+// verbatim prints everything up to the end of the current block as is
+func (s *styleScoper) verbatim() {
+	depth := 0
+	for !s.done() {
+		switch s.peek().tt {
+		case css.LeftBraceToken:
+			depth++
+		case css.RightBraceToken:
+			if depth == 0 {
+				return
+			}
+			depth--
+		}
+		s.emit()
+	}
+}
+
+// Whitespace around these is never needed
+var (
+	selectorSeparators    = map[string]bool{",": true, ">": true, "+": true, "~": true}
+	declarationSeparators = map[string]bool{",": true, "/": true, ":": true, "!": true, "=": true}
+	atRuleSeparators      = map[string]bool{",": true, ":": true}
+)
+
+// compact removes comments and any whitespace which isn't needed from
+// tokens: at either end, around separators, after `(` and before `)`, and
+// inside of attribute selectors. Other whitespace becomes a single space.
+func compact(tokens []cssToken, separators map[string]bool) []cssToken {
+	result := make([]cssToken, 0, len(tokens))
+	space := false
+	bracket := false
+	for _, t := range tokens {
+		switch t.tt {
+		case css.CommentToken:
+			continue
+		case css.WhitespaceToken:
+			space = true
+			continue
+		}
+		if space && len(result) > 0 && !bracket {
+			prev := result[len(result)-1]
+			if !separators[prev.data] && !separators[t.data] && prev.tt != css.LeftParenthesisToken && prev.tt != css.FunctionToken && t.tt != css.RightParenthesisToken {
+				result = append(result, cssToken{css.WhitespaceToken, " ", t.offset})
+			}
+		}
+		space = false
+		switch t.tt {
+		case css.LeftBracketToken:
+			bracket = true
+		case css.RightBracketToken:
+			bracket = false
+		}
+		result = append(result, t)
+	}
+	return result
+}
+
+// scopeSelector scopes every compound selector of a selector list
+func scopeSelector(tokens []cssToken, opts TransformOptions) string {
+	var out strings.Builder
+	parenCount := 0          // keeps track of open parens. scoping can’t happen inside parens (:not(), :where(), etc.)
+	isBracket := false       // keeps track of attr brackets (can’t nest like parens, so it’s simply ”open”/“close”)
+	isGlobal := false        // keeps track of :global() function (no scope, and omit from output)
+	isElement := true        // keeps track of base element selectors (e.g. body, h1). Elements must be assumed ("true") until ".", "#", etc. are encountered
+	isGlobalElement := false // keeps track of <body>, <html>, and other protected elements (isElement will always be true as well)
+	isPseudoState := false   // keeps track of pseudo state/element context (i.e. ensures :hover or ::before don’t get scoped). This is "false" until ":" is encountered
+	isStart := true          // whether this is the start of a selector in the list, ignoring whitespace
+	for n, val := range tokens {
+		strVal := val.data
+		start := isStart
+		isStart = isStart && val.tt == css.WhitespaceToken
+
+		switch strVal {
+		case ".",
+			"#":
+			isPseudoState = false
+			isElement = false
+			out.WriteString(strVal)
+		case ":":
+			isPseudoState = true
+			// look ahead to see if this is the start of ":global(".
+			// If so, omit from output and start global state
+			if len(tokens) > n+1 && tokens[n+1].data == "global(" {
+				isGlobal = true
+			} else {
+				// if not the start of ":global(", then include in output
+				out.WriteString(strVal)
+			}
+		case "global(":
+			parenCount++
+			// omit from output
+		case "(":
+			parenCount++
+			out.WriteString(strVal)
+			isElement = true
+			isPseudoState = false
+		case ")":
+			parenCount--
+			if !isGlobal || parenCount != 0 {
+				out.WriteString(strVal) // output only if this doesn’t close ":global("
+			}
+		case "[":
+			isBracket = true
+			isElement = false
+			isPseudoState = false
+			// if there is no selector before an attribute selector, then assume "*"
+			if start && parenCount == 0 && !isGlobal {
+				out.WriteString(scopeRule("", opts))
+			}
+			out.WriteString(strVal)
+		case "]":
+			isBracket = false
+			out.WriteString(strVal)
+		case ",":
+			out.WriteString(strVal)
+			if parenCount == 0 {
+				// the next selector of the list starts fresh
+				isStart = true
+				isElement = true
+				isGlobalElement = false
+				isPseudoState = false
+				if isGlobal {
+					isGlobal = false
 				}
+			}
+		case "*":
+			if parenCount == 0 && !isGlobal {
+				out.WriteString(scopeRule("", opts)) // turns "*" into ".astro-XXXXXX" rather than "*.astro-XXXXXX"
+			} else {
+				out.WriteString(strVal)
+			}
+		default:
+			// handle IDs with parens attached
+			if strings.Contains(strVal, "(") {
+				parenCount++ // if new paren opened, count it
+				isElement = true
+				isPseudoState = false
+			}
 
-				// generate tail of next rule
-				switch gt {
-				case css.BeginAtRuleGrammar,
-					css.BeginRulesetGrammar:
-					out += "{"
-				case css.DeclarationGrammar,
-					css.EndRulesetGrammar,
-					css.EndAtRuleGrammar:
-					out += ";"
-				case css.QualifiedRuleGrammar:
-					out += ","
+			// if this is an element, check if it’s <body>, etc.
+			if isElement && globalElement(strVal) {
+				isGlobalElement = true
+			}
+
+			// whitespace tokens are used to reset chained classes and functions
+			if val.tt == css.WhitespaceToken {
+				// important: global elements like <body> may have classes that should not be scoped
+				if isElement && isGlobalElement {
+					isGlobalElement = false
 				}
-			default:
-				strData := string(data)
-				out += strData
-				for _, val := range p.Values() {
-					strVal := string(val.Data)
-					// handle CSS variables
-					if strings.HasPrefix(strData, "--") {
-						out += ":"
-					}
-					out += strVal
+
+				// important: :global() might be chained (:global().some-class)
+				// so keep it active until whitespace is reached after final paren
+				if isGlobal && parenCount == 0 {
+					isGlobal = false
 				}
-				out += ";"
 			}
+
+			// scope class
+			isCssSelector := val.tt == css.IdentToken || val.tt == css.HashToken
+			if isCssSelector &&
+				!isPseudoState && // don’t scope pseudostates
+				!isGlobal && // don’t scope in :global() scope
+				!isGlobalElement &&
+				!isBracket && // don’t scope within element brackets
+				parenCount == 0 { // don’t scope within parens like :not()
+				out.WriteString(scopeRule(strVal, opts))
+			} else {
+				// otherwise, append output
+				out.WriteString(strVal)
+			}
+
+			// reset state
+			isElement = true
+			isPseudoState = false
 		}
-		n.FirstChild.Data = out
 	}
-	return didScope
+	return out.String()
 }
 
-// Turn ".foo" into ".foo.astro-XXXXXX"
+// Turn ".foo" into ".foo.astro-XXXXXX", ".foo[data-astro-cid-XXXXXX]" with
+// the attribute strategy or ".foo:where(.astro-XXXXXX)" with the where strategy
 func scopeRule(id string, opts TransformOptions) string {
+	switch opts.ScopedStyleStrategy {
+	case "attribute":
+		return id + "[" + scopeAttribute(opts) + "]"
+	case "where":
+		return id + ":where(.astro-" + opts.Scope + ")"
+	}
 	return id + ".astro-" + opts.Scope
 }
 
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	tycho "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/handler"
 	"github.com/snowpackjs/astro/internal/test_utils"
 )
 
@@ -219,7 +220,7 @@ func TestScopeStyle(t *testing.T) {
 			}
 			styleEl := doc.LastChild.FirstChild.FirstChild // note: root is <html>, and we need to get <style> which lives in head
 			styles := []*tycho.Node{styleEl}
-			ScopeStyle(styles, TransformOptions{Scope: "XXXXXX"})
+			ScopeStyle(styles, TransformOptions{Scope: "XXXXXX"}, handler.NewHandler(code, "<stdin>"), nil)
 			got := styles[0].FirstChild.Data
 			if tt.want != got {
 				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %s\n  got:  %s", tt.name, tt.want, got))
@@ -227,3 +228,267 @@ func TestScopeStyle(t *testing.T) {
 		})
 	}
 }
+
+func TestScopeStyleAttribute(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "class",
+			source: ".class{}",
+			want:   ".class[data-astro-cid-XXXXXX]{}",
+		},
+		{
+			name:   "element + pseudo state",
+			source: ".class button:focus{}",
+			want:   ".class[data-astro-cid-XXXXXX] button[data-astro-cid-XXXXXX]:focus{}",
+		},
+		{
+			name:   "attr universal implied",
+			source: "[aria-visible]{}",
+			want:   "[data-astro-cid-XXXXXX][aria-visible]{}",
+		},
+		{
+			name:   "universal",
+			source: ".class>*{}",
+			want:   ".class[data-astro-cid-XXXXXX]>[data-astro-cid-XXXXXX]{}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := test_utils.Dedent("<style>\n" + tt.source + " \n</style>")
+			doc, err := tycho.Parse(strings.NewReader(code))
+			if err != nil {
+				t.Error(err)
+			}
+			styles := []*tycho.Node{doc.LastChild.FirstChild.FirstChild}
+			ScopeStyle(styles, TransformOptions{Scope: "XXXXXX", ScopedStyleStrategy: "attribute"}, handler.NewHandler(code, "<stdin>"), nil)
+			got := styles[0].FirstChild.Data
+			if tt.want != got {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %s\n  got:  %s", tt.name, tt.want, got))
+			}
+		})
+	}
+}
+
+func TestScopeStyleWhere(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "class",
+			source: ".class{}",
+			want:   ".class:where(.astro-XXXXXX){}",
+		},
+		{
+			name:   "element + pseudo element",
+			source: ".class h3::before{}",
+			want:   ".class:where(.astro-XXXXXX) h3:where(.astro-XXXXXX)::before{}",
+		},
+		{
+			name:   "universal",
+			source: "*:hover{}",
+			want:   ":where(.astro-XXXXXX):hover{}",
+		},
+		{
+			name:   "global",
+			source: ".class :global(ul li){}",
+			want:   ".class:where(.astro-XXXXXX) ul li{}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := test_utils.Dedent("<style>\n" + tt.source + " \n</style>")
+			doc, err := tycho.Parse(strings.NewReader(code))
+			if err != nil {
+				t.Error(err)
+			}
+			styles := []*tycho.Node{doc.LastChild.FirstChild.FirstChild}
+			ScopeStyle(styles, TransformOptions{Scope: "XXXXXX", ScopedStyleStrategy: "where"}, handler.NewHandler(code, "<stdin>"), nil)
+			got := styles[0].FirstChild.Data
+			if tt.want != got {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %s\n  got:  %s", tt.name, tt.want, got))
+			}
+		})
+	}
+}
+
+func TestScopeStyleModern(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "nesting",
+			source: ".card{color:red;.title{font-weight:bold}}",
+			want:   ".card.astro-XXXXXX{color:red;.title.astro-XXXXXX{font-weight:bold;}}",
+		},
+		{
+			name:   "nesting selector",
+			source: ".card{&:hover{color:red}& .title{color:blue}}",
+			want:   ".card.astro-XXXXXX{&:hover{color:red;}& .title.astro-XXXXXX{color:blue;}}",
+		},
+		{
+			name:   "nested combinator",
+			source: ".card{> p{margin:0}}",
+			want:   ".card.astro-XXXXXX{>p.astro-XXXXXX{margin:0;}}",
+		},
+		{
+			name:   "nested element",
+			source: ".card{p{margin:0}}",
+			want:   ".card.astro-XXXXXX{p.astro-XXXXXX{margin:0;}}",
+		},
+		{
+			name:   "nested media",
+			source: ".card{color:red;@media (min-width:640px){color:blue}}",
+			want:   ".card.astro-XXXXXX{color:red;@media (min-width:640px){color:blue;}}",
+		},
+		{
+			name:   "container",
+			source: ".wrapper{container:card / inline-size}@container card (min-width:400px){.title{font-size:2rem}}",
+			want:   ".wrapper.astro-XXXXXX{container:card/inline-size;}@container card (min-width:400px){.title.astro-XXXXXX{font-size:2rem;}}",
+		},
+		{
+			name:   "layer statement",
+			source: "@layer base, components;",
+			want:   "@layer base,components;",
+		},
+		{
+			name:   "layer block",
+			source: "@layer base{h1{margin:0}}",
+			want:   "@layer base{h1.astro-XXXXXX{margin:0;}}",
+		},
+		{
+			name:   "anonymous layer",
+			source: "@layer{h1{margin:0}}",
+			want:   "@layer{h1.astro-XXXXXX{margin:0;}}",
+		},
+		{
+			name:   "scope",
+			source: "@scope (.card) to (.content){img{border:0}}",
+			want:   "@scope (.card) to (.content){img.astro-XXXXXX{border:0;}}",
+		},
+		{
+			name:   "supports",
+			source: "@supports (display:grid) and selector(:has(a)){.grid{display:grid}}",
+			want:   "@supports (display:grid) and selector(:has(a)){.grid.astro-XXXXXX{display:grid;}}",
+		},
+		{
+			name:   "selector list",
+			source: "h1, h2 , .title{margin:0}",
+			want:   "h1.astro-XXXXXX,h2.astro-XXXXXX,.title.astro-XXXXXX{margin:0;}",
+		},
+		{
+			name:   "is list",
+			source: ":is(h1, h2) a{color:red}",
+			want:   ":is(h1,h2) a.astro-XXXXXX{color:red;}",
+		},
+		{
+			name:   "has",
+			source: ".card:has(> img){padding:0}",
+			want:   ".card.astro-XXXXXX:has(>img){padding:0;}",
+		},
+		{
+			name:   "keyframes",
+			source: "@keyframes fade{from{opacity:0}50%{opacity:.5}to{opacity:1}}",
+			want:   "@keyframes fade{from{opacity:0;}50%{opacity:.5;}to{opacity:1;}}",
+		},
+		{
+			name:   "vendor keyframes",
+			source: "@-webkit-keyframes fade{from{opacity:0}}",
+			want:   "@-webkit-keyframes fade{from{opacity:0;}}",
+		},
+		{
+			name:   "property",
+			source: "@property --angle{syntax:'<angle>';inherits:false;initial-value:0deg}",
+			want:   "@property --angle{syntax:'<angle>';inherits:false;initial-value:0deg;}",
+		},
+		{
+			name:   "font-face",
+			source: "@font-face{font-family:Inter;src:url(inter.woff2) format('woff2')}",
+			want:   "@font-face{font-family:Inter;src:url(inter.woff2) format('woff2');}",
+		},
+		{
+			name:   "custom property",
+			source: ".a{--shadow: 0 0 1px red;color:var(--shadow)}",
+			want:   ".a.astro-XXXXXX{--shadow: 0 0 1px red;color:var(--shadow);}",
+		},
+		{
+			name:   "unknown at-rule",
+			source: "@tailwind base;@apply-to .a { color: red }.b{color:blue}",
+			want:   "@tailwind base;@apply-to .a { color: red }.b.astro-XXXXXX{color:blue;}",
+		},
+		{
+			name:   "stray brace",
+			source: "}.a{color:red}",
+			want:   "}.a.astro-XXXXXX{color:red;}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := test_utils.Dedent("<style>\n" + tt.source + " \n</style>")
+			doc, err := tycho.Parse(strings.NewReader(code))
+			if err != nil {
+				t.Error(err)
+			}
+			styles := []*tycho.Node{doc.LastChild.FirstChild.FirstChild}
+			ScopeStyle(styles, TransformOptions{Scope: "XXXXXX"}, handler.NewHandler(code, "<stdin>"), nil)
+			got := styles[0].FirstChild.Data
+			if tt.want != got {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %s\n  got:  %s", tt.name, tt.want, got))
+			}
+		})
+	}
+}
+
+func TestScopeStyleUnknownAtRule(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "unknown",
+			source: `<style>@tailwind base;.a{color:red}</style>`,
+			want:   []string{"Unknown at-rule @tailwind is left unscoped"},
+		},
+		{
+			name:   "known",
+			source: `<style>@media print{.a{color:red}}@font-face{font-family:A}</style>`,
+			want:   []string{},
+		},
+		{
+			name:   "inside of keyframes",
+			source: `<style>@keyframes a{@unknown{}}</style>`,
+			want:   []string{},
+		},
+		{
+			name:   "other language",
+			source: `<style lang="scss">@mixin a{color:red}</style>`,
+			want:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := tycho.Parse(strings.NewReader(tt.source))
+			if err != nil {
+				t.Error(err)
+			}
+			h := handler.NewHandler(tt.source, "<stdin>")
+			styles := []*tycho.Node{doc.LastChild.FirstChild.FirstChild}
+			ScopeStyle(styles, TransformOptions{Scope: "XXXXXX"}, h, nil)
+			got := make([]string, 0)
+			for _, w := range h.Warnings() {
+				got = append(got, w.Text)
+			}
+			if fmt.Sprint(tt.want) != fmt.Sprint(got) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %v\n  got:  %v", tt.name, tt.want, got))
+			}
+		})
+	}
+}
@@ -1,13 +1,19 @@
 package transform
 
 import (
+	"fmt"
+
 	tycho "github.com/snowpackjs/astro/internal"
 )
 
 func ScopeElement(n *tycho.Node, opts TransformOptions) {
 	if n.Type == tycho.ElementNode {
 		if _, noScope := NeverScopedElements[n.Data]; !noScope {
-			injectScopedClass(n, opts)
+			if opts.ScopedStyleStrategy == "attribute" {
+				injectScopedAttribute(n, opts)
+			} else {
+				injectScopedClass(n, opts)
+			}
 		}
 	}
 }
@@ -36,6 +42,48 @@ var NeverScopedSelectors map[string]bool = map[string]bool{
 	":root": true,
 }
 
+// ScopeRootElement adds the scoped class to n if it's a top-level element of
+// the template, outside of any other element. Implicit elements, fragments
+// and expressions don't count, so every element they wrap is top-level.
+func ScopeRootElement(n *tycho.Node, opts TransformOptions) {
+	if isRootElement(n) {
+		ScopeElement(n, opts)
+	}
+}
+
+func isRootElement(n *tycho.Node) bool {
+	if n.Type != tycho.ElementNode || isTransparentElement(n) {
+		return false
+	}
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.Type == tycho.ElementNode && !isTransparentElement(p) {
+			return false
+		}
+	}
+	return true
+}
+
+func isTransparentElement(n *tycho.Node) bool {
+	return IsImplictNode(n) || n.Fragment || n.Expression
+}
+
+func scopeAttribute(opts TransformOptions) string {
+	return "data-astro-cid-" + opts.Scope
+}
+
+// An attribute doesn't have to be merged with anything, components are passed
+// it as a prop
+func injectScopedAttribute(n *tycho.Node, opts TransformOptions) {
+	key := scopeAttribute(opts)
+	if HasAttr(n, key) {
+		return
+	}
+	n.Attr = append(n.Attr, tycho.Attribute{
+		Key:  key,
+		Type: tycho.EmptyAttribute,
+	})
+}
+
 func injectScopedClass(n *tycho.Node, opts TransformOptions) {
 	for i, attr := range n.Attr {
 		// If we find an existing class attribute, append the scoped class
@@ -67,9 +115,44 @@ func injectScopedClass(n *tycho.Node, opts TransformOptions) {
 			}
 		}
 	}
+	// `class:list` is rendered as the class attribute, so a second one would
+	// be ignored
+	for i, attr := range n.Attr {
+		if attr.Key == "class:list" {
+			switch attr.Type {
+			case tycho.QuotedAttribute, tycho.TemplateLiteralAttribute:
+				attr.Val = attr.Val + " astro-" + opts.Scope
+				n.Attr[i] = attr
+				return
+			case tycho.ExpressionAttribute:
+				// as one more entry of the list
+				attr.Val = "[" + attr.Val + `, "astro-` + opts.Scope + `"]`
+				n.Attr[i] = attr
+				return
+			}
+		}
+	}
+	// A spread of a root element may set the class too, so the scoped class is
+	// merged into whatever class the last spread has
+	if opts.ScopeRootElements && mergeIntoSpread(n, "class", `"astro-`+opts.Scope+`"`) {
+		return
+	}
 	// If we didn't find an existing class attribute, let's add one
 	n.Attr = append(n.Attr, tycho.Attribute{
 		Key: "class",
 		Val: "astro-" + opts.Scope,
 	})
 }
+
+// mergeIntoSpread merges value into the key attribute set by the last spread
+// of n with the `$$mergeAttr` runtime helper, and reports if n has a spread
+func mergeIntoSpread(n *tycho.Node, key string, value string) bool {
+	for i := len(n.Attr) - 1; i >= 0; i-- {
+		if attr := n.Attr[i]; attr.Type == tycho.SpreadAttribute {
+			attr.Key = fmt.Sprintf(`$$mergeAttr(%s, "%s", %s)`, attr.Key, key, value)
+			n.Attr[i] = attr
+			return true
+		}
+	}
+	return false
+}
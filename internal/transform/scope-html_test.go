@@ -55,6 +55,21 @@ func TestScopeHTML(t *testing.T) {
 			source: "<div class=`${value}` />",
 			want:   "<div class=`${value} astro-XXXXXX`></div>",
 		},
+		{
+			name:   "class:list expression",
+			source: "<div class:list={['a', { b: true }]} />",
+			want:   `<div class:list={[['a', { b: true }], "astro-XXXXXX"]}></div>`,
+		},
+		{
+			name:   "class:list quoted",
+			source: `<div class:list="a b" />`,
+			want:   `<div class:list="a b astro-XXXXXX"></div>`,
+		},
+		{
+			name:   "class before class:list",
+			source: `<div class="a" class:list={b} />`,
+			want:   `<div class="a astro-XXXXXX" class:list={b}></div>`,
+		},
 		{
 			name:   "component className not scoped",
 			source: `<Component className="test" />`,
@@ -87,3 +102,93 @@ func TestScopeHTML(t *testing.T) {
 		})
 	}
 }
+
+func TestScopeRootElement(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "top-level elements",
+			source: `<div><span /></div><p class="a" />`,
+			want:   `<html><head></head><body><div class="astro-XXXXXX"><span></span></div><p class="a astro-XXXXXX"></p></body></html>`,
+		},
+		{
+			name:   "fragments",
+			source: `<Fragment><div><span /></div></Fragment>`,
+			want:   `<html><body><Fragment><div class="astro-XXXXXX"><span></span></div></Fragment></body></html>`,
+		},
+		{
+			name:   "expressions",
+			source: `{show && <div><span /></div>}`,
+			want:   `<html><head></head><body><astro:expression>show && <div class="astro-XXXXXX"><span></span></div></astro:expression></body></html>`,
+		},
+		{
+			name:   "never scoped",
+			source: `<style></style><meta charset="utf-8" />`,
+			want:   `<html><head><style></style><meta charset="utf-8"></meta></head><body></body></html>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := astro.Parse(strings.NewReader(tt.source))
+			if err != nil {
+				t.Error(err)
+			}
+			var b strings.Builder
+			walk(doc, func(n *astro.Node) {
+				ScopeRootElement(n, TransformOptions{Scope: "XXXXXX"})
+			})
+			astro.PrintToSource(&b, doc)
+			got := b.String()
+			if tt.want != got {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %s\n  got:  %s", tt.name, tt.want, got))
+			}
+		})
+	}
+}
+
+func TestScopeHTMLAttribute(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "none",
+			source: "<div />",
+			want:   `<div data-astro-cid-XXXXXX></div>`,
+		},
+		{
+			name:   "class left alone",
+			source: `<div class={clsx(a)} class:list={b} />`,
+			want:   `<div class={clsx(a)} class:list={b} data-astro-cid-XXXXXX></div>`,
+		},
+		{
+			name:   "component",
+			source: `<Component className="test" />`,
+			want:   `<Component className="test" data-astro-cid-XXXXXX></Component>`,
+		},
+		{
+			name:   "already scoped",
+			source: `<div data-astro-cid-XXXXXX />`,
+			want:   `<div data-astro-cid-XXXXXX></div>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := astro.ParseFragment(strings.NewReader(tt.source), &astro.Node{Type: astro.ElementNode, DataAtom: atom.Body, Data: atom.Body.String()})
+			if err != nil {
+				t.Error(err)
+			}
+			ScopeElement(nodes[0], TransformOptions{Scope: "XXXXXX", ScopedStyleStrategy: "attribute"})
+			var b strings.Builder
+			astro.PrintToSource(&b, nodes[0])
+			got := b.String()
+			if tt.want != got {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %s\n  got:  %s", tt.name, tt.want, got))
+			}
+		})
+	}
+}
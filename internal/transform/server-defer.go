@@ -0,0 +1,53 @@
+package transform
+
+import (
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/js_scanner"
+)
+
+// Directive which splits an element and its children into a separate
+// component, so the server can render it later than the rest of the page
+const ServerDeferDirective = "server:defer"
+
+func IsDeferred(n *astro.Node) bool {
+	return n.Type == astro.ElementNode && HasAttr(n, ServerDeferDirective)
+}
+
+// DeferredProps returns the frontmatter variables which the deferred subtree n
+// refers to, in the order they are declared. The subtree is rendered outside
+// of the component function, so these have to be passed to it as props.
+// Imports are in scope at the top level and never need to be passed.
+func DeferredProps(doc *astro.Node, n *astro.Node) []string {
+	var frontmatter []byte
+	for c := doc.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == astro.FrontmatterNode && c.FirstChild != nil {
+			frontmatter = []byte(c.FirstChild.Data)
+		}
+	}
+	if frontmatter == nil {
+		return []string{}
+	}
+
+	imported := make(map[string]bool)
+	pos, statement := js_scanner.NextImportStatement(frontmatter, 0)
+	for pos != -1 {
+		for _, i := range statement.Imports {
+			imported[i.LocalName] = true
+		}
+		pos, statement = js_scanner.NextImportStatement(frontmatter, pos)
+	}
+	referenced := make(map[string]bool)
+	for _, name := range TemplateReferences(n) {
+		referenced[name] = true
+	}
+
+	props := make([]string, 0)
+	for _, name := range js_scanner.Declarations(frontmatter) {
+		if referenced[name] && !imported[name] {
+			props = append(props, name)
+			// A name may be declared more than once
+			referenced[name] = false
+		}
+	}
+	return props
+}
@@ -0,0 +1,67 @@
+package transform
+
+import (
+	"path"
+	"strings"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/js_scanner"
+	"github.com/snowpackjs/astro/internal/loc"
+)
+
+// StyleImport is a stylesheet imported by the frontmatter, like
+// `import './global.css'`
+type StyleImport struct {
+	Specifier string
+	Loc       loc.Loc
+}
+
+var styleExtensions = map[string]bool{
+	".css":     true,
+	".scss":    true,
+	".sass":    true,
+	".less":    true,
+	".styl":    true,
+	".stylus":  true,
+	".pcss":    true,
+	".postcss": true,
+}
+
+// CollectStyleImports returns every stylesheet the frontmatter imports, in
+// order, so a dev server can link them without loading the module graph.
+// Imports with a query, like `?url` or `?inline`, don't add a stylesheet to
+// the page and are left out.
+func CollectStyleImports(doc *astro.Node) []StyleImport {
+	imports := make([]StyleImport, 0)
+	var text *astro.Node
+	for c := doc.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == astro.FrontmatterNode && c.FirstChild != nil {
+			text = c.FirstChild
+			break
+		}
+	}
+	if text == nil {
+		return imports
+	}
+
+	source := []byte(text.Data)
+	pos, statement := js_scanner.NextImportStatement(source, 0)
+	for pos != -1 {
+		if isStyleSpecifier(statement.Specifier) {
+			i := StyleImport{Specifier: statement.Specifier}
+			if len(text.Loc) > 0 {
+				i.Loc = loc.Loc{Start: text.Loc[0].Start + statement.Span.Start}
+			}
+			imports = append(imports, i)
+		}
+		pos, statement = js_scanner.NextImportStatement(source, pos)
+	}
+	return imports
+}
+
+func isStyleSpecifier(specifier string) bool {
+	if strings.Contains(specifier, "?") {
+		return false
+	}
+	return styleExtensions[strings.ToLower(path.Ext(specifier))]
+}
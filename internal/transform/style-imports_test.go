@@ -0,0 +1,53 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	astro "github.com/snowpackjs/astro/internal"
+)
+
+func TestCollectStyleImports(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "stylesheets",
+			source: "---\nimport './global.css';\nimport Component from './Component.astro';\nimport styles from '../styles/Card.module.SCSS'\n---\n<Component />",
+			want:   []string{"./global.css@4", "../styles/Card.module.SCSS@70"},
+		},
+		{
+			name:   "queries",
+			source: "---\nimport url from './global.css?url';\nimport inline from './global.css?inline';\n---\n<div />",
+			want:   []string{},
+		},
+		{
+			name:   "commented out",
+			source: "---\n// import './global.css'\nconst a = \"import './b.css'\";\n---\n<div />",
+			want:   []string{},
+		},
+		{
+			name:   "no frontmatter",
+			source: "<link rel=\"stylesheet\" href=\"./global.css\">",
+			want:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := astro.Parse(strings.NewReader(tt.source))
+			if err != nil {
+				t.Error(err)
+			}
+			got := make([]string, 0)
+			for _, i := range CollectStyleImports(doc) {
+				got = append(got, fmt.Sprintf("%s@%d", i.Specifier, i.Loc.Start))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %v\n  got:  %v", tt.name, tt.want, got))
+			}
+		})
+	}
+}
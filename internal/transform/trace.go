@@ -0,0 +1,80 @@
+package transform
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	astro "github.com/snowpackjs/astro/internal"
+)
+
+// Trace records what a compile did, to debug why a template compiles slowly
+// or not as expected: how long each phase took, how many nodes the document
+// had after it, and the decisions the passes made along the way. Its methods
+// do nothing on a nil Trace, so passes can record decisions unconditionally.
+type Trace struct {
+	Events []TraceEvent
+	w      io.Writer
+}
+
+// TraceEvent is either the end of a phase, with its Duration and Nodes, or
+// a Decision made by a Pass during the phase
+type TraceEvent struct {
+	Phase    string
+	Pass     string
+	Decision string
+	Duration time.Duration
+	Nodes    int
+}
+
+// NewTrace returns a trace which also writes every event to w as a line of
+// text as soon as it happens, unless w is nil
+func NewTrace(w io.Writer) *Trace {
+	return &Trace{Events: make([]TraceEvent, 0), w: w}
+}
+
+// Phase starts timing phase, and returns the function which ends it with the
+// document after the phase
+func (t *Trace) Phase(phase string) func(doc *astro.Node) {
+	if t == nil {
+		return func(*astro.Node) {}
+	}
+	start := time.Now()
+	return func(doc *astro.Node) {
+		t.add(TraceEvent{Phase: phase, Duration: time.Since(start), Nodes: CountNodes(doc)})
+	}
+}
+
+// Decide records the decision pass made during phase
+func (t *Trace) Decide(phase string, pass string, format string, args ...interface{}) {
+	if t == nil {
+		return
+	}
+	t.add(TraceEvent{Phase: phase, Pass: pass, Decision: fmt.Sprintf(format, args...)})
+}
+
+func (t *Trace) add(e TraceEvent) {
+	t.Events = append(t.Events, e)
+	if t.w != nil {
+		fmt.Fprintln(t.w, e)
+	}
+}
+
+func (e TraceEvent) String() string {
+	if e.Pass != "" {
+		return fmt.Sprintf("%s: %s: %s", e.Phase, e.Pass, e.Decision)
+	}
+	return fmt.Sprintf("%s: %s, %d nodes", e.Phase, e.Duration, e.Nodes)
+}
+
+// CountNodes returns the number of nodes in the tree of n
+func CountNodes(n *astro.Node) int {
+	if n == nil {
+		return 0
+	}
+	count := 1
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		count += CountNodes(c)
+	}
+	return count
+}
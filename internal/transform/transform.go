@@ -3,9 +3,12 @@ package transform
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	astro "github.com/snowpackjs/astro/internal"
 	tycho "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/escape"
+	"github.com/snowpackjs/astro/internal/handler"
 	"golang.org/x/net/html/atom"
 	a "golang.org/x/net/html/atom"
 )
@@ -18,22 +21,204 @@ type TransformOptions struct {
 	SourceMap       string
 	Site            string
 	PreprocessStyle interface{}
+	DedentRaw       bool
+	Entities        string
+	// Maps custom element tag names to the module that defines them. Hydrated
+	// custom elements with an entry here are resolved to that module directly.
+	CustomElements map[string]string
+	// Called with every local asset URL while printing, the returned URL is
+	// printed in its place
+	RewriteAsset func(url string) string
+	// Warn about images without alt text or dimensions
+	ImageHints bool
+	// Warn about common accessibility mistakes
+	A11y bool
+	// Report likely mistakes, like unclosed elements or unknown directives, as
+	// errors instead of warnings so the compile fails
+	Strict bool
+	// How the runtime should serialize the props of hydrated components:
+	// "attribute" (the default), "script" or "reference"
+	PropsSerialization string
+	// Collect every piece of translatable text, see ExtractMessages
+	ExtractMessages bool
+	// The name of a function that translated text is passed through, which
+	// must be in scope in the frontmatter
+	Translate string
+	// Maps global expressions, like `import.meta.env.MODE`, to the JavaScript
+	// they're replaced with at compile time. Conditional markup which becomes
+	// constant is removed, along with imports only it used.
+	Define map[string]string
+	// Keep `{/* comments */}` in the template as HTML comments instead of removing them
+	PreserveJSXComments bool
+	// The kind of document being generated, "html" (the default) or "xml".
+	// XML is parsed without any HTML-specific rules, and keeps processing
+	// instructions and CDATA sections as authored.
+	ContentType string
+	// Keep the attribute names of HTML elements as authored instead of lowercasing them
+	PreserveAttributeCase bool
+	// Surround every component render with start and end markers, so SSR
+	// profiling tools can show how long each component took to render. The
+	// markers are only called while `$$result.renderTelemetry` is set.
+	RenderTelemetry bool
+	// Surround the render of every hydrated component with
+	// `<!--astro:island-start:ID-->` and `<!--astro:island-end:ID-->`
+	// comments, so streaming servers and edge middleware can find islands in
+	// the rendered HTML, see IslandNodes
+	IslandMarkers bool
+	// Add the compiler version to the component metadata, so caches of
+	// compiled components can be invalidated when the compiler changes
+	StampVersion bool
+	// Whether the component is a page, which adds what the build needs to
+	// validate its route to the component metadata, see RouteInfo
+	IsPage bool
+	// The route pattern of a page, like "/blog/[slug]", to check the params
+	// that the page reads from `Astro.params` against
+	Route string
+	// Read a block of YAML fenced by `---`, or TOML fenced by `+++`, at the
+	// very start of the source as data for content pipelines instead of as the
+	// component script, see ExtractDataFrontmatter
+	DataFrontmatter bool
+	// Always print the `$$metadata` export and the `Astro` global. They're
+	// left out of components which have no frontmatter, render no components
+	// and never mention `Astro`, unless something needs every module to have them.
+	FullOutput bool
+	// Leave the `//@ts-ignore` comments and blank lines out of the generated
+	// module, except in literals and where the source map needs them
+	CompactOutput bool
+	// The module format of the output: "esm" (the default), "cjs", which
+	// rewrites imports and exports to `require()` and `exports`, see
+	// js_scanner.ToCommonJS, or the experimental "iife", which wraps the
+	// CommonJS module in a function that takes the runtime from RuntimeGlobal
+	// and assigns the exports to GlobalName, for script tags without a bundler
+	ModuleFormat string
+	// How the object passed to createMetadata for the `$$metadata` export is
+	// formatted: "single-line" (the default), or "multi-line" with a line for
+	// every property and every item of its arrays, so output diffs stay small
+	// in projects which snapshot test their compiled components. The module
+	// imports the object refers to are a line each in both formats.
+	MetadataFormat string
+	// The global an "iife" module assigns its exports to, "AstroComponent" by default
+	GlobalName string
+	// The global an "iife" module takes the runtime from, "astroInternal" by default
+	RuntimeGlobal string
+	// Added to the top and the bottom of the generated module as they are,
+	// after it's converted to ModuleFormat, for things like license headers,
+	// polyfill imports or HMR code. They aren't mapped by the source map.
+	Banner string
+	Footer string
+	// Add the scope class to the top-level elements of the template even if
+	// the component has no styles, so styles scoped to the same Scope elsewhere
+	// apply to them too
+	ScopeRootElements bool
+	// How elements are matched by scoped styles: "class" (the default) adds
+	// an `astro-XXXX` class, "attribute" adds a `data-astro-cid-XXXX`
+	// attribute, which can't conflict with how frameworks handle classes, and
+	// "where" adds the class but matches it with `:where(.astro-XXXX)`, so
+	// scoping doesn't add to the specificity of selectors
+	ScopedStyleStrategy string
+	// What to do with scoped selectors which can't match any element of the
+	// template: "keep" them (the default), "warn" about them or "remove" them.
+	// A class or id which a script mentions is assumed to be added at runtime.
+	UnusedSelectors string
+	// How whitespace around scripts, styles and expressions is trimmed:
+	// "smart" (the default) trims wherever it can't change the output,
+	// "none" keeps everything as authored, and "aggressive" also trims
+	// template literal attributes and collapses whitespace between elements
+	TrimWhitespace string
+	// A JavaScript variable, like `Astro.locals.nonce`, which every <script>
+	// and <style> the component renders gets its nonce attribute from, for
+	// apps which enforce a strict Content Security Policy. Elements which
+	// have a nonce of their own keep it.
+	CSPNonceVar string
+	// Performance budgets, which are warned about when the component goes
+	// over them, see Budgets. Zero means no budget.
+	IslandBudget       int
+	InlineScriptBudget int // in bytes
+	// Limits which abort the compile with an error, so pathological or
+	// generated input can't hang the compiler or exhaust its memory. Zero
+	// means unlimited.
+	MaxInputSize       int // in bytes
+	MaxNodes           int
+	MaxExpressionDepth int
+	Timeout            time.Duration
+	// Records phase timings, node counts and the decisions of passes when
+	// set, see Trace
+	Trace *Trace
 }
 
-func Transform(doc *tycho.Node, opts TransformOptions) *tycho.Node {
-	shouldScope := len(doc.Styles) > 0 && ScopeStyle(doc.Styles, opts)
+func Transform(doc *tycho.Node, opts TransformOptions, h *handler.Handler) *tycho.Node {
+	opts.Normalize()
+	warnRouteParams(doc, opts, h)
+	// Constant conditions are only expected once defines have been substituted
+	if len(opts.Define) > 0 {
+		before := 0
+		if opts.Trace != nil {
+			before = CountNodes(doc)
+		}
+		Define(doc, opts.Define)
+		EliminateDeadBranches(doc)
+		if opts.Trace != nil {
+			opts.Trace.Decide("transform", "define", "substituted %d defines, dead branches removed %d nodes", len(opts.Define), before-CountNodes(doc))
+		}
+	}
+	var usage *SelectorUsage
+	if opts.UnusedSelectors != "keep" {
+		usage = CollectSelectorUsage(doc)
+	}
+	shouldScope := len(doc.Styles) > 0 && ScopeStyle(doc.Styles, opts, h, usage)
+	switch {
+	case shouldScope:
+		opts.Trace.Decide("transform", "scope", "elements scoped to %s with the %s strategy, unused selectors: %s", opts.Scope, opts.ScopedStyleStrategy, opts.UnusedSelectors)
+	case opts.ScopeRootElements && opts.Scope != "":
+		opts.Trace.Decide("transform", "scope", "only root elements scoped to %s, no style is scoped", opts.Scope)
+	default:
+		opts.Trace.Decide("transform", "scope", "not scoped, %d styles are all global", len(doc.Styles))
+	}
+	JSXComments(doc, opts.PreserveJSXComments)
+	declared := FrontmatterDeclarations(doc)
+	UnusedImports(doc, h)
+	warnUndefinedComponents(doc, h)
+	lowercaseAttributes := !opts.PreserveAttributeCase && opts.ContentType != "xml"
 	walk(doc, func(n *tycho.Node) {
+		// Check attributes before the compiler adds any of its own
+		UnknownDirectives(n, h)
+		DuplicateAttributes(n, h)
+		DuplicateProps(n, h)
+		if lowercaseAttributes {
+			LowercaseAttributes(n)
+		}
+		AddKey(n)
+		ScriptInterpolation(n, declared, h)
 		ExtractScript(doc, n)
-		AddComponentProps(doc, n)
+		AddComponentProps(doc, n, opts)
+		if opts.DedentRaw {
+			DedentRaw(n)
+		}
+		if opts.ImageHints {
+			ImageHints(n, h)
+		}
+		if opts.A11y {
+			A11y(n, h)
+		}
 		if shouldScope {
 			ScopeElement(n, opts)
+		} else if opts.ScopeRootElements && opts.Scope != "" {
+			ScopeRootElement(n, opts)
 		}
 	})
 
+	DefineStyleVars(doc)
+	AddTransitions(doc, opts)
+	if opts.Translate != "" {
+		TranslateText(doc, opts.Translate)
+	}
+
 	// Important! Remove scripts from original location *after* walking the doc
 	for _, script := range doc.Scripts {
 		script.Parent.RemoveChild(script)
 	}
+	opts.Trace.Decide("transform", "hoist", "%d styles and %d scripts hoisted", len(doc.Styles), len(doc.Scripts))
+	Budgets(doc, opts, h)
 
 	// Sometimes files have leading <script hoist> or <style>...
 	// Since we can't detect a "component-only" file until after `parse`, we need to handle
@@ -61,6 +246,7 @@ func Transform(doc *tycho.Node, opts TransformOptions) *tycho.Node {
 		if onlyComponent != nil {
 			p := onlyComponent.Parent
 			if IsImplictNode(p) {
+				opts.Trace.Decide("transform", "only-component", "<%s> is the only element, hoisted out of the implicit <%s>", onlyComponent.Data, p.Data)
 				onlyComponent.Parent.RemoveChild(onlyComponent)
 				rootNode.AppendChild(onlyComponent)
 				rootNode.RemoveChild(onlyComponent.PrevSibling)
@@ -84,6 +270,11 @@ func Transform(doc *tycho.Node, opts TransformOptions) *tycho.Node {
 		doc.AppendChild(empty)
 	}
 
+	if opts.Strict {
+		h.PromoteWarnings(strictWarnings...)
+		opts.Trace.Decide("transform", "strict", "likely mistakes promoted to errors")
+	}
+
 	return doc
 }
 
@@ -94,8 +285,8 @@ func ExtractStyles(doc *tycho.Node) {
 			if n.Parent != nil && n.Parent.DataAtom == atom.Svg {
 				return
 			}
-			// prepend node to maintain authored order
-			doc.Styles = append([]*tycho.Node{n}, doc.Styles...)
+			// Styles are kept in document order, so each one keeps its index
+			doc.Styles = append(doc.Styles, n)
 		}
 	})
 	// Important! Remove styles from original location *after* walking the doc
@@ -118,13 +309,17 @@ func ExtractScript(doc *tycho.Node, n *tycho.Node) {
 	if n.Type == tycho.ElementNode && n.DataAtom == a.Script {
 		// if <script hoist>, hoist to the document root
 		if hasTruthyAttr(n, "hoist") {
-			// prepend node to maintain authored order
-			doc.Scripts = append([]*tycho.Node{n}, doc.Scripts...)
+			// Scripts are kept in document order, so each one keeps its index
+			doc.Scripts = append(doc.Scripts, n)
 		}
 	}
 }
 
-func AddComponentProps(doc *tycho.Node, n *tycho.Node) {
+func AddComponentProps(doc *tycho.Node, n *tycho.Node, opts TransformOptions) {
+	// The tag of a dynamic element is only known at runtime, so it can't be hydrated
+	if DynamicTag(n) != nil {
+		return
+	}
 	if n.Type == tycho.ElementNode && (n.Component || n.CustomElement) {
 		for _, attr := range n.Attr {
 			id := n.Data
@@ -133,6 +328,23 @@ func AddComponentProps(doc *tycho.Node, n *tycho.Node) {
 			}
 
 			if strings.HasPrefix(attr.Key, "client:") {
+				// Custom elements register themselves when their module is evaluated,
+				// so they only need a path to load, never an export to render
+				if specifier, ok := opts.CustomElements[n.Data]; ok && n.CustomElement {
+					if attr.Key == "client:only" {
+						doc.ClientOnlyComponents = append([]*tycho.Node{n}, doc.ClientOnlyComponents...)
+					} else {
+						doc.HydratedComponents = append([]*tycho.Node{n}, doc.HydratedComponents...)
+					}
+					pathAttr := tycho.Attribute{
+						Key:  "client:component-path",
+						Val:  fmt.Sprintf(`$$metadata.resolvePath(%s)`, escape.JSONString(specifier)),
+						Type: tycho.ExpressionAttribute,
+					}
+					n.Attr = append(n.Attr, pathAttr)
+					break
+				}
+
 				if attr.Key == "client:only" {
 					doc.ClientOnlyComponents = append([]*tycho.Node{n}, doc.ClientOnlyComponents...)
 					break
@@ -155,6 +367,35 @@ func AddComponentProps(doc *tycho.Node, n *tycho.Node) {
 				break
 			}
 		}
+		AddPropsSerialization(doc, n, opts)
+	}
+}
+
+// AddPropsSerialization tells the runtime how to serialize the props of a
+// hydrated component. Islands which serialize their props by reference also
+// get an id that is stable for this component, so the props can be looked up.
+func AddPropsSerialization(doc *tycho.Node, n *tycho.Node, opts TransformOptions) {
+	if opts.PropsSerialization == "" || opts.PropsSerialization == "attribute" {
+		return
+	}
+	// Islands are prepended as they're found, so n must be first in one of the lists
+	hydrated := len(doc.HydratedComponents) > 0 && doc.HydratedComponents[0] == n
+	clientOnly := len(doc.ClientOnlyComponents) > 0 && doc.ClientOnlyComponents[0] == n
+	if !hydrated && !clientOnly {
+		return
+	}
+	n.Attr = append(n.Attr, tycho.Attribute{
+		Key:  "client:props-serialization",
+		Val:  opts.PropsSerialization,
+		Type: tycho.QuotedAttribute,
+	})
+	if opts.PropsSerialization == "reference" {
+		index := len(doc.HydratedComponents) + len(doc.ClientOnlyComponents) - 1
+		n.Attr = append(n.Attr, tycho.Attribute{
+			Key:  "client:props-id",
+			Val:  fmt.Sprintf("%s-%d", opts.Scope, index),
+			Type: tycho.QuotedAttribute,
+		})
 	}
 }
 
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/handler"
 )
 
 func TestTransformScoping(t *testing.T) {
@@ -100,7 +101,7 @@ func TestTransformScoping(t *testing.T) {
 				t.Error(err)
 			}
 			ExtractStyles(doc)
-			Transform(doc, TransformOptions{Scope: "XXXXXX"})
+			Transform(doc, TransformOptions{Scope: "XXXXXX"}, handler.NewHandler(tt.source, "TestTransformScoping.astro"))
 			astro.PrintToSource(&b, doc.LastChild.FirstChild.NextSibling.FirstChild)
 			got := b.String()
 			if tt.want != got {
@@ -182,7 +183,7 @@ func TestFullTransform(t *testing.T) {
 			ExtractStyles(doc)
 			// Clear doc.Styles to avoid scoping behavior, we're not testing that here
 			doc.Styles = make([]*astro.Node, 0)
-			Transform(doc, TransformOptions{})
+			Transform(doc, TransformOptions{}, handler.NewHandler(tt.source, "TestFullTransform.astro"))
 			astro.PrintToSource(&b, doc)
 			got := strings.TrimSpace(b.String())
 			if tt.want != got {
@@ -0,0 +1,94 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/escape"
+)
+
+// Transition is an element which takes part in view transitions
+type Transition struct {
+	Node *astro.Node
+	// Unique to this element, so the runtime can match it across pages
+	Scope   string
+	Name    *astro.Attribute
+	Animate *astro.Attribute
+	Persist *astro.Attribute
+}
+
+// IsTransitionDirective returns true for `transition:` attributes, which are
+// replaced by the attributes that AddTransitions generates
+func IsTransitionDirective(key string) bool {
+	return strings.HasPrefix(key, "transition:")
+}
+
+// Transitions returns every element with a `transition:` directive, in
+// document order
+func Transitions(doc *astro.Node, opts TransformOptions) []Transition {
+	transitions := make([]Transition, 0)
+	walk(doc, func(n *astro.Node) {
+		if n.Type != astro.ElementNode {
+			return
+		}
+		t := Transition{
+			Node:    n,
+			Scope:   fmt.Sprintf("%s-%d", opts.Scope, len(transitions)),
+			Name:    astro.GetAttribute(n, "transition:name"),
+			Animate: astro.GetAttribute(n, "transition:animate"),
+			Persist: astro.GetAttribute(n, "transition:persist"),
+		}
+		if t.Name != nil || t.Animate != nil || t.Persist != nil {
+			transitions = append(transitions, t)
+		}
+	})
+	return transitions
+}
+
+// AddTransitions adds the attributes which the view transition runtime reads to
+// every element with a `transition:` directive
+func AddTransitions(doc *astro.Node, opts TransformOptions) {
+	for _, t := range Transitions(doc, opts) {
+		n := t.Node
+		if t.Name != nil || t.Animate != nil {
+			n.Attr = append(n.Attr, astro.Attribute{
+				Key:  "data-astro-transition-scope",
+				Val:  fmt.Sprintf(`$$renderTransition($$result, "%s", %s, %s)`, t.Scope, transitionValue(t.Animate), transitionValue(t.Name)),
+				Type: astro.ExpressionAttribute,
+			})
+		}
+		if t.Persist != nil {
+			// An explicit id lets the element persist across pages where it has a different scope
+			if t.Persist.Type == astro.QuotedAttribute && t.Persist.Val != "" {
+				n.Attr = append(n.Attr, astro.Attribute{
+					Key:  "data-astro-transition-persist",
+					Val:  t.Persist.Val,
+					Type: astro.QuotedAttribute,
+				})
+				continue
+			}
+			n.Attr = append(n.Attr, astro.Attribute{
+				Key:  "data-astro-transition-persist",
+				Val:  fmt.Sprintf(`$$createTransitionScope($$result, "%s")`, t.Scope),
+				Type: astro.ExpressionAttribute,
+			})
+		}
+	}
+}
+
+// transitionValue prints the value of a directive as a JavaScript expression
+func transitionValue(attr *astro.Attribute) string {
+	if attr == nil {
+		return `""`
+	}
+	switch attr.Type {
+	case astro.QuotedAttribute:
+		return escape.JSONString(astro.UnescapeAttributeString(attr.Val))
+	case astro.ExpressionAttribute:
+		return "(" + attr.Val + ")"
+	case astro.TemplateLiteralAttribute:
+		return "`" + attr.Val + "`"
+	}
+	return `""`
+}
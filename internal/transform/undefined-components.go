@@ -0,0 +1,83 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/handler"
+	"github.com/snowpackjs/astro/internal/js_scanner"
+	"github.com/snowpackjs/astro/internal/loc"
+)
+
+// UndefinedComponent is a component used in the template which is never
+// imported or declared, so editors can offer to import it
+type UndefinedComponent struct {
+	Name string
+	Loc  loc.Loc
+}
+
+// UndefinedComponents returns every component tag whose name is never
+// mentioned in the frontmatter or in a template expression, in the order that
+// it appears in the template. This must run before the compiler adds any
+// attributes of its own, since those may reference the component.
+func UndefinedComponents(doc *astro.Node) []UndefinedComponent {
+	// Any mention counts, so bindings from destructuring or arrow function
+	// parameters don't need to be understood
+	known := map[string]bool{"Astro": true}
+	walk(doc, func(n *astro.Node) {
+		if n.Type != astro.TextNode || n.Parent == nil {
+			return
+		}
+		if n.Parent.Type == astro.FrontmatterNode || n.Parent.Expression {
+			for _, name := range js_scanner.Identifiers([]byte(n.Data)) {
+				known[name] = true
+			}
+		}
+	})
+
+	components := make([]UndefinedComponent, 0)
+	walk(doc, func(n *astro.Node) {
+		if n.Type != astro.ElementNode || !n.Component || len(n.Loc) == 0 || DynamicTag(n) != nil {
+			return
+		}
+		name := strings.Split(n.Data, ".")[0]
+		if !known[name] {
+			components = append(components, UndefinedComponent{Name: name, Loc: n.Loc[0]})
+		}
+	})
+	return components
+}
+
+func warnUndefinedComponents(doc *astro.Node, h *handler.Handler) {
+	for _, c := range UndefinedComponents(doc) {
+		h.AppendWarning(&loc.ErrorWithRange{
+			Code:  loc.WARNING_UNDEFINED_COMPONENT,
+			Text:  fmt.Sprintf("%s is not defined", c.Name),
+			Hint:  fmt.Sprintf("Did you forget to import %s in the frontmatter?", c.Name),
+			Range: loc.Range{Loc: c.Loc, Len: len(c.Name) + 1},
+			Fixes: []loc.Fix{importFix(doc, c.Name)},
+		})
+	}
+}
+
+// importFix imports name from a sibling .astro file of the same name, which
+// is where components usually live, at the top of the frontmatter. A file
+// without frontmatter gets one.
+func importFix(doc *astro.Node, name string) loc.Fix {
+	statement := fmt.Sprintf("import %s from './%s.astro';", name, name)
+	fix := loc.Fix{
+		Title: fmt.Sprintf("Import %s from './%s.astro'", name, name),
+		Text:  fmt.Sprintf("---\n%s\n---\n", statement),
+	}
+	for c := doc.FirstChild; c != nil; c = c.NextSibling {
+		// A file without frontmatter still gets an empty node for it, with
+		// no closing fence
+		if c.Type == astro.FrontmatterNode && (c.FirstChild != nil || len(c.Loc) > 1) {
+			// After the opening fence
+			fix.Range = loc.Range{Loc: loc.Loc{Start: c.Loc[0].Start + len("---")}}
+			fix.Text = "\n" + statement
+		}
+	}
+	return fix
+}
@@ -0,0 +1,113 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/handler"
+	"github.com/snowpackjs/astro/internal/js_scanner"
+	"github.com/snowpackjs/astro/internal/loc"
+)
+
+// UnusedImports warns about frontmatter imports which are never referenced in
+// either the frontmatter or the template
+func UnusedImports(doc *astro.Node, h *handler.Handler) {
+	var text *astro.Node
+	for c := doc.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == astro.FrontmatterNode && c.FirstChild != nil {
+			text = c.FirstChild
+			break
+		}
+	}
+	if text == nil {
+		return
+	}
+
+	source := []byte(text.Data)
+	statements := make([]js_scanner.ImportStatement, 0)
+	pos, statement := js_scanner.NextImportStatement(source, 0)
+	for pos != -1 {
+		statements = append(statements, statement)
+		pos, statement = js_scanner.NextImportStatement(source, pos)
+	}
+	if len(statements) == 0 {
+		return
+	}
+
+	// Blank out the imports, otherwise every binding would reference itself
+	rest := []byte(text.Data)
+	for _, statement := range statements {
+		for i := statement.Span.Start; i < statement.Span.End; i++ {
+			rest[i] = ' '
+		}
+	}
+	used := make(map[string]bool)
+	for _, name := range js_scanner.Identifiers(rest) {
+		used[name] = true
+	}
+	for _, name := range TemplateReferences(doc) {
+		used[name] = true
+	}
+
+	for _, statement := range statements {
+		unused := 0
+		for _, imported := range statement.Imports {
+			if !used[imported.LocalName] {
+				unused++
+			}
+		}
+		for _, imported := range statement.Imports {
+			if used[imported.LocalName] {
+				continue
+			}
+			span := strings.TrimSpace(text.Data[statement.Span.Start:statement.Span.End])
+			r := loc.Range{Len: len(span)}
+			if len(text.Loc) > 0 {
+				r.Loc = loc.Loc{Start: text.Loc[0].Start + statement.Span.Start}
+			}
+			warning := &loc.ErrorWithRange{
+				Code:  loc.WARNING_UNUSED_IMPORT,
+				Text:  fmt.Sprintf("%s is imported but never used", imported.LocalName),
+				Hint:  "Remove the import if it is no longer needed",
+				Range: r,
+			}
+			// The statement can only go once none of its bindings are used
+			if unused == len(statement.Imports) && len(text.Loc) > 0 {
+				fix := loc.Fix{Title: "Remove the unused import", Range: r}
+				if end := statement.Span.Start + len(span); end < len(text.Data) && text.Data[end] == '\n' {
+					fix.Range.Len++
+				}
+				warning.Fixes = []loc.Fix{fix}
+			}
+			h.AppendWarning(warning)
+		}
+	}
+}
+
+// TemplateReferences returns every identifier the template refers to, either
+// as a component or from inside of an expression
+func TemplateReferences(doc *astro.Node) []string {
+	references := make([]string, 0)
+	walk(doc, func(n *astro.Node) {
+		switch n.Type {
+		case astro.TextNode:
+			if n.Parent != nil && n.Parent.Expression {
+				references = append(references, js_scanner.Identifiers([]byte(n.Data))...)
+			}
+		case astro.ElementNode:
+			if n.Component {
+				references = append(references, strings.Split(n.Data, ".")[0])
+			}
+			for _, attr := range n.Attr {
+				switch attr.Type {
+				case astro.ExpressionAttribute, astro.TemplateLiteralAttribute:
+					references = append(references, js_scanner.Identifiers([]byte(attr.Val))...)
+				case astro.SpreadAttribute, astro.ShorthandAttribute:
+					references = append(references, js_scanner.Identifiers([]byte(attr.Key))...)
+				}
+			}
+		}
+	})
+	return references
+}
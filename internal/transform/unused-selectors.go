@@ -0,0 +1,137 @@
+package transform
+
+import (
+	"strings"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/tdewolff/parse/css"
+	a "golang.org/x/net/html/atom"
+)
+
+// SelectorUsage is every tag, class and id which the template of a component
+// can render, used to find scoped selectors which can never match
+type SelectorUsage struct {
+	tags, classes, ids      map[string]bool
+	anyTag, anyClass, anyID bool
+	scripts                 []string
+}
+
+// CollectSelectorUsage finds what the template of doc renders. Anything which
+// is only known at runtime, like a class expression, makes every selector of
+// that kind match. Components are given the scoped class, and may render any
+// element with it, so they do the same for everything.
+func CollectSelectorUsage(doc *astro.Node) *SelectorUsage {
+	u := &SelectorUsage{
+		tags:    make(map[string]bool),
+		classes: make(map[string]bool),
+		ids:     make(map[string]bool),
+	}
+	walk(doc, func(n *astro.Node) {
+		if n.Type != astro.ElementNode {
+			return
+		}
+		if n.DataAtom == a.Script && n.FirstChild != nil {
+			u.scripts = append(u.scripts, n.FirstChild.Data)
+		}
+		if n.Fragment || n.Expression {
+			return
+		}
+		if n.Component || DynamicTag(n) != nil {
+			u.anyTag, u.anyClass, u.anyID = true, true, true
+			return
+		}
+		u.tags[strings.ToLower(n.Data)] = true
+		for _, attr := range n.Attr {
+			switch {
+			case attr.Type == astro.SpreadAttribute:
+				u.anyClass, u.anyID = true, true
+			case attr.Key == "class" || attr.Key == "class:list":
+				if attr.Type == astro.QuotedAttribute {
+					for _, class := range strings.Fields(attr.Val) {
+						u.classes[class] = true
+					}
+				} else if attr.Type != astro.EmptyAttribute {
+					u.anyClass = true
+				}
+			case attr.Key == "id":
+				if attr.Type == astro.QuotedAttribute {
+					u.ids[attr.Val] = true
+				} else if attr.Type != astro.EmptyAttribute {
+					u.anyID = true
+				}
+			}
+		}
+	})
+	for _, s := range doc.Scripts {
+		if s.FirstChild != nil {
+			u.scripts = append(u.scripts, s.FirstChild.Data)
+		}
+	}
+	return u
+}
+
+// A script may add a class or id to an element at runtime, so one which is
+// mentioned by a script is always treated as used
+func (u *SelectorUsage) inScripts(name string) bool {
+	for _, s := range u.scripts {
+		if strings.Contains(s, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *SelectorUsage) hasTag(name string) bool {
+	name = strings.ToLower(name)
+	return u.anyTag || u.tags[name] || name == "html" || name == "head" || name == "body"
+}
+
+func (u *SelectorUsage) hasClass(name string) bool {
+	return u.anyClass || u.classes[name] || u.inScripts(name)
+}
+
+func (u *SelectorUsage) hasID(name string) bool {
+	return u.anyID || u.ids[name] || u.inScripts(name)
+}
+
+// CanMatch reports whether a single selector, without any commas, could
+// match an element of the template. Only what the selector requires outside
+// of any parentheses, like `:not()`, and outside of `:global()` is checked.
+func (u *SelectorUsage) CanMatch(selector []cssToken) bool {
+	depth := 0
+	isBracket := false
+	for i, t := range selector {
+		switch {
+		case t.tt == css.FunctionToken || t.tt == css.LeftParenthesisToken:
+			depth++
+		case t.tt == css.RightParenthesisToken:
+			depth--
+		case t.tt == css.LeftBracketToken:
+			isBracket = true
+		case t.tt == css.RightBracketToken:
+			isBracket = false
+		case depth > 0 || isBracket:
+		case t.tt == css.HashToken:
+			if !u.hasID(t.data[1:]) {
+				return false
+			}
+		case t.tt == css.IdentToken:
+			prev := ""
+			if i > 0 {
+				prev = selector[i-1].data
+			}
+			switch prev {
+			case ":", "&":
+			case ".":
+				if !u.hasClass(t.data) {
+					return false
+				}
+			default:
+				if !u.hasTag(t.data) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
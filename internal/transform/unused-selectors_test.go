@@ -0,0 +1,129 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/handler"
+)
+
+func TestUnusedSelectors(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		want     string
+		warnings []string
+	}{
+		{
+			name:     "used",
+			source:   `<style>h1{color:red}.title{color:red}#main{color:red}</style><main id="main"><h1 class="title">Hi</h1></main>`,
+			want:     `h1.astro-XXXXXX{color:red;}.title.astro-XXXXXX{color:red;}#main.astro-XXXXXX{color:red;}`,
+			warnings: []string{},
+		},
+		{
+			name:     "unused",
+			source:   `<style>h2{color:red}.title{color:red}#main{color:red}p{color:blue}</style><p>Hi</p>`,
+			want:     `p.astro-XXXXXX{color:blue;}`,
+			warnings: []string{`Unused CSS selector "h2"`, `Unused CSS selector ".title"`, `Unused CSS selector "#main"`},
+		},
+		{
+			name:     "selector list",
+			source:   `<style>h1, h2 , .title{margin:0}</style><h1>Hi</h1>`,
+			want:     `h1.astro-XXXXXX{margin:0;}`,
+			warnings: []string{`Unused CSS selector "h2"`, `Unused CSS selector ".title"`},
+		},
+		{
+			name:     "descendant",
+			source:   `<style>ul li{margin:0}.nav a{color:red}</style><ul><li>a</li></ul>`,
+			want:     `ul.astro-XXXXXX li.astro-XXXXXX{margin:0;}`,
+			warnings: []string{`Unused CSS selector ".nav a"`},
+		},
+		{
+			name:     "parentheses and global",
+			source:   `<style>p:not(.a){margin:0}:global(.b) p{margin:0}p::before{content:""}</style><p>a</p>`,
+			want:     `p.astro-XXXXXX:not(.a){margin:0;}.b p.astro-XXXXXX{margin:0;}p.astro-XXXXXX::before{content:"";}`,
+			warnings: []string{},
+		},
+		{
+			name:     "nested",
+			source:   `<style>.card{margin:0;.title{margin:0}&:hover{color:red}}</style><div class="card">a</div>`,
+			want:     `.card.astro-XXXXXX{margin:0;&:hover{color:red;}}`,
+			warnings: []string{`Unused CSS selector ".title"`},
+		},
+		{
+			name:     "inside of media",
+			source:   `<style>@media print{.a{color:red}}</style><div>a</div>`,
+			want:     `@media print{}`,
+			warnings: []string{`Unused CSS selector ".a"`},
+		},
+		{
+			name:     "class:list",
+			source:   `<style>.a{color:red}.b{color:red}</style><div class:list="a">a</div>`,
+			want:     `.a.astro-XXXXXX{color:red;}`,
+			warnings: []string{`Unused CSS selector ".b"`},
+		},
+		{
+			name:     "dynamic class",
+			source:   `<style>.a{color:red}h2{color:red}</style><div class={cls}>a</div>`,
+			want:     `.a.astro-XXXXXX{color:red;}`,
+			warnings: []string{`Unused CSS selector "h2"`},
+		},
+		{
+			name:     "spread",
+			source:   `<style>.a{color:red}#b{color:red}</style><div {...attrs}>a</div>`,
+			want:     `.a.astro-XXXXXX{color:red;}#b.astro-XXXXXX{color:red;}`,
+			warnings: []string{},
+		},
+		{
+			name:     "component",
+			source:   "---\nimport Card from './Card.astro';\n---\n<style>.a{color:red}h2{color:red}</style><Card />",
+			want:     `.a.astro-XXXXXX{color:red;}h2.astro-XXXXXX{color:red;}`,
+			warnings: []string{},
+		},
+		{
+			name:     "script",
+			source:   `<style>.open{color:red}.closed{color:red}</style><nav>a</nav><script>document.querySelector('nav').classList.add('open')</script>`,
+			want:     `.open.astro-XXXXXX{color:red;}`,
+			warnings: []string{`Unused CSS selector ".closed"`},
+		},
+		{
+			name:     "other language",
+			source:   `<style lang="scss">.a{&__b{color:red}}</style><div>a</div>`,
+			want:     `.a.astro-XXXXXX{&__b.astro-XXXXXX{color:red;}}`,
+			warnings: []string{},
+		},
+	}
+	for _, mode := range []string{"warn", "remove"} {
+		for _, tt := range tests {
+			t.Run(mode+" "+tt.name, func(t *testing.T) {
+				doc, err := astro.Parse(strings.NewReader(tt.source))
+				if err != nil {
+					t.Error(err)
+				}
+				h := handler.NewHandler(tt.source, "<stdin>")
+				ExtractStyles(doc)
+				Transform(doc, TransformOptions{Scope: "XXXXXX", UnusedSelectors: mode}, h)
+				got := make([]string, 0)
+				for _, w := range h.Warnings() {
+					if strings.HasPrefix(w.Text, "Unused CSS selector") {
+						got = append(got, w.Text)
+					}
+				}
+				want := tt.warnings
+				if mode == "remove" {
+					want = []string{}
+				}
+				if fmt.Sprint(got) != fmt.Sprint(want) {
+					t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %v\n  got:  %v", tt.name, want, got))
+				}
+				if mode == "remove" {
+					if css := doc.Styles[0].FirstChild.Data; css != tt.want {
+						t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %s\n  got:  %s", tt.name, tt.want, css))
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/handler"
+	"github.com/snowpackjs/astro/internal/js_scanner"
+	"github.com/snowpackjs/astro/internal/loc"
+	a "golang.org/x/net/html/atom"
+)
+
+// Warnings which become errors in strict mode
+var strictWarnings = []loc.DiagnosticCode{
+	loc.WARNING_UNCLOSED_ELEMENT,
+	loc.WARNING_DUPLICATE_ATTRIBUTE,
+	loc.WARNING_UNKNOWN_DIRECTIVE,
+	loc.WARNING_SCRIPT_INTERPOLATION,
+	loc.WARNING_UNDEFINED_COMPONENT,
+	loc.WARNING_DUPLICATE_PROP,
+}
+
+// DuplicateAttributes warns about attributes which are set more than once on
+// the same element. Only the first value would be used by the browser.
+func DuplicateAttributes(n *astro.Node, h *handler.Handler) {
+	if n.Type != astro.ElementNode || n.Component {
+		return
+	}
+	seen := make(map[string]bool)
+	for _, attr := range n.Attr {
+		if attr.Type == astro.SpreadAttribute || IsImplictNodeMarker(attr) {
+			continue
+		}
+		// HTML attribute names are case-insensitive
+		key := strings.ToLower(strings.TrimSpace(attr.Key))
+		if seen[key] {
+			h.AppendWarning(&loc.ErrorWithRange{
+				Code:  loc.WARNING_DUPLICATE_ATTRIBUTE,
+				Text:  fmt.Sprintf("Duplicate attribute %s", attr.Key),
+				Range: loc.Range{Loc: attr.KeyLoc, Len: len(attr.Key)},
+			})
+		}
+		seen[key] = true
+	}
+}
+
+// DuplicateProps warns about props which are passed to a component more than
+// once, either directly or by spreading an object literal. They end up as
+// duplicate keys of the props object, where the last one silently wins.
+func DuplicateProps(n *astro.Node, h *handler.Handler) {
+	if n.Type != astro.ElementNode || !n.Component {
+		return
+	}
+	seen := make(map[string]bool)
+	warn := func(key string, attr astro.Attribute) {
+		h.AppendWarning(&loc.ErrorWithRange{
+			Code:  loc.WARNING_DUPLICATE_PROP,
+			Text:  fmt.Sprintf("Duplicate prop %s", key),
+			Hint:  fmt.Sprintf("Only the last value is passed to <%s>", n.Data),
+			Range: loc.Range{Loc: attr.KeyLoc, Len: len(attr.Key)},
+		})
+	}
+	for _, attr := range n.Attr {
+		if IsImplictNodeMarker(attr) {
+			continue
+		}
+		if attr.Type == astro.SpreadAttribute {
+			// Only the keys of an object literal are known ahead of time
+			for _, key := range js_scanner.ObjectLiteralKeys([]byte(attr.Key)) {
+				if seen[key] {
+					warn(key, attr)
+				}
+				seen[key] = true
+			}
+			continue
+		}
+		key := strings.TrimSpace(attr.Key)
+		if seen[key] {
+			warn(key, attr)
+		}
+		seen[key] = true
+	}
+}
+
+// FrontmatterDeclarations returns the names declared at the top level of the frontmatter
+func FrontmatterDeclarations(doc *astro.Node) map[string]bool {
+	declared := make(map[string]bool)
+	for c := doc.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != astro.FrontmatterNode || c.FirstChild == nil {
+			continue
+		}
+		for _, name := range js_scanner.Declarations([]byte(c.FirstChild.Data)) {
+			declared[name] = true
+		}
+	}
+	return declared
+}
+
+// ScriptInterpolation warns about `${}` substitutions in a <script> that refer
+// to frontmatter variables. Scripts run in the browser, so those variables
+// aren't available unless they are passed with define:vars.
+func ScriptInterpolation(n *astro.Node, declared map[string]bool, h *handler.Handler) {
+	if n.Type != astro.ElementNode || n.DataAtom != a.Script || n.FirstChild == nil || len(declared) == 0 {
+		return
+	}
+	if HasAttr(n, "define:vars") {
+		return
+	}
+	if typ := astro.GetAttribute(n, "type"); typ != nil && typ.Val != "module" && typ.Val != "text/javascript" {
+		return
+	}
+	text := n.FirstChild
+	for _, interpolation := range js_scanner.Interpolations([]byte(text.Data)) {
+		if !declared[interpolation.Name] {
+			continue
+		}
+		r := loc.Range{Len: len(interpolation.Name)}
+		if len(text.Loc) > 0 {
+			r.Loc = loc.Loc{Start: text.Loc[0].Start + interpolation.Start}
+		}
+		h.AppendWarning(&loc.ErrorWithRange{
+			Code:  loc.WARNING_SCRIPT_INTERPOLATION,
+			Text:  fmt.Sprintf("%s is declared in the frontmatter, but <script> runs in the browser", interpolation.Name),
+			Hint:  fmt.Sprintf("Pass it to the script with define:vars={{ %s }}", interpolation.Name),
+			Range: r,
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	astro "github.com/snowpackjs/astro/internal"
+	"github.com/snowpackjs/astro/internal/handler"
+	"github.com/snowpackjs/astro/internal/loc"
+)
+
+func TestWarnings(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		opts   TransformOptions
+		want   []string
+	}{
+		{
+			name:   "duplicate attributes",
+			source: `<div class="a" id="b" CLASS="c"></div><Component a={1} a={2} A={3} />`,
+			want:   []string{"<Component Component is not defined", "CLASS Duplicate attribute CLASS", "a Duplicate prop a"},
+		},
+		{
+			name: "duplicate props",
+			source: `---
+import Card from './Card.astro';
+---
+<Card title="a" {...{ title: "b", "id": 1, [key]: 2, ...rest }} id={2} {...props} />`,
+			want: []string{`{ title: "b", "id": 1, [key]: 2, ...rest } Duplicate prop title`, "id Duplicate prop id"},
+		},
+		{
+			name:   "spread is not a duplicate",
+			source: `<div class="a" {...props} {...props}></div>`,
+			want:   []string{},
+		},
+		{
+			name: "script interpolation",
+			source: `---
+import { site } from '../config';
+const title = 'Hello';
+---
+<script>console.log(` + "`${title} on ${site.name}, ${document.title}`" + `);</script>`,
+			want: []string{
+				"import { site } from '../config'; site is imported but never used",
+				"title title is declared in the frontmatter, but <script> runs in the browser",
+				"site site is declared in the frontmatter, but <script> runs in the browser",
+			},
+		},
+		{
+			name: "script interpolation with define:vars",
+			source: `---
+const title = 'Hello';
+---
+<script define:vars={{ title }}>console.log(` + "`${title}`" + `);</script>
+<script type="text/template">` + "`${title}`" + `</script>`,
+			want: []string{},
+		},
+		{
+			name: "unused imports",
+			source: `---
+import Card from '../components/Card.astro';
+import Layout from '../layouts/Layout.astro';
+import { format, parse } from 'date-fns';
+import * as utils from '../utils';
+import '../styles/global.css';
+const date = format(new Date());
+---
+<Layout {...utils}>{date}</Layout>`,
+			want: []string{
+				"import Card from '../components/Card.astro'; Card is imported but never used",
+				"import { format, parse } from 'date-fns'; parse is imported but never used",
+			},
+		},
+		{
+			name: "imports used in the template",
+			source: `---
+import UI from '../components/ui';
+import Card from '../components/Card.astro';
+import { items, title } from '../data';
+---
+<UI.Button title={title} />
+<ul>{items.map(item => <li><Card {item} /></li>)}</ul>`,
+			want: []string{},
+		},
+		{
+			name: "undefined components",
+			source: `---
+import Card from '../components/Card.astro';
+const { Heading } = Astro.props;
+---
+<Heading /><Card><Icon.Star /></Card>{[1, 2].map(Item => <Item />)}<Astro.self /><Fragment />`,
+			want: []string{"<Icon Icon is not defined"},
+		},
+		{
+			name: "route params",
+			source: `---
+const { slug, page = 1 } = Astro.params;
+---
+<h1>{slug} {Astro.params['lang']} {Astro.params.id}</h1>`,
+			opts: TransformOptions{IsPage: true, Route: "/[lang]/[slug]"},
+			want: []string{
+				"page Astro.params.page is never set by the route /[lang]/[slug]",
+				"id Astro.params.id is never set by the route /[lang]/[slug]",
+			},
+		},
+		{
+			name: "prerendered dynamic route",
+			source: `---
+export const prerender = true;
+const { slug } = Astro.params;
+---
+<h1>{slug}</h1>`,
+			opts: TransformOptions{IsPage: true, Route: "/blog/[...slug]"},
+			want: []string{"prerender The dynamic route /blog/[...slug] is prerendered but doesn't export getStaticPaths()"},
+		},
+		{
+			name:   "route params of a component",
+			source: `<h1>{Astro.params.slug}</h1>`,
+			opts:   TransformOptions{Route: "/"},
+			want:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := handler.NewHandler(tt.source, "TestWarnings.astro")
+			doc, err := astro.ParseWithOptions(strings.NewReader(tt.source), astro.ParseOptionWithHandler(h))
+			if err != nil {
+				t.Error(err)
+			}
+			ExtractStyles(doc)
+			Transform(doc, tt.opts, h)
+			got := make([]string, 0)
+			for _, w := range h.Warnings() {
+				line := strings.Split(tt.source, "\n")[w.Location.Line-1]
+				start := w.Location.Column - 1
+				got = append(got, fmt.Sprintf("%s %s", line[start:start+w.Location.Length], w.Text))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %q\n  got:  %q", tt.name, tt.want, got))
+			}
+		})
+	}
+}
+
+func TestStrict(t *testing.T) {
+	source := `<div client:lod><span>Hello</div><img alt="">`
+	for _, strict := range []bool{false, true} {
+		h := handler.NewHandler(source, "TestStrict.astro")
+		doc, err := astro.ParseWithOptions(strings.NewReader(source), astro.ParseOptionWithHandler(h))
+		if err != nil {
+			t.Error(err)
+		}
+		Transform(doc, TransformOptions{Strict: strict, ImageHints: true}, h)
+		if strict {
+			if len(h.Errors()) != 2 || len(h.Warnings()) != 1 {
+				t.Errorf("strict: expected 2 errors and 1 warning, got %d and %d", len(h.Errors()), len(h.Warnings()))
+			}
+			if err := h.Error(); err == nil || err.Error() != "TestStrict.astro:1:17: <span> was not closed (and 1 more errors)" {
+				t.Errorf("strict: unexpected error %v", err)
+			}
+		} else {
+			if len(h.Errors()) != 0 || len(h.Warnings()) != 3 || h.Error() != nil {
+				t.Errorf("expected 0 errors and 3 warnings, got %d and %d", len(h.Errors()), len(h.Warnings()))
+			}
+		}
+	}
+}
+
+func TestQuickFixes(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "unclosed element",
+			source: `<div><span>Hello</div>`,
+			want:   []string{"Add a closing </span> tag: <div><span>Hello</span></div>"},
+		},
+		{
+			name:   "unclosed at the end of the file",
+			source: "<main>\n<p>Hello</p>\n",
+			want:   []string{"Add a closing </main> tag: <main>\n<p>Hello</p>\n</main>"},
+		},
+		{
+			name:   "typo'd directive",
+			source: `<div client:lod></div>`,
+			want:   []string{"Change to client:load: <div client:load></div>"},
+		},
+		{
+			name: "missing import",
+			source: `---
+const title = 'Hello';
+---
+<Card {title} />`,
+			want: []string{"Import Card from './Card.astro': ---\nimport Card from './Card.astro';\nconst title = 'Hello';\n---\n<Card {title} />"},
+		},
+		{
+			name:   "missing import without frontmatter",
+			source: `<Card />`,
+			want:   []string{"Import Card from './Card.astro': ---\nimport Card from './Card.astro';\n---\n<Card />"},
+		},
+		{
+			name:   "missing import with empty frontmatter",
+			source: "---\n---\n<Card />",
+			want:   []string{"Import Card from './Card.astro': ---\nimport Card from './Card.astro';\n---\n<Card />"},
+		},
+		{
+			name: "unused import",
+			source: `---
+import Card from './Card.astro';
+import { a, b } from './utils';
+---
+<p>{a}</p>`,
+			want: []string{"Remove the unused import: ---\nimport { a, b } from './utils';\n---\n<p>{a}</p>", "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := handler.NewHandler(tt.source, "TestQuickFixes.astro")
+			doc, err := astro.ParseWithOptions(strings.NewReader(tt.source), astro.ParseOptionWithHandler(h))
+			if err != nil {
+				t.Error(err)
+			}
+			Transform(doc, TransformOptions{}, h)
+			got := make([]string, 0)
+			for _, w := range h.Warnings() {
+				if len(w.Fixes) == 0 {
+					got = append(got, strings.Fields(w.Text)[0])
+				}
+				for _, fix := range w.Fixes {
+					got = append(got, fmt.Sprintf("%s: %s", fix.Title, applyFix(tt.source, fix)))
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %q\n  got:  %q", tt.name, tt.want, got))
+			}
+		})
+	}
+}
+
+// applyFix applies fix to an ASCII source
+func applyFix(source string, fix loc.DiagnosticFix) string {
+	lines := strings.SplitAfter(source, "\n")
+	start := fix.Location.Column - 1
+	for _, line := range lines[:fix.Location.Line-1] {
+		start += len(line)
+	}
+	return source[:start] + fix.Text + source[start+fix.Location.Length:]
+}
@@ -0,0 +1,6 @@
+package astro
+
+// Version is the version of the compiler, kept in sync with the version of
+// the @astrojs/compiler package. Output may change between versions, so it can
+// be used to invalidate caches of compiled components.
+const Version = "0.3.9"
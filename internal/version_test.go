@@ -0,0 +1,23 @@
+package astro
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	data, err := os.ReadFile("../lib/compiler/package.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pkg struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		t.Fatal(err)
+	}
+	if pkg.Version != Version {
+		t.Errorf("Version is %s, but @astrojs/compiler is %s", Version, pkg.Version)
+	}
+}
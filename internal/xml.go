@@ -0,0 +1,83 @@
+package astro
+
+import (
+	"strings"
+)
+
+// xmlIM builds the tree for XML documents, like RSS feeds or sitemaps. None
+// of the HTML insertion modes apply, so there are no implied <html>, <head>
+// or <body> elements, no void elements and every element is closed by the
+// nearest matching end tag.
+func xmlIM(p *parser) bool {
+	switch p.tok.Type {
+	case FrontmatterFenceToken:
+		p.setOriginalIM()
+		p.im = frontmatterIM
+		return false
+	case TextToken:
+		if p.oe.top() == nil {
+			p.tok.Data = strings.TrimLeft(p.tok.Data, whitespace)
+			if len(p.tok.Data) == 0 {
+				return true
+			}
+		}
+		p.addFrontmatter(true)
+		p.addText(p.tok.Data)
+	case CommentToken:
+		p.addFrontmatter(true)
+		p.addChild(p.newNode(Node{
+			Type: p.tokenizer.commentType,
+			Data: p.tok.Data,
+			Loc:  p.generateLoc(),
+		}))
+	case DoctypeToken:
+		p.addFrontmatter(true)
+		n, _ := parseDoctype(p.tok.Data)
+		p.doc.AppendChild(n)
+	case StartTagToken:
+		p.addFrontmatter(true)
+		p.addElement()
+		if p.hasSelfClosingToken {
+			p.oe.pop()
+			p.acknowledgeSelfClosingTag()
+		}
+	case EndTagToken:
+		for i := len(p.oe) - 1; i >= 0; i-- {
+			n := p.oe[i]
+			if n.Expression {
+				break
+			}
+			if n.Data == p.tok.Data {
+				for _, e := range p.oe[i+1:] {
+					p.warnUnclosed(e)
+				}
+				p.oe = p.oe[:i+1]
+				p.addLoc()
+				p.oe.pop()
+				break
+			}
+		}
+	case StartExpressionToken:
+		p.addFrontmatter(true)
+		p.addExpression()
+	case EndExpressionToken:
+		for i := len(p.oe) - 1; i >= 0; i-- {
+			n := p.oe[i]
+			if n.Expression {
+				for _, e := range p.oe[i+1:] {
+					p.warnUnclosed(e)
+				}
+				p.oe = p.oe[:i+1]
+				p.addLoc()
+				p.oe.pop()
+				break
+			}
+		}
+	case ErrorToken:
+		for _, n := range p.oe {
+			p.warnUnclosed(n)
+		}
+		p.oe = p.oe[:0]
+	}
+	return true
+}
@@ -43,7 +43,7 @@ func GetAttrs(n *astro.Node) js.Value {
 	for _, attr := range n.Attr {
 		switch attr.Type {
 		case astro.QuotedAttribute:
-			attrs.Set(attr.Key, attr.Val)
+			attrs.Set(attr.Key, astro.UnescapeAttributeString(attr.Val))
 		case astro.EmptyAttribute:
 			attrs.Set(attr.Key, true)
 		}
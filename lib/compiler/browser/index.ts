@@ -5,18 +5,47 @@ export const transform: typeof types.transform = (input, options) => {
   return ensureServiceIsRunning().transform(input, options);
 };
 
+export const outline: typeof types.outline = (input, options) => {
+  return ensureServiceIsRunning().outline(input, options);
+};
+
+export const semanticTokens: typeof types.semanticTokens = (input) => {
+  return ensureServiceIsRunning().semanticTokens(input);
+};
+
+export const completion: typeof types.completion = (input, offset) => {
+  return ensureServiceIsRunning().completion(input, offset);
+};
+
+export const dumpAST: typeof types.dumpAST = (input, options) => {
+  return ensureServiceIsRunning().dumpAST(input, options);
+};
+
+// Results with the `json` encoding arrive as a single buffer
+const decoder = new TextDecoder();
+const decodeResult = (result: types.TransformResult | Uint8Array): types.TransformResult => {
+  if (result instanceof Uint8Array) return JSON.parse(decoder.decode(result));
+  return result;
+};
+
 interface Service {
   transform: typeof types.transform;
+  outline: typeof types.outline;
+  semanticTokens: typeof types.semanticTokens;
+  completion: typeof types.completion;
+  dumpAST: typeof types.dumpAST;
 }
 
 let initializePromise: Promise<void> | undefined;
 let longLivedService: Service | undefined;
+let initializedWasmURL: string | undefined;
 
 export const initialize: typeof types.initialize = (options) => {
   let wasmURL = options.wasmURL;
   if (!wasmURL) throw new Error('Must provide the "wasmURL" option');
   wasmURL += '';
   if (initializePromise) throw new Error('Cannot call "initialize" more than once');
+  initializedWasmURL = wasmURL;
   initializePromise = startRunningService(wasmURL);
   initializePromise.catch(() => {
     // Let the caller try again if this fails
@@ -25,6 +54,12 @@ export const initialize: typeof types.initialize = (options) => {
   return initializePromise;
 };
 
+export const reset: typeof types.reset = () => {
+  if (!initializedWasmURL) throw new Error('You need to call "initialize" before calling this');
+  // The new service replaces the old one once it is running
+  return startRunningService(initializedWasmURL);
+};
+
 let ensureServiceIsRunning = (): Service => {
   if (longLivedService) return longLivedService;
   if (initializePromise) throw new Error('You need to wait for the promise returned from "initialize" to be resolved before calling this');
@@ -52,7 +87,7 @@ const startRunningService = async (wasmURL: string) => {
   const wasm = await instantiateWASM(wasmURL, go.importObject);
   go.run(wasm.instance);
 
-  const apiKeys = new Set(['transform']);
+  const apiKeys = new Set(['transform', 'outline', 'semanticTokens', 'completion', 'dumpAST']);
   const service: any = Object.create(null);
 
   for (const key of apiKeys.values()) {
@@ -62,6 +97,10 @@ const startRunningService = async (wasmURL: string) => {
   }
 
   longLivedService = {
-    transform: (input, options) => new Promise((resolve) => resolve(service.transform(input, options || {}))),
+    transform: (input, options) => new Promise<types.TransformResult | Uint8Array>((resolve) => resolve(service.transform(input, options || {}))).then(decodeResult),
+    outline: (input, options) => new Promise((resolve) => resolve(service.outline(input, options || {}))),
+    semanticTokens: (input) => new Promise((resolve) => resolve(service.semanticTokens(input))),
+    completion: (input, offset) => new Promise((resolve) => resolve(service.completion(input, offset))),
+    dumpAST: (input, options) => new Promise((resolve) => resolve(service.dumpAST(input, options || {}))),
   };
 };
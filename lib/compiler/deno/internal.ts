@@ -196,6 +196,10 @@ export const addAttribute = (value: any, key: string) => {
   if (value == null || value === false) {
     return '';
   }
+  // A style merged with the custom properties of define:vars
+  if (key === 'style' && Array.isArray(value)) {
+    return ` ${key}="${value.filter((v) => v != null && v !== false && v !== '').join('; ')}"`;
+  }
   return ` ${key}="${value}"`;
 };
 
@@ -207,12 +211,35 @@ export const spreadAttributes = (values: Record<any, any>) => {
   return output;
 };
 
-export const defineStyleVars = (astroId: string, vars: Record<any, any>) => {
-  let output = '\n';
-  for (const [key, value] of Object.entries(vars)) {
-    output += `  --${key}: ${value};\n`;
+// Merges a scope class or define:vars style into the attributes of a spread
+export const mergeAttr = (values: Record<any, any>, key: string, value: any) => {
+  const current = values?.[key];
+  if (current == null || current === false || current === '') {
+    return { ...values, [key]: value };
   }
-  return `.${astroId} {${output}}`;
+  return { ...values, [key]: key === 'style' ? [current, value] : `${current} ${value}` };
+};
+
+// Islands are counted per render, so an island in a loop or a component used
+// twice gets a new ID every time. A template evaluates its markers in order,
+// so an end marker always closes the innermost open island.
+export const openIsland = (result: any, scope: string) => {
+  result._islandCount = (result._islandCount ?? 0) + 1;
+  const id = `${scope}-${result._islandCount - 1}`;
+  (result._openIslands ??= []).push(id);
+  return id;
+};
+
+export const closeIsland = (result: any) => result._openIslands?.pop() ?? '';
+
+export const defineStyleVars = (defs: Record<any, any> | Record<any, any>[]) => {
+  let output = '';
+  for (const vars of Array.isArray(defs) ? defs : [defs]) {
+    for (const [key, value] of Object.entries(vars)) {
+      output += `--${key}: ${value};`;
+    }
+  }
+  return output;
 };
 
 export const defineScriptVars = (vars: Record<any, any>) => {
@@ -7,13 +7,45 @@ export const transform: typeof types.transform = async (input, options) => {
   return ensureServiceIsRunning().then((service) => service.transform(input, options));
 };
 
+export const outline: typeof types.outline = async (input, options) => {
+  return ensureServiceIsRunning().then((service) => service.outline(input, options));
+};
+
+export const semanticTokens: typeof types.semanticTokens = async (input) => {
+  return ensureServiceIsRunning().then((service) => service.semanticTokens(input));
+};
+
+export const completion: typeof types.completion = async (input, offset) => {
+  return ensureServiceIsRunning().then((service) => service.completion(input, offset));
+};
+
+export const dumpAST: typeof types.dumpAST = async (input, options) => {
+  return ensureServiceIsRunning().then((service) => service.dumpAST(input, options));
+};
+
+export const reset: typeof types.reset = async () => {
+  // The new service replaces the old one once it is running
+  await startRunningService();
+};
+
 export const compile = async (template: string): Promise<string> => {
   const { default: mod } = await import(`data:text/javascript;charset=utf-8;base64,${Buffer.from(template).toString('base64')}`);
   return mod;
 };
 
+// Results with the `json` encoding arrive as a single buffer
+const decoder = new TextDecoder();
+const decodeResult = (result: types.TransformResult | Uint8Array): types.TransformResult => {
+  if (result instanceof Uint8Array) return JSON.parse(decoder.decode(result));
+  return result;
+};
+
 interface Service {
   transform: typeof types.transform;
+  outline: typeof types.outline;
+  semanticTokens: typeof types.semanticTokens;
+  completion: typeof types.completion;
+  dumpAST: typeof types.dumpAST;
 }
 
 let longLivedService: Service | undefined;
@@ -40,7 +72,7 @@ const startRunningService = async () => {
   const wasm = await instantiateWASM(fileURLToPath(new URL('../astro.wasm', import.meta.url)), go.importObject);
   go.run(wasm.instance);
 
-  const apiKeys = new Set(['transform']);
+  const apiKeys = new Set(['transform', 'outline', 'semanticTokens', 'completion', 'dumpAST']);
   const service: any = Object.create(null);
 
   for (const key of apiKeys.values()) {
@@ -50,7 +82,11 @@ const startRunningService = async () => {
   }
 
   longLivedService = {
-    transform: (input, options) => new Promise((resolve) => resolve(service.transform(input, options || {}))),
+    transform: (input, options) => new Promise<types.TransformResult | Uint8Array>((resolve) => resolve(service.transform(input, options || {}))).then(decodeResult),
+    outline: (input, options) => new Promise((resolve) => resolve(service.outline(input, options || {}))),
+    semanticTokens: (input) => new Promise((resolve) => resolve(service.semanticTokens(input))),
+    completion: (input, offset) => new Promise((resolve) => resolve(service.completion(input, offset))),
+    dumpAST: (input, options) => new Promise((resolve) => resolve(service.dumpAST(input, options || {}))),
   };
   return longLivedService;
 };
@@ -10,11 +10,313 @@ export interface TransformOptions {
   sourcemap?: boolean | 'inline' | 'external' | 'both';
   as?: 'document' | 'fragment';
   preprocessStyle?: (content: string, attrs: Record<string, string>) => Promise<PreprocessorResult>;
+  dedentRaw?: boolean;
+  entities?: 'preserve' | 'normalize';
+  customElements?: Record<string, string>;
+  /** Called synchronously with every local asset URL, the returned URL is printed in its place */
+  rewriteAsset?: (url: string) => string;
+  /** Warn about images without alt text or dimensions */
+  imageHints?: boolean;
+  /** Warn about common accessibility mistakes, like missing alt text or unknown ARIA attributes */
+  a11y?: boolean;
+  /** Report likely mistakes, like unclosed elements or unknown directives, as errors and reject */
+  strict?: boolean;
+  /** How the runtime should serialize the props of hydrated components */
+  propsSerialization?: 'attribute' | 'script' | 'reference';
+  /** Collect every piece of translatable text in the template as `messages` */
+  extractMessages?: boolean;
+  /** The name of a function in scope in the frontmatter which translated text is passed through */
+  translate?: string;
+  /** Replace global expressions, like `import.meta.env.MODE`, with JavaScript at compile time */
+  define?: Record<string, string>;
+  /** Keep `{/* comments */}` in the template as HTML comments instead of removing them */
+  preserveJSXComments?: boolean;
+  /** The kind of document being generated. `xml` skips HTML-specific parsing rules, like implied `<html>`, `<head>` and `<body>` elements and void elements, and keeps processing instructions and CDATA sections as authored. */
+  contentType?: 'html' | 'xml';
+  /** Keep the attribute names of HTML elements as authored instead of lowercasing them. Components, custom elements, SVG and MathML always keep their case. */
+  preserveAttributeCase?: boolean;
+  /** Surround every component render with `markRenderStart` and `markRenderEnd` markers, so SSR profiling tools can show how long each component took to render. The markers are only called while `renderTelemetry` is set on the result of a render. */
+  renderTelemetry?: boolean;
+  /** Surround the render of every hydrated component with `<!--astro:island-start:ID-->` and `<!--astro:island-end:ID-->` comments, so streaming servers and edge middleware can find and manipulate islands in the rendered HTML. The ID is made by the `openIsland` runtime helper as the island renders, from the scope of the component and a count of the islands of the render, like `Q45PXZTM-0`, so an island in a loop or a component used twice gets a new ID every time. */
+  islandMarkers?: boolean;
+  /** Add the compiler version to the component metadata as `compilerVersion`, so caches of compiled components can be invalidated when the compiler changes */
+  stampVersion?: boolean;
+  /** Whether the component is a page. Adds a `route` entry to the component metadata and to the result, with the params the page reads from `Astro.params`, whether it exports `getStaticPaths` and the value of its `prerender` export, so the build can validate dynamic routes without running them. */
+  isPage?: boolean;
+  /** The route pattern of a page, like `/blog/[slug]` or `/docs/[...path]`. Reading a param from `Astro.params` which the route never sets is a warning, as is prerendering a dynamic route without `getStaticPaths`. */
+  route?: string;
+  /** Read a block of YAML fenced by `---`, or TOML fenced by `+++`, at the very start of the source as data instead of as the component script, and return it as `dataFrontmatter`, so content pipelines can validate it against a schema. A component script can follow the data. */
+  dataFrontmatter?: boolean;
+  /** Always export `$$metadata` and create the `Astro` global. They're left out of components which have no frontmatter, render no other components and never mention `Astro`, which makes their modules smaller; set this for tools which expect every compiled module to have them. */
+  fullOutput?: boolean;
+  /** Leave the `//@ts-ignore` comments and blank lines out of the generated module, for smaller output which is quicker to read. Blank lines in strings and template literals are kept, as are lines the source map needs. */
+  compactOutput?: boolean;
+  /** The module format of the output. `esm` (the default) keeps ES module syntax, `cjs` rewrites imports to `require()` calls and exports to assignments to `exports`, with the component as `exports.default`, for legacy Node toolchains. Exports are assigned at the end of the module, and source maps stay accurate to the line. `iife` is experimental: it wraps the CommonJS module in a function which takes the runtime from `runtimeGlobal` and assigns the exports to `globalName`, so a component can be embedded with a script tag without a bundler. Other imports go to a global `require()`, if the page has one. */
+  moduleFormat?: 'esm' | 'cjs' | 'iife';
+  /** How the object passed to `createMetadata` for the `$$metadata` export is formatted. `single-line` (the default) keeps it on one line, `multi-line` puts every property and every item of its arrays on a line of its own, so adding an island or a hoisted script changes one line of the output, which keeps diffs small in projects that snapshot test their compiled components. The `import * as $$moduleN` statements of the modules are on a line each in both formats. */
+  metadataFormat?: 'single-line' | 'multi-line';
+  /** The global an `iife` module assigns its exports to, `AstroComponent` by default */
+  globalName?: string;
+  /** The global an `iife` module takes the Astro runtime from, `astroInternal` by default */
+  runtimeGlobal?: string;
+  /** Added to the top of the compiled module as it is, after it's converted to `moduleFormat`, for things like license headers or polyfill imports. Source maps account for its lines. */
+  banner?: string;
+  /** Added to the bottom of the compiled module as it is, like HMR accept code */
+  footer?: string;
+  /** Add the scope class of the component to its top-level elements even when it has no styles, merged with any `class`, `class:list` or spread attribute, so styles scoped to the same component elsewhere apply to them */
+  scopeRootElements?: boolean;
+  /** How elements are matched by scoped styles. `class` (the default) adds an `astro-XXXX` class, `attribute` adds a `data-astro-cid-XXXX` attribute instead, which can't conflict with how frameworks handle classes. `where` adds the class but matches it with `:where(.astro-XXXX)`, so scoping doesn't increase the specificity of selectors and user overrides keep working. */
+  scopedStyleStrategy?: 'class' | 'attribute' | 'where';
+  /** What to do with scoped selectors which can't match any element of the template, judged by its tags and static `class` and `id` attributes. `keep` (the default) leaves them alone, `warn` reports each one and `remove` strips them from the output. Dynamic classes, components and spreads make every selector of that kind count as used, as does a class or id mentioned by a script. */
+  unusedSelectors?: 'keep' | 'warn' | 'remove';
+  /** How whitespace around scripts, styles and expressions is trimmed. `smart` (the default) trims wherever it can't change the output, `none` keeps everything as authored, and `aggressive` also trims template literal attributes and collapses whitespace between elements. */
+  trimWhitespace?: 'none' | 'smart' | 'aggressive';
+  /** A variable in scope in the frontmatter, like `Astro.locals.nonce`, which every `<script>` and `<style>` the component renders, hoisted or not, gets its `nonce` attribute from, for apps which enforce a strict Content Security Policy. Elements with a `nonce` of their own keep it, and no attribute is rendered when the variable is `null` or `undefined`. */
+  cspNonceVar?: string;
+  /** Warn when the component hydrates more islands than this */
+  islandBudget?: number;
+  /** Warn about inline scripts larger than this many bytes. Hoisted scripts are bundled, so they don't count. */
+  inlineScriptBudget?: number;
+  /** Reject inputs larger than this many bytes */
+  maxInputSize?: number;
+  /** Reject documents with more than this many nodes, as soon as the parser creates one too many */
+  maxNodes?: number;
+  /** Reject templates with expressions nested more than this many levels deep */
+  maxExpressionDepth?: number;
+  /** Reject compiles which take longer than this many milliseconds. The compile is checked for a timeout while it parses and between its phases. */
+  timeout?: number;
+  /** Record how long each phase of the compile took, how many nodes the document had after it and the decisions of the transform passes, like why styles were or weren't scoped, as `trace`, to debug why a component compiles slowly or not as expected */
+  trace?: boolean;
+  /** How the result crosses from WASM to JavaScript. `json` transfers it as a single buffer which is decoded at once, which is much faster than converting every field of the default `object` encoding. The result is the same either way. */
+  resultEncoding?: 'object' | 'json';
+}
+
+export interface AssetReference {
+  url: string;
+  element: string;
+  attribute: string;
+  start: number;
+}
+
+export interface StyleImport {
+  specifier: string;
+  start: number;
+}
+
+export interface DataFrontmatter {
+  lang: 'yaml' | 'toml';
+  /** The data between the fences, as authored */
+  content: string;
+  /** The position of `content` in the source */
+  start: number;
+}
+
+export interface Glob {
+  pattern: string;
+  start: number;
+}
+
+export interface Island {
+  name: string;
+  directive: 'load' | 'idle' | 'visible' | 'media' | 'only';
+  start: number;
+}
+
+export interface RouteMetadata {
+  /** The `route` option, or an empty string if it wasn't given */
+  pattern: string;
+  /** The params of the route pattern */
+  params: string[];
+  /** The params the page reads from `Astro.params`, in the order they're first read. Computed keys and rest elements can't be known and are left out. */
+  readParams: string[];
+  getStaticPaths: boolean;
+  /** The value of `export const prerender`, or null unless it's exported as a boolean literal */
+  prerender: boolean | null;
+}
+
+export interface Message {
+  id: string;
+  element: string;
+  start: number;
+}
+
+export interface UndefinedComponent {
+  name: string;
+  start: number;
+}
+
+/** An identifier which the template refers to. Property names, like `b` in `a.b`, aren't references, and neither are names the template binds itself, like the parameter of `items.map((item) => ...)`. */
+export interface Reference {
+  name: string;
+  kind: 'expression' | 'attribute' | 'component';
+  start: number;
+}
+
+/** What the compiler did with an element, for hovers */
+export interface NodeMetadata {
+  /** The directives of the element as authored, like `client:visible` */
+  directives: string[];
+  /** The client directive of a hydrated island without its namespace, like `visible`, or an empty string if the element isn't hydrated */
+  hydration: string;
+  /** The class or attribute which scoped styles match the element by, like `astro-XXXX` */
+  scope: string;
+  /** The named slot the element is passed to */
+  slot: string;
+}
+
+/** Links a component tag to the frontmatter import which defines it */
+export interface Definition {
+  /** The name of the tag, like `Card` or `Card.Header` */
+  name: string;
+  /** The position of the tag name */
+  start: number;
+  /** The position of the import statement */
+  importStart: number;
+  importEnd: number;
+  specifier: string;
+  /** The name the module exports the component as, like `default` */
+  exportName: string;
+}
+
+export enum DiagnosticSeverity {
+  Error = 1,
+  Warning = 2,
+  Information = 3,
+  Hint = 4,
+}
+
+export interface DiagnosticLocation {
+  file: string;
+  line: number;
+  column: number;
+  length: number;
+  lineText: string;
+}
+
+export interface DiagnosticMessage {
+  severity: DiagnosticSeverity;
+  code: number;
+  /** The stable identifier of `code`, like `AST2005`, which never changes once released, so tools can map it to documentation and quick fixes */
+  id: string;
+  /** The stable name of `code`, like `UnclosedElement` */
+  name: string;
+  text: string;
+  hint?: string;
+  location?: DiagnosticLocation;
+  /** Edits which resolve the problem, which language servers can offer as code actions */
+  fixes?: DiagnosticFix[];
+}
+
+/** Replaces the source at `location` with `text`. A location of length zero inserts `text`. */
+export interface DiagnosticFix {
+  title: string;
+  location?: DiagnosticLocation;
+  text: string;
+}
+
+export interface CompileStats {
+  elements: number;
+  components: number;
+  expressions: number;
+  islands: number;
+  styles: number;
+  scripts: number;
+  /** The size of the generated code in bytes, before any inline source map is added */
+  outputSize: number;
+  /** How long each phase of the compile took, in milliseconds */
+  durations: {
+    parse: number;
+    transform: number;
+    print: number;
+  };
+}
+
+/** The end of a phase of the compile, with its `duration` and `nodes`, or a `decision` made by a `pass` during the phase */
+export interface TraceEvent {
+  phase: 'parse' | 'transform' | 'print';
+  /** Empty for the end of a phase */
+  pass: string;
+  decision: string;
+  /** In milliseconds */
+  duration: number;
+  /** The number of nodes of the document after the phase */
+  nodes: number;
+}
+
+export interface TagReference {
+  /** The position of the tag in the document, which is also its position in the styles or hoisted scripts of the component */
+  index: number;
+  start: number;
+  /** A hash of the tag as authored, which changes whenever the tag is edited */
+  hash: string;
+  /** Whether a style is marked `is:critical`, so build tools can inline it into the head of the page and load the other styles later. Always false for scripts. */
+  critical: boolean;
+}
+
+export interface Preload {
+  /** The import specifier, which is relative to the component, or the URL of a script, as authored */
+  url: string;
+  kind: 'component' | 'script' | 'style';
+  /** `modulepreload` for components and module scripts, or `preload` */
+  rel: 'modulepreload' | 'preload';
+  as: 'script' | 'style';
+  start: number;
+}
+
+export interface InlineSource {
+  kind: 'script' | 'style' | 'event-handler';
+  start: number;
+  /** The name of an event handler attribute, like `onclick` */
+  attribute: string;
+  /** The CSP hash source of the content as the page renders it, like `sha256-...`, or an empty string if it isn't known until runtime, like that of an expression or of `define:vars`, which needs a nonce instead */
+  hash: string;
 }
 
 export interface TransformResult {
   code: string;
   map: string;
+  assets: AssetReference[];
+  /** Stylesheets imported by the frontmatter, like `import './global.css'`, so they can be linked without loading the module graph. Imports with a query, like `?url`, are left out. */
+  styleImports: StyleImport[];
+  /** The patterns of `Astro.glob()` calls in the frontmatter, so bundlers can turn them into glob imports and watch the files they match. Patterns which aren't string literals are left out. */
+  globs: Glob[];
+  /** Hydrated components, in document order */
+  islands: Island[];
+  /** Translatable text, when `extractMessages` is set */
+  messages: Message[];
+  /** Components used in the template which are never imported or declared */
+  undefinedComponents: UndefinedComponent[];
+  /** Every identifier the template refers to, in order, for rename, find-references and unused variable analysis across the frontmatter and the template */
+  references: Reference[];
+  /** The import of every component tag which is imported in the frontmatter, so editors can jump to it without analyzing the component again */
+  definitions: Definition[];
+  /** The metadata of every element which has any, keyed by the position of its start tag */
+  metadata: Record<string, NodeMetadata>;
+  diagnostics: DiagnosticMessage[];
+  stats: CompileStats;
+  /** Every `<style>`, in document order */
+  styles: TagReference[];
+  /** Every hoisted `<script>`, in document order */
+  scripts: TagReference[];
+  /** Hashes of the frontmatter and of the template, as authored, to decide between a full reload and a CSS-only or script-only update after an edit. The template excludes the frontmatter, styles and hoisted scripts. */
+  hashes: {
+    frontmatter: string;
+    template: string;
+  };
+  /** Every inline `<script>` and `<style>` with content, hoisted or not, and every event handler attribute the page renders, in document order, so a build can allow them with the hashes of a Content Security Policy instead of `'unsafe-inline'` */
+  inlineSources: InlineSource[];
+  /** The modules of hydrated components, remote scripts with a static `src` and the stylesheets imported by the frontmatter, in document order and each listed once, so servers can send `Link` preload headers or early hints for a page before rendering it. Specifiers have to be resolved to the URLs the build serves them at. */
+  preloads: Preload[];
+  /** Route metadata, when `isPage` is set */
+  route: RouteMetadata | null;
+  /** The value of `export const prerender` in the frontmatter, or null if it isn't exported as a boolean literal, so hybrid builds can decide between rendering a page on demand and prerendering it without running it */
+  prerender: boolean | null;
+  /** The data the source starts with, when `dataFrontmatter` is set and there is any */
+  dataFrontmatter: DataFrontmatter | null;
+  /** What the compile did, in order, when `trace` is set */
+  trace: TraceEvent[];
+  /** The version of the compiler which produced this result */
+  version: string;
 }
 
 // This function transforms a single JavaScript file. It can be used to minify
@@ -26,6 +328,105 @@ export interface TransformResult {
 // Works in browser: yes
 export declare function transform(input: string, options?: TransformOptions): Promise<TransformResult>;
 
+export interface OutlineOptions {
+  filename?: string;
+  as?: 'document' | 'fragment';
+  contentType?: string;
+}
+
+/** An entry of the outline of a component, for editor outlines and folding ranges */
+export interface DocumentSymbol {
+  /** The tag name, or `---` for the frontmatter */
+  name: string;
+  kind: 'frontmatter' | 'element' | 'component' | 'style' | 'script';
+  /** The range of the source from the start tag to the end tag */
+  start: number;
+  end: number;
+  /** The elements inside of this one. Implicit elements, fragments and expressions aren't symbols, so their elements are children of the closest element around them. */
+  children: DocumentSymbol[];
+}
+
+export interface OutlineResult {
+  symbols: DocumentSymbol[];
+  diagnostics: DiagnosticMessage[];
+}
+
+// This returns the frontmatter and the elements of a component as nested
+// symbols. It only parses the component, so it's much cheaper than "transform".
+//
+// Works in node: yes
+// Works in browser: yes
+export declare function outline(input: string, options?: OutlineOptions): Promise<OutlineResult>;
+
+export interface SemanticTokensResult {
+  /** The token types are tag, component, attribute, namespace (of directives, like `client` in `client:load`), punctuation (the braces of expressions) and frontmatter (its fences). There are no modifiers. */
+  legend: { tokenTypes: string[]; tokenModifiers: string[] };
+  /** Five integers per token in the relative encoding of the Language Server Protocol, with columns in UTF-16 code units */
+  data: number[];
+}
+
+// This returns the semantic tokens of a component for syntax highlighting,
+// which only takes tokenizing it.
+//
+// Works in node: yes
+// Works in browser: yes
+export declare function semanticTokens(input: string): Promise<SemanticTokensResult>;
+
+/** What can be typed at a position of a component */
+export interface CompletionContext {
+  kind: 'frontmatter' | 'tag-name' | 'end-tag-name' | 'attribute-name' | 'attribute-value' | 'expression' | 'text';
+  /** What has been typed from `start` to the position, which a completion replaces: a tag or attribute name, an attribute value or the member expression before the position, like `Astro.props.` */
+  prefix: string;
+  start: number;
+  /** The tag that the position is in, or else the closest open element, which is the one that an end tag closes */
+  tag: string;
+  /** The attribute whose value the position is in */
+  attribute: string;
+  /** The attributes of the tag which come before the position */
+  attributes: string[];
+  /** The elements open at the position, outermost first */
+  parents: string[];
+  /** The names the frontmatter declares or imports, which the template can refer to */
+  scope: string[];
+}
+
+// This returns the context of a position of a component for completion
+// providers. Only the source before the position decides it, so the rest may
+// be incomplete.
+//
+// Works in node: yes
+// Works in browser: yes
+export declare function completion(input: string, offset: number): Promise<CompletionContext>;
+
+export interface DumpASTOptions extends TransformOptions {
+  /** Dump the tree as it is after this phase, `parse` by default. Styles aren't preprocessed before the transform. */
+  phase?: 'parse' | 'transform';
+}
+
+export interface DumpASTResult {
+  /** A line for each node, indented by its depth, with its type, tag or content, locations and attributes, followed by the hoisted styles and scripts of the document */
+  ast: string;
+  diagnostics: DiagnosticMessage[];
+}
+
+// This returns the tree of a component in a readable form, for debugging how a
+// template is parsed or transformed. It isn't meant to be parsed, and its form
+// may change between versions.
+//
+// Works in node: yes
+// Works in browser: yes
+export declare function dumpAST(input: string, options?: DumpASTOptions): Promise<DumpASTResult>;
+
+// This replaces the WASM instance with a fresh one, releasing all of the memory
+// used by earlier compiles. WASM memory never shrinks and the Go runtime keeps
+// a reference to every JavaScript value it has seen, so long-running processes
+// which compile many files should call this periodically. Compiles which are
+// already running finish on the old instance.
+//
+// Works in node: yes
+// Works in browser: yes
+export declare function reset(): Promise<void>;
+
 // This configures the browser-based version of astro. It is necessary to
 // call this first and wait for the returned promise to be resolved before
 // making other API calls when using astro in the browser.
@@ -0,0 +1,52 @@
+/* eslint-disable no-console */
+import { transform, reset } from '@astrojs/compiler';
+
+const BATCH = 500;
+
+function source(i) {
+  return `---
+import Counter from '../components/Counter${i}.jsx';
+const items = [${i}, ${i + 1}, ${i + 2}];
+---
+<html>
+  <head><title>Page {${i}}</title></head>
+  <body>
+    <ul>{items.map((item) => <li class="item">{item}</li>)}</ul>
+    <Counter client:visible count={${i}} />
+    <style>.item { color: red; }</style>
+  </body>
+</html>`;
+}
+
+async function compileBatch(offset) {
+  for (let i = offset; i < offset + BATCH; i++) {
+    await transform(source(i), { sourcefile: `page${i}.astro`, sourcemap: 'both' });
+  }
+  await reset();
+  globalThis.gc?.();
+}
+
+function memory() {
+  const { heapUsed, arrayBuffers } = process.memoryUsage();
+  return heapUsed + arrayBuffers;
+}
+
+async function run() {
+  await compileBatch(0);
+  const before = memory();
+  for (let i = 1; i <= 8; i++) {
+    await compileBatch(i * BATCH);
+  }
+  const after = memory();
+
+  // Without a reset, memory grows with every compile. With one, it only varies
+  // with when the garbage collector last ran.
+  if (after - before > 64 * 1024 * 1024) {
+    throw new Error(`Memory grew from ${before} to ${after} bytes over ${8 * BATCH} compiles`);
+  }
+}
+
+await run().catch((err) => {
+  console.error(err);
+  process.exit(1);
+});
@@ -0,0 +1,34 @@
+/* eslint-disable no-console */
+import { transform } from '@astrojs/compiler';
+
+async function run() {
+  const source = `---
+import Counter from '../components/Counter.jsx';
+---
+
+<h1>Hello {name}</h1>
+<Counter client:load />
+
+<style>
+h1 {
+  color: red;
+}
+</style>
+`;
+  const options = { sourcefile: 'index.astro', sourcemap: 'external' };
+  const object = await transform(source, { ...options, resultEncoding: 'object' });
+  const json = await transform(source, { ...options, resultEncoding: 'json' });
+
+  // Durations differ between compiles
+  delete object.stats.durations;
+  delete json.stats.durations;
+
+  if (JSON.stringify(object) !== JSON.stringify(json)) {
+    throw new Error(`Expected both encodings to produce the same result.\n\nobject: ${JSON.stringify(object)}\n\njson: ${JSON.stringify(json)}`);
+  }
+}
+
+await run().catch((err) => {
+  console.error(err);
+  process.exit(1);
+});
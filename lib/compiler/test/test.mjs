@@ -2,3 +2,5 @@ import './basic.test.mjs';
 import './component-only.test.mjs';
 import './empty-style.test.mjs';
 import './output.test.mjs';
+import './result-encoding.test.mjs';
+import './reset.test.mjs';